@@ -2,13 +2,24 @@ package main
 
 import (
 	database "Students-Final-Assignment/Internal/Database"
+	"Students-Final-Assignment/Internal/LDAP"
+	"Students-Final-Assignment/Internal/Mail"
+	"Students-Final-Assignment/Internal/OIDC"
 	transportHTTP "Students-Final-Assignment/Internal/Services/http"
 	"Students-Final-Assignment/Internal/Student"
+	"Students-Final-Assignment/Internal/Tenant"
 	"Students-Final-Assignment/Internal/User"
+	"context"
+	"errors"
+	"fmt"
+	"os"
 
 	"go.uber.org/zap"
 )
 
+// configDir holds the JSON config files the app reads at startup.
+const configDir = "D:/training/GoLang/Students-Final-Assignment/Internal"
+
 func Run() error {
 	logger, err := zap.NewProduction()
 	if err != nil {
@@ -18,20 +29,166 @@ func Run() error {
 
 	logger.Info("Setting Up Our APP")
 
-	var dbErr error
-	db, dbErr := database.NewDatabase("D:/training/GoLang/Students-Final-Assignment/Internal/Database/config.json")
-	if dbErr != nil {
-		logger.Error("failed to setup connection to the database", zap.Error(dbErr))
-		return dbErr
+	dbConfig, err := database.LoadConfig(configDir + "/Database/config.json")
+	if err != nil {
+		logger.Error("failed to load the database config", zap.Error(err))
+		return err
+	}
+	var st stores
+	if dbConfig.InMemory {
+		logger.Warn("running on in-memory stores, nothing is kept across restarts")
+		st = memoryStores()
+	} else {
+		db, dbErr := database.Connect(dbConfig)
+		if dbErr != nil {
+			logger.Error("failed to setup connection to the database", zap.Error(dbErr))
+			return dbErr
+		}
+		if dbConfig.MigrateOnStartup {
+			migrator, err := database.NewMigrator(db.GetClient())
+			if err != nil {
+				logger.Error("failed to load the migrations", zap.Error(err))
+				return err
+			}
+			applied, err := migrator.Up(context.Background())
+			if err != nil {
+				logger.Error("failed to migrate the database", zap.Error(err))
+				return err
+			}
+			logger.Info("database migrated", zap.Int("applied", len(applied)))
+		}
+		st = sqlStores(db)
+	}
+
+	keys, err := User.LoadKeySet(configDir + "/User/keys.json")
+	if errors.Is(err, User.ErrMissingSecret) && dbConfig.InMemory {
+		// Nothing outlives the process in memory, so neither need tokens.
+		logger.Warn("no JWT secret in keys.json, signing with a random key for this run")
+		keys, err = User.EphemeralKeySet()
+	}
+	if err != nil {
+		logger.Error("failed to load the JWT signing keys", zap.Error(err))
+		return err
+	}
+
+	mailConfig, err := Mail.LoadConfig(configDir + "/Mail/config.json")
+	if err != nil {
+		logger.Error("failed to load the mail config", zap.Error(err))
+		return err
+	}
+	mailer, err := Mail.New(mailConfig)
+	if err != nil {
+		logger.Error("failed to set up the mailer", zap.Error(err))
+		return err
+	}
+
+	userConfig, err := User.LoadConfig(configDir + "/User/config.json")
+	if err != nil {
+		logger.Error("failed to load the user config", zap.Error(err))
+		return err
+	}
+
+	studentConfig, err := Student.LoadConfig(configDir + "/Student/config.json")
+	if err != nil {
+		logger.Error("failed to load the student config", zap.Error(err))
+		return err
 	}
 
-	studentStore := database.NewStudentStore(db.GetClient())
-	userStore := database.NewUserStore(db.GetClient())
+	studentService := Student.NewService(st.students)
+	studentService.TrashRetention = studentConfig.TrashRetention()
+	userService := User.NewService(st.users, st.sessions, keys)
+	userService.Resets = st.resets
+	userService.Mailer = mailer
+	userService.BaseURL = mailConfig.BaseURL
+	userService.RequireVerifiedEmail = userConfig.RequireVerifiedEmail
+	userService.Attempts = st.attempts
+	userService.MFA = st.mfa
+	userService.APIKeys = st.apiKeys
+	if userConfig.MFAIssuer != "" {
+		userService.MFAIssuer = userConfig.MFAIssuer
+	}
+	userService.PasswordHistory = st.passwordHistory
+	if policy := userConfig.PasswordPolicy; policy != nil {
+		userService.PasswordPolicy = *policy
+		if policy.BreachedListPath != "" {
+			if userService.Breached, err = User.LoadBreachedPasswords(policy.BreachedListPath); err != nil {
+				logger.Error("failed to load the breached password list", zap.Error(err))
+				return err
+			}
+		}
+	}
+	if userConfig.Lockout != nil {
+		userService.Lockout = *userConfig.Lockout
+	}
+	if userConfig.PasswordResetLimit != nil {
+		userService.ResetLimit = *userConfig.PasswordResetLimit
+	}
+	userService.Identities = st.identities
+	if dbConfig.InMemory {
+		if userConfig.BootstrapAdmin == nil {
+			logger.Warn("no BootstrapAdmin in the user config, nobody can administer the in-memory app")
+		} else {
+			ctx := Tenant.ContextWithTenant(context.Background(), Tenant.DefaultTenantID)
+			created, err := userService.EnsureBootstrapAdmin(ctx, *userConfig.BootstrapAdmin)
+			if err != nil {
+				logger.Error("failed to create the bootstrap admin", zap.Error(err))
+				return err
+			}
+			if created {
+				logger.Info("bootstrap admin created", zap.String("username", userConfig.BootstrapAdmin.Username))
+			}
+		}
+	}
+
+	ldapConfig, err := LDAP.LoadConfig(configDir + "/LDAP/config.json")
+	if err != nil {
+		logger.Error("failed to load the LDAP config", zap.Error(err))
+		return err
+	}
+	if ldapConfig.Enabled {
+		directory, err := LDAP.New(ldapConfig)
+		if err != nil {
+			logger.Error("failed to set up the LDAP authenticator", zap.Error(err))
+			return err
+		}
+		userService.Authenticators = append(userService.Authenticators, directory)
+	}
+	tenantConfig, err := Tenant.LoadConfig(configDir + "/Tenant/config.json")
+	if err != nil {
+		logger.Error("failed to load the tenant config", zap.Error(err))
+		return err
+	}
 
-	studentService := Student.NewService(studentStore)
-	userService := User.NewService(userStore)
 	handler := transportHTTP.NewHandler(studentService, userService)
+	handler.Tenants = Tenant.NewService(st.tenants, tenantConfig)
+
+	oidcConfig, err := OIDC.LoadConfig(configDir + "/OIDC/config.json")
+	if err != nil {
+		logger.Error("failed to load the OIDC config", zap.Error(err))
+		return err
+	}
+	if oidcConfig.Enabled {
+		if handler.OIDC, err = OIDC.NewProvider(context.Background(), oidcConfig); err != nil {
+			logger.Error("failed to set up the OIDC provider", zap.Error(err))
+			return err
+		}
+		handler.OIDCPolicy = User.ExternalLoginPolicy{
+			AutoProvision: oidcConfig.AutoProvision,
+			GroupRoles:    make(map[string]User.Role, len(oidcConfig.GroupRoles)),
+			DefaultRole:   User.Role(oidcConfig.DefaultRole),
+			LinkByEmail:   oidcConfig.LinkByEmail,
+		}
+		for group, role := range oidcConfig.GroupRoles {
+			handler.OIDCPolicy.GroupRoles[group] = User.Role(role)
+		}
+		if err := handler.OIDCPolicy.Validate(); err != nil {
+			logger.Error("invalid OIDC role mapping", zap.Error(err))
+			return err
+		}
+	}
 
+	// Mails queued by requests answered before the shutdown still go out.
+	defer userService.Wait()
 	if serveErr := handler.Serve(); serveErr != nil {
 		logger.Error("failed to gracefully serve our application", zap.Error(serveErr))
 		return serveErr
@@ -41,6 +198,13 @@ func Run() error {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 	if err := Run(); err != nil {
 		logger, _ := zap.NewProduction()
 		defer logger.Sync()
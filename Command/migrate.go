@@ -0,0 +1,108 @@
+package main
+
+import (
+	database "Students-Final-Assignment/Internal/Database"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+// migrationsDir is where "migrate create" writes new scripts. They are
+// embedded into the binary on the next build.
+const migrationsDir = configDir + "/Database/migrations"
+
+const migrateUsage = `usage: migrate <command>
+
+  up             apply every pending migration
+  baseline       record 0001_baseline_schema as applied on a database
+                 that already has those tables, then run "up" for the rest
+  down [n]       roll back the latest n migrations (default 1)
+  status         list migrations and whether they are applied
+  create <name>  add an empty up and down script to ` + migrationsDir
+
+// runMigrate handles the "migrate" subcommand.
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+	if args[0] == "create" {
+		if len(args) != 2 {
+			return errors.New(migrateUsage)
+		}
+		up, down, err := database.CreateMigration(migrationsDir, args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("created %s\ncreated %s\n", up, down)
+		return nil
+	}
+
+	db, err := database.NewDatabase(configDir + "/Database/config.json")
+	if err != nil {
+		return err
+	}
+	defer db.Client.Close()
+	migrator, err := database.NewMigrator(db.GetClient())
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("database is up to date")
+		}
+		return err
+	case "baseline":
+		m, err := migrator.Baseline(ctx)
+		if err == nil {
+			fmt.Printf("recorded %04d_%s as applied\n", m.Version, m.Name)
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return errors.New(migrateUsage)
+			}
+		}
+		rolledBack, err := migrator.Down(ctx, steps)
+		for _, m := range rolledBack {
+			fmt.Printf("rolled back %04d_%s\n", m.Version, m.Name)
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT\tSTATE")
+		for _, st := range statuses {
+			applied, state := "-", "pending"
+			if st.AppliedAt != nil {
+				applied, state = st.AppliedAt.Format("2006-01-02 15:04:05"), "applied"
+			}
+			switch {
+			case st.Modified:
+				state = "modified"
+			case st.Baselined:
+				state = "baselined"
+			case st.Up == "" && st.AppliedAt != nil:
+				state = "unknown"
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", st.Version, st.Name, applied, state)
+		}
+		return w.Flush()
+	default:
+		return errors.New(migrateUsage)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+
+	database "Students-Final-Assignment/Internal/Database"
+	"Students-Final-Assignment/Internal/Student"
+	"Students-Final-Assignment/Internal/Tenant"
+	"Students-Final-Assignment/Internal/User"
+)
+
+// stores are the backends the services run on.
+type stores struct {
+	students        Student.StudentStore
+	users           User.UserStore
+	sessions        User.SessionStore
+	resets          User.PasswordResetStore
+	attempts        User.LoginAttemptStore
+	mfa             User.MFAStore
+	apiKeys         User.APIKeyStore
+	passwordHistory User.PasswordHistoryStore
+	identities      User.IdentityStore
+	tenants         Tenant.TenantStore
+}
+
+func sqlStores(db *database.Database) stores {
+	client := db.GetClient()
+	return stores{
+		students:        database.NewStudentStore(client),
+		users:           database.NewUserStore(client),
+		sessions:        database.NewSessionStore(client),
+		resets:          database.NewPasswordResetStore(client),
+		attempts:        database.NewLoginAttemptStore(client),
+		mfa:             database.NewMFAStore(client),
+		apiKeys:         database.NewAPIKeyStore(client),
+		passwordHistory: database.NewPasswordHistoryStore(client),
+		identities:      database.NewIdentityStore(client),
+		tenants:         database.NewTenantStore(client),
+	}
+}
+
+func memoryStores() stores {
+	students := Student.NewMemoryStudentStore()
+	users := User.NewMemoryUserStore()
+	// Like the SQL store, a tenant can only go once it has no users and no
+	// students, trashed ones included.
+	inUse := func(ctx context.Context, id int64) (bool, error) {
+		ctx = Tenant.ContextWithTenant(ctx, id)
+		userPage, err := users.ListUsers(ctx, User.UserListOptions{Limit: 1})
+		if err != nil || len(userPage.Users) > 0 {
+			return true, err
+		}
+		for _, list := range []func(context.Context, Student.ListOptions) (Student.StudentPage, error){students.ListStudents, students.ListTrash} {
+			page, err := list(ctx, Student.ListOptions{Limit: 1})
+			if err != nil || len(page.Students) > 0 {
+				return true, err
+			}
+		}
+		return false, nil
+	}
+	return stores{
+		students:        students,
+		users:           users,
+		sessions:        User.NewMemorySessionStore(),
+		resets:          User.NewMemoryPasswordResetStore(),
+		attempts:        User.NewMemoryLoginAttemptStore(),
+		mfa:             User.NewMemoryMFAStore(),
+		apiKeys:         User.NewMemoryAPIKeyStore(),
+		passwordHistory: User.NewMemoryPasswordHistoryStore(),
+		identities:      User.NewMemoryIdentityStore(),
+		tenants:         Tenant.NewMemoryTenantStore(inUse),
+	}
+}
@@ -17,30 +17,47 @@ type Config struct {
 	DBUsername string `json:"DBUsername"`
 	DBPassword string `json:"DBPassword"`
 	DBName     string `json:"DBName"`
+	// MigrateOnStartup applies pending migrations before the server starts.
+	MigrateOnStartup bool `json:"MigrateOnStartup"`
+	// InMemory runs the app on in-process stores instead of MySQL. Nothing
+	// is kept across restarts and the other settings are ignored.
+	InMemory bool `json:"InMemory"`
 }
 
 type Database struct {
 	Client *sqlx.DB
+	Config Config
 }
 
 func (d *Database) GetClient() *sqlx.DB {
 	return d.Client
 }
 
-func NewDatabase(configPath string) (*Database, error) {
-	log.Info("Setting up new database connection")
-
+func LoadConfig(configPath string) (Config, error) {
 	file, err := os.Open(configPath)
 	if err != nil {
-		return nil, fmt.Errorf("could not open config file: %w", err)
+		return Config{}, fmt.Errorf("could not open config file: %w", err)
 	}
 	defer file.Close()
 
 	var config Config
 	decoder := json.NewDecoder(file)
 	if err := decoder.Decode(&config); err != nil {
-		return nil, fmt.Errorf("could not decode config file: %w", err)
+		return Config{}, fmt.Errorf("could not decode config file: %w", err)
 	}
+	return config, nil
+}
+
+func NewDatabase(configPath string) (*Database, error) {
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	return Connect(config)
+}
+
+func Connect(config Config) (*Database, error) {
+	log.Info("Setting up new database connection")
 
 	connectionString := fmt.Sprintf(
 		"%s:%s@tcp(%s:%s)/%s?parseTime=true",
@@ -58,6 +75,7 @@ func NewDatabase(configPath string) (*Database, error) {
 
 	return &Database{
 		Client: db,
+		Config: config,
 	}, nil
 }
 
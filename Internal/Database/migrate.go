@@ -0,0 +1,524 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	log "github.com/sirupsen/logrus"
+)
+
+// migrationFiles holds the schema as numbered pairs of
+// <version>_<name>.up.sql and <version>_<name>.down.sql files.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLock is the MySQL named lock held while migrating, so instances
+// starting together apply each migration once.
+const migrationLock = "schema_migrations"
+
+// DefaultMigrationLockTimeout is how long Up and Down wait for another
+// instance to finish migrating.
+const DefaultMigrationLockTimeout = time.Minute
+
+var (
+	ErrInvalidMigration = errors.New("invalid migration")
+	// ErrChecksumMismatch means an applied migration was edited afterwards.
+	// Changes to the schema go into a new migration instead.
+	ErrChecksumMismatch = errors.New("applied migration has been modified")
+	// ErrUnknownMigration means the database has a migration this build
+	// does not know, e.g. after rolling back to an older release.
+	ErrUnknownMigration = errors.New("applied migration is unknown to this build")
+	ErrMigrationLocked  = errors.New("timed out waiting for the migration lock")
+	// ErrUnrecordedSchema means the database already has tables but no
+	// recorded migrations, such as one set up by hand with the schema of
+	// migrations/0001_baseline_schema.up.sql. Baseline adopts it.
+	ErrUnrecordedSchema = errors.New(`the database has tables but no recorded migrations; adopt them with "migrate baseline"`)
+	// ErrNotBaseline means Baseline found a schema other than the first
+	// migration's.
+	ErrNotBaseline = errors.New("the database does not have the baseline schema")
+	// ErrBaselined means a rollback reached a migration that was adopted
+	// rather than run, whose tables it did not create.
+	ErrBaselined = errors.New("migration was adopted by baseline and cannot be rolled back")
+)
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+	// Checksum is the SHA-256 of the up script, recorded when it is applied.
+	// Line endings are normalised first, so checkouts on Windows agree.
+	Checksum string
+}
+
+// MigrationStatus is a known migration and, if applied, when and with which
+// checksum. Baselined ones were adopted from an existing schema.
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+	Modified  bool
+	Baselined bool
+}
+
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// LoadMigrations reads the migrations in fsys, ordered by version. Every
+// version needs both an up and a down script.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("could not read migrations: %w", err)
+	}
+	byVersion := map[int64]*Migration{}
+	hasUp, hasDown := map[int64]bool{}, map[int64]bool{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		m := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if m == nil {
+			return nil, fmt.Errorf("%w: file name %q", ErrInvalidMigration, entry.Name())
+		}
+		version, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("%w: version of %q", ErrInvalidMigration, entry.Name())
+		}
+		body, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("could not read migration %s: %w", entry.Name(), err)
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != m[2] {
+			return nil, fmt.Errorf("%w: version %d is named both %q and %q", ErrInvalidMigration, version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(body)
+			sum := sha256.Sum256(bytes.ReplaceAll(body, []byte("\r\n"), []byte("\n")))
+			mig.Checksum = hex.EncodeToString(sum[:])
+			hasUp[version] = true
+		} else {
+			mig.Down = string(body)
+			hasDown[version] = true
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if !hasUp[mig.Version] || !hasDown[mig.Version] {
+			return nil, fmt.Errorf("%w: version %d needs both an up and a down script", ErrInvalidMigration, mig.Version)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+func (m Migration) fileName(direction string) string {
+	return fmt.Sprintf("%04d_%s.%s.sql", m.Version, m.Name, direction)
+}
+
+// EmbeddedMigrations returns the migrations built into the binary.
+func EmbeddedMigrations() ([]Migration, error) {
+	sub, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return LoadMigrations(sub)
+}
+
+// splitStatements cuts a script into the statements the driver runs one at
+// a time. A statement ends with a semicolon at the end of a line; lines
+// that are only a comment are dropped.
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
+
+// createTablePattern finds the tables a script creates.
+var createTablePattern = regexp.MustCompile("(?i)CREATE\\s+TABLE\\s+(?:IF\\s+NOT\\s+EXISTS\\s+)?`?(\\w+)`?")
+
+// createdTables lists the tables an up script creates, in order.
+func createdTables(script string) []string {
+	var tables []string
+	for _, m := range createTablePattern.FindAllStringSubmatch(script, -1) {
+		tables = append(tables, m[1])
+	}
+	return tables
+}
+
+type appliedMigration struct {
+	Version   int64     `db:"version"`
+	Name      string    `db:"name"`
+	Checksum  string    `db:"checksum"`
+	AppliedAt time.Time `db:"applied_at"`
+	Baselined bool      `db:"baselined"`
+}
+
+// pendingMigrations returns the migrations not applied yet, in order.
+func pendingMigrations(migrations []Migration, applied map[int64]appliedMigration) []Migration {
+	var pending []Migration
+	for _, mig := range migrations {
+		if _, ok := applied[mig.Version]; !ok {
+			pending = append(pending, mig)
+		}
+	}
+	return pending
+}
+
+// rollbackMigrations returns the latest steps applied migrations, newest
+// first. It stops with ErrBaselined rather than drop tables that a
+// baselined migration did not create.
+func rollbackMigrations(migrations []Migration, applied map[int64]appliedMigration, steps int) ([]Migration, error) {
+	var rollback []Migration
+	for i := len(migrations) - 1; i >= 0 && len(rollback) < steps; i-- {
+		mig := migrations[i]
+		row, ok := applied[mig.Version]
+		if !ok {
+			continue
+		}
+		if row.Baselined {
+			return nil, fmt.Errorf("%w: %d_%s", ErrBaselined, mig.Version, mig.Name)
+		}
+		rollback = append(rollback, mig)
+	}
+	return rollback, nil
+}
+
+// Migrator applies and rolls back migrations, recording them in the
+// schema_migrations table. MySQL commits DDL as it goes, so a failing
+// script can leave its earlier statements applied; it is not recorded and
+// has to be fixed by hand before it is run again.
+type Migrator struct {
+	Client      *sqlx.DB
+	Migrations  []Migration
+	LockTimeout time.Duration
+}
+
+// NewMigrator uses the migrations built into the binary.
+func NewMigrator(db *sqlx.DB) (*Migrator, error) {
+	migrations, err := EmbeddedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{Client: db, Migrations: migrations, LockTimeout: DefaultMigrationLockTimeout}, nil
+}
+
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	_, err := m.Client.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS `schema_migrations` ("+
+		"`version` bigint NOT NULL, "+
+		"`name` varchar(255) not null, "+
+		"`checksum` char(64) not null, "+
+		"`applied_at` datetime not null, "+
+		"`baselined` tinyint(1) not null default 0, "+
+		"PRIMARY KEY (`version`))")
+	if err != nil {
+		return fmt.Errorf("could not create schema_migrations: %w", err)
+	}
+	return nil
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int64]appliedMigration, error) {
+	var rows []appliedMigration
+	if err := m.Client.SelectContext(ctx, &rows, "SELECT version, name, checksum, applied_at, baselined FROM schema_migrations"); err != nil {
+		return nil, fmt.Errorf("an error occurred fetching applied migrations: %w", err)
+	}
+	applied := make(map[int64]appliedMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// verify fails if an applied migration was modified or is not known.
+func (m *Migrator) verify(applied map[int64]appliedMigration) error {
+	known := make(map[int64]Migration, len(m.Migrations))
+	for _, mig := range m.Migrations {
+		known[mig.Version] = mig
+	}
+	versions := make([]int64, 0, len(applied))
+	for v := range applied {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+	for _, v := range versions {
+		mig, ok := known[v]
+		if !ok {
+			return fmt.Errorf("%w: %d_%s", ErrUnknownMigration, v, applied[v].Name)
+		}
+		if mig.Checksum != applied[v].Checksum {
+			return fmt.Errorf("%w: %s", ErrChecksumMismatch, mig.fileName("up"))
+		}
+	}
+	return nil
+}
+
+// withLock runs fn while holding the migration lock on a connection of its
+// own; MySQL named locks belong to the session that took them.
+func (m *Migrator) withLock(ctx context.Context, fn func() error) error {
+	conn, err := m.Client.Connx(ctx)
+	if err != nil {
+		return fmt.Errorf("could not get a connection for the migration lock: %w", err)
+	}
+	defer conn.Close()
+
+	var got sql.NullInt64
+	timeout := int(m.LockTimeout.Seconds())
+	if err := conn.GetContext(ctx, &got, "SELECT GET_LOCK(?, ?)", migrationLock, timeout); err != nil {
+		return fmt.Errorf("could not take the migration lock: %w", err)
+	}
+	if got.Int64 != 1 {
+		return ErrMigrationLocked
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", migrationLock); err != nil {
+			log.Errorf("could not release the migration lock: %s", err.Error())
+		}
+	}()
+	return fn()
+}
+
+func (m *Migrator) run(ctx context.Context, mig Migration, script string) error {
+	for i, stmt := range splitStatements(script) {
+		if _, err := m.Client.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("migration %d_%s failed at statement %d: %w", mig.Version, mig.Name, i+1, err)
+		}
+	}
+	return nil
+}
+
+// Up applies every pending migration in order and returns the ones it
+// applied. It refuses to run while an applied migration was modified or is
+// unknown.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(ctx, func() error {
+		if err := m.ensureTable(ctx); err != nil {
+			return err
+		}
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+		if err := m.verify(applied); err != nil {
+			return err
+		}
+		if len(applied) == 0 && len(m.Migrations) > 0 {
+			existing, err := m.existingTables(ctx, createdTables(m.Migrations[0].Up))
+			if err != nil {
+				return err
+			}
+			if len(existing) > 0 {
+				return ErrUnrecordedSchema
+			}
+		}
+		for _, mig := range pendingMigrations(m.Migrations, applied) {
+			log.Infof("applying migration %d_%s", mig.Version, mig.Name)
+			if err := m.run(ctx, mig, mig.Up); err != nil {
+				return err
+			}
+			_, err := m.Client.ExecContext(
+				ctx,
+				"INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)",
+				mig.Version, mig.Name, mig.Checksum, time.Now(),
+			)
+			if err != nil {
+				return fmt.Errorf("could not record migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down rolls back the latest steps applied migrations, newest first, and
+// returns the ones it rolled back. A baselined migration is never rolled
+// back.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(ctx, func() error {
+		if err := m.ensureTable(ctx); err != nil {
+			return err
+		}
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+		if err := m.verify(applied); err != nil {
+			return err
+		}
+		rollback, err := rollbackMigrations(m.Migrations, applied, steps)
+		if err != nil {
+			return err
+		}
+		for _, mig := range rollback {
+			log.Infof("rolling back migration %d_%s", mig.Version, mig.Name)
+			if err := m.run(ctx, mig, mig.Down); err != nil {
+				return err
+			}
+			if _, err := m.Client.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = ?", mig.Version); err != nil {
+				return fmt.Errorf("could not record rollback of %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// existingTables returns which of tables are in the current database.
+func (m *Migrator) existingTables(ctx context.Context, tables []string) ([]string, error) {
+	if len(tables) == 0 {
+		return nil, nil
+	}
+	query, args, err := sqlx.In("SELECT table_name FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name IN (?)", tables)
+	if err != nil {
+		return nil, err
+	}
+	var existing []string
+	if err := m.Client.SelectContext(ctx, &existing, m.Client.Rebind(query), args...); err != nil {
+		return nil, fmt.Errorf("could not list tables: %w", err)
+	}
+	return existing, nil
+}
+
+// Baseline adopts a database whose tables were created by hand with the
+// schema of migrations/0001_baseline_schema.up.sql: that migration is
+// recorded as applied without running it, and Up then applies the rest.
+// It never rolls back.
+func (m *Migrator) Baseline(ctx context.Context) (Migration, error) {
+	if len(m.Migrations) == 0 {
+		return Migration{}, fmt.Errorf("%w: there are no migrations", ErrInvalidMigration)
+	}
+	baseline := m.Migrations[0]
+	err := m.withLock(ctx, func() error {
+		if err := m.ensureTable(ctx); err != nil {
+			return err
+		}
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+		if len(applied) > 0 {
+			return fmt.Errorf("%w: migrations are already recorded", ErrNotBaseline)
+		}
+		tables := createdTables(baseline.Up)
+		existing, err := m.existingTables(ctx, tables)
+		if err != nil {
+			return err
+		}
+		if len(existing) != len(tables) {
+			return fmt.Errorf("%w: expected tables %s, found %s", ErrNotBaseline, strings.Join(tables, ", "), strings.Join(existing, ", "))
+		}
+		log.Infof("adopting the existing schema as migration %d_%s", baseline.Version, baseline.Name)
+		_, err = m.Client.ExecContext(
+			ctx,
+			"INSERT INTO schema_migrations (version, name, checksum, applied_at, baselined) VALUES (?, ?, ?, ?, 1)",
+			baseline.Version, baseline.Name, baseline.Checksum, time.Now(),
+		)
+		if err != nil {
+			return fmt.Errorf("could not record the baseline: %w", err)
+		}
+		return nil
+	})
+	return baseline, err
+}
+
+// Status lists every known migration, plus any applied one this build does
+// not know about, in version order.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]MigrationStatus, 0, len(m.Migrations))
+	for _, mig := range m.Migrations {
+		st := MigrationStatus{Migration: mig}
+		if row, ok := applied[mig.Version]; ok {
+			at := row.AppliedAt
+			st.AppliedAt = &at
+			st.Modified = row.Checksum != mig.Checksum
+			st.Baselined = row.Baselined
+			delete(applied, mig.Version)
+		}
+		statuses = append(statuses, st)
+	}
+	for _, row := range applied {
+		at := row.AppliedAt
+		statuses = append(statuses, MigrationStatus{
+			Migration: Migration{Version: row.Version, Name: row.Name, Checksum: row.Checksum},
+			AppliedAt: &at,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+var migrationNameCleaner = regexp.MustCompile(`[^a-z0-9]+`)
+
+// CreateMigration writes an empty up and down script to dir, numbered after
+// the highest version already there, and returns their paths.
+func CreateMigration(dir, name string) (string, string, error) {
+	name = strings.Trim(migrationNameCleaner.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return "", "", fmt.Errorf("%w: the name needs letters or digits", ErrInvalidMigration)
+	}
+	existing, err := LoadMigrations(os.DirFS(dir))
+	if err != nil {
+		return "", "", err
+	}
+	mig := Migration{Version: 1, Name: name}
+	if len(existing) > 0 {
+		mig.Version = existing[len(existing)-1].Version + 1
+	}
+
+	up := filepath.Join(dir, mig.fileName("up"))
+	down := filepath.Join(dir, mig.fileName("down"))
+	if err := os.WriteFile(up, []byte("-- "+name+"\n"), 0o644); err != nil {
+		return "", "", fmt.Errorf("could not create migration: %w", err)
+	}
+	if err := os.WriteFile(down, []byte("-- undo "+name+"\n"), 0o644); err != nil {
+		return "", "", fmt.Errorf("could not create migration: %w", err)
+	}
+	return up, down, nil
+}
@@ -0,0 +1,384 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// schemaTable is a table as far as the tests below track it: its columns in
+// order and the names of its keys.
+type schemaTable struct {
+	Columns []string
+	Keys    []string
+}
+
+type schema map[string]schemaTable
+
+func (s schema) clone() schema {
+	c := make(schema, len(s))
+	for name, t := range s {
+		c[name] = schemaTable{Columns: append([]string(nil), t.Columns...), Keys: append([]string(nil), t.Keys...)}
+	}
+	return c
+}
+
+var (
+	identPattern  = regexp.MustCompile("^`(\\w+)`")
+	keyPattern    = regexp.MustCompile("^(?:UNIQUE )?KEY `(\\w+)`")
+	afterPattern  = regexp.MustCompile("AFTER `(\\w+)`$")
+	alterPattern  = regexp.MustCompile("(?s)^ALTER TABLE `(\\w+)`\\s+(.*)$")
+	dropTablePatt = regexp.MustCompile("^DROP TABLE `(\\w+)`$")
+)
+
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
+
+func remove(list []string, s string) ([]string, error) {
+	i := indexOf(list, s)
+	if i < 0 {
+		return nil, fmt.Errorf("%s does not exist", s)
+	}
+	return append(list[:i:i], list[i+1:]...), nil
+}
+
+// apply runs the DDL of a script against s the way MySQL would, failing on
+// tables, columns and keys that are missing or already there. Data
+// statements are skipped.
+func (s schema) apply(script string) error {
+	for _, stmt := range splitStatements(script) {
+		stmt = strings.TrimSpace(stmt)
+		switch {
+		case strings.HasPrefix(stmt, "CREATE TABLE"):
+			name := createdTables(stmt)[0]
+			if _, ok := s[name]; ok {
+				return fmt.Errorf("table %s already exists", name)
+			}
+			var t schemaTable
+			body := stmt[strings.Index(stmt, "(")+1 : strings.LastIndex(stmt, ")")]
+			for _, line := range strings.Split(body, "\n") {
+				line = strings.TrimSuffix(strings.TrimSpace(line), ",")
+				switch {
+				case line == "":
+				case strings.HasPrefix(line, "PRIMARY KEY"):
+					t.Keys = append(t.Keys, "PRIMARY")
+				case keyPattern.MatchString(line):
+					t.Keys = append(t.Keys, keyPattern.FindStringSubmatch(line)[1])
+				case identPattern.MatchString(line):
+					t.Columns = append(t.Columns, identPattern.FindStringSubmatch(line)[1])
+				default:
+					return fmt.Errorf("unexpected line in %s: %q", name, line)
+				}
+			}
+			s[name] = t
+		case strings.HasPrefix(stmt, "ALTER TABLE"):
+			m := alterPattern.FindStringSubmatch(stmt)
+			t, ok := s[m[1]]
+			if !ok {
+				return fmt.Errorf("table %s does not exist", m[1])
+			}
+			for _, clause := range strings.Split(m[2], "\n") {
+				if err := t.alter(strings.TrimSuffix(strings.TrimSpace(clause), ",")); err != nil {
+					return fmt.Errorf("%s: %w", m[1], err)
+				}
+			}
+			s[m[1]] = t
+		case dropTablePatt.MatchString(stmt):
+			name := dropTablePatt.FindStringSubmatch(stmt)[1]
+			if _, ok := s[name]; !ok {
+				return fmt.Errorf("table %s does not exist", name)
+			}
+			delete(s, name)
+		case strings.HasPrefix(stmt, "INSERT"), strings.HasPrefix(stmt, "UPDATE"):
+		default:
+			return fmt.Errorf("unexpected statement %q", stmt)
+		}
+	}
+	return nil
+}
+
+func (t *schemaTable) alter(clause string) error {
+	var err error
+	switch {
+	case strings.HasPrefix(clause, "ADD COLUMN "):
+		column := identPattern.FindStringSubmatch(strings.TrimPrefix(clause, "ADD COLUMN "))[1]
+		if indexOf(t.Columns, column) >= 0 {
+			return fmt.Errorf("column %s already exists", column)
+		}
+		at := len(t.Columns)
+		if m := afterPattern.FindStringSubmatch(clause); m != nil {
+			if at = indexOf(t.Columns, m[1]); at < 0 {
+				return fmt.Errorf("column %s does not exist", m[1])
+			}
+			at++
+		}
+		t.Columns = append(t.Columns[:at:at], append([]string{column}, t.Columns[at:]...)...)
+	case strings.HasPrefix(clause, "ADD "):
+		key := keyPattern.FindStringSubmatch(strings.TrimPrefix(clause, "ADD "))
+		if key == nil {
+			return fmt.Errorf("unexpected clause %q", clause)
+		}
+		if indexOf(t.Keys, key[1]) >= 0 {
+			return fmt.Errorf("key %s already exists", key[1])
+		}
+		t.Keys = append(t.Keys, key[1])
+	case strings.HasPrefix(clause, "DROP COLUMN "):
+		t.Columns, err = remove(t.Columns, identPattern.FindStringSubmatch(strings.TrimPrefix(clause, "DROP COLUMN "))[1])
+	case strings.HasPrefix(clause, "DROP KEY "):
+		t.Keys, err = remove(t.Keys, identPattern.FindStringSubmatch(strings.TrimPrefix(clause, "DROP KEY "))[1])
+	default:
+		return fmt.Errorf("unexpected clause %q", clause)
+	}
+	return err
+}
+
+// wantSchema is what the stores expect once every migration has run.
+var wantSchema = schema{
+	"students": {
+		Columns: []string{"id", "tenant_id", "fname", "lname", "date_of_birth", "email", "address", "gender", "created_by", "created_on", "updated_by", "updated_on", "version", "deleted_at", "deleted_by"},
+		Keys:    []string{"PRIMARY", "idx_students_deleted_at", "idx_students_tenant"},
+	},
+	"users": {
+		Columns: []string{"uid", "tenant_id", "username", "password", "email", "jwt_token", "role", "created_on", "updated_on", "email_verified_at", "disabled_at"},
+		Keys:    []string{"PRIMARY", "uq_users_tenant_username"},
+	},
+	"tenants": {
+		Columns: []string{"id", "slug", "name", "created_on"},
+		Keys:    []string{"PRIMARY", "uq_tenants_slug"},
+	},
+	"student_history": {
+		Columns: []string{"id", "tenant_id", "student_id", "version", "action", "changed_by", "changed_at", "snapshot"},
+		Keys:    []string{"PRIMARY", "uq_student_history_version", "idx_student_history_changed_at"},
+	},
+	"user_sessions": {
+		Columns: []string{"id", "uid", "tenant_id", "access_jti", "created_on", "revoked_at"},
+		Keys:    []string{"PRIMARY", "idx_user_sessions_uid"},
+	},
+	"refresh_tokens": {
+		Columns: []string{"token_hash", "session_id", "expires_at", "used_at"},
+		Keys:    []string{"PRIMARY", "idx_refresh_tokens_session"},
+	},
+	"login_attempts": {
+		Columns: []string{"attempt_key", "failures", "last_failed_at", "locked_until"},
+		Keys:    []string{"PRIMARY", "idx_login_attempts_locked_until"},
+	},
+	"password_reset_tokens": {
+		Columns: []string{"token_hash", "uid", "tenant_id", "created_on", "expires_at", "used_at"},
+		Keys:    []string{"PRIMARY", "idx_password_reset_tokens_uid"},
+	},
+	"password_history": {
+		Columns: []string{"id", "uid", "password_hash", "created_on"},
+		Keys:    []string{"PRIMARY", "idx_password_history_uid"},
+	},
+	"user_totp": {
+		Columns: []string{"uid", "secret", "confirmed_at", "last_step"},
+		Keys:    []string{"PRIMARY"},
+	},
+	"user_recovery_codes": {
+		Columns: []string{"uid", "code_hash", "used_at"},
+		Keys:    []string{"PRIMARY"},
+	},
+	"api_keys": {
+		Columns: []string{"id", "uid", "tenant_id", "name", "prefix", "key_hash", "scopes", "created_on", "last_used_at", "expires_at"},
+		Keys:    []string{"PRIMARY", "uq_api_keys_prefix", "idx_api_keys_uid"},
+	},
+	"user_identities": {
+		Columns: []string{"tenant_id", "provider", "subject", "uid", "created_on"},
+		Keys:    []string{"PRIMARY", "idx_user_identities_uid"},
+	},
+}
+
+func sortedKeys(s schema) schema {
+	for name, t := range s {
+		t.Keys = append([]string(nil), t.Keys...)
+		sort.Strings(t.Keys)
+		s[name] = t
+	}
+	return s
+}
+
+func TestMigrationsUpAndDown(t *testing.T) {
+	migrations, err := EmbeddedMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, mig := range migrations {
+		if mig.Version != int64(i+1) {
+			t.Fatalf("migration %d has version %d", i+1, mig.Version)
+		}
+	}
+
+	s := schema{}
+	before := make([]schema, len(migrations))
+	for i, mig := range migrations {
+		before[i] = s.clone()
+		if err := s.apply(mig.Up); err != nil {
+			t.Fatalf("%s: %v", mig.fileName("up"), err)
+		}
+	}
+	if got, want := sortedKeys(s.clone()), sortedKeys(wantSchema.clone()); !reflect.DeepEqual(got, want) {
+		for name := range want {
+			if !reflect.DeepEqual(got[name], want[name]) {
+				t.Errorf("%s = %+v, want %+v", name, got[name], want[name])
+			}
+		}
+		for name := range got {
+			if _, ok := want[name]; !ok {
+				t.Errorf("unexpected table %s", name)
+			}
+		}
+	}
+
+	// Each down script undoes exactly its up script, so it never drops
+	// what an earlier migration created.
+	for i := len(migrations) - 1; i >= 0; i-- {
+		if err := s.apply(migrations[i].Down); err != nil {
+			t.Fatalf("%s: %v", migrations[i].fileName("down"), err)
+		}
+		if !reflect.DeepEqual(sortedKeys(s.clone()), sortedKeys(before[i])) {
+			t.Fatalf("%s left %+v, want %+v", migrations[i].fileName("down"), s, before[i])
+		}
+	}
+}
+
+// baselineSchema is the schema databases were set up with by hand before
+// migrations existed, without its USE statement.
+const baselineSchema = "CREATE TABLE `students` (" +
+	"`id` bigint NOT NULL AUTO_INCREMENT, `fname` varchar(50) not null, `lname` varchar(50) not null, " +
+	"`date_of_birth` datetime not null, `email` varchar(50) not null, `address` varchar(50) not null, " +
+	"`gender` varchar(50) not null, `created_by` varchar(255) NULL, `created_on` datetime DEFAULT CURRENT_TIMESTAMP, " +
+	"`updated_by` varchar(255) NULL, `updated_on` datetime DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP, " +
+	"PRIMARY KEY (`id`) );" +
+	"CREATE TABLE `users` (" +
+	"`uid` bigint NOT NULL AUTO_INCREMENT, `username` varchar(100) not null, `password` varchar(100) not null, " +
+	"`email` varchar(100) not null, `jwt_token` TEXT, `created_on` datetime default current_timestamp, " +
+	"`updated_on` datetime default current_timestamp on update current_timestamp, PRIMARY KEY (`uid`) );"
+
+func collapseSpace(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	return strings.NewReplacer("( ", "(", " )", ")").Replace(s)
+}
+
+func TestFirstMigrationIsTheBaselineSchema(t *testing.T) {
+	migrations, err := EmbeddedMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := migrations[0]
+	got := collapseSpace(strings.Join(splitStatements(first.Up), ";") + ";")
+	if want := collapseSpace(baselineSchema); got != want {
+		t.Errorf("%s =\n%s\nwant\n%s", first.fileName("up"), got, want)
+	}
+	if tables := createdTables(first.Up); !reflect.DeepEqual(tables, []string{"students", "users"}) {
+		t.Errorf("baseline tables = %v", tables)
+	}
+}
+
+func TestMigrationsNeverCreateOrDropConditionally(t *testing.T) {
+	migrations, err := EmbeddedMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mig := range migrations {
+		for direction, script := range map[string]string{"up": mig.Up, "down": mig.Down} {
+			if strings.Contains(strings.ToUpper(script), "IF NOT EXISTS") || strings.Contains(strings.ToUpper(script), "IF EXISTS") {
+				t.Errorf("%s adopts or skips existing tables", mig.fileName(direction))
+			}
+		}
+	}
+}
+
+func TestExistingUsersCountAsVerified(t *testing.T) {
+	migrations, err := EmbeddedMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mig := range migrations {
+		for _, stmt := range splitStatements(mig.Up) {
+			if strings.HasPrefix(stmt, "UPDATE `users` SET `email_verified_at`") {
+				// The column has to exist by then, and in the same script so
+				// only accounts from before it are marked.
+				if !strings.Contains(mig.Up, "ADD COLUMN `email_verified_at`") {
+					t.Errorf("%s backfills a column it did not add", mig.fileName("up"))
+				}
+				return
+			}
+		}
+	}
+	t.Error("no migration marks existing users as verified")
+}
+
+func TestPendingAndRollbackMigrations(t *testing.T) {
+	migrations := []Migration{{Version: 1, Name: "baseline"}, {Version: 2, Name: "b"}, {Version: 3, Name: "c"}}
+	applied := map[int64]appliedMigration{1: {Version: 1, Baselined: true}, 2: {Version: 2}}
+
+	pending := pendingMigrations(migrations, applied)
+	if len(pending) != 1 || pending[0].Version != 3 {
+		t.Errorf("pending = %+v", pending)
+	}
+
+	rollback, err := rollbackMigrations(migrations, applied, 1)
+	if err != nil || len(rollback) != 1 || rollback[0].Version != 2 {
+		t.Errorf("rollback 1 = %+v, %v", rollback, err)
+	}
+	if _, err := rollbackMigrations(migrations, applied, 2); !errors.Is(err, ErrBaselined) {
+		t.Errorf("rolling back the baseline: err = %v", err)
+	}
+
+	applied[1] = appliedMigration{Version: 1}
+	rollback, err = rollbackMigrations(migrations, applied, 5)
+	if err != nil || len(rollback) != 2 || rollback[0].Version != 2 || rollback[1].Version != 1 {
+		t.Errorf("rollback all = %+v, %v", rollback, err)
+	}
+}
+
+func TestLoadMigrationsRejectsBrokenSets(t *testing.T) {
+	file := func(body string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(body)} }
+	for name, fsys := range map[string]fstest.MapFS{
+		"missing down": {"0001_a.up.sql": file("")},
+		"bad name":     {"1-a.up.sql": file(""), "1-a.down.sql": file("")},
+		"two names":    {"0001_a.up.sql": file(""), "0001_b.down.sql": file("")},
+		"version zero": {"0000_a.up.sql": file(""), "0000_a.down.sql": file("")},
+	} {
+		if _, err := LoadMigrations(fsys); !errors.Is(err, ErrInvalidMigration) {
+			t.Errorf("%s: err = %v", name, err)
+		}
+	}
+
+	migrations, err := LoadMigrations(fstest.MapFS{
+		"0002_b.up.sql":   file("SELECT 2;\r\n"),
+		"0002_b.down.sql": file(""),
+		"0001_a.up.sql":   file("SELECT 1;\n"),
+		"0001_a.down.sql": file(""),
+		"README.md":       file(""),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 2 || migrations[0].Name != "a" || migrations[1].Name != "b" {
+		t.Fatalf("migrations = %+v", migrations)
+	}
+	crlf, _ := LoadMigrations(fstest.MapFS{"0002_b.up.sql": file("SELECT 2;\n"), "0002_b.down.sql": file("")})
+	if migrations[1].Checksum != crlf[0].Checksum {
+		t.Error("checksum depends on line endings")
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	got := splitStatements("-- a comment\nCREATE TABLE `a` (\n  `id` int\n);\n\nUPDATE a SET id = 1;\nSELECT 1")
+	want := []string{"CREATE TABLE `a` (\n  `id` int\n)", "UPDATE a SET id = 1", "SELECT 1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("statements = %q", got)
+	}
+}
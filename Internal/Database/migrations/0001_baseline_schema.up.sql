@@ -0,0 +1,28 @@
+-- The schema databases were set up with by hand before migrations
+-- existed. Such a database is adopted with "migrate baseline" instead of
+-- running this script; everything added since goes into the migrations
+-- after it.
+CREATE TABLE `students` (
+    `id` bigint NOT NULL AUTO_INCREMENT,
+    `fname` varchar(50) not null,
+    `lname` varchar(50) not null,
+    `date_of_birth` datetime not null,
+    `email` varchar(50) not null,
+    `address` varchar(50) not null,
+    `gender` varchar(50) not null,
+    `created_by` varchar(255) NULL,
+    `created_on` datetime DEFAULT CURRENT_TIMESTAMP,
+    `updated_by` varchar(255) NULL,
+    `updated_on` datetime DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`)
+);
+CREATE TABLE `users` (
+    `uid` bigint NOT NULL AUTO_INCREMENT,
+    `username` varchar(100) not null,
+    `password` varchar(100) not null,
+    `email` varchar(100) not null,
+    `jwt_token` TEXT,
+    `created_on` datetime default current_timestamp,
+    `updated_on` datetime default current_timestamp on update current_timestamp,
+    PRIMARY KEY (`uid`)
+);
@@ -0,0 +1,6 @@
+DROP TABLE `student_history`;
+ALTER TABLE `students`
+    DROP KEY `idx_students_deleted_at`,
+    DROP COLUMN `deleted_by`,
+    DROP COLUMN `deleted_at`,
+    DROP COLUMN `version`;
@@ -0,0 +1,20 @@
+-- version counts the changes to a student for ETags and the history;
+-- deleted_at and deleted_by move students to the trash instead of
+-- deleting them.
+ALTER TABLE `students`
+    ADD COLUMN `version` bigint NOT NULL DEFAULT 1,
+    ADD COLUMN `deleted_at` datetime NULL,
+    ADD COLUMN `deleted_by` varchar(255) NULL,
+    ADD KEY `idx_students_deleted_at` (`deleted_at`);
+CREATE TABLE `student_history` (
+    `id` bigint NOT NULL AUTO_INCREMENT,
+    `student_id` bigint NOT NULL,
+    `version` bigint NOT NULL,
+    `action` varchar(20) not null,
+    `changed_by` varchar(255) not null,
+    `changed_at` datetime not null,
+    `snapshot` json not null,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uq_student_history_version` (`student_id`, `version`),
+    KEY `idx_student_history_changed_at` (`student_id`, `changed_at`)
+);
@@ -0,0 +1,4 @@
+ALTER TABLE `users`
+    DROP COLUMN `disabled_at`,
+    DROP COLUMN `email_verified_at`,
+    DROP COLUMN `role`;
@@ -0,0 +1,12 @@
+-- Roles are assigned through the API, which needs an admin. Promote the
+-- first one by hand, or configure a bootstrap admin:
+-- UPDATE users SET role = 'admin' WHERE username = '<username>';
+-- and the first super-admin, who manages tenants:
+-- UPDATE users SET role = 'superadmin' WHERE username = '<username>';
+ALTER TABLE `users`
+    ADD COLUMN `role` varchar(20) not null default 'readonly' AFTER `jwt_token`,
+    ADD COLUMN `email_verified_at` datetime NULL,
+    ADD COLUMN `disabled_at` datetime NULL;
+-- Accounts from before verification existed were never asked to verify;
+-- they keep working when RequireVerifiedEmail is switched on.
+UPDATE `users` SET `email_verified_at` = COALESCE(`created_on`, NOW()) WHERE `email_verified_at` IS NULL;
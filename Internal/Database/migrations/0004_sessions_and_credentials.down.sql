@@ -0,0 +1,8 @@
+DROP TABLE `api_keys`;
+DROP TABLE `user_recovery_codes`;
+DROP TABLE `user_totp`;
+DROP TABLE `password_history`;
+DROP TABLE `password_reset_tokens`;
+DROP TABLE `login_attempts`;
+DROP TABLE `refresh_tokens`;
+DROP TABLE `user_sessions`;
@@ -0,0 +1,69 @@
+CREATE TABLE `user_sessions` (
+    `id` varchar(64) NOT NULL,
+    `uid` bigint NOT NULL,
+    `access_jti` varchar(64) not null,
+    `created_on` datetime not null,
+    `revoked_at` datetime NULL,
+    PRIMARY KEY (`id`),
+    KEY `idx_user_sessions_uid` (`uid`)
+);
+CREATE TABLE `refresh_tokens` (
+    `token_hash` char(64) NOT NULL,
+    `session_id` varchar(64) not null,
+    `expires_at` datetime not null,
+    `used_at` datetime NULL,
+    PRIMARY KEY (`token_hash`),
+    KEY `idx_refresh_tokens_session` (`session_id`)
+);
+CREATE TABLE `login_attempts` (
+    `attempt_key` varchar(255) NOT NULL,
+    `failures` int not null,
+    `last_failed_at` datetime not null,
+    `locked_until` datetime NULL,
+    PRIMARY KEY (`attempt_key`),
+    KEY `idx_login_attempts_locked_until` (`locked_until`)
+);
+CREATE TABLE `password_reset_tokens` (
+    `token_hash` char(64) NOT NULL,
+    `uid` bigint NOT NULL,
+    `created_on` datetime not null,
+    `expires_at` datetime not null,
+    `used_at` datetime NULL,
+    PRIMARY KEY (`token_hash`),
+    KEY `idx_password_reset_tokens_uid` (`uid`)
+);
+CREATE TABLE `password_history` (
+    `id` bigint NOT NULL AUTO_INCREMENT,
+    `uid` bigint NOT NULL,
+    `password_hash` varchar(100) not null,
+    `created_on` datetime not null,
+    PRIMARY KEY (`id`),
+    KEY `idx_password_history_uid` (`uid`)
+);
+CREATE TABLE `user_totp` (
+    `uid` bigint NOT NULL,
+    `secret` varchar(64) not null,
+    `confirmed_at` datetime NULL,
+    `last_step` bigint not null default 0,
+    PRIMARY KEY (`uid`)
+);
+CREATE TABLE `user_recovery_codes` (
+    `uid` bigint NOT NULL,
+    `code_hash` char(64) NOT NULL,
+    `used_at` datetime NULL,
+    PRIMARY KEY (`uid`, `code_hash`)
+);
+CREATE TABLE `api_keys` (
+    `id` bigint NOT NULL AUTO_INCREMENT,
+    `uid` bigint NOT NULL,
+    `name` varchar(100) not null,
+    `prefix` varchar(20) not null,
+    `key_hash` char(64) not null,
+    `scopes` varchar(255) not null,
+    `created_on` datetime not null,
+    `last_used_at` datetime NULL,
+    `expires_at` datetime NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uq_api_keys_prefix` (`prefix`),
+    KEY `idx_api_keys_uid` (`uid`)
+);
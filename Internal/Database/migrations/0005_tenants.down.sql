@@ -0,0 +1,16 @@
+-- Rolling back merges every tenant's rows into one.
+ALTER TABLE `api_keys`
+    DROP COLUMN `tenant_id`;
+ALTER TABLE `password_reset_tokens`
+    DROP COLUMN `tenant_id`;
+ALTER TABLE `user_sessions`
+    DROP COLUMN `tenant_id`;
+ALTER TABLE `student_history`
+    DROP COLUMN `tenant_id`;
+ALTER TABLE `users`
+    DROP KEY `uq_users_tenant_username`,
+    DROP COLUMN `tenant_id`;
+ALTER TABLE `students`
+    DROP KEY `idx_students_tenant`,
+    DROP COLUMN `tenant_id`;
+DROP TABLE `tenants`;
@@ -0,0 +1,27 @@
+CREATE TABLE `tenants` (
+    `id` bigint NOT NULL AUTO_INCREMENT,
+    `slug` varchar(63) not null,
+    `name` varchar(100) not null,
+    `created_on` datetime not null,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uq_tenants_slug` (`slug`)
+);
+-- Rows from before tenants existed belong to the default tenant, which
+-- every tenant_id column defaults to.
+INSERT INTO `tenants` (`id`, `slug`, `name`, `created_on`) VALUES (1, 'default', 'Default', NOW());
+ALTER TABLE `students`
+    ADD COLUMN `tenant_id` bigint NOT NULL DEFAULT 1 AFTER `id`,
+    ADD KEY `idx_students_tenant` (`tenant_id`, `deleted_at`);
+-- Usernames were unique by convention only; duplicates have to be renamed
+-- before this runs.
+ALTER TABLE `users`
+    ADD COLUMN `tenant_id` bigint NOT NULL DEFAULT 1 AFTER `uid`,
+    ADD UNIQUE KEY `uq_users_tenant_username` (`tenant_id`, `username`);
+ALTER TABLE `student_history`
+    ADD COLUMN `tenant_id` bigint NOT NULL DEFAULT 1 AFTER `id`;
+ALTER TABLE `user_sessions`
+    ADD COLUMN `tenant_id` bigint NOT NULL DEFAULT 1 AFTER `uid`;
+ALTER TABLE `password_reset_tokens`
+    ADD COLUMN `tenant_id` bigint NOT NULL DEFAULT 1 AFTER `uid`;
+ALTER TABLE `api_keys`
+    ADD COLUMN `tenant_id` bigint NOT NULL DEFAULT 1 AFTER `uid`;
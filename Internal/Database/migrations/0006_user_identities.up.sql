@@ -0,0 +1,11 @@
+-- Links accounts to the directory and identity provider users that sign
+-- in to them.
+CREATE TABLE `user_identities` (
+    `tenant_id` bigint NOT NULL DEFAULT 1,
+    `provider` varchar(255) NOT NULL,
+    `subject` varchar(255) NOT NULL,
+    `uid` bigint NOT NULL,
+    `created_on` datetime not null,
+    PRIMARY KEY (`tenant_id`, `provider`, `subject`),
+    KEY `idx_user_identities_uid` (`uid`)
+);
@@ -0,0 +1,128 @@
+package database
+
+import (
+	"Students-Final-Assignment/Internal/User"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// APIKeyRow stores the scopes of a key as a comma separated list.
+type APIKeyRow struct {
+	ID         int64        `db:"id"`
+	UID        int64        `db:"uid"`
+	TenantID   int64        `db:"tenant_id"`
+	Name       string       `db:"name"`
+	Prefix     string       `db:"prefix"`
+	Hash       string       `db:"key_hash"`
+	Scopes     string       `db:"scopes"`
+	CreatedOn  time.Time    `db:"created_on"`
+	LastUsedAt sql.NullTime `db:"last_used_at"`
+	ExpiresAt  sql.NullTime `db:"expires_at"`
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func convertAPIKeyRowToAPIKey(row APIKeyRow) User.APIKey {
+	var scopes []User.Permission
+	for _, scope := range strings.Split(row.Scopes, ",") {
+		if scope != "" {
+			scopes = append(scopes, User.Permission(scope))
+		}
+	}
+	return User.APIKey{
+		ID:         row.ID,
+		UID:        row.UID,
+		TenantID:   row.TenantID,
+		Name:       row.Name,
+		Prefix:     row.Prefix,
+		Hash:       row.Hash,
+		Scopes:     scopes,
+		CreatedOn:  row.CreatedOn,
+		LastUsedAt: nullTimePtr(row.LastUsedAt),
+		ExpiresAt:  nullTimePtr(row.ExpiresAt),
+	}
+}
+
+const apiKeySelect = "SELECT id, uid, tenant_id, name, prefix, key_hash, scopes, created_on, last_used_at, expires_at FROM api_keys"
+
+type SQLAPIKeyStore struct {
+	Client *sqlx.DB
+}
+
+func NewAPIKeyStore(db *sqlx.DB) User.APIKeyStore {
+	return &SQLAPIKeyStore{Client: db}
+}
+
+func (s *SQLAPIKeyStore) CreateAPIKey(ctx context.Context, key User.APIKey) (int64, error) {
+	scopes := make([]string, len(key.Scopes))
+	for i, scope := range key.Scopes {
+		scopes[i] = string(scope)
+	}
+	res, err := s.Client.ExecContext(
+		ctx,
+		"INSERT INTO api_keys (uid, tenant_id, name, prefix, key_hash, scopes, created_on, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		key.UID, key.TenantID, key.Name, key.Prefix, key.Hash, strings.Join(scopes, ","), key.CreatedOn, key.ExpiresAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (s *SQLAPIKeyStore) GetAPIKeyByPrefix(ctx context.Context, prefix string) (User.APIKey, error) {
+	var row APIKeyRow
+	err := s.Client.GetContext(ctx, &row, apiKeySelect+" WHERE prefix = ?", prefix)
+	if errors.Is(err, sql.ErrNoRows) {
+		return User.APIKey{}, User.ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return User.APIKey{}, fmt.Errorf("an error occurred fetching API key: %w", err)
+	}
+	return convertAPIKeyRowToAPIKey(row), nil
+}
+
+func (s *SQLAPIKeyStore) ListAPIKeys(ctx context.Context, uid int64) ([]User.APIKey, error) {
+	var rows []APIKeyRow
+	if err := s.Client.SelectContext(ctx, &rows, apiKeySelect+" WHERE uid = ? ORDER BY id", uid); err != nil {
+		return nil, fmt.Errorf("an error occurred listing API keys: %w", err)
+	}
+	keys := make([]User.APIKey, 0, len(rows))
+	for _, row := range rows {
+		keys = append(keys, convertAPIKeyRowToAPIKey(row))
+	}
+	return keys, nil
+}
+
+func (s *SQLAPIKeyStore) DeleteAPIKey(ctx context.Context, uid, id int64) error {
+	res, err := s.Client.ExecContext(ctx, "DELETE FROM api_keys WHERE id = ? AND uid = ?", id, uid)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return User.ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+func (s *SQLAPIKeyStore) DeleteUserAPIKeys(ctx context.Context, uid int64) error {
+	if _, err := s.Client.ExecContext(ctx, "DELETE FROM api_keys WHERE uid = ?", uid); err != nil {
+		return fmt.Errorf("failed to delete API keys: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLAPIKeyStore) TouchAPIKey(ctx context.Context, id int64, at time.Time) error {
+	_, err := s.Client.ExecContext(ctx, "UPDATE api_keys SET last_used_at = ? WHERE id = ?", at, id)
+	return err
+}
@@ -0,0 +1,64 @@
+package database
+
+import (
+	"Students-Final-Assignment/Internal/User"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type SQLIdentityStore struct {
+	Client *sqlx.DB
+}
+
+func NewIdentityStore(db *sqlx.DB) User.IdentityStore {
+	return &SQLIdentityStore{Client: db}
+}
+
+func (s *SQLIdentityStore) GetIdentityUID(ctx context.Context, provider, subject string) (int64, error) {
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var uid int64
+	err = s.Client.GetContext(ctx, &uid, "SELECT uid FROM user_identities WHERE tenant_id = ? AND provider = ? AND subject = ?", tid, provider, subject)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, User.ErrIdentityNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("an error occurred fetching identity: %w", err)
+	}
+	return uid, nil
+}
+
+func (s *SQLIdentityStore) LinkIdentity(ctx context.Context, provider, subject string, uid int64, at time.Time) error {
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = s.Client.ExecContext(
+		ctx,
+		`INSERT INTO user_identities (tenant_id, provider, subject, uid, created_on) VALUES (?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE uid = VALUES(uid), created_on = VALUES(created_on)`,
+		tid, provider, subject, uid, at,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to link identity: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLIdentityStore) UnlinkUser(ctx context.Context, uid int64) error {
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := s.Client.ExecContext(ctx, "DELETE FROM user_identities WHERE tenant_id = ? AND uid = ?", tid, uid); err != nil {
+		return fmt.Errorf("failed to unlink identities: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package database
+
+import (
+	"Students-Final-Assignment/Internal/User"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type SQLLoginAttemptStore struct {
+	Client *sqlx.DB
+}
+
+func NewLoginAttemptStore(db *sqlx.DB) User.LoginAttemptStore {
+	return &SQLLoginAttemptStore{Client: db}
+}
+
+func (s *SQLLoginAttemptStore) GetAttempts(ctx context.Context, key string) (User.LoginAttempts, error) {
+	var attempts User.LoginAttempts
+	err := s.Client.GetContext(ctx, &attempts, "SELECT attempt_key, failures, last_failed_at, locked_until FROM login_attempts WHERE attempt_key = ?", key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return User.LoginAttempts{Key: key}, nil
+	}
+	if err != nil {
+		return User.LoginAttempts{}, fmt.Errorf("an error occurred fetching login attempts: %w", err)
+	}
+	return attempts, nil
+}
+
+func (s *SQLLoginAttemptStore) RecordFailure(ctx context.Context, key string, now, resetBefore time.Time) (int, error) {
+	tx, err := s.Client.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO login_attempts (attempt_key, failures, last_failed_at) VALUES (?, 1, ?)
+		ON DUPLICATE KEY UPDATE failures = IF(last_failed_at < ?, 1, failures + 1), last_failed_at = VALUES(last_failed_at)`,
+		key, now, resetBefore,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record login failure: %w", err)
+	}
+	var failures int
+	if err := tx.GetContext(ctx, &failures, "SELECT failures FROM login_attempts WHERE attempt_key = ?", key); err != nil {
+		return 0, err
+	}
+	return failures, tx.Commit()
+}
+
+func (s *SQLLoginAttemptStore) LockUntil(ctx context.Context, key string, until time.Time) error {
+	_, err := s.Client.ExecContext(ctx, "UPDATE login_attempts SET locked_until = ? WHERE attempt_key = ?", until, key)
+	return err
+}
+
+func (s *SQLLoginAttemptStore) ClearAttempts(ctx context.Context, key string) error {
+	_, err := s.Client.ExecContext(ctx, "DELETE FROM login_attempts WHERE attempt_key = ?", key)
+	return err
+}
+
+func (s *SQLLoginAttemptStore) ListLockouts(ctx context.Context, now time.Time) ([]User.LoginAttempts, error) {
+	locked := []User.LoginAttempts{}
+	err := s.Client.SelectContext(
+		ctx,
+		&locked,
+		"SELECT attempt_key, failures, last_failed_at, locked_until FROM login_attempts WHERE locked_until > ? ORDER BY attempt_key",
+		now,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("an error occurred listing lockouts: %w", err)
+	}
+	return locked, nil
+}
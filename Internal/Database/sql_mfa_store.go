@@ -0,0 +1,103 @@
+package database
+
+import (
+	"Students-Final-Assignment/Internal/User"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type SQLMFAStore struct {
+	Client *sqlx.DB
+}
+
+func NewMFAStore(db *sqlx.DB) User.MFAStore {
+	return &SQLMFAStore{Client: db}
+}
+
+func (s *SQLMFAStore) GetTOTP(ctx context.Context, uid int64) (User.TOTP, error) {
+	var totp User.TOTP
+	err := s.Client.GetContext(ctx, &totp, "SELECT uid, secret, confirmed_at, last_step FROM user_totp WHERE uid = ?", uid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return User.TOTP{}, User.ErrTOTPNotEnrolled
+	}
+	if err != nil {
+		return User.TOTP{}, fmt.Errorf("an error occurred fetching TOTP secret: %w", err)
+	}
+	return totp, nil
+}
+
+func (s *SQLMFAStore) SaveTOTPSecret(ctx context.Context, uid int64, secret string) error {
+	_, err := s.Client.ExecContext(
+		ctx,
+		`INSERT INTO user_totp (uid, secret, last_step) VALUES (?, ?, 0)
+		ON DUPLICATE KEY UPDATE secret = VALUES(secret), confirmed_at = NULL, last_step = 0`,
+		uid, secret,
+	)
+	return err
+}
+
+func (s *SQLMFAStore) ConfirmTOTP(ctx context.Context, uid int64, at time.Time) error {
+	_, err := s.Client.ExecContext(ctx, "UPDATE user_totp SET confirmed_at = ? WHERE uid = ?", at, uid)
+	return err
+}
+
+func (s *SQLMFAStore) UseTOTPStep(ctx context.Context, uid int64, step int64) (bool, error) {
+	res, err := s.Client.ExecContext(ctx, "UPDATE user_totp SET last_step = ? WHERE uid = ? AND last_step < ?", step, uid, step)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (s *SQLMFAStore) DeleteTOTP(ctx context.Context, uid int64) error {
+	tx, err := s.Client.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_recovery_codes WHERE uid = ?", uid); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_totp WHERE uid = ?", uid); err != nil {
+		return fmt.Errorf("failed to delete TOTP secret: %w", err)
+	}
+	return tx.Commit()
+}
+
+func (s *SQLMFAStore) ReplaceRecoveryCodes(ctx context.Context, uid int64, hashes []string) error {
+	tx, err := s.Client.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_recovery_codes WHERE uid = ?", uid); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	for _, hash := range hashes {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO user_recovery_codes (uid, code_hash) VALUES (?, ?)", uid, hash); err != nil {
+			return fmt.Errorf("failed to insert recovery code: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLMFAStore) UseRecoveryCode(ctx context.Context, uid int64, hash string) (bool, error) {
+	res, err := s.Client.ExecContext(
+		ctx,
+		"UPDATE user_recovery_codes SET used_at = ? WHERE uid = ? AND code_hash = ? AND used_at IS NULL",
+		time.Now(), uid, hash,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
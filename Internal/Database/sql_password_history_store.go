@@ -0,0 +1,60 @@
+package database
+
+import (
+	"Students-Final-Assignment/Internal/User"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type SQLPasswordHistoryStore struct {
+	Client *sqlx.DB
+}
+
+func NewPasswordHistoryStore(db *sqlx.DB) User.PasswordHistoryStore {
+	return &SQLPasswordHistoryStore{Client: db}
+}
+
+func (s *SQLPasswordHistoryStore) AddPasswordHistory(ctx context.Context, uid int64, hash string, at time.Time, keep int) error {
+	tx, err := s.Client.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO password_history (uid, password_hash, created_on) VALUES (?, ?, ?)", uid, hash, at)
+	if err != nil {
+		return fmt.Errorf("failed to insert password history: %w", err)
+	}
+	// MySQL cannot LIMIT a subquery on the table being deleted from, hence
+	// the derived table.
+	_, err = tx.ExecContext(
+		ctx,
+		`DELETE FROM password_history WHERE uid = ? AND id NOT IN (
+			SELECT id FROM (SELECT id FROM password_history WHERE uid = ? ORDER BY id DESC LIMIT ?) AS newest
+		)`,
+		uid, uid, keep,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to trim password history: %w", err)
+	}
+	return tx.Commit()
+}
+
+func (s *SQLPasswordHistoryStore) ListPasswordHistory(ctx context.Context, uid int64, n int) ([]string, error) {
+	var hashes []string
+	err := s.Client.SelectContext(ctx, &hashes, "SELECT password_hash FROM password_history WHERE uid = ? ORDER BY id DESC LIMIT ?", uid, n)
+	if err != nil {
+		return nil, fmt.Errorf("an error occurred fetching password history: %w", err)
+	}
+	return hashes, nil
+}
+
+func (s *SQLPasswordHistoryStore) DeletePasswordHistory(ctx context.Context, uid int64) error {
+	if _, err := s.Client.ExecContext(ctx, "DELETE FROM password_history WHERE uid = ?", uid); err != nil {
+		return fmt.Errorf("failed to delete password history: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"Students-Final-Assignment/Internal/User"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type SQLPasswordResetStore struct {
+	Client *sqlx.DB
+}
+
+func NewPasswordResetStore(db *sqlx.DB) User.PasswordResetStore {
+	return &SQLPasswordResetStore{Client: db}
+}
+
+func (s *SQLPasswordResetStore) CreateResetToken(ctx context.Context, token User.PasswordResetToken) error {
+	tx, err := s.Client.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM password_reset_tokens WHERE uid = ? AND used_at IS NULL", token.UID); err != nil {
+		return fmt.Errorf("failed to drop older reset tokens: %w", err)
+	}
+	_, err = tx.ExecContext(
+		ctx,
+		"INSERT INTO password_reset_tokens (token_hash, uid, tenant_id, created_on, expires_at) VALUES (?, ?, ?, ?, ?)",
+		token.Hash, token.UID, token.TenantID, token.CreatedOn, token.ExpiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert reset token: %w", err)
+	}
+	return tx.Commit()
+}
+
+func (s *SQLPasswordResetStore) GetResetToken(ctx context.Context, hash string) (User.PasswordResetToken, error) {
+	var token User.PasswordResetToken
+	err := s.Client.GetContext(
+		ctx,
+		&token,
+		"SELECT token_hash, uid, tenant_id, created_on, expires_at, used_at FROM password_reset_tokens WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?",
+		hash, time.Now(),
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return User.PasswordResetToken{}, User.ErrInvalidResetToken
+	}
+	if err != nil {
+		return User.PasswordResetToken{}, err
+	}
+	return token, nil
+}
+
+func (s *SQLPasswordResetStore) ConsumeResetToken(ctx context.Context, hash string) (User.PasswordResetToken, error) {
+	tx, err := s.Client.BeginTxx(ctx, nil)
+	if err != nil {
+		return User.PasswordResetToken{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	res, err := tx.ExecContext(
+		ctx,
+		"UPDATE password_reset_tokens SET used_at = ? WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?",
+		now, hash, now,
+	)
+	if err != nil {
+		return User.PasswordResetToken{}, fmt.Errorf("failed to mark reset token used: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return User.PasswordResetToken{}, User.ErrInvalidResetToken
+	}
+
+	var token User.PasswordResetToken
+	err = tx.GetContext(ctx, &token, "SELECT token_hash, uid, tenant_id, created_on, expires_at, used_at FROM password_reset_tokens WHERE token_hash = ?", hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return User.PasswordResetToken{}, User.ErrInvalidResetToken
+	}
+	if err != nil {
+		return User.PasswordResetToken{}, err
+	}
+	return token, tx.Commit()
+}
+
+func (s *SQLPasswordResetStore) DeleteUserResetTokens(ctx context.Context, uid int64) error {
+	if _, err := s.Client.ExecContext(ctx, "DELETE FROM password_reset_tokens WHERE uid = ?", uid); err != nil {
+		return fmt.Errorf("failed to delete reset tokens: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package database
+
+import (
+	"Students-Final-Assignment/Internal/User"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type SQLSessionStore struct {
+	Client *sqlx.DB
+}
+
+func NewSessionStore(db *sqlx.DB) User.SessionStore {
+	return &SQLSessionStore{Client: db}
+}
+
+func (s *SQLSessionStore) CreateSession(ctx context.Context, session User.Session, refresh User.RefreshToken) error {
+	tx, err := s.Client.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(
+		ctx,
+		"INSERT INTO user_sessions (id, uid, tenant_id, access_jti, created_on) VALUES (?, ?, ?, ?, ?)",
+		session.ID, session.UID, session.TenantID, session.AccessJTI, session.CreatedOn,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert session: %w", err)
+	}
+	_, err = tx.ExecContext(
+		ctx,
+		"INSERT INTO refresh_tokens (token_hash, session_id, expires_at) VALUES (?, ?, ?)",
+		refresh.Hash, refresh.SessionID, refresh.ExpiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert refresh token: %w", err)
+	}
+	return tx.Commit()
+}
+
+func (s *SQLSessionStore) GetSession(ctx context.Context, id string) (User.Session, error) {
+	var session User.Session
+	err := s.Client.GetContext(ctx, &session, "SELECT id, uid, tenant_id, access_jti, created_on, revoked_at FROM user_sessions WHERE id = ?", id)
+	if err != nil {
+		return User.Session{}, fmt.Errorf("an error occurred fetching session: %w", err)
+	}
+	return session, nil
+}
+
+func (s *SQLSessionStore) GetRefreshToken(ctx context.Context, hash string) (User.RefreshToken, error) {
+	var refresh User.RefreshToken
+	err := s.Client.GetContext(ctx, &refresh, "SELECT token_hash, session_id, expires_at, used_at FROM refresh_tokens WHERE token_hash = ?", hash)
+	if err != nil {
+		return User.RefreshToken{}, fmt.Errorf("an error occurred fetching refresh token: %w", err)
+	}
+	return refresh, nil
+}
+
+func (s *SQLSessionStore) RotateRefreshToken(ctx context.Context, oldHash string, refresh User.RefreshToken, accessJTI string) error {
+	tx, err := s.Client.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "UPDATE refresh_tokens SET used_at = ? WHERE token_hash = ? AND used_at IS NULL", time.Now(), oldHash)
+	if err != nil {
+		return fmt.Errorf("failed to mark refresh token used: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return User.ErrRefreshTokenReused
+	}
+	_, err = tx.ExecContext(
+		ctx,
+		"INSERT INTO refresh_tokens (token_hash, session_id, expires_at) VALUES (?, ?, ?)",
+		refresh.Hash, refresh.SessionID, refresh.ExpiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert refresh token: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE user_sessions SET access_jti = ? WHERE id = ?", accessJTI, refresh.SessionID); err != nil {
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+	return tx.Commit()
+}
+
+func (s *SQLSessionStore) RevokeSession(ctx context.Context, id string) error {
+	_, err := s.Client.ExecContext(ctx, "UPDATE user_sessions SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL", time.Now(), id)
+	return err
+}
+
+func (s *SQLSessionStore) RevokeOtherSessions(ctx context.Context, uid int64, keepID string) (int64, error) {
+	res, err := s.Client.ExecContext(ctx, "UPDATE user_sessions SET revoked_at = ? WHERE uid = ? AND id <> ? AND revoked_at IS NULL", time.Now(), uid, keepID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *SQLSessionStore) RevokeUserSessions(ctx context.Context, uid int64) (int64, error) {
+	res, err := s.Client.ExecContext(ctx, "UPDATE user_sessions SET revoked_at = ? WHERE uid = ? AND revoked_at IS NULL", time.Now(), uid)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,170 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"Students-Final-Assignment/Internal/Student"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type StudentHistoryRow struct {
+	StudentID int64     `db:"student_id"`
+	Version   int64     `db:"version"`
+	Action    string    `db:"action"`
+	ChangedBy string    `db:"changed_by"`
+	ChangedAt time.Time `db:"changed_at"`
+	Snapshot  []byte    `db:"snapshot"`
+}
+
+func convertHistoryRowToEntry(row StudentHistoryRow) (Student.HistoryEntry, error) {
+	entry := Student.HistoryEntry{
+		Version:   row.Version,
+		Action:    row.Action,
+		ChangedBy: row.ChangedBy,
+		ChangedAt: row.ChangedAt,
+	}
+	if err := json.Unmarshal(row.Snapshot, &entry.Snapshot); err != nil {
+		return Student.HistoryEntry{}, fmt.Errorf("could not decode student snapshot: %w", err)
+	}
+	return entry, nil
+}
+
+// withTx runs fn in a transaction that is committed only if fn succeeds.
+func (s *SQLStudentStore) withTx(ctx context.Context, fn func(*sqlx.Tx) error) error {
+	tx, err := s.Client.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// lockLiveStudent locks the row of a student of the tenant that is not in
+// the trash for the rest of the transaction. A non-zero ifVersion must
+// match the row's version, otherwise Student.ErrConflict is returned.
+func lockLiveStudent(ctx context.Context, tx *sqlx.Tx, tid, id int64, ifVersion int64) error {
+	var version int64
+	err := tx.GetContext(ctx, &version, `SELECT version FROM students WHERE id = ? AND tenant_id = ? AND deleted_at IS NULL FOR UPDATE`, id, tid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Student.ErrNoStudentFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock student: %w", err)
+	}
+	if ifVersion != 0 && version != ifVersion {
+		return Student.ErrConflict
+	}
+	return nil
+}
+
+// recordStudentHistory snapshots the student row as it is inside tx and
+// appends it to the student's history as the next version. The snapshot is
+// returned so writers can hand back the stored row. Callers have already
+// checked the row belongs to the request's tenant; the history row is
+// filed under the same tenant.
+func recordStudentHistory(ctx context.Context, tx *sqlx.Tx, id int64, action, changedBy string) (Student.Student, error) {
+	var row StudentRow
+	if err := tx.GetContext(ctx, &row, studentSelect+` WHERE id = ?`, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Student.Student{}, Student.ErrNoStudentFound
+		}
+		return Student.Student{}, fmt.Errorf("failed to read student for history: %w", err)
+	}
+	st := convertStudentRowToStudent(row)
+
+	snapshot, err := json.Marshal(st)
+	if err != nil {
+		return Student.Student{}, fmt.Errorf("could not encode student snapshot: %w", err)
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO student_history (tenant_id, student_id, version, action, changed_by, changed_at, snapshot)
+		SELECT ?, ?, COALESCE(MAX(version), 0) + 1, ?, ?, ?, ?
+		FROM student_history
+		WHERE student_id = ?`,
+		row.TenantID, id, action, changedBy, time.Now(), snapshot, id,
+	)
+	if err != nil {
+		return Student.Student{}, fmt.Errorf("failed to record student history: %w", err)
+	}
+	return st, nil
+}
+
+func (s *SQLStudentStore) GetStudentHistory(ctx context.Context, id int64) ([]Student.HistoryEntry, error) {
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var rows []StudentHistoryRow
+	err = s.Client.SelectContext(
+		ctx,
+		&rows,
+		`SELECT student_id, version, action, changed_by, changed_at, snapshot
+		FROM student_history
+		WHERE student_id = ? AND tenant_id = ?
+		ORDER BY version ASC`,
+		id, tid,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch student history: %w", err)
+	}
+
+	entries := make([]Student.HistoryEntry, 0, len(rows))
+	for _, row := range rows {
+		entry, err := convertHistoryRowToEntry(row)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// GetStudentAsOf returns the student as recorded by the last history entry
+// at or before asOf.
+func (s *SQLStudentStore) GetStudentAsOf(ctx context.Context, id int64, asOf time.Time) (Student.Student, error) {
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return Student.Student{}, err
+	}
+	var row StudentHistoryRow
+	err = s.Client.GetContext(
+		ctx,
+		&row,
+		`SELECT student_id, version, action, changed_by, changed_at, snapshot
+		FROM student_history
+		WHERE student_id = ? AND tenant_id = ? AND changed_at <= ?
+		ORDER BY version DESC
+		LIMIT 1`,
+		id, tid, asOf,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Student.Student{}, Student.ErrNoStudentFound
+	}
+	if err != nil {
+		return Student.Student{}, fmt.Errorf("failed to fetch student as of %s: %w", asOf, err)
+	}
+
+	entry, err := convertHistoryRowToEntry(row)
+	if err != nil {
+		return Student.Student{}, err
+	}
+	if entry.Action == Student.ActionDelete {
+		return Student.Student{}, Student.ErrNoStudentFound
+	}
+	return entry.Snapshot, nil
+}
@@ -0,0 +1,124 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"Students-Final-Assignment/Internal/Student"
+)
+
+// maxSearchCandidates bounds how many rows are pulled back for ranking.
+const maxSearchCandidates = 500
+
+// maxSearchPatterns bounds the LIKE patterns of one query. Full terms come
+// first, so only trigrams are dropped.
+const maxSearchPatterns = 32
+
+// Weights of the relevance estimate the candidates are ordered by before
+// the limit: a whole term counts more than one of its trigrams, and a name
+// more than the contact columns.
+const (
+	termWeight    = 4
+	trigramWeight = 1
+)
+
+var searchColumns = []struct {
+	name   string
+	weight int
+}{{"fname", 2}, {"lname", 2}, {"email", 1}, {"address", 1}}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func containsPattern(v string) string {
+	return "%" + likeEscaper.Replace(v) + "%"
+}
+
+type searchPattern struct {
+	text   string
+	weight int
+}
+
+// searchPatterns returns the terms and then their sorted trigrams, at most
+// maxSearchPatterns in all.
+func searchPatterns(terms []string) []searchPattern {
+	seen := map[string]bool{}
+	var patterns []searchPattern
+	add := func(text string, weight int) {
+		if len(patterns) < maxSearchPatterns && !seen[text] {
+			seen[text] = true
+			patterns = append(patterns, searchPattern{text, weight})
+		}
+	}
+	for _, term := range terms {
+		add(term, termWeight)
+	}
+	for _, term := range terms {
+		if len([]rune(term)) < 3 {
+			continue
+		}
+		var grams []string
+		for g := range Student.Trigrams(term) {
+			// Padded trigrams mark word boundaries and can't be matched
+			// with LIKE.
+			if !strings.Contains(g, " ") {
+				grams = append(grams, g)
+			}
+		}
+		sort.Strings(grams)
+		for _, g := range grams {
+			add(g, trigramWeight)
+		}
+	}
+	return patterns
+}
+
+// buildSearchQuery selects the live students of a tenant matching any of the
+// patterns, best estimated match first, so the candidate limit keeps the
+// rows most likely to rank well.
+func buildSearchQuery(tid int64, terms []string) (string, []interface{}) {
+	var clauses, scores []string
+	var whereArgs, scoreArgs []interface{}
+	for _, p := range searchPatterns(terms) {
+		for _, col := range searchColumns {
+			clauses = append(clauses, col.name+" LIKE ?")
+			whereArgs = append(whereArgs, containsPattern(p.text))
+			scores = append(scores, fmt.Sprintf("(%s LIKE ?) * %d", col.name, p.weight*col.weight))
+			scoreArgs = append(scoreArgs, containsPattern(p.text))
+		}
+	}
+	query := studentSelect + `
+		WHERE tenant_id = ? AND deleted_at IS NULL AND (` + strings.Join(clauses, " OR ") + `)
+		ORDER BY (` + strings.Join(scores, " + ") + `) DESC, id ASC
+		LIMIT ?`
+	args := append([]interface{}{tid}, whereArgs...)
+	args = append(args, scoreArgs...)
+	return query, append(args, maxSearchCandidates)
+}
+
+// SearchStudents narrows the table down with LIKE on the query terms and
+// their trigrams, which also catches most typos, and then ranks the
+// candidates in Go with Student.RankStudents.
+func (s *SQLStudentStore) SearchStudents(ctx context.Context, query string, limit int) ([]Student.SearchResult, error) {
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	terms := Student.QueryTerms(query)
+	if len(terms) == 0 {
+		return []Student.SearchResult{}, nil
+	}
+
+	stmt, args := buildSearchQuery(tid, terms)
+	var rows []StudentRow
+	if err := s.Client.SelectContext(ctx, &rows, stmt, args...); err != nil {
+		return nil, fmt.Errorf("failed to search students: %w", err)
+	}
+
+	candidates := make([]Student.Student, 0, len(rows))
+	for _, row := range rows {
+		candidates = append(candidates, convertStudentRowToStudent(row))
+	}
+	return Student.RankStudents(terms, candidates, limit), nil
+}
@@ -0,0 +1,51 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSearchPatternsTermsFirstAndCapped(t *testing.T) {
+	patterns := searchPatterns([]string{"ann", "lee"})
+	if len(patterns) < 2 || patterns[0].text != "ann" || patterns[1].text != "lee" || patterns[0].weight != termWeight {
+		t.Fatalf("patterns = %+v", patterns)
+	}
+	for _, p := range patterns[2:] {
+		if p.weight != trigramWeight || strings.Contains(p.text, " ") {
+			t.Errorf("trigram pattern = %+v", p)
+		}
+	}
+
+	var terms []string
+	for i := 0; i < 8; i++ {
+		terms = append(terms, fmt.Sprintf("averylongsearchterm%d", i))
+	}
+	patterns = searchPatterns(terms)
+	if len(patterns) != maxSearchPatterns {
+		t.Fatalf("%d patterns, want %d", len(patterns), maxSearchPatterns)
+	}
+	for i, term := range terms {
+		if patterns[i].text != term {
+			t.Errorf("pattern %d = %q, want term %q", i, patterns[i].text, term)
+		}
+	}
+}
+
+func TestBuildSearchQueryOrdersBeforeLimit(t *testing.T) {
+	query, args := buildSearchQuery(3, []string{"ab"})
+	order := strings.Index(query, "ORDER BY (")
+	if order < 0 || order > strings.Index(query, "LIMIT ?") {
+		t.Fatalf("query does not order before the limit: %s", query)
+	}
+	if !strings.Contains(query, "(fname LIKE ?) * 8") || !strings.Contains(query, "id ASC") {
+		t.Errorf("query = %s", query)
+	}
+	// tenant, 4 WHERE patterns, 4 score patterns, limit
+	if len(args) != 10 || args[0] != int64(3) || args[1] != "%ab%" || args[9] != maxSearchCandidates {
+		t.Errorf("args = %v", args)
+	}
+	if n := strings.Count(query, "?"); n != len(args) {
+		t.Errorf("%d placeholders for %d args", n, len(args))
+	}
+}
@@ -2,7 +2,10 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"Students-Final-Assignment/Internal/Student"
@@ -11,17 +14,27 @@ import (
 )
 
 type StudentRow struct {
-	ID          int64     `db:"id"`
-	FName       string    `db:"fname"`
-	LName       string    `db:"lname"`
-	DateOfBirth time.Time `db:"date_of_birth"`
-	Email       string    `db:"email"`
-	Address     string    `db:"address"`
-	Gender      string    `db:"gender"`
-	CreatedBy   string    `db:"created_by"`
-	CreatedOn   time.Time `db:"created_on"`
+	ID          int64          `db:"id"`
+	TenantID    int64          `db:"tenant_id"`
+	FName       string         `db:"fname"`
+	LName       string         `db:"lname"`
+	DateOfBirth time.Time      `db:"date_of_birth"`
+	Email       string         `db:"email"`
+	Address     string         `db:"address"`
+	Gender      string         `db:"gender"`
+	CreatedBy   sql.NullString `db:"created_by"`
+	CreatedOn   time.Time      `db:"created_on"`
+	UpdatedBy   sql.NullString `db:"updated_by"`
+	UpdatedOn   sql.NullTime   `db:"updated_on"`
+	Version     int64          `db:"version"`
+	DeletedAt   sql.NullTime   `db:"deleted_at"`
+	DeletedBy   sql.NullString `db:"deleted_by"`
 }
 
+// studentSelect is the column list shared by every student read.
+const studentSelect = `SELECT id, tenant_id, fname, lname, date_of_birth, email, address, gender, created_by, created_on, updated_by, updated_on, version, deleted_at, deleted_by
+		FROM students`
+
 type SQLStudentStore struct {
 	Client *sqlx.DB
 }
@@ -35,28 +48,40 @@ func (s *SQLStudentStore) Ping(ctx context.Context) error {
 }
 
 func convertStudentRowToStudent(row StudentRow) Student.Student {
-	return Student.Student{
+	st := Student.Student{
 		ID:          row.ID,
+		TenantID:    row.TenantID,
 		Fname:       row.FName,
 		Lname:       row.LName,
 		DateOfBirth: row.DateOfBirth,
 		Email:       row.Email,
 		Address:     row.Address,
 		Gender:      row.Gender,
-		CreatedBy:   row.CreatedBy,
+		CreatedBy:   row.CreatedBy.String,
 		CreatedOn:   row.CreatedOn,
+		UpdatedBy:   row.UpdatedBy.String,
+		UpdatedOn:   row.UpdatedOn.Time,
+		Version:     row.Version,
+	}
+	if row.DeletedAt.Valid {
+		st.DeletedAt = &row.DeletedAt.Time
+		st.DeletedBy = row.DeletedBy.String
 	}
+	return st
 }
 
 func (s *SQLStudentStore) GetStudent(ctx context.Context, id int64) (Student.Student, error) {
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return Student.Student{}, err
+	}
 	var row StudentRow
-	err := s.Client.GetContext(
+	err = s.Client.GetContext(
 		ctx,
 		&row,
-		`SELECT id, fname, lname, date_of_birth, email, address, gender, created_by, created_on
-		FROM students 
-		WHERE id = ?`,
-		id,
+		studentSelect+`
+		WHERE id = ? AND tenant_id = ? AND deleted_at IS NULL`,
+		id, tid,
 	)
 	if err != nil {
 		return Student.Student{}, fmt.Errorf("an error occurred fetching a student by id: %w", err)
@@ -64,39 +89,427 @@ func (s *SQLStudentStore) GetStudent(ctx context.Context, id int64) (Student.Stu
 	return convertStudentRowToStudent(row), nil
 }
 
-func (s *SQLStudentStore) PostStudent(ctx context.Context, st Student.Student) (Student.Student, error) {
-	_, err := s.Client.ExecContext(
+func (s *SQLStudentStore) PostStudent(ctx context.Context, st Student.Student, actor string) (Student.Student, error) {
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return Student.Student{}, err
+	}
+	err = s.withTx(ctx, func(tx *sqlx.Tx) error {
+		now := time.Now()
+		res, err := tx.ExecContext(
+			ctx,
+			`INSERT INTO students (tenant_id, fname, lname, date_of_birth, email, address, gender, created_by, created_on, updated_by, updated_on) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			tid, st.Fname, st.Lname, st.DateOfBirth, st.Email, st.Address, st.Gender, actor, now, actor, now,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert student: %w", err)
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to read inserted student id: %w", err)
+		}
+		st, err = recordStudentHistory(ctx, tx, id, Student.ActionCreate, actor)
+		return err
+	})
+	if err != nil {
+		return Student.Student{}, err
+	}
+	return st, nil
+}
+
+func (s *SQLStudentStore) ImportStudents(ctx context.Context, students []Student.Student, dryRun bool, actor string) ([]Student.Student, error) {
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := s.Client.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin import transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PreparexContext(
 		ctx,
-		`INSERT INTO students (fname, lname, date_of_birth, email, address, gender, created_by, created_on) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
-		st.Fname, st.Lname, st.DateOfBirth, st.Email, st.Address, st.Gender, "admin", time.Now(),
+		`INSERT INTO students (tenant_id, fname, lname, date_of_birth, email, address, gender, created_by, created_on, updated_by, updated_on) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 	)
 	if err != nil {
-		return Student.Student{}, fmt.Errorf("failed to insert student: %w", err)
+		return nil, fmt.Errorf("failed to prepare student import: %w", err)
+	}
+	defer stmt.Close()
+
+	imported := make([]Student.Student, 0, len(students))
+	for i, st := range students {
+		now := time.Now()
+		res, err := stmt.ExecContext(ctx, tid, st.Fname, st.Lname, st.DateOfBirth, st.Email, st.Address, st.Gender, actor, now, actor, now)
+		if err != nil {
+			return nil, fmt.Errorf("failed to import student %d: %w", i+1, err)
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read imported student id: %w", err)
+		}
+		if st, err = recordStudentHistory(ctx, tx, id, Student.ActionCreate, actor); err != nil {
+			return nil, fmt.Errorf("failed to import student %d: %w", i+1, err)
+		}
+		// Ids handed out inside a rolled back dry run are never used.
+		if dryRun {
+			st.ID = 0
+		}
+		imported = append(imported, st)
+	}
+
+	if dryRun {
+		return imported, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit student import: %w", err)
+	}
+	return imported, nil
+}
+
+func (s *SQLStudentStore) UpdateStudent(ctx context.Context, id int64, st Student.Student, ifVersion int64, actor string) (Student.Student, error) {
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return Student.Student{}, err
+	}
+	err = s.withTx(ctx, func(tx *sqlx.Tx) error {
+		if err := lockLiveStudent(ctx, tx, tid, id, ifVersion); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(
+			ctx,
+			`UPDATE students SET fname = ?, lname = ?, date_of_birth = ?, email = ?, address = ?, gender = ?, updated_by = ?, updated_on = ?, version = version + 1 WHERE id = ? AND tenant_id = ? AND deleted_at IS NULL`,
+			st.Fname, st.Lname, st.DateOfBirth, st.Email, st.Address, st.Gender, actor, time.Now(), id, tid,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to update student: %w", err)
+		}
+		st, err = recordStudentHistory(ctx, tx, id, Student.ActionUpdate, actor)
+		return err
+	})
+	if err != nil {
+		return Student.Student{}, err
+	}
+	return st, nil
+}
+
+// PatchStudent updates only the columns set in the patch and returns the
+// stored row afterwards.
+func (s *SQLStudentStore) PatchStudent(ctx context.Context, id int64, patch Student.StudentPatch, ifVersion int64, actor string) (Student.Student, error) {
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return Student.Student{}, err
+	}
+	if patch.IsEmpty() {
+		st, err := s.GetStudent(ctx, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return Student.Student{}, Student.ErrNoStudentFound
+		}
+		if err == nil && ifVersion != 0 && st.Version != ifVersion {
+			return Student.Student{}, Student.ErrConflict
+		}
+		return st, err
+	}
+
+	var sets []string
+	var args []interface{}
+	set := func(col string, v interface{}) {
+		sets = append(sets, col+" = ?")
+		args = append(args, v)
+	}
+
+	if patch.Fname != nil {
+		set("fname", *patch.Fname)
+	}
+	if patch.Lname != nil {
+		set("lname", *patch.Lname)
+	}
+	if patch.DateOfBirth != nil {
+		set("date_of_birth", *patch.DateOfBirth)
+	}
+	if patch.Email != nil {
+		set("email", *patch.Email)
+	}
+	if patch.Address != nil {
+		set("address", *patch.Address)
+	}
+	if patch.Gender != nil {
+		set("gender", *patch.Gender)
+	}
+	set("updated_by", actor)
+	set("updated_on", time.Now())
+
+	var st Student.Student
+	err = s.withTx(ctx, func(tx *sqlx.Tx) error {
+		if err := lockLiveStudent(ctx, tx, tid, id, ifVersion); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(
+			ctx,
+			`UPDATE students SET `+strings.Join(sets, ", ")+`, version = version + 1 WHERE id = ? AND tenant_id = ? AND deleted_at IS NULL`,
+			append(args, id, tid)...,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to patch student: %w", err)
+		}
+		st, err = recordStudentHistory(ctx, tx, id, Student.ActionUpdate, actor)
+		return err
+	})
+	if err != nil {
+		return Student.Student{}, err
 	}
 	return st, nil
 }
 
-func (s *SQLStudentStore) UpdateStudent(ctx context.Context, id int64, st Student.Student) (Student.Student, error) {
-	_, err := s.Client.ExecContext(
+// DeleteStudent moves a student to the trash. The row stays in the table
+// until it is restored or purged.
+func (s *SQLStudentStore) DeleteStudent(ctx context.Context, id int64, ifVersion int64, actor string) error {
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+	return s.withTx(ctx, func(tx *sqlx.Tx) error {
+		if err := lockLiveStudent(ctx, tx, tid, id, ifVersion); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(
+			ctx,
+			`UPDATE students SET deleted_at = ?, deleted_by = ?, version = version + 1 WHERE id = ? AND tenant_id = ?`,
+			time.Now(), actor, id, tid,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to delete student from the database: %w", err)
+		}
+		_, err = recordStudentHistory(ctx, tx, id, Student.ActionDelete, actor)
+		return err
+	})
+}
+
+func (s *SQLStudentStore) RestoreStudent(ctx context.Context, id int64, actor string) (Student.Student, error) {
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return Student.Student{}, err
+	}
+	var st Student.Student
+	err = s.withTx(ctx, func(tx *sqlx.Tx) error {
+		res, err := tx.ExecContext(
+			ctx,
+			`UPDATE students SET deleted_at = NULL, deleted_by = NULL, updated_by = ?, updated_on = ?, version = version + 1 WHERE id = ? AND tenant_id = ? AND deleted_at IS NOT NULL`,
+			actor, time.Now(), id, tid,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to restore student: %w", err)
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return Student.ErrNoStudentFound
+		}
+		st, err = recordStudentHistory(ctx, tx, id, Student.ActionRestore, actor)
+		return err
+	})
+	if err != nil {
+		return Student.Student{}, err
+	}
+	return st, nil
+}
+
+func (s *SQLStudentStore) ListTrash(ctx context.Context, opts Student.ListOptions) (Student.StudentPage, error) {
+	return s.listStudents(ctx, opts, true)
+}
+
+// PurgeStudents permanently removes students of the tenant that have been
+// in the trash since before the given time, together with their history,
+// which holds a full copy of every version.
+func (s *SQLStudentStore) PurgeStudents(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	err = s.withTx(ctx, func(tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(
+			ctx,
+			`DELETE h FROM student_history h JOIN students s ON s.id = h.student_id
+			WHERE s.tenant_id = ? AND s.deleted_at IS NOT NULL AND s.deleted_at < ?`,
+			tid, deletedBefore,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to purge student history: %w", err)
+		}
+		res, err := tx.ExecContext(
+			ctx,
+			`DELETE FROM students WHERE tenant_id = ? AND deleted_at IS NOT NULL AND deleted_at < ?`,
+			tid, deletedBefore,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to purge students: %w", err)
+		}
+		n, err = res.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// studentSortColumns maps the sortable Student fields to their columns so
+// that nothing from the request is ever spliced into the query text.
+var studentSortColumns = map[string]string{
+	"id":            "id",
+	"fname":         "fname",
+	"lname":         "lname",
+	"date_of_birth": "date_of_birth",
+	"email":         "email",
+	"gender":        "gender",
+	"created_on":    "created_on",
+}
+
+// buildStudentFilter always restricts to the tenant's live rows unless
+// trashed is set, in which case only soft-deleted rows match.
+func buildStudentFilter(tid int64, f Student.ListFilter, trashed bool) (string, []interface{}) {
+	clauses := []string{"tenant_id = ?", "deleted_at IS NULL"}
+	if trashed {
+		clauses[1] = "deleted_at IS NOT NULL"
+	}
+	args := []interface{}{tid}
+
+	if f.Fname != "" {
+		clauses = append(clauses, "fname = ?")
+		args = append(args, f.Fname)
+	}
+	if f.Lname != "" {
+		clauses = append(clauses, "lname = ?")
+		args = append(args, f.Lname)
+	}
+	if f.Email != "" {
+		clauses = append(clauses, "email = ?")
+		args = append(args, f.Email)
+	}
+	if f.Gender != "" {
+		clauses = append(clauses, "gender = ?")
+		args = append(args, f.Gender)
+	}
+	if !f.DateOfBirthFrom.IsZero() {
+		clauses = append(clauses, "date_of_birth >= ?")
+		args = append(args, f.DateOfBirthFrom)
+	}
+	if !f.DateOfBirthTo.IsZero() {
+		clauses = append(clauses, "date_of_birth <= ?")
+		args = append(args, f.DateOfBirthTo)
+	}
+	if !f.DateOfBirthBefore.IsZero() {
+		clauses = append(clauses, "date_of_birth < ?")
+		args = append(args, f.DateOfBirthBefore)
+	}
+	if !f.CreatedFrom.IsZero() {
+		clauses = append(clauses, "created_on >= ?")
+		args = append(args, f.CreatedFrom)
+	}
+	if !f.CreatedTo.IsZero() {
+		clauses = append(clauses, "created_on <= ?")
+		args = append(args, f.CreatedTo)
+	}
+	if !f.CreatedBefore.IsZero() {
+		clauses = append(clauses, "created_on < ?")
+		args = append(args, f.CreatedBefore)
+	}
+
+	return " WHERE " + strings.Join(clauses, " AND "), args
+}
+
+func buildStudentOrderBy(sort []Student.SortField) (string, error) {
+	var parts []string
+	for _, sf := range sort {
+		col, ok := studentSortColumns[sf.Field]
+		if !ok {
+			return "", fmt.Errorf("%w: %s", Student.ErrInvalidSortField, sf.Field)
+		}
+		if sf.Desc {
+			col += " DESC"
+		} else {
+			col += " ASC"
+		}
+		parts = append(parts, col)
+	}
+	// id is appended as a tie-breaker so pages are stable.
+	parts = append(parts, "id ASC")
+	return " ORDER BY " + strings.Join(parts, ", "), nil
+}
+
+func (s *SQLStudentStore) ListStudents(ctx context.Context, opts Student.ListOptions) (Student.StudentPage, error) {
+	return s.listStudents(ctx, opts, false)
+}
+
+func (s *SQLStudentStore) listStudents(ctx context.Context, opts Student.ListOptions, trashed bool) (Student.StudentPage, error) {
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return Student.StudentPage{}, err
+	}
+	where, args := buildStudentFilter(tid, opts.Filter, trashed)
+	orderBy, err := buildStudentOrderBy(opts.Sort)
+	if err != nil {
+		return Student.StudentPage{}, err
+	}
+
+	var total int64
+	if err := s.Client.GetContext(ctx, &total, `SELECT COUNT(*) FROM students`+where, args...); err != nil {
+		return Student.StudentPage{}, fmt.Errorf("failed to count students: %w", err)
+	}
+
+	var rows []StudentRow
+	err = s.Client.SelectContext(
 		ctx,
-		`UPDATE students SET fname = ?, lname = ?, date_of_birth = ?, email = ?, address = ?, gender = ?, updated_by = ?, updated_on = ? WHERE id = ?`,
-		st.Fname, st.Lname, st.DateOfBirth, st.Email, st.Address, st.Gender, "admin", time.Now(), id,
+		&rows,
+		studentSelect+where+orderBy+` LIMIT ? OFFSET ?`,
+		append(args, opts.Limit, opts.Offset)...,
 	)
 	if err != nil {
-		return Student.Student{}, fmt.Errorf("failed to update student: %w", err)
+		return Student.StudentPage{}, fmt.Errorf("failed to list students: %w", err)
 	}
-	st.ID = id
-	return st, nil
+
+	students := make([]Student.Student, 0, len(rows))
+	for _, row := range rows {
+		students = append(students, convertStudentRowToStudent(row))
+	}
+	return Student.StudentPage{
+		Students: students,
+		Total:    total,
+		Limit:    opts.Limit,
+		Offset:   opts.Offset,
+	}, nil
 }
 
-func (s *SQLStudentStore) DeleteStudent(ctx context.Context, id int64) error {
-	_, err := s.Client.ExecContext(
+// StreamStudents walks the matching rows with a cursor instead of loading
+// them into a slice, so exports of any size use constant memory.
+func (s *SQLStudentStore) StreamStudents(ctx context.Context, opts Student.ListOptions, fn func(Student.Student) error) error {
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+	where, args := buildStudentFilter(tid, opts.Filter, false)
+	orderBy, err := buildStudentOrderBy(opts.Sort)
+	if err != nil {
+		return err
+	}
+
+	rows, err := s.Client.QueryxContext(
 		ctx,
-		`DELETE FROM students WHERE id = ?`,
-		id,
+		studentSelect+where+orderBy,
+		args...,
 	)
 	if err != nil {
-		return fmt.Errorf("failed to delete student from the database: %w", err)
+		return fmt.Errorf("failed to query students for export: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row StudentRow
+		if err := rows.StructScan(&row); err != nil {
+			return fmt.Errorf("failed to scan student row: %w", err)
+		}
+		if err := fn(convertStudentRowToStudent(row)); err != nil {
+			return err
+		}
 	}
-	return nil
+	return rows.Err()
 }
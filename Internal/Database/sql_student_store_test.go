@@ -0,0 +1,44 @@
+package database
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"Students-Final-Assignment/Internal/Student"
+)
+
+func TestBuildStudentFilter(t *testing.T) {
+	day := time.Date(2001, 2, 4, 0, 0, 0, 0, time.UTC)
+	where, args := buildStudentFilter(7, Student.ListFilter{
+		Fname:             "Ann",
+		DateOfBirthBefore: day,
+		CreatedTo:         day,
+	}, false)
+
+	want := " WHERE tenant_id = ? AND deleted_at IS NULL AND fname = ? AND date_of_birth < ? AND created_on <= ?"
+	if where != want {
+		t.Errorf("where = %q, want %q", where, want)
+	}
+	if len(args) != 4 || args[0] != int64(7) || args[1] != "Ann" {
+		t.Errorf("args = %v", args)
+	}
+
+	where, _ = buildStudentFilter(7, Student.ListFilter{}, true)
+	if !strings.Contains(where, "deleted_at IS NOT NULL") {
+		t.Errorf("trash filter = %q", where)
+	}
+}
+
+func TestBuildStudentOrderBy(t *testing.T) {
+	orderBy, err := buildStudentOrderBy([]Student.SortField{{Field: "lname", Desc: true}, {Field: "fname"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := " ORDER BY lname DESC, fname ASC, id ASC"; orderBy != want {
+		t.Errorf("orderBy = %q, want %q", orderBy, want)
+	}
+	if _, err := buildStudentOrderBy([]Student.SortField{{Field: "password"}}); err == nil {
+		t.Error("unknown sort field accepted")
+	}
+}
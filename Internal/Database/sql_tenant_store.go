@@ -0,0 +1,100 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"Students-Final-Assignment/Internal/Tenant"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// tenantID returns the tenant every query of a request is scoped to. A
+// context without one is an error rather than a query over all schools.
+func tenantID(ctx context.Context) (int64, error) {
+	id, ok := Tenant.FromContext(ctx)
+	if !ok {
+		return 0, Tenant.ErrNoTenant
+	}
+	return id, nil
+}
+
+type SQLTenantStore struct {
+	Client *sqlx.DB
+}
+
+func NewTenantStore(db *sqlx.DB) Tenant.TenantStore {
+	return &SQLTenantStore{Client: db}
+}
+
+const tenantSelect = "SELECT id, slug, name, created_on FROM tenants"
+
+func (s *SQLTenantStore) GetTenant(ctx context.Context, id int64) (Tenant.Tenant, error) {
+	var t Tenant.Tenant
+	err := s.Client.GetContext(ctx, &t, tenantSelect+" WHERE id = ?", id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Tenant.Tenant{}, Tenant.ErrTenantNotFound
+	}
+	if err != nil {
+		return Tenant.Tenant{}, fmt.Errorf("an error occurred fetching tenant: %w", err)
+	}
+	return t, nil
+}
+
+func (s *SQLTenantStore) GetTenantBySlug(ctx context.Context, slug string) (Tenant.Tenant, error) {
+	var t Tenant.Tenant
+	err := s.Client.GetContext(ctx, &t, tenantSelect+" WHERE slug = ?", slug)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Tenant.Tenant{}, Tenant.ErrTenantNotFound
+	}
+	if err != nil {
+		return Tenant.Tenant{}, fmt.Errorf("an error occurred fetching tenant: %w", err)
+	}
+	return t, nil
+}
+
+func (s *SQLTenantStore) ListTenants(ctx context.Context) ([]Tenant.Tenant, error) {
+	tenants := []Tenant.Tenant{}
+	if err := s.Client.SelectContext(ctx, &tenants, tenantSelect+" ORDER BY id"); err != nil {
+		return nil, fmt.Errorf("an error occurred listing tenants: %w", err)
+	}
+	return tenants, nil
+}
+
+func (s *SQLTenantStore) CreateTenant(ctx context.Context, t Tenant.Tenant) (Tenant.Tenant, error) {
+	res, err := s.Client.ExecContext(ctx, "INSERT INTO tenants (slug, name, created_on) VALUES (?, ?, ?)", t.Slug, t.Name, t.CreatedOn)
+	if err != nil {
+		return Tenant.Tenant{}, fmt.Errorf("failed to insert tenant: %w", err)
+	}
+	if t.ID, err = res.LastInsertId(); err != nil {
+		return Tenant.Tenant{}, fmt.Errorf("failed to read inserted tenant id: %w", err)
+	}
+	return t, nil
+}
+
+func (s *SQLTenantStore) UpdateTenant(ctx context.Context, t Tenant.Tenant) error {
+	_, err := s.Client.ExecContext(ctx, "UPDATE tenants SET slug = ?, name = ? WHERE id = ?", t.Slug, t.Name, t.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update tenant: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLTenantStore) DeleteTenant(ctx context.Context, id int64) error {
+	res, err := s.Client.ExecContext(
+		ctx,
+		`DELETE FROM tenants WHERE id = ?
+		AND NOT EXISTS (SELECT 1 FROM users WHERE tenant_id = ?)
+		AND NOT EXISTS (SELECT 1 FROM students WHERE tenant_id = ?)`,
+		id, id, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete tenant: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return Tenant.ErrTenantNotEmpty
+	}
+	return nil
+}
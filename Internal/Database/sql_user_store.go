@@ -3,8 +3,13 @@ package database
 import (
 	"Students-Final-Assignment/Internal/User"
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -12,20 +17,43 @@ type SQLUserStore struct {
 	Client *sqlx.DB
 }
 
+// errDuplicateEntry is MySQL's error number for a violated unique key.
+const errDuplicateEntry = 1062
+
+// usernameError maps a violation of the unique key on (tenant_id, username)
+// to ErrUsernameTaken, so a lost race between two requests for the same
+// name reads like the check the service did first.
+func usernameError(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == errDuplicateEntry {
+		return User.ErrUsernameTaken
+	}
+	return err
+}
+
+const userSelect = "SELECT uid, tenant_id, username, password, email, jwt_token, role, created_on, updated_on, email_verified_at, disabled_at FROM users"
+
 func NewUserStore(db *sqlx.DB) User.UserStore {
 	return &SQLUserStore{Client: db}
 }
 
 func (s *SQLUserStore) GetUserByUsername(ctx context.Context, username string) (User.User, error) {
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return User.User{}, err
+	}
 	var user User.User
-	err := s.Client.GetContext(
+	err = s.Client.GetContext(
 		ctx,
 		&user,
-		`SELECT uid, username, password, email, COALESCE(jwt_token, '') as jwt_token, created_on, updated_on
+		`SELECT uid, tenant_id, username, password, email, COALESCE(jwt_token, '') as jwt_token, role, created_on, updated_on, email_verified_at, disabled_at
 		FROM users 
-		WHERE username = ?`,
-		username,
+		WHERE username = ? AND tenant_id = ?`,
+		username, tid,
 	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return User.User{}, User.ErrUserNotFound
+	}
 	if err != nil {
 		return User.User{}, fmt.Errorf("an error occurred fetching user by username: %w", err)
 	}
@@ -33,8 +61,31 @@ func (s *SQLUserStore) GetUserByUsername(ctx context.Context, username string) (
 }
 
 func (s *SQLUserStore) GetUserByID(ctx context.Context, id int64) (User.User, error) {
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return User.User{}, err
+	}
 	var user User.User
-	err := s.Client.GetContext(ctx, &user, "SELECT uid, username, password, email, jwt_token FROM users WHERE uid = ?", id)
+	err = s.Client.GetContext(ctx, &user, userSelect+" WHERE uid = ? AND tenant_id = ?", id, tid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return User.User{}, User.ErrUserNotFound
+	}
+	if err != nil {
+		return User.User{}, err
+	}
+	return user, nil
+}
+
+func (s *SQLUserStore) GetUserByEmail(ctx context.Context, email string) (User.User, error) {
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return User.User{}, err
+	}
+	var user User.User
+	err = s.Client.GetContext(ctx, &user, userSelect+" WHERE email = ? AND tenant_id = ? ORDER BY uid LIMIT 1", email, tid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return User.User{}, User.ErrUserNotFound
+	}
 	if err != nil {
 		return User.User{}, err
 	}
@@ -42,17 +93,107 @@ func (s *SQLUserStore) GetUserByID(ctx context.Context, id int64) (User.User, er
 }
 
 func (s *SQLUserStore) CreateUser(ctx context.Context, user User.User) error {
-	_, err := s.Client.ExecContext(ctx, "INSERT INTO users (username, password, email) VALUES (?, ?, ?)", user.Username, user.Password, user.Email)
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = s.Client.ExecContext(ctx, "INSERT INTO users (tenant_id, username, password, email, role) VALUES (?, ?, ?, ?, ?)", tid, user.Username, user.Password, user.Email, user.Role)
+	return usernameError(err)
+}
+
+func (s *SQLUserStore) SetUserRole(ctx context.Context, id int64, role User.Role) error {
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = s.Client.ExecContext(ctx, "UPDATE users SET role = ? WHERE uid = ? AND tenant_id = ?", role, id, tid)
+	return err
+}
+
+func (s *SQLUserStore) SetEmailVerified(ctx context.Context, id int64, at time.Time) error {
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = s.Client.ExecContext(ctx, "UPDATE users SET email_verified_at = ? WHERE uid = ? AND tenant_id = ?", at, id, tid)
+	return err
+}
+
+func (s *SQLUserStore) ChangeEmail(ctx context.Context, id int64, email string) error {
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = s.Client.ExecContext(ctx, "UPDATE users SET email = ?, email_verified_at = NULL WHERE uid = ? AND tenant_id = ?", email, id, tid)
+	return err
+}
+
+func (s *SQLUserStore) RenameUser(ctx context.Context, id int64, username string) error {
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = s.Client.ExecContext(ctx, "UPDATE users SET username = ? WHERE uid = ? AND tenant_id = ?", username, id, tid)
+	return usernameError(err)
+}
+
+func (s *SQLUserStore) SetUserDisabled(ctx context.Context, id int64, at *time.Time) error {
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = s.Client.ExecContext(ctx, "UPDATE users SET disabled_at = ? WHERE uid = ? AND tenant_id = ?", at, id, tid)
 	return err
 }
 
+func (s *SQLUserStore) ListUsers(ctx context.Context, opts User.UserListOptions) (User.UserPage, error) {
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return User.UserPage{}, err
+	}
+	conditions, args := []string{"tenant_id = ?"}, []interface{}{tid}
+	if opts.Query != "" {
+		pattern := containsPattern(opts.Query)
+		conditions = append(conditions, "(username LIKE ? OR email LIKE ?)")
+		args = append(args, pattern, pattern)
+	}
+	if opts.Role != "" {
+		conditions = append(conditions, "role = ?")
+		args = append(args, opts.Role)
+	}
+	where := " WHERE " + strings.Join(conditions, " AND ")
+
+	page := User.UserPage{Users: []User.User{}, Limit: opts.Limit, Offset: opts.Offset}
+	if err := s.Client.GetContext(ctx, &page.Total, "SELECT COUNT(*) FROM users"+where, args...); err != nil {
+		return User.UserPage{}, fmt.Errorf("an error occurred counting users: %w", err)
+	}
+	err = s.Client.SelectContext(
+		ctx,
+		&page.Users,
+		userSelect+where+" ORDER BY uid LIMIT ? OFFSET ?",
+		append(args, opts.Limit, opts.Offset)...,
+	)
+	if err != nil {
+		return User.UserPage{}, fmt.Errorf("an error occurred listing users: %w", err)
+	}
+	return page, nil
+}
+
 func (s *SQLUserStore) UpdateUser(ctx context.Context, user User.User) error {
-	_, err := s.Client.ExecContext(ctx, "UPDATE users SET password = ?, email = ?, jwt_token = ? WHERE uid = ?", user.Password, user.Email, user.JWTToken, user.UID)
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = s.Client.ExecContext(ctx, "UPDATE users SET password = ?, email = ?, jwt_token = ? WHERE uid = ? AND tenant_id = ?", user.Password, user.Email, user.JWTToken, user.UID, tid)
 	return err
 }
 
 func (s *SQLUserStore) DeleteUser(ctx context.Context, id int64) error {
-	_, err := s.Client.ExecContext(ctx, "DELETE FROM users WHERE uid = ?", id)
+	tid, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = s.Client.ExecContext(ctx, "DELETE FROM users WHERE uid = ? AND tenant_id = ?", id, tid)
 	return err
 }
 
@@ -0,0 +1,27 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"Students-Final-Assignment/Internal/User"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestUsernameError(t *testing.T) {
+	duplicate := &mysql.MySQLError{Number: 1062, Message: "Duplicate entry '1-ann' for key 'uq_users_tenant_username'"}
+	if err := usernameError(duplicate); !errors.Is(err, User.ErrUsernameTaken) {
+		t.Errorf("duplicate entry: err = %v", err)
+	}
+	if err := usernameError(fmt.Errorf("exec: %w", duplicate)); !errors.Is(err, User.ErrUsernameTaken) {
+		t.Errorf("wrapped duplicate entry: err = %v", err)
+	}
+
+	for _, err := range []error{nil, &mysql.MySQLError{Number: 1406, Message: "Data too long"}, errors.New("connection refused")} {
+		if got := usernameError(err); got != err {
+			t.Errorf("usernameError(%v) = %v", err, got)
+		}
+	}
+}
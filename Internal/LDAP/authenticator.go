@@ -0,0 +1,211 @@
+package LDAP
+
+import (
+	"Students-Final-Assignment/Internal/User"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const defaultTimeout = 10 * time.Second
+
+// Config points the app at a directory. Users bind as the DN made from
+// BindDN, where {username} stands for the login name.
+type Config struct {
+	Enabled bool `json:"Enabled"`
+	// Name identifies the directory in linked identities, so it must stay
+	// the same when the server moves.
+	Name               string `json:"Name"`
+	URL                string `json:"URL"`
+	StartTLS           bool   `json:"StartTLS"`
+	InsecureSkipVerify bool   `json:"InsecureSkipVerify"`
+	TimeoutSeconds     int    `json:"TimeoutSeconds"`
+	// BindDN is a template such as "uid={username},ou=people,dc=example,dc=org"
+	// or, for Active Directory, "{username}@example.org".
+	BindDN string `json:"BindDN"`
+	// SearchBase and UsernameAttribute find the user's entry when BindDN
+	// is not a DN, such as an Active Directory principal name. Otherwise
+	// the entry is read at the bound DN.
+	SearchBase        string `json:"SearchBase"`
+	UsernameAttribute string `json:"UsernameAttribute"`
+	EmailAttribute    string `json:"EmailAttribute"`
+	GroupAttribute    string `json:"GroupAttribute"`
+
+	// TrustEmail vouches for the directory's email addresses: they count
+	// as verified, and a first bind may sign in to the local account with
+	// the same address. Leave it off unless the directory controls who
+	// gets which address.
+	TrustEmail bool `json:"TrustEmail"`
+	// AutoProvision creates a local user on a first successful bind.
+	AutoProvision bool `json:"AutoProvision"`
+	// GroupRoles maps groups to role names. A group is matched by its
+	// full DN or by the value of its first RDN, such as the cn, ignoring
+	// case.
+	GroupRoles  map[string]string `json:"GroupRoles"`
+	DefaultRole string            `json:"DefaultRole"`
+}
+
+func LoadConfig(configPath string) (Config, error) {
+	file, err := os.Open(configPath)
+	if err != nil {
+		return Config{}, fmt.Errorf("could not open LDAP config file: %w", err)
+	}
+	defer file.Close()
+
+	var config Config
+	if err := json.NewDecoder(file).Decode(&config); err != nil {
+		return Config{}, fmt.Errorf("could not decode LDAP config file: %w", err)
+	}
+	return config, nil
+}
+
+func (c Config) timeout() time.Duration {
+	if c.TimeoutSeconds <= 0 {
+		return defaultTimeout
+	}
+	return time.Duration(c.TimeoutSeconds) * time.Second
+}
+
+// Authenticator is a User.Authenticator that binds to a directory as the
+// user.
+type Authenticator struct {
+	config Config
+	policy User.ExternalLoginPolicy
+}
+
+func New(config Config) (*Authenticator, error) {
+	if config.URL == "" || !strings.Contains(config.BindDN, "{username}") {
+		return nil, errors.New("LDAP needs a URL and a BindDN containing {username}")
+	}
+	if config.Name == "" {
+		config.Name = "ldap"
+	}
+	if config.EmailAttribute == "" {
+		config.EmailAttribute = "mail"
+	}
+	if config.GroupAttribute == "" {
+		config.GroupAttribute = "memberOf"
+	}
+
+	policy := User.ExternalLoginPolicy{
+		AutoProvision: config.AutoProvision,
+		GroupRoles:    make(map[string]User.Role, len(config.GroupRoles)),
+		DefaultRole:   User.Role(config.DefaultRole),
+		LinkByEmail:   config.TrustEmail,
+	}
+	for group, role := range config.GroupRoles {
+		policy.GroupRoles[strings.ToLower(group)] = User.Role(role)
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return &Authenticator{config: config, policy: policy}, nil
+}
+
+func (a *Authenticator) LoginPolicy() User.ExternalLoginPolicy {
+	return a.policy
+}
+
+// Authenticate binds as the user and reads their email and groups. The
+// email only counts as verified with Config.TrustEmail.
+func (a *Authenticator) Authenticate(ctx context.Context, username, password string) (User.ExternalIdentity, error) {
+	// An empty password would make an unauthenticated bind, which most
+	// servers accept for any DN.
+	if username == "" || password == "" {
+		return User.ExternalIdentity{}, User.ErrInvalidCredentials
+	}
+
+	c, err := dial(ctx, a.config)
+	if err != nil {
+		return User.ExternalIdentity{}, fmt.Errorf("could not reach the directory: %w", err)
+	}
+	defer c.close()
+
+	bindDN := strings.ReplaceAll(a.config.BindDN, "{username}", EscapeDN(username))
+	if err := c.bind(bindDN, password); err != nil {
+		var re *ResultError
+		if errors.As(err, &re) && re.Code == resultInvalidCredentials {
+			return User.ExternalIdentity{}, User.ErrInvalidCredentials
+		}
+		return User.ExternalIdentity{}, fmt.Errorf("bind failed: %w", err)
+	}
+
+	req := searchRequest{
+		base:       bindDN,
+		scope:      scopeBaseObject,
+		attributes: []string{a.config.EmailAttribute, a.config.GroupAttribute},
+	}
+	if a.config.SearchBase != "" && a.config.UsernameAttribute != "" {
+		req.base, req.scope = a.config.SearchBase, scopeWholeSubtree
+		req.attribute, req.value = a.config.UsernameAttribute, username
+	}
+	entries, err := c.search(req)
+	if err != nil {
+		return User.ExternalIdentity{}, fmt.Errorf("could not read the user's entry: %w", err)
+	}
+	if len(entries) != 1 {
+		return User.ExternalIdentity{}, fmt.Errorf("expected one entry for %q, found %d", username, len(entries))
+	}
+	entry := entries[0]
+
+	var groups []string
+	for _, dn := range entry.Attributes[strings.ToLower(a.config.GroupAttribute)] {
+		groups = append(groups, strings.ToLower(dn))
+		if name := firstRDNValue(dn); name != "" {
+			groups = append(groups, strings.ToLower(name))
+		}
+	}
+	email := entry.first(a.config.EmailAttribute)
+	return User.ExternalIdentity{
+		Provider:      a.config.Name,
+		Subject:       strings.ToLower(entry.DN),
+		Email:         email,
+		EmailVerified: a.config.TrustEmail && email != "",
+		Username:      username,
+		Groups:        groups,
+	}, nil
+}
+
+// EscapeDN escapes a value for use in a distinguished name (RFC 4514
+// section 2.4), so a username cannot add RDNs of its own.
+func EscapeDN(value string) string {
+	var b strings.Builder
+	for i, r := range value {
+		switch {
+		case strings.ContainsRune(`,+"\<>;=`, r),
+			(i == 0 && (r == ' ' || r == '#')),
+			(i == len(value)-1 && r == ' '):
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == 0:
+			b.WriteString(`\00`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// firstRDNValue returns "staff" for "cn=staff,ou=groups,dc=example,dc=org".
+func firstRDNValue(dn string) string {
+	rdn := dn
+	for i := 0; i < len(dn); i++ {
+		if dn[i] == '\\' {
+			i++
+			continue
+		}
+		if dn[i] == ',' || dn[i] == '+' {
+			rdn = dn[:i]
+			break
+		}
+	}
+	_, value, ok := strings.Cut(rdn, "=")
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(value)
+}
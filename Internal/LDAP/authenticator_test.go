@@ -0,0 +1,254 @@
+package LDAP
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+
+	"Students-Final-Assignment/Internal/Mail"
+	"Students-Final-Assignment/Internal/Tenant"
+	"Students-Final-Assignment/Internal/User"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func init() {
+	log.SetOutput(io.Discard)
+}
+
+const (
+	peopleDN = "ou=people,dc=example,dc=org"
+	adminsDN = "cn=students-admins,ou=groups,dc=example,dc=org"
+)
+
+// newTestDirectory starts a MemoryServer with ann, a member of the admins
+// group, and returns a config pointing at it.
+func newTestDirectory(t *testing.T) (*MemoryServer, Config) {
+	t.Helper()
+	server := NewMemoryServer()
+	server.AddEntry("uid=ann,"+peopleDN, "ann-secret", map[string][]string{
+		"uid":      {"ann"},
+		"mail":     {"ann@example.org"},
+		"memberOf": {adminsDN, "cn=staff,ou=groups,dc=example,dc=org"},
+	})
+	url, err := server.Start("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+	return server, Config{
+		Name:          "campus",
+		URL:           url,
+		BindDN:        "uid={username}," + peopleDN,
+		AutoProvision: true,
+		GroupRoles:    map[string]string{"Students-Admins": "admin"},
+		DefaultRole:   "readonly",
+	}
+}
+
+// newTestUserService returns a user service on in-memory stores and a
+// context in the default tenant.
+func newTestUserService(t *testing.T) (*User.Service, context.Context) {
+	t.Helper()
+	keys, err := User.NewKeySet(User.KeysConfig{
+		SigningKeyID: "test",
+		Keys:         []User.KeyConfig{{KID: "test", Algorithm: "HS256", Secret: "a-test-secret-of-at-least-32-bytes"}},
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := User.NewService(User.NewMemoryUserStore(), User.NewMemorySessionStore(), keys)
+	s.MFA = User.NewMemoryMFAStore()
+	s.PasswordHistory = User.NewMemoryPasswordHistoryStore()
+	s.Mailer = Mail.NewMemoryMailer()
+	t.Cleanup(s.Wait)
+	return s, Tenant.ContextWithTenant(context.Background(), Tenant.DefaultTenantID)
+}
+
+func newTestAuthenticator(t *testing.T, config Config) *Authenticator {
+	t.Helper()
+	a, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestAuthenticateBindsAsTheUser(t *testing.T) {
+	_, config := newTestDirectory(t)
+	a := newTestAuthenticator(t, config)
+
+	id, err := a.Authenticate(context.Background(), "ann", "ann-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := User.ExternalIdentity{
+		Provider: "campus",
+		Subject:  "uid=ann," + peopleDN,
+		Email:    "ann@example.org",
+		Username: "ann",
+		Groups:   []string{adminsDN, "students-admins", "cn=staff,ou=groups,dc=example,dc=org", "staff"},
+	}
+	if !reflect.DeepEqual(id, want) {
+		t.Errorf("identity = %+v", id)
+	}
+
+	// Only a trusted directory vouches for the address.
+	config.TrustEmail = true
+	a = newTestAuthenticator(t, config)
+	if id, err := a.Authenticate(context.Background(), "ann", "ann-secret"); err != nil || !id.EmailVerified {
+		t.Errorf("trusted directory: %+v, %v", id, err)
+	}
+	if !a.LoginPolicy().LinkByEmail {
+		t.Error("trusted directory may not link by email")
+	}
+}
+
+func TestAuthenticateRejectsBadCredentials(t *testing.T) {
+	_, config := newTestDirectory(t)
+	a := newTestAuthenticator(t, config)
+
+	for _, tc := range []struct{ username, password string }{
+		{"ann", "wrong"},
+		{"nobody", "ann-secret"},
+		{"ANN", ""},
+		{"", "ann-secret"},
+	} {
+		if _, err := a.Authenticate(context.Background(), tc.username, tc.password); !errors.Is(err, User.ErrInvalidCredentials) {
+			t.Errorf("%q/%q: err = %v", tc.username, tc.password, err)
+		}
+	}
+}
+
+func TestAuthenticateRefusesEmptyPasswordsWithoutBinding(t *testing.T) {
+	// Nothing listens here, so any attempt to bind would fail differently.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	a := newTestAuthenticator(t, Config{URL: "ldap://" + addr, BindDN: "uid={username}," + peopleDN})
+
+	if _, err := a.Authenticate(context.Background(), "ann", ""); !errors.Is(err, User.ErrInvalidCredentials) {
+		t.Errorf("empty password: err = %v", err)
+	}
+	if _, err := a.Authenticate(context.Background(), "ann", "ann-secret"); err == nil || errors.Is(err, User.ErrInvalidCredentials) {
+		t.Errorf("unreachable directory: err = %v", err)
+	}
+}
+
+func TestAuthenticateEscapesTheUsername(t *testing.T) {
+	server, config := newTestDirectory(t)
+	// Binding as "mallory,ou=admins" must not reach this entry.
+	server.AddEntry("uid=mallory,ou=admins,"+peopleDN, "secret", map[string][]string{"memberOf": {adminsDN}})
+	a := newTestAuthenticator(t, config)
+
+	if _, err := a.Authenticate(context.Background(), "mallory,ou=admins", "secret"); !errors.Is(err, User.ErrInvalidCredentials) {
+		t.Errorf("injected RDN: err = %v", err)
+	}
+
+	server.AddEntry(`uid=o\,brien,`+peopleDN, "secret", map[string][]string{"mail": {"ob@example.org"}})
+	id, err := a.Authenticate(context.Background(), "o,brien", "secret")
+	if err != nil || id.Email != "ob@example.org" {
+		t.Errorf("escaped username: %+v, %v", id, err)
+	}
+}
+
+func TestEscapeDN(t *testing.T) {
+	for in, want := range map[string]string{
+		"ann":             "ann",
+		"a,b+c":           `a\,b\+c`,
+		`x"<y>;z=\`:       `x\"\<y\>\;z\=\\`,
+		" #lead":          `\ #lead`,
+		"#hash":           `\#hash`,
+		"trail ":          `trail\ `,
+		"nul\x00":         `nul\00`,
+		"mid dle#":        "mid dle#",
+		"ann,ou=admins,x": `ann\,ou\=admins\,x`,
+	} {
+		if got := EscapeDN(in); got != want {
+			t.Errorf("EscapeDN(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestAuthenticateSearchesForPrincipalNames(t *testing.T) {
+	server, config := newTestDirectory(t)
+	// Active Directory binds as user@domain and finds the entry by name.
+	server.AddEntry("ann@example.org", "ann-secret", nil)
+	config.BindDN = "{username}@example.org"
+	config.SearchBase = peopleDN
+	config.UsernameAttribute = "uid"
+	a := newTestAuthenticator(t, config)
+
+	id, err := a.Authenticate(context.Background(), "ann", "ann-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.Subject != "uid=ann,"+peopleDN || id.Email != "ann@example.org" {
+		t.Errorf("identity = %+v", id)
+	}
+}
+
+func TestNewValidatesTheGroupMapping(t *testing.T) {
+	for _, config := range []Config{
+		{URL: "ldap://localhost", BindDN: "cn=fixed"},
+		{BindDN: "uid={username}"},
+		{URL: "ldap://localhost", BindDN: "uid={username}", GroupRoles: map[string]string{"root": "superadmin"}},
+		{URL: "ldap://localhost", BindDN: "uid={username}", DefaultRole: "owner"},
+	} {
+		if _, err := New(config); err == nil {
+			t.Errorf("%+v was accepted", config)
+		}
+	}
+}
+
+func TestDirectoryLoginMapsGroupsToRoles(t *testing.T) {
+	_, config := newTestDirectory(t)
+	s, ctx := newTestUserService(t)
+	s.Authenticators = []User.Authenticator{newTestAuthenticator(t, config)}
+
+	if _, err := s.Login(ctx, "ann", "ann-secret", "192.0.2.1"); err != nil {
+		t.Fatal(err)
+	}
+	ann, err := s.GetUserByUsername(ctx, "ann")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ann.Role != User.RoleAdmin || ann.Email != "ann@example.org" || ann.EmailVerified() {
+		t.Errorf("provisioned = %+v", ann)
+	}
+}
+
+func TestUntrustedDirectoryDoesNotTakeOverAccounts(t *testing.T) {
+	_, config := newTestDirectory(t)
+	s, ctx := newTestUserService(t)
+	if err := s.Register(ctx, "ann-local", "Correct-Horse-Battery-9", "ann@example.org"); err != nil {
+		t.Fatal(err)
+	}
+	local, _ := s.GetUserByUsername(ctx, "ann-local")
+	if err := s.AssignRole(ctx, local.UID, User.RoleTeacher); err != nil {
+		t.Fatal(err)
+	}
+
+	s.Authenticators = []User.Authenticator{newTestAuthenticator(t, config)}
+	if _, err := s.Login(ctx, "ann", "ann-secret", "192.0.2.1"); !errors.Is(err, User.ErrIdentityConflict) {
+		t.Fatalf("untrusted directory: err = %v", err)
+	}
+	if local, _ = s.GetUserByUsername(ctx, "ann-local"); local.Role != User.RoleTeacher {
+		t.Errorf("local account changed: %+v", local)
+	}
+
+	config.TrustEmail = true
+	s.Authenticators = []User.Authenticator{newTestAuthenticator(t, config)}
+	if _, err := s.Login(ctx, "ann", "ann-secret", "192.0.2.1"); err != nil {
+		t.Fatal(err)
+	}
+	if local, _ = s.GetUserByUsername(ctx, "ann-local"); local.Role != User.RoleAdmin || !local.EmailVerified() {
+		t.Errorf("linked account = %+v", local)
+	}
+}
@@ -0,0 +1,201 @@
+package LDAP
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// The small part of BER (X.690) that LDAP messages use: definite lengths,
+// single-byte tags, integers, strings, booleans and constructed values.
+
+const (
+	classUniversal   = 0x00
+	classApplication = 0x40
+	classContext     = 0x80
+	constructed      = 0x20
+
+	tagBoolean     = 0x01
+	tagInteger     = 0x02
+	tagOctetString = 0x04
+	tagEnumerated  = 0x0a
+	tagSequence    = 0x30
+	tagSet         = 0x31
+
+	// maxPacketSize bounds what a server can make us allocate.
+	maxPacketSize = 1 << 20
+)
+
+var errMalformed = errors.New("malformed LDAP packet")
+
+type packet struct {
+	tag      byte
+	value    []byte
+	children []*packet
+}
+
+func (p *packet) constructed() bool {
+	return p.tag&constructed != 0
+}
+
+func seq(tag byte, children ...*packet) *packet {
+	return &packet{tag: tag, children: children}
+}
+
+func octets(tag byte, s string) *packet {
+	return &packet{tag: tag, value: []byte(s)}
+}
+
+func integer(tag byte, n int64) *packet {
+	var b []byte
+	for {
+		b = append([]byte{byte(n)}, b...)
+		n >>= 8
+		if (n == 0 && b[0]&0x80 == 0) || (n == -1 && b[0]&0x80 != 0) {
+			break
+		}
+	}
+	return &packet{tag: tag, value: b}
+}
+
+func boolean(b bool) *packet {
+	if b {
+		return &packet{tag: tagBoolean, value: []byte{0xff}}
+	}
+	return &packet{tag: tagBoolean, value: []byte{0x00}}
+}
+
+func (p *packet) bytes() []byte {
+	content := p.value
+	if p.constructed() {
+		content = nil
+		for _, c := range p.children {
+			content = append(content, c.bytes()...)
+		}
+	}
+	out := []byte{p.tag}
+	out = append(out, encodeLength(len(content))...)
+	return append(out, content...)
+}
+
+func encodeLength(n int) []byte {
+	if n < 0x80 {
+		return []byte{byte(n)}
+	}
+	var b []byte
+	for ; n > 0; n >>= 8 {
+		b = append([]byte{byte(n)}, b...)
+	}
+	return append([]byte{0x80 | byte(len(b))}, b...)
+}
+
+func (p *packet) int() (int64, error) {
+	if len(p.value) == 0 || len(p.value) > 8 {
+		return 0, errMalformed
+	}
+	n := int64(int8(p.value[0]))
+	for _, b := range p.value[1:] {
+		n = n<<8 | int64(b)
+	}
+	return n, nil
+}
+
+func (p *packet) str() string {
+	return string(p.value)
+}
+
+// child returns the i-th child, or an error for a packet too short to be
+// the message it claims to be.
+func (p *packet) child(i int) (*packet, error) {
+	if i >= len(p.children) {
+		return nil, errMalformed
+	}
+	return p.children[i], nil
+}
+
+// readPacket reads one complete message from the connection.
+func readPacket(r *bufio.Reader) (*packet, error) {
+	tag, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	n, err := readLength(r)
+	if err != nil {
+		return nil, err
+	}
+	content := make([]byte, n)
+	if _, err := io.ReadFull(r, content); err != nil {
+		return nil, err
+	}
+	return decode(tag, content)
+}
+
+func readLength(r io.ByteReader) (int, error) {
+	first, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if first < 0x80 {
+		return int(first), nil
+	}
+	size := int(first & 0x7f)
+	if size == 0 || size > 3 {
+		return 0, fmt.Errorf("%w: unsupported length", errMalformed)
+	}
+	n := 0
+	for i := 0; i < size; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		n = n<<8 | int(b)
+	}
+	if n > maxPacketSize {
+		return 0, fmt.Errorf("%w: packet too large", errMalformed)
+	}
+	return n, nil
+}
+
+func decode(tag byte, content []byte) (*packet, error) {
+	p := &packet{tag: tag}
+	if tag&constructed == 0 {
+		p.value = content
+		return p, nil
+	}
+	for len(content) > 0 {
+		if len(content) < 2 {
+			return nil, errMalformed
+		}
+		childTag := content[0]
+		r := &sliceReader{b: content[1:]}
+		n, err := readLength(r)
+		if err != nil {
+			return nil, err
+		}
+		rest := r.b
+		if n > len(rest) {
+			return nil, errMalformed
+		}
+		child, err := decode(childTag, rest[:n])
+		if err != nil {
+			return nil, err
+		}
+		p.children = append(p.children, child)
+		content = rest[n:]
+	}
+	return p, nil
+}
+
+type sliceReader struct {
+	b []byte
+}
+
+func (r *sliceReader) ReadByte() (byte, error) {
+	if len(r.b) == 0 {
+		return 0, errMalformed
+	}
+	b := r.b[0]
+	r.b = r.b[1:]
+	return b, nil
+}
@@ -0,0 +1,290 @@
+package LDAP
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// LDAPv3 protocol operations (RFC 4511 section 4.2 onwards).
+const (
+	opBindRequest            = classApplication | constructed | 0
+	opBindResponse           = classApplication | constructed | 1
+	opUnbindRequest          = classApplication | 2
+	opSearchRequest          = classApplication | constructed | 3
+	opSearchResultEntry      = classApplication | constructed | 4
+	opSearchResultDone       = classApplication | constructed | 5
+	opSearchResultRef        = classApplication | constructed | 19
+	opExtendedRequest        = classApplication | constructed | 23
+	opExtendedResponse       = classApplication | constructed | 24
+	authSimple               = classContext | 0
+	filterEqualityMatch      = classContext | constructed | 3
+	filterPresent            = classContext | 7
+	extendedRequestName      = classContext | 0
+	startTLSOID              = "1.3.6.1.4.1.1466.20037"
+	scopeBaseObject          = 0
+	scopeWholeSubtree        = 2
+	derefNever               = 0
+	resultSuccess            = 0
+	resultNoSuchObject       = 32
+	resultInvalidCredentials = 49
+)
+
+// ResultError is a non-success result code from the server.
+type ResultError struct {
+	Code    int64
+	Message string
+}
+
+func (e *ResultError) Error() string {
+	return fmt.Sprintf("LDAP result %d: %s", e.Code, e.Message)
+}
+
+// Entry is one search result. Attribute names are lower case.
+type Entry struct {
+	DN         string
+	Attributes map[string][]string
+}
+
+func (e Entry) first(attribute string) string {
+	if values := e.Attributes[strings.ToLower(attribute)]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+type conn struct {
+	c      net.Conn
+	r      *bufio.Reader
+	nextID int64
+}
+
+// dial connects to an ldap:// or ldaps:// URL, upgrading ldap:// with
+// StartTLS when asked to.
+func dial(ctx context.Context, config Config) (*conn, error) {
+	u, err := url.Parse(config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LDAP URL: %w", err)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		port := "389"
+		if u.Scheme == "ldaps" {
+			port = "636"
+		}
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+	tlsConfig := &tls.Config{ServerName: u.Hostname(), InsecureSkipVerify: config.InsecureSkipVerify}
+
+	dialer := &net.Dialer{Timeout: config.timeout()}
+	var c net.Conn
+	switch u.Scheme {
+	case "ldap":
+		c, err = dialer.DialContext(ctx, "tcp", host)
+	case "ldaps":
+		c, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", host)
+	default:
+		return nil, fmt.Errorf("unsupported LDAP URL scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(config.timeout())
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	c.SetDeadline(deadline)
+
+	lc := &conn{c: c, r: bufio.NewReader(c)}
+	if u.Scheme == "ldap" && config.StartTLS {
+		if err := lc.startTLS(tlsConfig, deadline); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return lc, nil
+}
+
+func (c *conn) send(op *packet) (int64, error) {
+	c.nextID++
+	msg := seq(tagSequence, integer(tagInteger, c.nextID), op)
+	_, err := c.c.Write(msg.bytes())
+	return c.nextID, err
+}
+
+// receive returns the protocol operation of the next message for id.
+func (c *conn) receive(id int64) (*packet, error) {
+	for {
+		msg, err := readPacket(c.r)
+		if err != nil {
+			return nil, err
+		}
+		msgID, err := msg.child(0)
+		if err != nil {
+			return nil, err
+		}
+		n, err := msgID.int()
+		if err != nil {
+			return nil, err
+		}
+		if n != id {
+			// Unsolicited notifications and stray replies are skipped.
+			continue
+		}
+		return msg.child(1)
+	}
+}
+
+func result(op *packet) error {
+	code, err := op.child(0)
+	if err != nil {
+		return err
+	}
+	n, err := code.int()
+	if err != nil {
+		return err
+	}
+	if n == resultSuccess {
+		return nil
+	}
+	message := ""
+	if diag, err := op.child(2); err == nil {
+		message = diag.str()
+	}
+	return &ResultError{Code: n, Message: message}
+}
+
+func (c *conn) startTLS(config *tls.Config, deadline time.Time) error {
+	id, err := c.send(seq(opExtendedRequest, octets(extendedRequestName, startTLSOID)))
+	if err != nil {
+		return err
+	}
+	op, err := c.receive(id)
+	if err != nil {
+		return err
+	}
+	if op.tag != opExtendedResponse {
+		return errMalformed
+	}
+	if err := result(op); err != nil {
+		return fmt.Errorf("StartTLS refused: %w", err)
+	}
+	tc := tls.Client(c.c, config)
+	tc.SetDeadline(deadline)
+	if err := tc.Handshake(); err != nil {
+		return err
+	}
+	c.c, c.r = tc, bufio.NewReader(tc)
+	return nil
+}
+
+func (c *conn) bind(dn, password string) error {
+	id, err := c.send(seq(opBindRequest,
+		integer(tagInteger, 3),
+		octets(tagOctetString, dn),
+		octets(authSimple, password),
+	))
+	if err != nil {
+		return err
+	}
+	op, err := c.receive(id)
+	if err != nil {
+		return err
+	}
+	if op.tag != opBindResponse {
+		return errMalformed
+	}
+	return result(op)
+}
+
+type searchRequest struct {
+	base       string
+	scope      int64
+	attribute  string // matched with value; presence of objectClass if empty
+	value      string
+	attributes []string
+}
+
+func (c *conn) search(req searchRequest) ([]Entry, error) {
+	filter := octets(filterPresent, "objectClass")
+	if req.attribute != "" {
+		filter = seq(filterEqualityMatch, octets(tagOctetString, req.attribute), octets(tagOctetString, req.value))
+	}
+	attributes := seq(tagSequence)
+	for _, a := range req.attributes {
+		attributes.children = append(attributes.children, octets(tagOctetString, a))
+	}
+	id, err := c.send(seq(opSearchRequest,
+		octets(tagOctetString, req.base),
+		integer(tagEnumerated, req.scope),
+		integer(tagEnumerated, derefNever),
+		integer(tagInteger, 2), // size limit: one match is all we want
+		integer(tagInteger, 0),
+		boolean(false),
+		filter,
+		attributes,
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for {
+		op, err := c.receive(id)
+		if err != nil {
+			return nil, err
+		}
+		switch op.tag {
+		case opSearchResultEntry:
+			entry, err := parseEntry(op)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		case opSearchResultRef:
+			// Referrals to other servers are not followed.
+		case opSearchResultDone:
+			return entries, result(op)
+		default:
+			return nil, errMalformed
+		}
+	}
+}
+
+func parseEntry(op *packet) (Entry, error) {
+	dn, err := op.child(0)
+	if err != nil {
+		return Entry{}, err
+	}
+	attrs, err := op.child(1)
+	if err != nil {
+		return Entry{}, err
+	}
+	entry := Entry{DN: dn.str(), Attributes: make(map[string][]string)}
+	for _, attr := range attrs.children {
+		name, err := attr.child(0)
+		if err != nil {
+			return Entry{}, err
+		}
+		values, err := attr.child(1)
+		if err != nil {
+			return Entry{}, err
+		}
+		key := strings.ToLower(name.str())
+		for _, v := range values.children {
+			entry.Attributes[key] = append(entry.Attributes[key], v.str())
+		}
+	}
+	return entry, nil
+}
+
+func (c *conn) close() {
+	c.send(&packet{tag: opUnbindRequest})
+	c.c.Close()
+}
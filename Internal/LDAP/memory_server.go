@@ -0,0 +1,213 @@
+package LDAP
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+)
+
+const (
+	resultProtocolError            = 2
+	resultInsufficientAccessRights = 50
+	resultUnwillingToPerform       = 53
+)
+
+type memoryEntry struct {
+	dn         string
+	password   string
+	attributes map[string][]string
+}
+
+// MemoryServer is an in-process directory for trying the authenticator
+// without a real server. It answers simple binds and the searches the
+// Authenticator makes, over plain ldap:// only.
+type MemoryServer struct {
+	mu       sync.Mutex
+	entries  map[string]memoryEntry
+	listener net.Listener
+}
+
+func NewMemoryServer() *MemoryServer {
+	return &MemoryServer{entries: make(map[string]memoryEntry)}
+}
+
+// AddEntry adds or replaces the entry at dn. An empty password means the
+// entry cannot be bound to.
+func (s *MemoryServer) AddEntry(dn, password string, attributes map[string][]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attrs := make(map[string][]string, len(attributes))
+	for k, v := range attributes {
+		attrs[strings.ToLower(k)] = v
+	}
+	s.entries[strings.ToLower(dn)] = memoryEntry{dn: dn, password: password, attributes: attrs}
+}
+
+// Start listens on addr, such as "127.0.0.1:0", and returns the URL to put
+// in Config.URL.
+func (s *MemoryServer) Start(addr string) (string, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", err
+	}
+	s.listener = l
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+	return "ldap://" + l.Addr().String(), nil
+}
+
+func (s *MemoryServer) Close() error {
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+func ldapResult(op byte, code int64, message string) *packet {
+	return seq(op, integer(tagEnumerated, code), octets(tagOctetString, ""), octets(tagOctetString, message))
+}
+
+func (s *MemoryServer) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	bound := ""
+
+	reply := func(id int64, op *packet) bool {
+		_, err := c.Write(seq(tagSequence, integer(tagInteger, id), op).bytes())
+		return err == nil
+	}
+	for {
+		msg, err := readPacket(r)
+		if err != nil || len(msg.children) < 2 {
+			return
+		}
+		id, err := msg.children[0].int()
+		if err != nil {
+			return
+		}
+		op := msg.children[1]
+
+		switch op.tag {
+		case opBindRequest:
+			if len(op.children) < 3 {
+				return
+			}
+			code, message := s.bind(op.children[1].str(), op.children[2].str())
+			if code == resultSuccess {
+				bound = op.children[1].str()
+			}
+			if !reply(id, ldapResult(opBindResponse, code, message)) {
+				return
+			}
+		case opSearchRequest:
+			if bound == "" {
+				if !reply(id, ldapResult(opSearchResultDone, resultInsufficientAccessRights, "bind first")) {
+					return
+				}
+				continue
+			}
+			entries, code := s.search(op)
+			for _, e := range entries {
+				if !reply(id, e) {
+					return
+				}
+			}
+			if !reply(id, ldapResult(opSearchResultDone, code, "")) {
+				return
+			}
+		case opExtendedRequest:
+			if !reply(id, ldapResult(opExtendedResponse, resultProtocolError, "extended operations are not supported")) {
+				return
+			}
+		case opUnbindRequest:
+			return
+		default:
+			if !reply(id, ldapResult(opSearchResultDone, resultUnwillingToPerform, "unsupported operation")) {
+				return
+			}
+		}
+	}
+}
+
+func (s *MemoryServer) bind(dn, password string) (int64, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[strings.ToLower(dn)]
+	if !ok || e.password == "" || e.password != password {
+		return resultInvalidCredentials, "invalid credentials"
+	}
+	return resultSuccess, ""
+}
+
+func (s *MemoryServer) search(op *packet) ([]*packet, int64) {
+	if len(op.children) < 8 {
+		return nil, resultProtocolError
+	}
+	base := strings.ToLower(op.children[0].str())
+	scope, _ := op.children[1].int()
+	filter := op.children[6]
+	var wanted []string
+	for _, a := range op.children[7].children {
+		wanted = append(wanted, strings.ToLower(a.str()))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if scope == scopeBaseObject {
+		e, ok := s.entries[base]
+		if !ok {
+			return nil, resultNoSuchObject
+		}
+		return []*packet{e.packet(wanted)}, resultSuccess
+	}
+
+	var found []*packet
+	for dn, e := range s.entries {
+		if (dn == base || strings.HasSuffix(dn, ","+base)) && e.matches(filter) {
+			found = append(found, e.packet(wanted))
+		}
+	}
+	return found, resultSuccess
+}
+
+// matches understands the equality and presence filters the client sends.
+func (e memoryEntry) matches(filter *packet) bool {
+	switch filter.tag {
+	case filterPresent:
+		return strings.EqualFold(filter.str(), "objectClass") || len(e.attributes[strings.ToLower(filter.str())]) > 0
+	case filterEqualityMatch:
+		if len(filter.children) < 2 {
+			return false
+		}
+		for _, v := range e.attributes[strings.ToLower(filter.children[0].str())] {
+			if strings.EqualFold(v, filter.children[1].str()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (e memoryEntry) packet(wanted []string) *packet {
+	attrs := seq(tagSequence)
+	for _, name := range wanted {
+		values, ok := e.attributes[name]
+		if !ok {
+			continue
+		}
+		set := seq(tagSet)
+		for _, v := range values {
+			set.children = append(set.children, octets(tagOctetString, v))
+		}
+		attrs.children = append(attrs.children, seq(tagSequence, octets(tagOctetString, name), set))
+	}
+	return seq(opSearchResultEntry, octets(tagOctetString, e.dn), attrs)
+}
@@ -0,0 +1,37 @@
+package Mail
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileMailer writes every message to its own .eml file instead of sending
+// it, for development without a mail server.
+type FileMailer struct {
+	Dir  string
+	From string
+
+	mu sync.Mutex
+	n  int
+}
+
+func NewFileMailer(dir, from string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("could not create mail directory: %w", err)
+	}
+	return &FileMailer{Dir: dir, From: from}, nil
+}
+
+func (m *FileMailer) Send(msg Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.n++
+	name := fmt.Sprintf("%s-%d.eml", time.Now().Format("20060102T150405.000000000"), m.n)
+	m.mu.Unlock()
+	return os.WriteFile(filepath.Join(m.Dir, name), format(m.From, msg), 0o600)
+}
@@ -0,0 +1,71 @@
+package Mail
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var ErrInvalidMessage = errors.New("invalid mail message")
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers plain text mail.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// Config picks and configures a Mailer. Driver is "smtp", "file" or
+// "memory". BaseURL is the public address links in mails point to.
+type Config struct {
+	Driver   string `json:"Driver"`
+	Host     string `json:"Host"`
+	Port     string `json:"Port"`
+	Username string `json:"Username"`
+	Password string `json:"Password"`
+	From     string `json:"From"`
+	Dir      string `json:"Dir"`
+	BaseURL  string `json:"BaseURL"`
+}
+
+func LoadConfig(configPath string) (Config, error) {
+	file, err := os.Open(configPath)
+	if err != nil {
+		return Config{}, fmt.Errorf("could not open mail config file: %w", err)
+	}
+	defer file.Close()
+
+	var config Config
+	if err := json.NewDecoder(file).Decode(&config); err != nil {
+		return Config{}, fmt.Errorf("could not decode mail config file: %w", err)
+	}
+	return config, nil
+}
+
+func New(config Config) (Mailer, error) {
+	switch config.Driver {
+	case "smtp":
+		return NewSMTPMailer(config), nil
+	case "file":
+		return NewFileMailer(config.Dir, config.From)
+	case "memory":
+		return NewMemoryMailer(), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", config.Driver)
+	}
+}
+
+// validate rejects line breaks in header values, which would let a caller
+// add headers of their own.
+func (m Message) validate() error {
+	if m.To == "" || strings.ContainsAny(m.To+m.Subject, "\r\n") {
+		return ErrInvalidMessage
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package OIDC
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	ErrDiscovery      = errors.New("could not discover the OIDC provider")
+	ErrCodeExchange   = errors.New("could not exchange the authorization code")
+	ErrInvalidIDToken = errors.New("invalid ID token")
+)
+
+// Config points the app at an identity provider. Any issuer that serves
+// /.well-known/openid-configuration works, including a local mock.
+type Config struct {
+	Enabled      bool     `json:"Enabled"`
+	IssuerURL    string   `json:"IssuerURL"`
+	ClientID     string   `json:"ClientID"`
+	ClientSecret string   `json:"ClientSecret"`
+	RedirectURL  string   `json:"RedirectURL"`
+	Scopes       []string `json:"Scopes"`
+	// GroupsClaim names the ID token claim listing the user's groups.
+	GroupsClaim string `json:"GroupsClaim"`
+
+	// AutoProvision creates local accounts for unknown users.
+	AutoProvision bool `json:"AutoProvision"`
+	// GroupRoles maps provider groups to local role names.
+	GroupRoles map[string]string `json:"GroupRoles"`
+	// DefaultRole is given to provisioned users in no mapped group.
+	DefaultRole string `json:"DefaultRole"`
+	// LinkByEmail lets a first login sign in to the local account with
+	// the same verified email. Only set it if the provider verifies
+	// addresses.
+	LinkByEmail bool `json:"LinkByEmail"`
+}
+
+func LoadConfig(configPath string) (Config, error) {
+	file, err := os.Open(configPath)
+	if err != nil {
+		return Config{}, fmt.Errorf("could not open OIDC config file: %w", err)
+	}
+	defer file.Close()
+
+	var config Config
+	if err := json.NewDecoder(file).Decode(&config); err != nil {
+		return Config{}, fmt.Errorf("could not decode OIDC config file: %w", err)
+	}
+	return config, nil
+}
+
+// discovery is the part of the provider metadata the login flow needs.
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type Provider struct {
+	config Config
+	meta   discovery
+	client *http.Client
+	keys   *remoteKeySet
+}
+
+// NewProvider fetches the issuer's metadata. The issuer it reports must be
+// the configured one, as OpenID Connect Discovery requires.
+func NewProvider(ctx context.Context, config Config) (*Provider, error) {
+	if config.GroupsClaim == "" {
+		config.GroupsClaim = "groups"
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	wellKnown := strings.TrimRight(config.IssuerURL, "/") + "/.well-known/openid-configuration"
+	var meta discovery
+	if err := getJSON(ctx, client, wellKnown, &meta); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrDiscovery, err.Error())
+	}
+	if strings.TrimRight(meta.Issuer, "/") != strings.TrimRight(config.IssuerURL, "/") {
+		return nil, fmt.Errorf("%w: issuer %q does not match %q", ErrDiscovery, meta.Issuer, config.IssuerURL)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: metadata is missing endpoints", ErrDiscovery)
+	}
+
+	return &Provider{
+		config: config,
+		meta:   meta,
+		client: client,
+		keys:   &remoteKeySet{uri: meta.JWKSURI, client: client},
+	}, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, uri string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s answered %s", uri, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// LoginState is what the app has to remember between sending the browser
+// to the provider and the callback.
+type LoginState struct {
+	State    string
+	Nonce    string
+	Verifier string
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func NewLoginState() (LoginState, error) {
+	var ls LoginState
+	var err error
+	if ls.State, err = randomString(); err != nil {
+		return LoginState{}, err
+	}
+	if ls.Nonce, err = randomString(); err != nil {
+		return LoginState{}, err
+	}
+	if ls.Verifier, err = randomString(); err != nil {
+		return LoginState{}, err
+	}
+	return ls, nil
+}
+
+// AuthCodeURL is where the browser goes to sign in. The PKCE challenge is
+// the S256 hash of the verifier, which never leaves the app.
+func (p *Provider) AuthCodeURL(ls LoginState) string {
+	challenge := sha256.Sum256([]byte(ls.Verifier))
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.config.ClientID)
+	q.Set("redirect_uri", p.config.RedirectURL)
+	q.Set("scope", strings.Join(p.config.Scopes, " "))
+	q.Set("state", ls.State)
+	q.Set("nonce", ls.Nonce)
+	q.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	q.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(p.meta.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return p.meta.AuthorizationEndpoint + sep + q.Encode()
+}
+
+// Identity is what the app learns about the user from the ID token.
+type Identity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Username      string
+	Groups        []string
+}
+
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Exchange trades the callback's code for tokens and returns the verified
+// identity from the ID token.
+func (p *Provider) Exchange(ctx context.Context, code string, ls LoginState) (Identity, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("client_id", p.config.ClientID)
+	form.Set("code_verifier", ls.Verifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Identity{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return Identity{}, fmt.Errorf("%w: %s", ErrCodeExchange, err.Error())
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return Identity{}, fmt.Errorf("%w: %s", ErrCodeExchange, err.Error())
+	}
+	var tokens tokenResponse
+	if err := json.Unmarshal(body, &tokens); err != nil {
+		return Identity{}, fmt.Errorf("%w: %s", ErrCodeExchange, err.Error())
+	}
+	if resp.StatusCode != http.StatusOK || tokens.Error != "" {
+		return Identity{}, fmt.Errorf("%w: %s", ErrCodeExchange, strings.TrimSpace(tokens.Error+" "+tokens.ErrorDescription))
+	}
+	if tokens.IDToken == "" {
+		return Identity{}, fmt.Errorf("%w: no id_token in the response", ErrCodeExchange)
+	}
+	return p.verify(ctx, tokens.IDToken, ls.Nonce)
+}
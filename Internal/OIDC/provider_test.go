@@ -0,0 +1,366 @@
+package OIDC
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const testClientID = "students"
+
+// mockIdP is an identity provider serving discovery, a JWKS and a token
+// endpoint that answers with whatever ID token the test set last.
+type mockIdP struct {
+	t      *testing.T
+	server *httptest.Server
+
+	mu         sync.Mutex
+	keys       map[string]interface{}
+	published  []string
+	jwksHits   int
+	challenge  string
+	idToken    string
+	lastSecret string
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	m := &mockIdP{t: t, keys: map[string]interface{}{}}
+	mux := http.NewServeMux()
+	serveDiscovery := func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(discovery{
+			Issuer:                m.server.URL,
+			AuthorizationEndpoint: m.server.URL + "/authorize",
+			TokenEndpoint:         m.server.URL + "/token",
+			JWKSURI:               m.server.URL + "/jwks",
+		})
+	}
+	mux.HandleFunc("/.well-known/openid-configuration", serveDiscovery)
+	// Another issuer's path that reports this one as the issuer.
+	mux.HandleFunc("/other/.well-known/openid-configuration", serveDiscovery)
+	mux.HandleFunc("/jwks", m.serveJWKS)
+	mux.HandleFunc("/token", m.serveToken)
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+func encodeInt(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+// addKey creates a signing key and, if publish is set, lists it in the JWKS.
+func (m *mockIdP) addKey(kid string, ec, publish bool) {
+	var key interface{}
+	var err error
+	if ec {
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	} else {
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	}
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys[kid] = key
+	if publish {
+		m.published = append(m.published, kid)
+	}
+}
+
+func (m *mockIdP) publish(kid string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.published = append(m.published, kid)
+}
+
+func (m *mockIdP) serveJWKS(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jwksHits++
+	set := struct {
+		Keys []jwk `json:"keys"`
+	}{}
+	for _, kid := range m.published {
+		switch key := m.keys[kid].(type) {
+		case *rsa.PrivateKey:
+			set.Keys = append(set.Keys, jwk{Kty: "RSA", Kid: kid, Use: "sig", N: encodeInt(key.N), E: encodeInt(big.NewInt(int64(key.E)))})
+		case *ecdsa.PrivateKey:
+			set.Keys = append(set.Keys, jwk{Kty: "EC", Kid: kid, Crv: "P-256", X: encodeInt(key.X), Y: encodeInt(key.Y)})
+		}
+	}
+	// An encryption key and an unknown key type are skipped, not fatal.
+	set.Keys = append(set.Keys, jwk{Kty: "RSA", Kid: "enc", Use: "enc"}, jwk{Kty: "OKP", Kid: "ed"})
+	json.NewEncoder(w).Encode(set)
+}
+
+// serveToken checks the PKCE verifier against the challenge of the last
+// AuthCodeURL before handing out the ID token.
+func (m *mockIdP) serveToken(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if r.PostForm.Get("code") != "good-code" || base64.RawURLEncoding.EncodeToString(sum[:]) != m.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(tokenResponse{Error: "invalid_grant"})
+		return
+	}
+	_, m.lastSecret, _ = r.BasicAuth()
+	json.NewEncoder(w).Encode(tokenResponse{IDToken: m.idToken})
+}
+
+// sign makes an ID token with kid in its header.
+func (m *mockIdP) sign(method jwt.SigningMethod, kid string, claims jwt.MapClaims) string {
+	m.t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	m.mu.Lock()
+	key := m.keys[kid]
+	m.mu.Unlock()
+	if method == jwt.SigningMethodNone {
+		key = jwt.UnsafeAllowNoneSignatureType
+	} else if method == jwt.SigningMethodHS256 {
+		key = []byte("a-shared-secret-the-idp-never-had")
+	}
+	raw, err := token.SignedString(key)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	return raw
+}
+
+// claims are valid ID token claims for nonce.
+func (m *mockIdP) claims(nonce string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":                m.server.URL,
+		"sub":                "idp-user-1",
+		"aud":                testClientID,
+		"exp":                time.Now().Add(time.Minute).Unix(),
+		"iat":                time.Now().Unix(),
+		"nonce":              nonce,
+		"email":              "ann@example.org",
+		"email_verified":     "true",
+		"preferred_username": "ann",
+		"groups":             []string{"students-teachers", "staff"},
+	}
+}
+
+// login runs the browser part of the flow and exchanges the code for
+// idToken.
+func (m *mockIdP) login(p *Provider, ls LoginState, idToken string) (Identity, error) {
+	m.t.Helper()
+	authURL, err := url.Parse(p.AuthCodeURL(ls))
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	m.mu.Lock()
+	m.challenge = authURL.Query().Get("code_challenge")
+	m.idToken = idToken
+	m.mu.Unlock()
+	return p.Exchange(context.Background(), "good-code", ls)
+}
+
+func newTestProvider(t *testing.T, m *mockIdP) *Provider {
+	t.Helper()
+	p, err := NewProvider(context.Background(), Config{
+		IssuerURL:    m.server.URL + "/",
+		ClientID:     testClientID,
+		ClientSecret: "client secret",
+		RedirectURL:  "http://localhost:8080/api/v1/oidc/callback",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func newTestLoginState(t *testing.T) LoginState {
+	t.Helper()
+	ls, err := NewLoginState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ls
+}
+
+func TestExchangeReturnsTheVerifiedIdentity(t *testing.T) {
+	m := newMockIdP(t)
+	m.addKey("rsa", false, true)
+	m.addKey("ec", true, true)
+	p := newTestProvider(t, m)
+
+	authURL, _ := url.Parse(p.AuthCodeURL(newTestLoginState(t)))
+	q := authURL.Query()
+	if authURL.Path != "/authorize" || q.Get("client_id") != testClientID || q.Get("code_challenge_method") != "S256" || q.Get("scope") != "openid email profile" {
+		t.Errorf("auth URL = %s", authURL)
+	}
+
+	want := Identity{
+		Issuer:        m.server.URL,
+		Subject:       "idp-user-1",
+		Email:         "ann@example.org",
+		EmailVerified: true,
+		Username:      "ann",
+		Groups:        []string{"students-teachers", "staff"},
+	}
+	for _, tc := range []struct {
+		method jwt.SigningMethod
+		kid    string
+	}{{jwt.SigningMethodRS256, "rsa"}, {jwt.SigningMethodES256, "ec"}} {
+		ls := newTestLoginState(t)
+		claims := m.claims(ls.Nonce)
+		claims["aud"] = []string{"someone-else", testClientID}
+		id, err := m.login(p, ls, m.sign(tc.method, tc.kid, claims))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.kid, err)
+		}
+		if !reflect.DeepEqual(id, want) {
+			t.Errorf("%s: identity = %+v", tc.kid, id)
+		}
+	}
+	if m.lastSecret != url.QueryEscape("client secret") {
+		t.Errorf("client secret sent as %q", m.lastSecret)
+	}
+	if m.jwksHits != 1 {
+		t.Errorf("JWKS fetched %d times, want 1", m.jwksHits)
+	}
+}
+
+func TestExchangeRejectsBadIDTokens(t *testing.T) {
+	m := newMockIdP(t)
+	m.addKey("rsa", false, true)
+	m.addKey("unpublished", false, false)
+	p := newTestProvider(t, m)
+
+	for name, tamper := range map[string]func(c jwt.MapClaims) (jwt.SigningMethod, string){
+		"wrong nonce": func(c jwt.MapClaims) (jwt.SigningMethod, string) {
+			c["nonce"] = "replayed"
+			return jwt.SigningMethodRS256, "rsa"
+		},
+		"no nonce": func(c jwt.MapClaims) (jwt.SigningMethod, string) {
+			delete(c, "nonce")
+			return jwt.SigningMethodRS256, "rsa"
+		},
+		"wrong audience": func(c jwt.MapClaims) (jwt.SigningMethod, string) {
+			c["aud"] = "someone-else"
+			return jwt.SigningMethodRS256, "rsa"
+		},
+		"audience list": func(c jwt.MapClaims) (jwt.SigningMethod, string) {
+			c["aud"] = []string{"someone-else"}
+			return jwt.SigningMethodRS256, "rsa"
+		},
+		"wrong issuer": func(c jwt.MapClaims) (jwt.SigningMethod, string) {
+			c["iss"] = "https://evil.example.org"
+			return jwt.SigningMethodRS256, "rsa"
+		},
+		"expired": func(c jwt.MapClaims) (jwt.SigningMethod, string) {
+			c["exp"] = time.Now().Add(-time.Minute).Unix()
+			return jwt.SigningMethodRS256, "rsa"
+		},
+		"no expiry": func(c jwt.MapClaims) (jwt.SigningMethod, string) {
+			delete(c, "exp")
+			return jwt.SigningMethodRS256, "rsa"
+		},
+		"no subject": func(c jwt.MapClaims) (jwt.SigningMethod, string) {
+			delete(c, "sub")
+			return jwt.SigningMethodRS256, "rsa"
+		},
+		"alg none":    func(c jwt.MapClaims) (jwt.SigningMethod, string) { return jwt.SigningMethodNone, "rsa" },
+		"alg HS256":   func(c jwt.MapClaims) (jwt.SigningMethod, string) { return jwt.SigningMethodHS256, "rsa" },
+		"unknown key": func(c jwt.MapClaims) (jwt.SigningMethod, string) { return jwt.SigningMethodRS256, "unpublished" },
+	} {
+		ls := newTestLoginState(t)
+		claims := m.claims(ls.Nonce)
+		method, kid := tamper(claims)
+		if _, err := m.login(p, ls, m.sign(method, kid, claims)); !errors.Is(err, ErrInvalidIDToken) {
+			t.Errorf("%s: err = %v", name, err)
+		}
+	}
+
+	ls := newTestLoginState(t)
+	valid := m.sign(jwt.SigningMethodRS256, "rsa", m.claims(ls.Nonce))
+	if _, err := p.Exchange(context.Background(), "bad-code", ls); !errors.Is(err, ErrCodeExchange) {
+		t.Errorf("bad code: err = %v", err)
+	}
+	// The token endpoint only answers for the verifier of the challenge.
+	m.login(p, ls, valid)
+	other := newTestLoginState(t)
+	other.Nonce = ls.Nonce
+	if _, err := p.Exchange(context.Background(), "good-code", other); !errors.Is(err, ErrCodeExchange) {
+		t.Errorf("wrong verifier: err = %v", err)
+	}
+}
+
+func TestUnknownKidRefreshesTheJWKS(t *testing.T) {
+	m := newMockIdP(t)
+	m.addKey("old", false, true)
+	m.addKey("new", false, false)
+	p := newTestProvider(t, m)
+
+	ls := newTestLoginState(t)
+	if _, err := m.login(p, ls, m.sign(jwt.SigningMethodRS256, "old", m.claims(ls.Nonce))); err != nil {
+		t.Fatal(err)
+	}
+
+	// The provider rolls its key. Right after a fetch an unknown kid does
+	// not trigger another one.
+	m.publish("new")
+	ls = newTestLoginState(t)
+	rolled := m.sign(jwt.SigningMethodRS256, "new", m.claims(ls.Nonce))
+	if _, err := m.login(p, ls, rolled); !errors.Is(err, ErrInvalidIDToken) {
+		t.Errorf("refetched too early: err = %v", err)
+	}
+	if m.jwksHits != 1 {
+		t.Errorf("JWKS fetched %d times, want 1", m.jwksHits)
+	}
+
+	p.keys.mu.Lock()
+	p.keys.fetchedAt = time.Now().Add(-minRefreshInterval)
+	p.keys.mu.Unlock()
+	if _, err := m.login(p, ls, rolled); err != nil {
+		t.Fatalf("rolled key: %v", err)
+	}
+	if m.jwksHits != 2 {
+		t.Errorf("JWKS fetched %d times, want 2", m.jwksHits)
+	}
+	// Known keys are served from the cache.
+	ls = newTestLoginState(t)
+	if _, err := m.login(p, ls, m.sign(jwt.SigningMethodRS256, "old", m.claims(ls.Nonce))); err != nil {
+		t.Fatal(err)
+	}
+	if m.jwksHits != 2 {
+		t.Errorf("JWKS fetched %d times, want 2", m.jwksHits)
+	}
+}
+
+func TestNewProviderChecksTheIssuer(t *testing.T) {
+	m := newMockIdP(t)
+	_, err := NewProvider(context.Background(), Config{IssuerURL: m.server.URL + "/other", ClientID: testClientID})
+	if !errors.Is(err, ErrDiscovery) {
+		t.Errorf("other issuer: err = %v", err)
+	}
+	p := newTestProvider(t, m)
+	if p.config.GroupsClaim != "groups" {
+		t.Errorf("groups claim = %q", p.config.GroupsClaim)
+	}
+}
@@ -0,0 +1,93 @@
+package http
+
+import (
+	"Students-Final-Assignment/Internal/Tenant"
+	"Students-Final-Assignment/Internal/User"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+func (h *Handler) ListLockouts(w http.ResponseWriter, r *http.Request) {
+	lockouts, err := h.UserService.ListLockouts(r.Context())
+	if err != nil {
+		log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(lockouts); err != nil {
+		panic(err)
+	}
+}
+
+// ClearLockout takes the lockout key as ?key=, e.g. "user:1:alice" or
+// "ip:10.0.0.7".
+func (h *Handler) ClearLockout(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := h.UserService.ClearLockout(r.Context(), key); err != nil {
+		if errors.Is(err, Tenant.ErrOutsideTenant) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	log.Infof("user %s cleared lockout %s", User.ActorFromContext(r.Context()), key)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type AssignRoleRequest struct {
+	Role User.Role `json:"role"`
+}
+
+func (h *Handler) ListRoles(w http.ResponseWriter, r *http.Request) {
+	if err := json.NewEncoder(w).Encode(User.RolePermissions); err != nil {
+		panic(err)
+	}
+}
+
+func (h *Handler) AssignRole(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var req AssignRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if err := h.UserService.AssignRole(r.Context(), id, req.Role); err != nil {
+		switch {
+		case errors.Is(err, User.ErrInvalidRole):
+			w.WriteHeader(http.StatusBadRequest)
+		case errors.Is(err, User.ErrUserNotFound):
+			w.WriteHeader(http.StatusNotFound)
+		case errors.Is(err, User.ErrSuperAdminOnly), errors.Is(err, User.ErrCannotDemoteSelf):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case errors.Is(err, User.ErrLastAdmin):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			log.Error(err)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	log.Infof("user %s assigned role %q to user %d", User.ActorFromContext(r.Context()), req.Role, id)
+	if err := json.NewEncoder(w).Encode(Response{Message: "Role assigned"}); err != nil {
+		panic(err)
+	}
+}
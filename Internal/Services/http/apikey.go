@@ -0,0 +1,85 @@
+package http
+
+import (
+	"Students-Final-Assignment/Internal/User"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+// requireSession wraps JWTAuth for routes an API key must not reach, so a
+// leaked key cannot mint more keys or switch off the second factor.
+func (h *Handler) requireSession(original func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	return h.JWTAuth(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := User.APIKeyFromContext(r.Context()); ok {
+			w.WriteHeader(http.StatusForbidden)
+			log.Error("API keys cannot be used to manage credentials")
+			return
+		}
+		original(w, r)
+	})
+}
+
+func (h *Handler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	uid, _ := User.UIDFromContext(r.Context())
+	keys, err := h.UserService.ListAPIKeys(r.Context(), uid)
+	if err != nil {
+		log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(keys); err != nil {
+		panic(err)
+	}
+}
+
+func (h *Handler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req User.NewAPIKey
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	uid, _ := User.UIDFromContext(r.Context())
+	key, err := h.UserService.CreateAPIKey(r.Context(), uid, req)
+	if err != nil {
+		if errors.Is(err, User.ErrInvalidScope) || errors.Is(err, User.ErrEmptyKeyName) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	log.Infof("user %d created API key %s", uid, key.Prefix)
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(key); err != nil {
+		panic(err)
+	}
+}
+
+func (h *Handler) DeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	uid, _ := User.UIDFromContext(r.Context())
+	if err := h.UserService.DeleteAPIKey(r.Context(), uid, id); err != nil {
+		if errors.Is(err, User.ErrAPIKeyNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
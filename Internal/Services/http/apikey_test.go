@@ -0,0 +1,40 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"Students-Final-Assignment/Internal/User"
+)
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	e := newTestEnv(t)
+	uid := e.addUser("sync", User.RoleRegistrar)
+	e.addUser("admin", User.RoleAdmin)
+	token := e.token("sync")
+
+	rec := e.do("POST", "/api/v1/me/api-keys", token, `{"name":"nightly","scopes":["students:read","students:export"]}`)
+	expectStatus(t, rec, http.StatusCreated)
+	var created User.CreatedAPIKey
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+	apiKey := "ApiKey " + created.Key
+
+	expectStatus(t, e.do("GET", "/api/v1/students", "", "", "Authorization", apiKey), http.StatusOK)
+	expectStatus(t, e.do("GET", "/api/v1/students", created.Key, ""), http.StatusOK)
+	// Outside the key's scopes, though the owner's role allows it.
+	expectStatus(t, e.do("PATCH", "/api/v1/student/1", "", `{}`, "Authorization", apiKey, "Content-Type", mergePatch), http.StatusForbidden)
+	// Keys can't mint more keys.
+	expectStatus(t, e.do("GET", "/api/v1/me/api-keys", "", "", "Authorization", apiKey), http.StatusForbidden)
+	expectStatus(t, e.do("GET", "/api/v1/students", "", "", "Authorization", "ApiKey "+created.Prefix+".forged"), http.StatusUnauthorized)
+
+	// The owner's current role caps the key.
+	expectStatus(t, e.do("PUT", fmt.Sprintf("/api/v1/admin/users/%d/role", uid), e.token("admin"), `{"role":"readonly"}`), http.StatusOK)
+	expectStatus(t, e.do("GET", "/api/v1/students/export", "", "", "Authorization", apiKey), http.StatusForbidden)
+
+	expectStatus(t, e.do("DELETE", fmt.Sprintf("/api/v1/me/api-keys/%d", created.ID), token, ""), http.StatusNoContent)
+	expectStatus(t, e.do("GET", "/api/v1/students", "", "", "Authorization", apiKey), http.StatusUnauthorized)
+}
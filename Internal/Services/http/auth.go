@@ -1,7 +1,7 @@
 package http
 
 import (
-	"errors"
+	"Students-Final-Assignment/Internal/User"
 	"net/http"
 	"strings"
 
@@ -9,24 +9,96 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-func validateToken(accessToken string) bool {
-	var mySigningKey = []byte("missionimpossible")
-	token, err := jwt.Parse(accessToken, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("could not validate auth token")
-		}
-		return mySigningKey, nil
-	})
+// bearerToken returns the token of an "Authorization: Bearer" header.
+func bearerToken(r *http.Request) (string, bool) {
+	authHeader := r.Header["Authorization"]
+	if authHeader == nil {
+		return "", false
+	}
+	authHeaderParts := strings.Split(authHeader[0], " ")
+	if len(authHeaderParts) != 2 || strings.ToLower(authHeaderParts[0]) != "bearer" {
+		return "", false
+	}
+	return authHeaderParts[1], true
+}
 
-	if err != nil {
-		return false
+// apiKeyCredential returns the key of an "Authorization: ApiKey" header.
+// Keys sent as bearer tokens are accepted too, since that is all clients
+// such as SCIM provisioners can send.
+func apiKeyCredential(r *http.Request) (string, bool) {
+	scheme, key, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || key == "" {
+		return "", false
 	}
+	if strings.EqualFold(scheme, "bearer") {
+		return key, User.IsAPIKey(key)
+	}
+	return key, strings.EqualFold(scheme, "apikey")
+}
 
-	return token.Valid
+// tokenRole reads the role claim. Tokens without one get no permissions.
+func tokenRole(token *jwt.Token) User.Role {
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return ""
+	}
+	role, _ := claims["role"].(string)
+	return User.Role(role)
 }
 
-func JWTAuth(original func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+// tokenString reads a string claim such as sid or jti.
+func tokenString(token *jwt.Token, claim string) string {
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return ""
+	}
+	v, _ := claims[claim].(string)
+	return v
+}
+
+// tokenTenant reads the tenant claim of an access token.
+func tokenTenant(token *jwt.Token) int64 {
+	claims, _ := token.Claims.(jwt.MapClaims)
+	return User.ClaimTenant(claims)
+}
+
+// tokenUID reads the uid claim Login puts into every token.
+func tokenUID(token *jwt.Token) (int64, bool) {
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return 0, false
+	}
+	uid, ok := claims["uid"].(float64)
+	return int64(uid), ok
+}
+
+// JWTAuth lets through requests with a valid bearer token whose session is
+// still live, and stores the token's uid, role, session and tenant in the
+// request context for the layers below. Requests with a valid API key get
+// the key and its owner's uid, current role and tenant instead. Either is
+// refused on the subdomain of a different tenant.
+func (h *Handler) JWTAuth(original func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if raw, ok := apiKeyCredential(r); ok {
+			key, user, err := h.UserService.AuthenticateAPIKey(r.Context(), raw)
+			if err != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+				log.Error("could not validate incoming API key")
+				return
+			}
+			ctx, ok := withCredentialTenant(r.Context(), user.TenantID)
+			if !ok {
+				w.WriteHeader(http.StatusUnauthorized)
+				log.Errorf("API key of tenant %d used on another tenant's host", user.TenantID)
+				return
+			}
+			ctx = User.ContextWithUID(ctx, user.UID)
+			ctx = User.ContextWithRole(ctx, user.Role)
+			ctx = User.ContextWithAPIKey(ctx, key)
+			original(w, r.WithContext(ctx))
+			return
+		}
+
 		authHeader := r.Header["Authorization"]
 		if authHeader == nil {
 			w.WriteHeader(http.StatusUnauthorized)
@@ -34,19 +106,63 @@ func JWTAuth(original func(w http.ResponseWriter, r *http.Request)) func(w http.
 			return
 		}
 
-		authHeaderParts := strings.Split(authHeader[0], " ")
-		if len(authHeaderParts) != 2 || strings.ToLower(authHeaderParts[0]) != "bearer" {
+		accessToken, ok := bearerToken(r)
+		if !ok {
 			w.WriteHeader(http.StatusUnauthorized)
 			log.Error("authorization header could not be parsed")
 			return
 		}
 
-		if validateToken(authHeaderParts[1]) {
-			original(w, r)
-		} else {
+		token, err := h.UserService.ParseToken(accessToken)
+		if err != nil || !token.Valid {
 			w.WriteHeader(http.StatusUnauthorized)
 			log.Error("could not validate incoming token")
 			return
 		}
+
+		uid, ok := tokenUID(token)
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			log.Error("token does not carry a user id")
+			return
+		}
+		sessionID := tokenString(token, "sid")
+		if err := h.UserService.ValidateSession(r.Context(), sessionID, tokenString(token, "jti")); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			log.Error("token has been revoked or replaced")
+			return
+		}
+
+		ctx, ok := withCredentialTenant(r.Context(), tokenTenant(token))
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			log.Errorf("token of tenant %d used on another tenant's host", tokenTenant(token))
+			return
+		}
+		ctx = User.ContextWithUID(ctx, uid)
+		ctx = User.ContextWithRole(ctx, tokenRole(token))
+		ctx = User.ContextWithSessionID(ctx, sessionID)
+		original(w, r.WithContext(ctx))
 	}
 }
+
+// RequirePermission authenticates like JWTAuth and then only lets the
+// request through if the token's role grants perm and, for an API key, the
+// key is scoped to it.
+func (h *Handler) RequirePermission(perm User.Permission, original func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	return h.JWTAuth(func(w http.ResponseWriter, r *http.Request) {
+		role, _ := User.RoleFromContext(r.Context())
+		if !role.Can(perm) {
+			uid, _ := User.UIDFromContext(r.Context())
+			w.WriteHeader(http.StatusForbidden)
+			log.Errorf("user %d with role %q lacks permission %q", uid, role, perm)
+			return
+		}
+		if key, ok := User.APIKeyFromContext(r.Context()); ok && !key.Allows(perm) {
+			w.WriteHeader(http.StatusForbidden)
+			log.Errorf("API key %s is not scoped to %q", key.Prefix, perm)
+			return
+		}
+		original(w, r)
+	})
+}
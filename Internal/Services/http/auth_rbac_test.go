@@ -0,0 +1,58 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"Students-Final-Assignment/Internal/User"
+)
+
+func TestRoutesRequireTheirPermission(t *testing.T) {
+	e := newTestEnv(t)
+	for _, role := range []User.Role{User.RoleReadOnly, User.RoleTeacher, User.RoleRegistrar, User.RoleAdmin} {
+		e.addUser(string(role), role)
+	}
+	st := e.addStudent()
+	student := fmt.Sprintf("/api/v1/student/%d", st.ID)
+
+	for _, c := range []struct {
+		method, path string
+		allowed      []User.Role
+	}{
+		{"GET", student, []User.Role{User.RoleReadOnly, User.RoleTeacher, User.RoleRegistrar, User.RoleAdmin}},
+		{"PATCH", student, []User.Role{User.RoleTeacher, User.RoleRegistrar, User.RoleAdmin}},
+		{"GET", "/api/v1/students/export", []User.Role{User.RoleRegistrar, User.RoleAdmin}},
+		{"DELETE", "/api/v1/students/trash", []User.Role{User.RoleAdmin}},
+		{"GET", "/api/v1/admin/roles", []User.Role{User.RoleAdmin}},
+	} {
+		allowed := map[User.Role]bool{}
+		for _, r := range c.allowed {
+			allowed[r] = true
+		}
+		for _, role := range []User.Role{User.RoleReadOnly, User.RoleTeacher, User.RoleRegistrar, User.RoleAdmin} {
+			rec := e.do(c.method, c.path, e.token(string(role)), `{"lname":"Lee"}`, "Content-Type", mergePatch)
+			if got := rec.Code != http.StatusForbidden; got != allowed[role] {
+				t.Errorf("%s %s as %s: status %d", c.method, c.path, role, rec.Code)
+			}
+		}
+		expectStatus(t, e.do(c.method, c.path, "", ""), http.StatusUnauthorized)
+	}
+}
+
+func TestRoleChangeTakesEffectWithTheNextToken(t *testing.T) {
+	e := newTestEnv(t)
+	uid := e.addUser("reader", User.RoleReadOnly)
+	admin := e.addUser("admin", User.RoleAdmin)
+	before := e.token("reader")
+	st := e.addStudent()
+	path := fmt.Sprintf("/api/v1/student/%d", st.ID)
+
+	expectStatus(t, e.do("PUT", fmt.Sprintf("/api/v1/admin/users/%d/role", uid), e.token("admin"), `{"role":"teacher"}`), http.StatusOK)
+	expectStatus(t, e.do("PATCH", path, before, `{"lname":"Ray"}`, "Content-Type", mergePatch), http.StatusForbidden)
+	expectStatus(t, e.do("PATCH", path, e.token("reader"), `{"lname":"Ray"}`, "Content-Type", mergePatch), http.StatusOK)
+
+	expectStatus(t, e.do("PUT", fmt.Sprintf("/api/v1/admin/users/%d/role", uid), e.token("admin"), `{"role":"root"}`), http.StatusBadRequest)
+	expectStatus(t, e.do("PUT", fmt.Sprintf("/api/v1/admin/users/%d/role", uid), e.token("admin"), `{"role":"superadmin"}`), http.StatusForbidden)
+	expectStatus(t, e.do("PUT", fmt.Sprintf("/api/v1/admin/users/%d/role", admin), e.token("admin"), `{"role":"teacher"}`), http.StatusForbidden)
+}
@@ -0,0 +1,48 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	student "Students-Final-Assignment/Internal/Student"
+)
+
+var errPreconditionFailed = errors.New("If-Match does not name the current version")
+
+func studentETag(s student.Student) string {
+	return fmt.Sprintf(`"%d-%d"`, s.ID, s.Version)
+}
+
+func setStudentETag(w http.ResponseWriter, s student.Student) {
+	w.Header().Set("ETag", studentETag(s))
+}
+
+// ifMatchVersion reads the student version a request is conditional on. It
+// returns 0 when there is no If-Match header or it is "*", so the write is
+// unconditional. Only strong ETags of the same student are accepted.
+func ifMatchVersion(r *http.Request, id int64) (int64, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if strings.HasPrefix(tag, "W/") || len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			continue
+		}
+		idPart, versionPart, ok := strings.Cut(tag[1:len(tag)-1], "-")
+		if !ok || idPart != strconv.FormatInt(id, 10) {
+			continue
+		}
+		version, err := strconv.ParseInt(versionPart, 10, 64)
+		if err != nil || version <= 0 {
+			continue
+		}
+		return version, nil
+	}
+	return 0, errPreconditionFailed
+}
@@ -0,0 +1,74 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"Students-Final-Assignment/Internal/User"
+)
+
+func TestIfMatchVersion(t *testing.T) {
+	for header, want := range map[string]int64{
+		"":               0,
+		"*":              0,
+		`"7-3"`:          3,
+		`"8-1", "7-4"`:   4,
+		`W/"7-3", "7-5"`: 5,
+	} {
+		r := httptest.NewRequest("PUT", "/", nil)
+		r.Header.Set("If-Match", header)
+		if got, err := ifMatchVersion(r, 7); err != nil || got != want {
+			t.Errorf("ifMatchVersion(%q) = %d, %v; want %d", header, got, err, want)
+		}
+	}
+	for _, header := range []string{`W/"7-3"`, `"8-3"`, `"7-0"`, `"7-x"`, `7-3`} {
+		r := httptest.NewRequest("PUT", "/", nil)
+		r.Header.Set("If-Match", header)
+		if _, err := ifMatchVersion(r, 7); err == nil {
+			t.Errorf("ifMatchVersion(%q) accepted", header)
+		}
+	}
+}
+
+func TestIfMatchGuardsWrites(t *testing.T) {
+	e := newTestEnv(t)
+	e.addUser("registrar", User.RoleRegistrar)
+	token := e.token("registrar")
+	st := e.addStudent()
+	path := fmt.Sprintf("/api/v1/student/%d", st.ID)
+
+	rec := e.do("GET", path, token, "")
+	expectStatus(t, rec, http.StatusOK)
+	etag := rec.Header().Get("ETag")
+	if etag != studentETag(st) {
+		t.Fatalf("ETag = %q, want %q", etag, studentETag(st))
+	}
+
+	put := `{"fname":"Ann","lname":"Lee","date_of_birth":"2001-02-03T00:00:00Z","email":"ann@example.org","address":"3 Low St","gender":"f"}`
+	rec = e.do("PUT", path, token, put, "If-Match", etag)
+	expectStatus(t, rec, http.StatusOK)
+	newTag := rec.Header().Get("ETag")
+	if newTag == etag || newTag == "" {
+		t.Fatalf("ETag after update = %q", newTag)
+	}
+
+	// Every write with the stale tag loses.
+	expectStatus(t, e.do("PUT", path, token, put, "If-Match", etag), http.StatusPreconditionFailed)
+	expectStatus(t, e.do("PATCH", path, token, `{"fname":"Bo"}`, "Content-Type", mergePatch, "If-Match", etag), http.StatusPreconditionFailed)
+	expectStatus(t, e.do("DELETE", path, token, "", "If-Match", etag), http.StatusPreconditionFailed)
+	expectStatus(t, e.do("DELETE", path, token, "", "If-Match", `"999-1"`), http.StatusPreconditionFailed)
+
+	got, err := e.students.GetStudent(e.ctx, st.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Fname != "Ann" || got.Address != "3 Low St" || studentETag(got) != newTag {
+		t.Errorf("student after stale writes = %+v", got)
+	}
+
+	rec = e.do("PATCH", path, token, `{"fname":"Bo"}`, "Content-Type", mergePatch, "If-Match", newTag)
+	expectStatus(t, rec, http.StatusOK)
+	expectStatus(t, e.do("DELETE", path, token, "", "If-Match", rec.Header().Get("ETag")), http.StatusOK)
+}
@@ -1,14 +1,20 @@
 package http
 
 import (
+	"Students-Final-Assignment/Internal/OIDC"
+	"Students-Final-Assignment/Internal/Tenant"
 	"Students-Final-Assignment/Internal/User"
 	"context"
 	"encoding/json"
+	"errors"
+	"math"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"time"
 
+	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
 )
@@ -18,12 +24,26 @@ type Handler struct {
 	Service     StudentService
 	Server      *http.Server
 	UserService *User.Service
+	// OIDC is nil unless sign in with an identity provider is configured.
+	OIDC       *OIDC.Provider
+	OIDCPolicy User.ExternalLoginPolicy
+	// Tenants resolves subdomains to tenants. Without it every request
+	// that carries no token is served as the default tenant, and the
+	// tenant endpoints answer 404.
+	Tenants *Tenant.Service
 }
 
 type Response struct {
 	Message string `json:"message"`
 }
 
+// LoginResponse keeps the "token" field older clients read next to the
+// access and refresh token pair.
+type LoginResponse struct {
+	Token string `json:"token"`
+	User.TokenPair
+}
+
 func NewHandler(service StudentService, userService *User.Service) *Handler {
 	log.Info("setting up our handler")
 	h := &Handler{
@@ -35,6 +55,7 @@ func NewHandler(service StudentService, userService *User.Service) *Handler {
 	h.Router.Use(JSONMiddleware)
 	h.Router.Use(LoggingMiddleware)
 	h.Router.Use(TimeoutMiddleware)
+	h.Router.Use(h.TenantMiddleware)
 	h.mapRoutes()
 
 	h.Server = &http.Server{
@@ -50,12 +71,70 @@ func NewHandler(service StudentService, userService *User.Service) *Handler {
 func (h *Handler) mapRoutes() {
 	h.Router.HandleFunc("/alive", h.AliveCheck).Methods("GET")
 	h.Router.HandleFunc("/ready", h.ReadyCheck).Methods("GET")
-	h.Router.HandleFunc("/api/v1/student", JWTAuth(h.PostStudent)).Methods("POST")
-	h.Router.HandleFunc("/api/v1/student/{id}", JWTAuth(h.GetStudent)).Methods("GET")
-	h.Router.HandleFunc("/api/v1/student/{id}", JWTAuth(h.UpdateStudent)).Methods("PUT")
-	h.Router.HandleFunc("/api/v1/student/{id}", JWTAuth(h.DeleteStudent)).Methods("DELETE")
+	h.Router.HandleFunc("/api/v1/student", h.RequirePermission(User.PermWriteStudents, h.PostStudent)).Methods("POST")
+	h.Router.HandleFunc("/api/v1/students", h.RequirePermission(User.PermReadStudents, h.ListStudents)).Methods("GET")
+	h.Router.HandleFunc("/api/v1/students/search", h.RequirePermission(User.PermReadStudents, h.SearchStudents)).Methods("GET")
+	h.Router.HandleFunc("/api/v1/students/import", h.RequirePermission(User.PermImportStudents, h.ImportStudents)).Methods("POST")
+	h.Router.HandleFunc("/api/v1/students/export", h.RequirePermission(User.PermExportStudents, h.ExportStudents)).Methods("GET").Name(streamingRoute)
+	h.Router.HandleFunc("/api/v1/students/trash", h.RequirePermission(User.PermReadStudents, h.ListTrash)).Methods("GET")
+	h.Router.HandleFunc("/api/v1/students/trash", h.RequirePermission(User.PermPurgeStudents, h.PurgeTrash)).Methods("DELETE")
+	h.Router.HandleFunc("/api/v1/student/{id}/restore", h.RequirePermission(User.PermDeleteStudents, h.RestoreStudent)).Methods("POST")
+	h.Router.HandleFunc("/api/v1/student/{id}/history", h.RequirePermission(User.PermReadStudents, h.GetStudentHistory)).Methods("GET")
+	h.Router.HandleFunc("/api/v1/student/{id}", h.RequirePermission(User.PermReadStudents, h.GetStudent)).Methods("GET")
+	h.Router.HandleFunc("/api/v1/student/{id}", h.RequirePermission(User.PermWriteStudents, h.UpdateStudent)).Methods("PUT")
+	h.Router.HandleFunc("/api/v1/student/{id}", h.RequirePermission(User.PermWriteStudents, h.PatchStudent)).Methods("PATCH")
+	h.Router.HandleFunc("/api/v1/student/{id}", h.RequirePermission(User.PermDeleteStudents, h.DeleteStudent)).Methods("DELETE")
+	h.Router.HandleFunc("/api/v1/admin/roles", h.RequirePermission(User.PermManageUsers, h.ListRoles)).Methods("GET")
+	h.Router.HandleFunc("/api/v1/admin/users", h.RequirePermission(User.PermManageUsers, h.ListUsers)).Methods("GET")
+	h.Router.HandleFunc("/api/v1/admin/users/{id}", h.RequirePermission(User.PermManageUsers, h.GetUser)).Methods("GET")
+	h.Router.HandleFunc("/api/v1/admin/users/{id}", h.RequirePermission(User.PermManageUsers, h.DeleteUser)).Methods("DELETE")
+	h.Router.HandleFunc("/api/v1/admin/users/{id}/disable", h.RequirePermission(User.PermManageUsers, h.DisableUser)).Methods("POST")
+	h.Router.HandleFunc("/api/v1/admin/users/{id}/enable", h.RequirePermission(User.PermManageUsers, h.EnableUser)).Methods("POST")
+	h.Router.HandleFunc("/api/v1/admin/users/{id}/email", h.RequirePermission(User.PermManageUsers, h.ChangeUserEmail)).Methods("PUT")
+	h.Router.HandleFunc("/api/v1/admin/users/{id}/reset-password", h.RequirePermission(User.PermManageUsers, h.ForcePasswordReset)).Methods("POST")
+	h.Router.HandleFunc("/api/v1/admin/users/{id}/role", h.RequirePermission(User.PermManageUsers, h.AssignRole)).Methods("PUT")
+	h.Router.HandleFunc("/api/v1/admin/users/{id}/revoke-sessions", h.RequirePermission(User.PermManageUsers, h.RevokeUserSessions)).Methods("POST")
+	h.Router.HandleFunc("/.well-known/jwks.json", h.JWKS).Methods("GET")
+	h.Router.HandleFunc("/api/v1/admin/lockouts", h.RequirePermission(User.PermManageUsers, h.ListLockouts)).Methods("GET")
+	h.Router.HandleFunc("/api/v1/admin/lockouts", h.RequirePermission(User.PermManageUsers, h.ClearLockout)).Methods("DELETE")
 	h.Router.HandleFunc("/api/v1/login", h.Login).Methods("POST")
+	h.Router.HandleFunc("/api/v1/login/mfa", h.LoginMFA).Methods("POST")
+	h.Router.HandleFunc("/api/v1/oidc/login", h.OIDCLogin).Methods("GET")
+	h.Router.HandleFunc("/api/v1/oidc/callback", h.OIDCCallback).Methods("GET")
+	h.Router.HandleFunc("/api/v1/me", h.JWTAuth(h.GetMe)).Methods("GET")
+	h.Router.HandleFunc("/api/v1/me", h.requireSession(h.UpdateMe)).Methods("PUT")
+	h.Router.HandleFunc("/api/v1/me/password", h.requireSession(h.ChangePassword)).Methods("POST")
+	h.Router.HandleFunc("/api/v1/me/mfa/totp", h.requireSession(h.EnrollTOTP)).Methods("POST")
+	h.Router.HandleFunc("/api/v1/me/mfa/totp", h.requireSession(h.DisableTOTP)).Methods("DELETE")
+	h.Router.HandleFunc("/api/v1/me/mfa/totp/confirm", h.requireSession(h.ConfirmTOTP)).Methods("POST")
+	h.Router.HandleFunc("/api/v1/me/mfa/recovery-codes", h.requireSession(h.RegenerateRecoveryCodes)).Methods("POST")
+	h.Router.HandleFunc("/api/v1/me/api-keys", h.requireSession(h.ListAPIKeys)).Methods("GET")
+	h.Router.HandleFunc("/api/v1/me/api-keys", h.requireSession(h.CreateAPIKey)).Methods("POST")
+	h.Router.HandleFunc("/api/v1/me/api-keys/{id}", h.requireSession(h.DeleteAPIKey)).Methods("DELETE")
 	h.Router.HandleFunc("/api/v1/register", h.Register).Methods("POST")
+	h.Router.HandleFunc("/api/v1/password/forgot", h.ForgotPassword).Methods("POST")
+	h.Router.HandleFunc("/api/v1/password/reset", h.ResetPassword).Methods("POST")
+	h.Router.HandleFunc("/api/v1/verify-email", h.VerifyEmail).Methods("GET")
+	h.Router.HandleFunc("/api/v1/verify-email/resend", h.ResendVerification).Methods("POST")
+	h.Router.HandleFunc("/api/v1/token/refresh", h.RefreshToken).Methods("POST")
+	h.Router.HandleFunc("/api/v1/logout", h.JWTAuth(h.Logout)).Methods("POST")
+	h.Router.HandleFunc("/api/v1/tenants", h.RequirePermission(User.PermManageTenants, h.withTenants(h.ListTenants))).Methods("GET")
+	h.Router.HandleFunc("/api/v1/tenants", h.RequirePermission(User.PermManageTenants, h.withTenants(h.CreateTenant))).Methods("POST")
+	h.Router.HandleFunc("/api/v1/tenants/{id}", h.RequirePermission(User.PermManageTenants, h.withTenants(h.GetTenant))).Methods("GET")
+	h.Router.HandleFunc("/api/v1/tenants/{id}", h.RequirePermission(User.PermManageTenants, h.withTenants(h.UpdateTenant))).Methods("PUT")
+	h.Router.HandleFunc("/api/v1/tenants/{id}", h.RequirePermission(User.PermManageTenants, h.withTenants(h.DeleteTenant))).Methods("DELETE")
+	h.Router.HandleFunc("/api/v1/tenants/{id}/users", h.RequirePermission(User.PermManageTenants, h.withTenants(h.CreateTenantAdmin))).Methods("POST")
+	h.Router.HandleFunc(scimPath+"/ServiceProviderConfig", h.RequirePermission(User.PermManageUsers, h.SCIMServiceProviderConfig)).Methods("GET")
+	h.Router.HandleFunc(scimPath+"/Users", h.RequirePermission(User.PermManageUsers, h.SCIMListUsers)).Methods("GET")
+	h.Router.HandleFunc(scimPath+"/Users", h.RequirePermission(User.PermManageUsers, h.SCIMCreateUser)).Methods("POST")
+	h.Router.HandleFunc(scimPath+"/Users/{id}", h.RequirePermission(User.PermManageUsers, h.SCIMGetUser)).Methods("GET")
+	h.Router.HandleFunc(scimPath+"/Users/{id}", h.RequirePermission(User.PermManageUsers, h.SCIMReplaceUser)).Methods("PUT")
+	h.Router.HandleFunc(scimPath+"/Users/{id}", h.RequirePermission(User.PermManageUsers, h.SCIMPatchUser)).Methods("PATCH")
+	h.Router.HandleFunc(scimPath+"/Users/{id}", h.RequirePermission(User.PermManageUsers, h.SCIMDeleteUser)).Methods("DELETE")
+	h.Router.HandleFunc(scimPath+"/Groups", h.RequirePermission(User.PermManageUsers, h.SCIMListGroups)).Methods("GET")
+	h.Router.HandleFunc(scimPath+"/Groups/{id}", h.RequirePermission(User.PermManageUsers, h.SCIMGetGroup)).Methods("GET")
+	h.Router.HandleFunc(scimPath+"/Groups/{id}", h.RequirePermission(User.PermManageUsers, h.SCIMReplaceGroup)).Methods("PUT")
+	h.Router.HandleFunc(scimPath+"/Groups/{id}", h.RequirePermission(User.PermManageUsers, h.SCIMPatchGroup)).Methods("PATCH")
 }
 
 func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
@@ -64,26 +143,68 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request payload", http.StatusBadRequest)
 		return
 	}
-	token, err := h.UserService.Login(creds.Username, creds.Password)
+	tokens, err := h.UserService.Login(r.Context(), creds.Username, creds.Password, clientIP(r))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
+		writeLoginError(w, err)
 		return
 	}
-	response := map[string]string{"token": token}
+	writeLoginResponse(w, tokens)
+}
+
+func writeLoginResponse(w http.ResponseWriter, tokens User.TokenPair) {
+	response := LoginResponse{Token: tokens.AccessToken, TokenPair: tokens}
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		http.Error(w, "Error encoding response", http.StatusInternalServerError)
 	}
 }
 
+// writeLoginError maps the errors of both login steps. An MFA challenge is
+// not a failure for the client, so it is answered with 200.
+func writeLoginError(w http.ResponseWriter, err error) {
+	var lockout *User.LockoutError
+	var mfa *User.MFARequiredError
+	switch {
+	case errors.As(err, &mfa):
+		response := MFAChallengeResponse{MFARequired: true, MFAToken: mfa.Token, ExpiresIn: mfa.ExpiresIn}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		}
+	case errors.As(err, &lockout):
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(lockout.RetryAfter.Seconds()))))
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	case errors.Is(err, User.ErrEmailNotVerified),
+		errors.Is(err, User.ErrAccountDisabled),
+		errors.Is(err, User.ErrNoLocalAccount),
+		errors.Is(err, User.ErrIdentityConflict):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, User.ErrInvalidCredentials),
+		errors.Is(err, User.ErrInvalidMFACode),
+		errors.Is(err, User.ErrInvalidMFAChallenge),
+		errors.Is(err, User.ErrTOTPNotEnrolled):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	default:
+		log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
 func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 	var creds User.Credentials
 	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
 		http.Error(w, "Invalid request payload", http.StatusBadRequest)
 		return
 	}
+	validate := validator.New()
+	if err := validate.Struct(creds); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
 
-	err := h.UserService.Register(creds.Username, creds.Password, creds.Email)
+	err := h.UserService.Register(r.Context(), creds.Username, creds.Password, creds.Email)
+	if writePasswordPolicyError(w, err) {
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -0,0 +1,109 @@
+package http
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"Students-Final-Assignment/Internal/Mail"
+	"Students-Final-Assignment/Internal/Student"
+	"Students-Final-Assignment/Internal/Tenant"
+	"Students-Final-Assignment/Internal/User"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const testPassword = "Correct-Horse-Battery-9"
+
+func init() {
+	log.SetOutput(io.Discard)
+}
+
+// testEnv is a handler on in-memory stores, in the default tenant.
+type testEnv struct {
+	t        *testing.T
+	h        *Handler
+	students *Student.MemoryStudentStore
+	users    *User.MemoryUserStore
+	mailer   *Mail.MemoryMailer
+	ctx      context.Context
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	keys, err := User.NewKeySet(User.KeysConfig{
+		SigningKeyID: "test",
+		Keys:         []User.KeyConfig{{KID: "test", Algorithm: "HS256", Secret: "a-test-secret-of-at-least-32-bytes"}},
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &testEnv{
+		t:        t,
+		students: Student.NewMemoryStudentStore(),
+		users:    User.NewMemoryUserStore(),
+		mailer:   Mail.NewMemoryMailer(),
+		ctx:      Tenant.ContextWithTenant(context.Background(), Tenant.DefaultTenantID),
+	}
+	us := User.NewService(e.users, User.NewMemorySessionStore(), keys)
+	us.Resets = User.NewMemoryPasswordResetStore()
+	us.MFA = User.NewMemoryMFAStore()
+	us.APIKeys = User.NewMemoryAPIKeyStore()
+	us.PasswordHistory = User.NewMemoryPasswordHistoryStore()
+	us.Mailer = e.mailer
+	e.h = NewHandler(Student.NewService(e.students), us)
+	return e
+}
+
+// addUser creates a user with testPassword and returns its uid.
+func (e *testEnv) addUser(username string, role User.Role) int64 {
+	e.t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	err = e.users.CreateUser(e.ctx, User.User{Username: username, Password: string(hash), Email: username + "@example.org", Role: role})
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	u, err := e.users.GetUserByUsername(e.ctx, username)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	return u.UID
+}
+
+// token logs a user in and returns the access token.
+func (e *testEnv) token(username string) string {
+	e.t.Helper()
+	tokens, err := e.h.UserService.Login(e.ctx, username, testPassword, "192.0.2.1")
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	return tokens.AccessToken
+}
+
+// do serves one request. Headers are given as name, value pairs.
+func (e *testEnv) do(method, path, token, body string, headers ...string) *httptest.ResponseRecorder {
+	e.t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	e.h.Router.ServeHTTP(rec, req)
+	return rec
+}
+
+func expectStatus(t *testing.T, rec *httptest.ResponseRecorder, want int) {
+	t.Helper()
+	if rec.Code != want {
+		t.Fatalf("status = %d, want %d; body: %s", rec.Code, want, rec.Body.String())
+	}
+}
@@ -0,0 +1,122 @@
+package http
+
+import (
+	"Students-Final-Assignment/Internal/User"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// MFAChallengeResponse answers a correct password on an account with TOTP.
+// MFAToken is posted with a code to /api/v1/login/mfa.
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code"`
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// writeMFAError maps the TOTP errors shared by the handlers below.
+func writeMFAError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, User.ErrInvalidMFACode), errors.Is(err, User.ErrTOTPNotEnrolled):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, User.ErrTOTPAlreadyEnabled):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	var req MFALoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MFAToken == "" || req.Code == "" {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := h.UserService.CompleteMFALogin(r.Context(), req.MFAToken, req.Code, clientIP(r))
+	if err != nil {
+		writeLoginError(w, err)
+		return
+	}
+	writeLoginResponse(w, tokens)
+}
+
+func (h *Handler) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	uid, _ := User.UIDFromContext(r.Context())
+	enrollment, err := h.UserService.EnrollTOTP(r.Context(), uid)
+	if err != nil {
+		writeMFAError(w, err)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(enrollment); err != nil {
+		panic(err)
+	}
+}
+
+func (h *Handler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	var req MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	uid, _ := User.UIDFromContext(r.Context())
+	codes, err := h.UserService.ConfirmTOTP(r.Context(), uid, req.Code)
+	if err != nil {
+		writeMFAError(w, err)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(RecoveryCodesResponse{RecoveryCodes: codes}); err != nil {
+		panic(err)
+	}
+}
+
+func (h *Handler) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	var req MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	uid, _ := User.UIDFromContext(r.Context())
+	if err := h.UserService.DisableTOTP(r.Context(), uid, req.Code); err != nil {
+		writeMFAError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	var req MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	uid, _ := User.UIDFromContext(r.Context())
+	codes, err := h.UserService.RegenerateRecoveryCodes(r.Context(), uid, req.Code)
+	if err != nil {
+		writeMFAError(w, err)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(RecoveryCodesResponse{RecoveryCodes: codes}); err != nil {
+		panic(err)
+	}
+}
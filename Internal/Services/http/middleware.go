@@ -2,9 +2,11 @@ package http
 
 import (
 	"context"
+	"net"
 	"net/http"
 	"time"
 
+	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -15,8 +17,16 @@ func JSONMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// streamingRoute names routes that stream a response of any length. They
+// are not timed out as a whole but extend their write deadline as they go.
+const streamingRoute = "streaming"
+
 func TimeoutMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route := mux.CurrentRoute(r); route != nil && route.GetName() == streamingRoute {
+			next.ServeHTTP(w, r)
+			return
+		}
 		ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
 		defer cancel()
 		next.ServeHTTP(w, r.WithContext(ctx))
@@ -34,3 +44,13 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// clientIP is the address the request came from. X-Forwarded-For is not
+// trusted, since any client can set it.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,120 @@
+package http
+
+import (
+	"Students-Final-Assignment/Internal/OIDC"
+	"Students-Final-Assignment/Internal/User"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	oidcCookie     = "oidc_login"
+	oidcCookiePath = "/api/v1/oidc"
+	// oidcLoginTTL is how long the user has to sign in at the provider.
+	oidcLoginTTL = 10 * time.Minute
+)
+
+// OIDCLogin sends the browser to the provider. The state, nonce and PKCE
+// verifier travel in a signed cookie so any instance can take the callback.
+func (h *Handler) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if h.OIDC == nil {
+		http.NotFound(w, r)
+		return
+	}
+	ls, err := OIDC.NewLoginState()
+	if err != nil {
+		log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	sealed, err := h.UserService.SealExternalLogin(map[string]string{
+		"state":    ls.State,
+		"nonce":    ls.Nonce,
+		"verifier": ls.Verifier,
+	}, oidcLoginTTL)
+	if err != nil {
+		log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// Lax, not Strict: the callback is a top-level redirect from the
+	// provider's site.
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcCookie,
+		Value:    sealed,
+		Path:     oidcCookiePath,
+		MaxAge:   int(oidcLoginTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, h.OIDC.AuthCodeURL(ls), http.StatusFound)
+}
+
+// OIDCCallback finishes the login the provider redirected back from and
+// answers like /api/v1/login.
+func (h *Handler) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if h.OIDC == nil {
+		http.NotFound(w, r)
+		return
+	}
+	query := r.URL.Query()
+	if providerErr := query.Get("error"); providerErr != "" {
+		http.Error(w, "sign in failed at the provider: "+providerErr, http.StatusUnauthorized)
+		return
+	}
+
+	cookie, err := r.Cookie(oidcCookie)
+	if err != nil {
+		http.Error(w, User.ErrInvalidExternalLogin.Error(), http.StatusBadRequest)
+		return
+	}
+	// The login can only be attempted once.
+	http.SetCookie(w, &http.Cookie{Name: oidcCookie, Path: oidcCookiePath, MaxAge: -1, HttpOnly: true})
+
+	values, err := h.UserService.OpenExternalLogin(cookie.Value)
+	state := query.Get("state")
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(values["state"])) != 1 {
+		http.Error(w, User.ErrInvalidExternalLogin.Error(), http.StatusBadRequest)
+		return
+	}
+	code := query.Get("code")
+	if code == "" {
+		http.Error(w, "missing authorization code", http.StatusBadRequest)
+		return
+	}
+
+	identity, err := h.OIDC.Exchange(r.Context(), code, OIDC.LoginState{
+		State:    values["state"],
+		Nonce:    values["nonce"],
+		Verifier: values["verifier"],
+	})
+	if err != nil {
+		log.Infof("OIDC login failed: %s", err.Error())
+		if errors.Is(err, OIDC.ErrCodeExchange) || errors.Is(err, OIDC.ErrInvalidIDToken) {
+			http.Error(w, "could not verify the sign in", http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+
+	tokens, err := h.UserService.LoginExternal(r.Context(), User.ExternalIdentity{
+		Provider:      identity.Issuer,
+		Subject:       identity.Subject,
+		Email:         identity.Email,
+		EmailVerified: identity.EmailVerified,
+		Username:      identity.Username,
+		Groups:        identity.Groups,
+	}, h.OIDCPolicy)
+	if err != nil {
+		writeLoginError(w, err)
+		return
+	}
+	writeLoginResponse(w, tokens)
+}
@@ -0,0 +1,84 @@
+package http
+
+import (
+	"Students-Final-Assignment/Internal/User"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+// PasswordPolicyResponse lists every rule a rejected password broke.
+type PasswordPolicyResponse struct {
+	Message    string                   `json:"message"`
+	Violations []User.PasswordViolation `json:"violations"`
+}
+
+// writePasswordPolicyError answers 422 with the violations if err is a
+// PasswordPolicyError and reports whether it did.
+func writePasswordPolicyError(w http.ResponseWriter, err error) bool {
+	var policyErr *User.PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		return false
+	}
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	response := PasswordPolicyResponse{Message: User.ErrWeakPassword.Error(), Violations: policyErr.Violations}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Error(err)
+	}
+	return true
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// ForgotPassword answers 202 whether or not the email is known, so it
+// cannot be used to find out who has an account. Too many requests from
+// one address or for one email get 429.
+func (h *Handler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	err := h.UserService.ForgotPassword(r.Context(), req.Email, clientIP(r))
+	if errors.Is(err, User.ErrTooManyResetRequests) {
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+	if err != nil {
+		log.Errorf("could not send password reset mail: %s", err.Error())
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *Handler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	err := h.UserService.ResetPassword(r.Context(), req.Token, req.Password)
+	if writePasswordPolicyError(w, err) {
+		return
+	}
+	if err != nil {
+		if errors.Is(err, User.ErrInvalidResetToken) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,54 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"Students-Final-Assignment/Internal/User"
+)
+
+func TestForgotPasswordAnswersTheSameAndRateLimits(t *testing.T) {
+	e := newTestEnv(t)
+	e.addUser("ann", User.RoleReadOnly)
+
+	expectStatus(t, e.do("POST", "/api/v1/password/forgot", "", `{"email":"ann@example.org"}`), http.StatusAccepted)
+	expectStatus(t, e.do("POST", "/api/v1/password/forgot", "", `{"email":"nobody@example.org"}`), http.StatusAccepted)
+	expectStatus(t, e.do("POST", "/api/v1/password/forgot", "", `{}`), http.StatusBadRequest)
+
+	for i := 1; i < User.DefaultResetRateLimit.PerEmail; i++ {
+		expectStatus(t, e.do("POST", "/api/v1/password/forgot", "", `{"email":"ann@example.org"}`), http.StatusAccepted)
+	}
+	expectStatus(t, e.do("POST", "/api/v1/password/forgot", "", `{"email":"ann@example.org"}`), http.StatusTooManyRequests)
+
+	e.h.UserService.Wait()
+	if n := len(e.mailer.Messages()); n != User.DefaultResetRateLimit.PerEmail {
+		t.Errorf("%d reset mails sent, want %d", n, User.DefaultResetRateLimit.PerEmail)
+	}
+}
+
+func TestWeakPasswordsListEveryViolation(t *testing.T) {
+	e := newTestEnv(t)
+	e.addUser("ann", User.RoleReadOnly)
+	token := e.token("ann")
+
+	for _, tc := range []struct {
+		method, path, token, body string
+	}{
+		{"POST", "/api/v1/register", "", `{"username":"bob","password":"bob","email":"bob@example.org"}`},
+		{"POST", "/api/v1/me/password", token, `{"current_password":"` + testPassword + `","new_password":"bob"}`},
+	} {
+		rec := e.do(tc.method, tc.path, tc.token, tc.body)
+		expectStatus(t, rec, http.StatusUnprocessableEntity)
+		var response PasswordPolicyResponse
+		if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Message != User.ErrWeakPassword.Error() || len(response.Violations) < 3 {
+			t.Errorf("%s: response = %+v", tc.path, response)
+		}
+	}
+	if _, err := e.users.GetUserByUsername(e.ctx, "bob"); err == nil {
+		t.Error("user registered with a weak password")
+	}
+}
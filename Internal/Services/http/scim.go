@@ -0,0 +1,802 @@
+package http
+
+import (
+	"Students-Final-Assignment/Internal/User"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+// SCIM 2.0 (RFC 7643, RFC 7644) lets an HR system or identity provider
+// keep accounts in sync. Users map onto accounts; Groups are the fixed
+// roles, and a user is a member of exactly one of them.
+const (
+	scimUserSchema   = "urn:ietf:params:scim:schemas:core:2.0:User"
+	scimGroupSchema  = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	scimListSchema   = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	scimErrorSchema  = "urn:ietf:params:scim:api:messages:2.0:Error"
+	scimConfigSchema = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	scimContentType  = "application/scim+json"
+	scimPath         = "/scim/v2"
+)
+
+type SCIMMeta struct {
+	ResourceType string     `json:"resourceType"`
+	Created      *time.Time `json:"created,omitempty"`
+	LastModified *time.Time `json:"lastModified,omitempty"`
+	Location     string     `json:"location"`
+}
+
+type SCIMEmail struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type SCIMGroupRef struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+// SCIMUser is both the resource served and the body of create and replace.
+// Password is write-only. Attributes the app does not keep are ignored.
+type SCIMUser struct {
+	Schemas  []string       `json:"schemas"`
+	ID       string         `json:"id,omitempty"`
+	UserName string         `json:"userName"`
+	Emails   []SCIMEmail    `json:"emails,omitempty"`
+	Active   *bool          `json:"active,omitempty"`
+	Password string         `json:"password,omitempty"`
+	Groups   []SCIMGroupRef `json:"groups,omitempty"`
+	Meta     *SCIMMeta      `json:"meta,omitempty"`
+}
+
+type SCIMGroup struct {
+	Schemas     []string       `json:"schemas"`
+	ID          string         `json:"id"`
+	DisplayName string         `json:"displayName"`
+	Members     []SCIMGroupRef `json:"members"`
+	Meta        *SCIMMeta      `json:"meta,omitempty"`
+}
+
+type SCIMListResponse struct {
+	Schemas      []string      `json:"schemas"`
+	TotalResults int64         `json:"totalResults"`
+	StartIndex   int           `json:"startIndex"`
+	ItemsPerPage int           `json:"itemsPerPage"`
+	Resources    []interface{} `json:"Resources"`
+}
+
+type SCIMPatchRequest struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []SCIMPatchOperation `json:"Operations"`
+}
+
+type SCIMPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+type SCIMError struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	SCIMType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+// scimError is a failure with the status and scimType keyword RFC 7644
+// section 3.12 defines for it.
+type scimError struct {
+	status   int
+	scimType string
+	detail   string
+}
+
+func (e *scimError) Error() string {
+	return e.detail
+}
+
+func scimBadRequest(scimType, format string, args ...interface{}) error {
+	return &scimError{status: http.StatusBadRequest, scimType: scimType, detail: fmt.Sprintf(format, args...)}
+}
+
+func writeSCIM(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", scimContentType)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error(err)
+	}
+}
+
+func writeSCIMError(w http.ResponseWriter, err error) {
+	var se *scimError
+	var policy *User.PasswordPolicyError
+	switch {
+	case errors.As(err, &se):
+	case errors.As(err, &policy):
+		se = &scimError{status: http.StatusBadRequest, scimType: "invalidValue", detail: err.Error()}
+	case errors.Is(err, User.ErrUserNotFound):
+		se = &scimError{status: http.StatusNotFound, detail: err.Error()}
+	case errors.Is(err, User.ErrUsernameTaken):
+		se = &scimError{status: http.StatusConflict, scimType: "uniqueness", detail: err.Error()}
+	case errors.Is(err, User.ErrCannotModifySelf), errors.Is(err, User.ErrCannotDemoteSelf), errors.Is(err, User.ErrSuperAdminOnly):
+		se = &scimError{status: http.StatusForbidden, detail: err.Error()}
+	case errors.Is(err, User.ErrLastAdmin):
+		se = &scimError{status: http.StatusConflict, detail: err.Error()}
+	case errors.Is(err, User.ErrInvalidRole):
+		se = &scimError{status: http.StatusNotFound, detail: "group not found"}
+	default:
+		log.Error(err)
+		se = &scimError{status: http.StatusInternalServerError, detail: "internal error"}
+	}
+	writeSCIM(w, se.status, SCIMError{
+		Schemas:  []string{scimErrorSchema},
+		Status:   strconv.Itoa(se.status),
+		SCIMType: se.scimType,
+		Detail:   se.detail,
+	})
+}
+
+func (h *Handler) scimLocation(resource, id string) string {
+	return strings.TrimRight(h.UserService.BaseURL, "/") + scimPath + "/" + resource + "/" + id
+}
+
+func (h *Handler) scimUser(user User.User) SCIMUser {
+	active := !user.Disabled()
+	id := strconv.FormatInt(user.UID, 10)
+	created, updated := user.CreatedOn, user.UpdatedOn
+	resource := SCIMUser{
+		Schemas:  []string{scimUserSchema},
+		ID:       id,
+		UserName: user.Username,
+		Active:   &active,
+		Groups: []SCIMGroupRef{{
+			Value:   string(user.Role),
+			Display: string(user.Role),
+			Ref:     h.scimLocation("Groups", string(user.Role)),
+		}},
+		Meta: &SCIMMeta{ResourceType: "User", Created: &created, LastModified: &updated, Location: h.scimLocation("Users", id)},
+	}
+	if user.Email != "" {
+		resource.Emails = []SCIMEmail{{Value: user.Email, Type: "work", Primary: true}}
+	}
+	return resource
+}
+
+// primaryEmail picks the primary address, or the first one.
+func primaryEmail(emails []SCIMEmail) string {
+	for _, e := range emails {
+		if e.Primary {
+			return e.Value
+		}
+	}
+	if len(emails) > 0 {
+		return emails[0].Value
+	}
+	return ""
+}
+
+func validateSCIMUser(username, email string) error {
+	validate := validator.New()
+	if err := validate.Var(username, "required,max=100"); err != nil {
+		return scimBadRequest("invalidValue", "userName is required and at most 100 characters")
+	}
+	if err := validate.Var(email, "required,email,max=100"); err != nil {
+		return scimBadRequest("invalidValue", "a valid email is required")
+	}
+	return nil
+}
+
+// scimPage reads startIndex and count. startIndex is 1-based, and counts
+// above the largest page are cut down to it as RFC 7644 allows.
+func scimPage(r *http.Request) (startIndex, count int, err error) {
+	startIndex, count = 1, User.DefaultUserListLimit
+	if v := r.URL.Query().Get("startIndex"); v != "" {
+		if startIndex, err = strconv.Atoi(v); err != nil {
+			return 0, 0, scimBadRequest("invalidValue", "startIndex must be a number")
+		}
+		if startIndex < 1 {
+			startIndex = 1
+		}
+	}
+	if v := r.URL.Query().Get("count"); v != "" {
+		if count, err = strconv.Atoi(v); err != nil {
+			return 0, 0, scimBadRequest("invalidValue", "count must be a number")
+		}
+		if count < 0 {
+			count = 0
+		}
+		if count > User.MaxUserListLimit {
+			count = User.MaxUserListLimit
+		}
+	}
+	return startIndex, count, nil
+}
+
+// parseEqFilter understands the one filter provisioning clients rely on,
+// `<attribute> eq "<value>"`.
+func parseEqFilter(filter string) (attribute, value string, err error) {
+	attribute, rest, ok := strings.Cut(strings.TrimSpace(filter), " ")
+	op, operand, ok2 := strings.Cut(strings.TrimSpace(rest), " ")
+	if !ok || !ok2 || !strings.EqualFold(op, "eq") {
+		return "", "", scimBadRequest("invalidFilter", "only `attribute eq \"value\"` filters are supported")
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(operand)), &value); err != nil {
+		return "", "", scimBadRequest("invalidFilter", "the filter value must be a quoted string")
+	}
+	return attribute, value, nil
+}
+
+func scimList(total int64, startIndex int, resources []interface{}) SCIMListResponse {
+	return SCIMListResponse{
+		Schemas:      []string{scimListSchema},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}
+}
+
+func (h *Handler) SCIMServiceProviderConfig(w http.ResponseWriter, r *http.Request) {
+	supported := func(ok bool) map[string]bool { return map[string]bool{"supported": ok} }
+	writeSCIM(w, http.StatusOK, map[string]interface{}{
+		"schemas":        []string{scimConfigSchema},
+		"patch":          supported(true),
+		"bulk":           map[string]interface{}{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         map[string]interface{}{"supported": true, "maxResults": User.MaxUserListLimit},
+		"changePassword": supported(true),
+		"sort":           supported(false),
+		"etag":           supported(false),
+		"authenticationSchemes": []map[string]string{{
+			"type":        "oauthbearertoken",
+			"name":        "API key",
+			"description": "An API key with the users:manage scope, sent as a bearer token",
+		}},
+	})
+}
+
+func (h *Handler) SCIMListUsers(w http.ResponseWriter, r *http.Request) {
+	startIndex, count, err := scimPage(r)
+	if err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+
+	resources := []interface{}{}
+	if filter := r.URL.Query().Get("filter"); filter != "" {
+		attribute, value, err := parseEqFilter(filter)
+		if err != nil {
+			writeSCIMError(w, err)
+			return
+		}
+		if !strings.EqualFold(attribute, "userName") {
+			writeSCIMError(w, scimBadRequest("invalidFilter", "filtering is only supported on userName"))
+			return
+		}
+		user, err := h.UserService.GetUserByUsername(r.Context(), value)
+		if errors.Is(err, User.ErrUserNotFound) {
+			writeSCIM(w, http.StatusOK, scimList(0, startIndex, resources))
+			return
+		}
+		if err != nil {
+			writeSCIMError(w, err)
+			return
+		}
+		if startIndex == 1 && count > 0 {
+			resources = append(resources, h.scimUser(user))
+		}
+		writeSCIM(w, http.StatusOK, scimList(1, startIndex, resources))
+		return
+	}
+
+	// A count of 0 asks only for totalResults; one row is fetched to get it.
+	limit := count
+	if limit == 0 {
+		limit = 1
+	}
+	page, err := h.UserService.ListUsers(r.Context(), User.UserListOptions{Limit: limit, Offset: startIndex - 1})
+	if err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+	if count > 0 {
+		for _, user := range page.Users {
+			resources = append(resources, h.scimUser(user))
+		}
+	}
+	writeSCIM(w, http.StatusOK, scimList(page.Total, startIndex, resources))
+}
+
+func (h *Handler) SCIMGetUser(w http.ResponseWriter, r *http.Request) {
+	id, err := userID(r)
+	if err != nil {
+		writeSCIMError(w, User.ErrUserNotFound)
+		return
+	}
+	user, err := h.UserService.GetUser(r.Context(), id)
+	if err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+	writeSCIM(w, http.StatusOK, h.scimUser(user))
+}
+
+func decodeSCIMUser(r *http.Request) (SCIMUser, string, error) {
+	var req SCIMUser
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return SCIMUser{}, "", scimBadRequest("invalidSyntax", "the body is not a SCIM user")
+	}
+	email := primaryEmail(req.Emails)
+	if err := validateSCIMUser(req.UserName, email); err != nil {
+		return SCIMUser{}, "", err
+	}
+	return req, email, nil
+}
+
+func (h *Handler) SCIMCreateUser(w http.ResponseWriter, r *http.Request) {
+	req, email, err := decodeSCIMUser(r)
+	if err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+	active := req.Active == nil || *req.Active
+
+	user, err := h.UserService.ProvisionUser(r.Context(), req.UserName, email, req.Password, active)
+	if err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+	log.Infof("SCIM provisioned user %d (%s)", user.UID, user.Username)
+	resource := h.scimUser(user)
+	w.Header().Set("Location", resource.Meta.Location)
+	writeSCIM(w, http.StatusCreated, resource)
+}
+
+// SCIMReplaceUser handles PUT. An omitted active attribute means active,
+// and an omitted password leaves the current one.
+func (h *Handler) SCIMReplaceUser(w http.ResponseWriter, r *http.Request) {
+	id, err := userID(r)
+	if err != nil {
+		writeSCIMError(w, User.ErrUserNotFound)
+		return
+	}
+	req, email, err := decodeSCIMUser(r)
+	if err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+	active := req.Active == nil || *req.Active
+	changes := User.AccountChanges{Username: &req.UserName, Email: &email, Active: &active}
+	if req.Password != "" {
+		changes.Password = &req.Password
+	}
+	h.updateSCIMUser(w, r, id, changes)
+}
+
+func (h *Handler) updateSCIMUser(w http.ResponseWriter, r *http.Request, id int64, changes User.AccountChanges) {
+	actor, _ := User.UIDFromContext(r.Context())
+	user, err := h.UserService.UpdateAccount(r.Context(), actor, id, changes)
+	if err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+	writeSCIM(w, http.StatusOK, h.scimUser(user))
+}
+
+// SCIMPatchUser applies add, replace and remove operations to userName,
+// emails, active and password. Operations on other attributes are ignored.
+func (h *Handler) SCIMPatchUser(w http.ResponseWriter, r *http.Request) {
+	id, err := userID(r)
+	if err != nil {
+		writeSCIMError(w, User.ErrUserNotFound)
+		return
+	}
+	var req SCIMPatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Operations) == 0 {
+		writeSCIMError(w, scimBadRequest("invalidSyntax", "the body is not a SCIM patch request"))
+		return
+	}
+
+	var changes User.AccountChanges
+	for _, op := range req.Operations {
+		if err := applyUserPatch(&changes, op); err != nil {
+			writeSCIMError(w, err)
+			return
+		}
+	}
+	if changes.Email != nil {
+		if err := validator.New().Var(*changes.Email, "required,email,max=100"); err != nil {
+			writeSCIMError(w, scimBadRequest("invalidValue", "a valid email is required"))
+			return
+		}
+	}
+	if changes.Username != nil && (*changes.Username == "" || len(*changes.Username) > 100) {
+		writeSCIMError(w, scimBadRequest("invalidValue", "userName is required and at most 100 characters"))
+		return
+	}
+	h.updateSCIMUser(w, r, id, changes)
+}
+
+func applyUserPatch(changes *User.AccountChanges, op SCIMPatchOperation) error {
+	kind := strings.ToLower(op.Op)
+	if kind != "add" && kind != "replace" && kind != "remove" {
+		return scimBadRequest("invalidSyntax", "unknown operation %q", op.Op)
+	}
+
+	// Without a path the value holds the attributes to set.
+	if op.Path == "" {
+		if kind == "remove" {
+			return scimBadRequest("noTarget", "remove needs a path")
+		}
+		var attributes map[string]json.RawMessage
+		if err := json.Unmarshal(op.Value, &attributes); err != nil {
+			return scimBadRequest("invalidValue", "the value must be an object")
+		}
+		for name, value := range attributes {
+			if err := applyUserPatch(changes, SCIMPatchOperation{Op: kind, Path: name, Value: value}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	attribute := strings.ToLower(op.Path)
+	if i := strings.IndexAny(attribute, "[."); i >= 0 {
+		attribute = attribute[:i]
+	}
+	if kind == "remove" {
+		switch attribute {
+		case "username", "emails":
+			return scimBadRequest("mutability", "%s is required", op.Path)
+		}
+		return nil
+	}
+
+	switch attribute {
+	case "username":
+		var username string
+		if err := json.Unmarshal(op.Value, &username); err != nil {
+			return scimBadRequest("invalidValue", "userName must be a string")
+		}
+		changes.Username = &username
+	case "password":
+		var password string
+		if err := json.Unmarshal(op.Value, &password); err != nil {
+			return scimBadRequest("invalidValue", "password must be a string")
+		}
+		changes.Password = &password
+	case "active":
+		active, err := scimBool(op.Value)
+		if err != nil {
+			return err
+		}
+		changes.Active = &active
+	case "emails":
+		// Either the whole list or, with a path such as
+		// emails[type eq "work"].value, the address alone.
+		var email string
+		if err := json.Unmarshal(op.Value, &email); err != nil {
+			var emails []SCIMEmail
+			if err := json.Unmarshal(op.Value, &emails); err != nil {
+				return scimBadRequest("invalidValue", "emails must be a list of addresses")
+			}
+			email = primaryEmail(emails)
+		}
+		changes.Email = &email
+	}
+	return nil
+}
+
+// scimBool accepts true as well as "True", which some clients send.
+func scimBool(raw json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(raw, &b); err == nil {
+		return b, nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		if b, err := strconv.ParseBool(strings.ToLower(s)); err == nil {
+			return b, nil
+		}
+	}
+	return false, scimBadRequest("invalidValue", "active must be a boolean")
+}
+
+func (h *Handler) SCIMDeleteUser(w http.ResponseWriter, r *http.Request) {
+	id, err := userID(r)
+	if err != nil {
+		writeSCIMError(w, User.ErrUserNotFound)
+		return
+	}
+	actor, _ := User.UIDFromContext(r.Context())
+	if err := h.UserService.DeleteUser(r.Context(), actor, id); err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+	log.Infof("SCIM deprovisioned user %d", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// scimRoles lists the roles from most to least privileged, so groups come
+// out in a stable order.
+var scimRoles = []User.Role{User.RoleAdmin, User.RoleRegistrar, User.RoleTeacher, User.RoleReadOnly}
+
+func (h *Handler) scimGroup(r *http.Request, role User.Role) (SCIMGroup, error) {
+	group := SCIMGroup{
+		Schemas:     []string{scimGroupSchema},
+		ID:          string(role),
+		DisplayName: string(role),
+		Members:     []SCIMGroupRef{},
+		Meta:        &SCIMMeta{ResourceType: "Group", Location: h.scimLocation("Groups", string(role))},
+	}
+	if strings.Contains(r.URL.Query().Get("excludedAttributes"), "members") {
+		return group, nil
+	}
+	members, err := h.roleMembers(r, role)
+	if err != nil {
+		return SCIMGroup{}, err
+	}
+	for _, user := range members {
+		id := strconv.FormatInt(user.UID, 10)
+		group.Members = append(group.Members, SCIMGroupRef{Value: id, Display: user.Username, Ref: h.scimLocation("Users", id)})
+	}
+	return group, nil
+}
+
+func (h *Handler) roleMembers(r *http.Request, role User.Role) ([]User.User, error) {
+	var members []User.User
+	opts := User.UserListOptions{Role: role, Limit: User.MaxUserListLimit}
+	for {
+		page, err := h.UserService.ListUsers(r.Context(), opts)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, page.Users...)
+		opts.Offset += len(page.Users)
+		if len(page.Users) == 0 || int64(opts.Offset) >= page.Total {
+			return members, nil
+		}
+	}
+}
+
+func (h *Handler) SCIMListGroups(w http.ResponseWriter, r *http.Request) {
+	startIndex, count, err := scimPage(r)
+	if err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+	roles := scimRoles
+	if filter := r.URL.Query().Get("filter"); filter != "" {
+		attribute, value, err := parseEqFilter(filter)
+		if err != nil {
+			writeSCIMError(w, err)
+			return
+		}
+		if !strings.EqualFold(attribute, "displayName") && !strings.EqualFold(attribute, "id") {
+			writeSCIMError(w, scimBadRequest("invalidFilter", "filtering is only supported on displayName"))
+			return
+		}
+		roles = nil
+		if User.Role(value).Valid() {
+			roles = []User.Role{User.Role(value)}
+		}
+	}
+
+	resources := []interface{}{}
+	for i := startIndex - 1; i < len(roles) && len(resources) < count; i++ {
+		group, err := h.scimGroup(r, roles[i])
+		if err != nil {
+			writeSCIMError(w, err)
+			return
+		}
+		resources = append(resources, group)
+	}
+	writeSCIM(w, http.StatusOK, scimList(int64(len(roles)), startIndex, resources))
+}
+
+func (h *Handler) SCIMGetGroup(w http.ResponseWriter, r *http.Request) {
+	role := User.Role(mux.Vars(r)["id"])
+	if !role.Valid() {
+		writeSCIMError(w, User.ErrInvalidRole)
+		return
+	}
+	group, err := h.scimGroup(r, role)
+	if err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+	writeSCIM(w, http.StatusOK, group)
+}
+
+// SCIMPatchGroup adds and removes members. Adding a user moves them to the
+// role; removing them drops them to User.DefaultRole.
+func (h *Handler) SCIMPatchGroup(w http.ResponseWriter, r *http.Request) {
+	role := User.Role(mux.Vars(r)["id"])
+	if !role.Valid() {
+		writeSCIMError(w, User.ErrInvalidRole)
+		return
+	}
+	var req SCIMPatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Operations) == 0 {
+		writeSCIMError(w, scimBadRequest("invalidSyntax", "the body is not a SCIM patch request"))
+		return
+	}
+
+	for _, op := range req.Operations {
+		var err error
+		switch kind := strings.ToLower(op.Op); {
+		case !strings.HasPrefix(strings.ToLower(op.Path), "members"):
+			err = scimBadRequest("mutability", "groups are fixed roles; only members can change")
+		case kind == "add":
+			err = h.addSCIMMembers(r, role, op.Value)
+		case kind == "remove":
+			err = h.removeSCIMMembers(r, role, op)
+		case kind == "replace":
+			err = h.replaceSCIMMembers(r, role, op.Value)
+		default:
+			err = scimBadRequest("invalidSyntax", "unknown operation %q", op.Op)
+		}
+		if err != nil {
+			writeSCIMError(w, err)
+			return
+		}
+	}
+	h.SCIMGetGroup(w, r)
+}
+
+// SCIMReplaceGroup handles PUT, which sets the complete member list.
+func (h *Handler) SCIMReplaceGroup(w http.ResponseWriter, r *http.Request) {
+	role := User.Role(mux.Vars(r)["id"])
+	if !role.Valid() {
+		writeSCIMError(w, User.ErrInvalidRole)
+		return
+	}
+	var req struct {
+		Members json.RawMessage `json:"members"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeSCIMError(w, scimBadRequest("invalidSyntax", "the body is not a SCIM group"))
+		return
+	}
+	if len(req.Members) == 0 {
+		req.Members = json.RawMessage("[]")
+	}
+	if err := h.replaceSCIMMembers(r, role, req.Members); err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+	h.SCIMGetGroup(w, r)
+}
+
+func scimMemberIDs(raw json.RawMessage) ([]int64, error) {
+	var members []SCIMGroupRef
+	if err := json.Unmarshal(raw, &members); err != nil {
+		return nil, scimBadRequest("invalidValue", "members must be a list of {\"value\": id}")
+	}
+	ids := make([]int64, 0, len(members))
+	for _, m := range members {
+		id, err := strconv.ParseInt(m.Value, 10, 64)
+		if err != nil {
+			return nil, scimBadRequest("invalidValue", "unknown member %q", m.Value)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (h *Handler) addSCIMMembers(r *http.Request, role User.Role, raw json.RawMessage) error {
+	ids, err := scimMemberIDs(raw)
+	if err != nil {
+		return err
+	}
+	return h.assignSCIMMembers(r, role, ids)
+}
+
+// removeSCIMMembers takes the members from the value or, as some clients
+// send it, from a members[value eq "<id>"] path.
+func (h *Handler) removeSCIMMembers(r *http.Request, role User.Role, op SCIMPatchOperation) error {
+	var ids []int64
+	if _, filter, ok := strings.Cut(op.Path, "["); ok {
+		_, value, err := parseEqFilter(strings.TrimSuffix(filter, "]"))
+		if err != nil {
+			return err
+		}
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return scimBadRequest("invalidValue", "unknown member %q", value)
+		}
+		ids = append(ids, id)
+	} else if len(op.Value) > 0 {
+		var err error
+		if ids, err = scimMemberIDs(op.Value); err != nil {
+			return err
+		}
+	} else {
+		return h.replaceSCIMMembers(r, role, json.RawMessage("[]"))
+	}
+
+	for _, id := range ids {
+		if err := h.removeSCIMMember(r, role, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *Handler) removeSCIMMember(r *http.Request, role User.Role, id int64) error {
+	user, err := h.UserService.GetUser(r.Context(), id)
+	if errors.Is(err, User.ErrUserNotFound) {
+		return scimBadRequest("invalidValue", "unknown member %d", id)
+	}
+	if err != nil {
+		return err
+	}
+	if user.Role != role || role == User.DefaultRole {
+		return nil
+	}
+	return h.UserService.AssignRole(r.Context(), id, User.DefaultRole)
+}
+
+func (h *Handler) replaceSCIMMembers(r *http.Request, role User.Role, raw json.RawMessage) error {
+	ids, err := scimMemberIDs(raw)
+	if err != nil {
+		return err
+	}
+	keep := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		keep[id] = true
+	}
+
+	current, err := h.roleMembers(r, role)
+	if err != nil {
+		return err
+	}
+	// Refuse up front what AssignRole would refuse halfway through.
+	if role == User.RoleAdmin {
+		if len(ids) == 0 {
+			return User.ErrLastAdmin
+		}
+		if actor, ok := User.UIDFromContext(r.Context()); ok && !keep[actor] {
+			for _, member := range current {
+				if member.UID == actor {
+					return User.ErrCannotDemoteSelf
+				}
+			}
+		}
+	}
+	// New members go in first, so swapping out the only admin works.
+	if err := h.assignSCIMMembers(r, role, ids); err != nil {
+		return err
+	}
+	for _, member := range current {
+		if !keep[member.UID] {
+			if err := h.removeSCIMMember(r, role, member.UID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (h *Handler) assignSCIMMembers(r *http.Request, role User.Role, ids []int64) error {
+	for _, id := range ids {
+		err := h.UserService.AssignRole(r.Context(), id, role)
+		if errors.Is(err, User.ErrUserNotFound) {
+			return scimBadRequest("invalidValue", "unknown member %d", id)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"Students-Final-Assignment/Internal/User"
+)
+
+// scimKey returns an API key of username with the users:manage scope, as a
+// provisioning client would hold.
+func (e *testEnv) scimKey(username string) string {
+	e.t.Helper()
+	u, err := e.users.GetUserByUsername(e.ctx, username)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	created, err := e.h.UserService.CreateAPIKey(e.ctx, u.UID, User.NewAPIKey{Name: "scim", Scopes: []User.Permission{User.PermManageUsers}})
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	return created.Key
+}
+
+func decodeSCIMError(t *testing.T, rec *httptest.ResponseRecorder) SCIMError {
+	t.Helper()
+	if ct := rec.Header().Get("Content-Type"); ct != scimContentType {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	var body SCIMError
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Schemas) != 1 || body.Schemas[0] != scimErrorSchema || body.Status != strconv.Itoa(rec.Code) {
+		t.Errorf("error body = %+v", body)
+	}
+	return body
+}
+
+func (e *testEnv) role(uid int64) User.Role {
+	e.t.Helper()
+	u, err := e.users.GetUserByID(e.ctx, uid)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	return u.Role
+}
+
+func TestSCIMUserLifecycle(t *testing.T) {
+	e := newTestEnv(t)
+	e.addUser("admin", User.RoleAdmin)
+	key := e.scimKey("admin")
+
+	rec := e.do("POST", "/scim/v2/Users", key, `{"schemas":["`+scimUserSchema+`"],"userName":"ann","emails":[{"value":"ann@example.org","primary":true}],"active":false}`)
+	expectStatus(t, rec, http.StatusCreated)
+	var created SCIMUser
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+	if created.UserName != "ann" || *created.Active || rec.Header().Get("Location") != created.Meta.Location {
+		t.Errorf("created = %+v", created)
+	}
+	path := "/scim/v2/Users/" + created.ID
+
+	rec = e.do("POST", "/scim/v2/Users", key, `{"userName":"ann","emails":[{"value":"ann@example.org"}]}`)
+	expectStatus(t, rec, http.StatusConflict)
+	if body := decodeSCIMError(t, rec); body.SCIMType != "uniqueness" {
+		t.Errorf("duplicate: %+v", body)
+	}
+
+	rec = e.do("GET", `/scim/v2/Users?filter=userName%20eq%20%22ann%22`, key, "")
+	expectStatus(t, rec, http.StatusOK)
+	var list SCIMListResponse
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil || list.TotalResults != 1 {
+		t.Errorf("filtered list = %+v, %v", list, err)
+	}
+
+	expectStatus(t, e.do("PATCH", path, key, `{"Operations":[{"op":"replace","value":{"active":"True","emails":[{"value":"ann@example.com"}]}}]}`), http.StatusOK)
+	ann, _ := e.users.GetUserByUsername(e.ctx, "ann")
+	if ann.Disabled() || ann.Email != "ann@example.com" || ann.EmailVerifiedAt == nil {
+		t.Errorf("patched = %+v", ann)
+	}
+	expectStatus(t, e.do("DELETE", path, key, ""), http.StatusNoContent)
+	decodeSCIMError(t, e.do("GET", path, key, ""))
+}
+
+func TestSCIMRefusesToChangeSuperAdmins(t *testing.T) {
+	e := newTestEnv(t)
+	e.addUser("admin", User.RoleAdmin)
+	root := e.addUser("root", User.RoleSuperAdmin)
+	key := e.scimKey("admin")
+
+	for _, tc := range []struct{ method, path, body string }{
+		{"PUT", "/scim/v2/Groups/superadmin", `{"members":[]}`},
+		{"PATCH", "/scim/v2/Groups/admin", fmt.Sprintf(`{"Operations":[{"op":"add","path":"members","value":[{"value":"%d"}]}]}`, root)},
+	} {
+		rec := e.do(tc.method, tc.path, key, tc.body)
+		expectStatus(t, rec, http.StatusForbidden)
+		decodeSCIMError(t, rec)
+	}
+	if role := e.role(root); role != User.RoleSuperAdmin {
+		t.Errorf("super-admin is now %s", role)
+	}
+}
+
+func TestSCIMKeepsTheTenantsAdmins(t *testing.T) {
+	e := newTestEnv(t)
+	caller := e.addUser("admin", User.RoleAdmin)
+	other := e.addUser("other", User.RoleAdmin)
+	teacher := e.addUser("teacher", User.RoleTeacher)
+	key := e.scimKey("admin")
+
+	for _, tc := range []struct {
+		method, body string
+		want         int
+	}{
+		{"PUT", `{"members":[]}`, http.StatusConflict},
+		{"PUT", `{}`, http.StatusConflict},
+		{"PATCH", `{"Operations":[{"op":"remove","path":"members"}]}`, http.StatusConflict},
+		{"PUT", fmt.Sprintf(`{"members":[{"value":"%d"}]}`, other), http.StatusForbidden},
+		{"PATCH", fmt.Sprintf(`{"Operations":[{"op":"remove","path":"members[value eq \"%d\"]"}]}`, caller), http.StatusForbidden},
+	} {
+		rec := e.do(tc.method, "/scim/v2/Groups/admin", key, tc.body)
+		expectStatus(t, rec, tc.want)
+		decodeSCIMError(t, rec)
+		if e.role(caller) != User.RoleAdmin || e.role(other) != User.RoleAdmin || e.role(teacher) != User.RoleTeacher {
+			t.Fatalf("%s %s changed roles", tc.method, tc.body)
+		}
+	}
+
+	// Swapping the other admin for the teacher keeps the caller.
+	expectStatus(t, e.do("PUT", "/scim/v2/Groups/admin", key, fmt.Sprintf(`{"members":[{"value":"%d"},{"value":"%d"}]}`, caller, teacher)), http.StatusOK)
+	if e.role(other) != User.DefaultRole || e.role(teacher) != User.RoleAdmin {
+		t.Errorf("roles after swap: other %s, teacher %s", e.role(other), e.role(teacher))
+	}
+}
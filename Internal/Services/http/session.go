@@ -0,0 +1,84 @@
+package http
+
+import (
+	"Students-Final-Assignment/Internal/User"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (h *Handler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var req RefreshTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := h.UserService.Refresh(r.Context(), req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, User.ErrInvalidRefreshToken) || errors.Is(err, User.ErrRefreshTokenReused) {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(tokens); err != nil {
+		panic(err)
+	}
+}
+
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	sessionID, _ := User.SessionIDFromContext(r.Context())
+	if err := h.UserService.Logout(r.Context(), sessionID); err != nil {
+		log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) RevokeUserSessions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	n, err := h.UserService.RevokeAllSessions(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, User.ErrUserNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	log.Infof("user %s revoked %d sessions of user %d", User.ActorFromContext(r.Context()), n, id)
+	if err := json.NewEncoder(w).Encode(map[string]int64{"revoked": n}); err != nil {
+		panic(err)
+	}
+}
+
+// JWKS publishes the public signing keys so other services can verify our
+// access tokens.
+func (h *Handler) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	if err := json.NewEncoder(w).Encode(h.UserService.JWKS()); err != nil {
+		log.Error(err)
+	}
+}
@@ -0,0 +1,42 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"Students-Final-Assignment/Internal/User"
+)
+
+func TestRefreshLogoutAndRevokeEndpoints(t *testing.T) {
+	e := newTestEnv(t)
+	uid := e.addUser("ann", User.RoleReadOnly)
+	e.addUser("admin", User.RoleAdmin)
+	tokens, err := e.h.UserService.Login(e.ctx, "ann", testPassword, "192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := e.do("POST", "/api/v1/token/refresh", "", fmt.Sprintf(`{"refresh_token":%q}`, tokens.RefreshToken))
+	expectStatus(t, rec, http.StatusOK)
+	var refreshed User.TokenPair
+	if err := json.NewDecoder(rec.Body).Decode(&refreshed); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, e.do("GET", "/api/v1/students", tokens.AccessToken, ""), http.StatusUnauthorized)
+	expectStatus(t, e.do("GET", "/api/v1/students", refreshed.AccessToken, ""), http.StatusOK)
+
+	// Presenting the spent refresh token again ends the session.
+	expectStatus(t, e.do("POST", "/api/v1/token/refresh", "", fmt.Sprintf(`{"refresh_token":%q}`, tokens.RefreshToken)), http.StatusUnauthorized)
+	expectStatus(t, e.do("GET", "/api/v1/students", refreshed.AccessToken, ""), http.StatusUnauthorized)
+	expectStatus(t, e.do("POST", "/api/v1/token/refresh", "", `{}`), http.StatusBadRequest)
+
+	access := e.token("ann")
+	expectStatus(t, e.do("POST", "/api/v1/logout", access, ""), http.StatusNoContent)
+	expectStatus(t, e.do("GET", "/api/v1/students", access, ""), http.StatusUnauthorized)
+
+	access = e.token("ann")
+	expectStatus(t, e.do("POST", fmt.Sprintf("/api/v1/admin/users/%d/revoke-sessions", uid), e.token("admin"), ""), http.StatusOK)
+	expectStatus(t, e.do("GET", "/api/v1/students", access, ""), http.StatusUnauthorized)
+}
@@ -4,6 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
 	"strconv"
 	"time"
@@ -19,8 +22,18 @@ import (
 type StudentService interface {
 	GetStudent(ctx context.Context, ID int64) (student.Student, error)
 	PostStudent(ctx context.Context, s student.Student) (student.Student, error)
-	UpdateStudent(ctx context.Context, ID int64, s student.Student) (student.Student, error)
-	DeleteStudent(ctx context.Context, ID int64) error
+	UpdateStudent(ctx context.Context, ID int64, s student.Student, ifVersion int64) (student.Student, error)
+	PatchStudent(ctx context.Context, ID int64, patch student.StudentPatch, ifVersion int64) (student.Student, error)
+	GetStudentHistory(ctx context.Context, ID int64) ([]student.HistoryEntry, error)
+	GetStudentAsOf(ctx context.Context, ID int64, asOf time.Time) (student.Student, error)
+	DeleteStudent(ctx context.Context, ID int64, ifVersion int64) error
+	RestoreStudent(ctx context.Context, ID int64) (student.Student, error)
+	ListTrash(ctx context.Context, opts student.ListOptions) (student.StudentPage, error)
+	PurgeTrash(ctx context.Context) (int64, error)
+	ListStudents(ctx context.Context, opts student.ListOptions) (student.StudentPage, error)
+	ExportStudents(ctx context.Context, opts student.ListOptions, fn func(student.Student) error) error
+	ImportStudents(ctx context.Context, students []student.Student, dryRun bool) ([]student.Student, error)
+	SearchStudents(ctx context.Context, query string, limit int) ([]student.SearchResult, error)
 	ReadyCheck(ctx context.Context) error
 }
 
@@ -38,9 +51,22 @@ func (h *Handler) GetStudent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s, err := h.Service.GetStudent(r.Context(), id)
+	var s student.Student
+	if asOf := r.URL.Query().Get("as_of"); asOf != "" {
+		t, _, parseErr := parseQueryTime(asOf)
+		if parseErr != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s, err = h.Service.GetStudentAsOf(r.Context(), id, t)
+	} else {
+		s, err = h.Service.GetStudent(r.Context(), id)
+		if err == nil {
+			setStudentETag(w, s)
+		}
+	}
 	if err != nil {
-		if errors.Is(err, student.ErrFetchingStudent) {
+		if errors.Is(err, student.ErrFetchingStudent) || errors.Is(err, student.ErrNoStudentFound) {
 			w.WriteHeader(http.StatusNotFound)
 			return
 		}
@@ -56,7 +82,7 @@ func (h *Handler) GetStudent(w http.ResponseWriter, r *http.Request) {
 type PostStudentRequest struct {
 	FirstName   string `json:"fname" validate:"required"`
 	LastName    string `json:"lname" validate:"required"`
-	DateOfBirth string `json:"date_of_birth" validate:"required"`
+	DateOfBirth string `json:"date_of_birth" validate:"required,datetime=2006-01-02"`
 	Email       string `json:"email" validate:"required,email"`
 	Address     string `json:"address" validate:"required"`
 	Gender      string `json:"gender" validate:"required"`
@@ -95,6 +121,7 @@ func (h *Handler) PostStudent(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	setStudentETag(w, s)
 	if err := json.NewEncoder(w).Encode(s); err != nil {
 		panic(err)
 	}
@@ -134,6 +161,12 @@ func (h *Handler) UpdateStudent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ifVersion, err := ifMatchVersion(r, id)
+	if err != nil {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+
 	var updateStudentRequest UpdateStudentRequest
 	if err := json.NewDecoder(r.Body).Decode(&updateStudentRequest); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
@@ -148,17 +181,186 @@ func (h *Handler) UpdateStudent(w http.ResponseWriter, r *http.Request) {
 	}
 
 	s := studentFromUpdateStudentRequest(updateStudentRequest)
-	s, err = h.Service.UpdateStudent(r.Context(), id, s)
+	s, err = h.Service.UpdateStudent(r.Context(), id, s, ifVersion)
 	if err != nil {
+		if errors.Is(err, student.ErrNoStudentFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, student.ErrConflict) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
 		log.Error(err.Error())
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	setStudentETag(w, s)
 	if err := json.NewEncoder(w).Encode(s); err != nil {
 		panic(err)
 	}
 }
 
+// PatchStudentRequest is a JSON merge patch (RFC 7396). Only the fields
+// present in the body are validated and written.
+type PatchStudentRequest struct {
+	FirstName   *string    `json:"fname" validate:"omitnil,min=1"`
+	LastName    *string    `json:"lname" validate:"omitnil,min=1"`
+	DateOfBirth *time.Time `json:"date_of_birth" validate:"omitnil"`
+	Email       *string    `json:"email" validate:"omitnil,email"`
+	Address     *string    `json:"address" validate:"omitnil,min=1"`
+	Gender      *string    `json:"gender" validate:"omitnil,min=1"`
+}
+
+var patchStudentFields = map[string]bool{
+	"fname":         true,
+	"lname":         true,
+	"date_of_birth": true,
+	"email":         true,
+	"address":       true,
+	"gender":        true,
+}
+
+// decodePatchStudentRequest rejects unknown members and nulls: every
+// student column is required, so removing one is not a valid patch.
+func decodePatchStudentRequest(body io.Reader) (PatchStudentRequest, error) {
+	var raw map[string]json.RawMessage
+	if err := json.NewDecoder(body).Decode(&raw); err != nil {
+		return PatchStudentRequest{}, err
+	}
+	for k, v := range raw {
+		if !patchStudentFields[k] {
+			return PatchStudentRequest{}, fmt.Errorf("unknown field %q", k)
+		}
+		if string(v) == "null" {
+			return PatchStudentRequest{}, fmt.Errorf("field %q cannot be removed", k)
+		}
+	}
+
+	var req PatchStudentRequest
+	for k, v := range raw {
+		var err error
+		switch k {
+		case "fname":
+			err = json.Unmarshal(v, &req.FirstName)
+		case "lname":
+			err = json.Unmarshal(v, &req.LastName)
+		case "date_of_birth":
+			err = json.Unmarshal(v, &req.DateOfBirth)
+		case "email":
+			err = json.Unmarshal(v, &req.Email)
+		case "address":
+			err = json.Unmarshal(v, &req.Address)
+		case "gender":
+			err = json.Unmarshal(v, &req.Gender)
+		}
+		if err != nil {
+			return PatchStudentRequest{}, fmt.Errorf("invalid %s: %w", k, err)
+		}
+	}
+	return req, nil
+}
+
+func studentPatchFromPatchStudentRequest(u PatchStudentRequest) student.StudentPatch {
+	return student.StudentPatch{
+		Fname:       u.FirstName,
+		Lname:       u.LastName,
+		DateOfBirth: u.DateOfBirth,
+		Email:       u.Email,
+		Address:     u.Address,
+		Gender:      u.Gender,
+	}
+}
+
+func (h *Handler) PatchStudent(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+	if idStr == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/merge-patch+json" && mediaType != "application/json" {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	ifVersion, err := ifMatchVersion(r, id)
+	if err != nil {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+
+	patchStudentRequest, err := decodePatchStudentRequest(r.Body)
+	if err != nil {
+		log.Info(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(patchStudentRequest); err != nil {
+		log.Info(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	patch := studentPatchFromPatchStudentRequest(patchStudentRequest)
+	s, err := h.Service.PatchStudent(r.Context(), id, patch, ifVersion)
+	if err != nil {
+		if errors.Is(err, student.ErrNoStudentFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, student.ErrConflict) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	setStudentETag(w, s)
+	if err := json.NewEncoder(w).Encode(s); err != nil {
+		panic(err)
+	}
+}
+
+func (h *Handler) GetStudentHistory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+	if idStr == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	history, err := h.Service.GetStudentHistory(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, student.ErrNoStudentFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(history); err != nil {
+		panic(err)
+	}
+}
+
 func (h *Handler) DeleteStudent(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	idStr := vars["id"]
@@ -173,9 +375,19 @@ func (h *Handler) DeleteStudent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.Service.DeleteStudent(r.Context(), id)
+	ifVersion, err := ifMatchVersion(r, id)
 	if err != nil {
-		if errors.Is(err, student.ErrDeletingStudent) {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+
+	err = h.Service.DeleteStudent(r.Context(), id, ifVersion)
+	if err != nil {
+		if errors.Is(err, student.ErrConflict) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		if errors.Is(err, student.ErrDeletingStudent) || errors.Is(err, student.ErrNoStudentFound) {
 			w.WriteHeader(http.StatusNotFound)
 			return
 		}
@@ -0,0 +1,39 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"Students-Final-Assignment/Internal/Student"
+	"Students-Final-Assignment/Internal/User"
+)
+
+func TestAuditColumnsNameTheAuthenticatedUser(t *testing.T) {
+	e := newTestEnv(t)
+	creator := e.addUser("creator", User.RoleTeacher)
+	editor := e.addUser("editor", User.RoleTeacher)
+
+	body := `{"fname":"Ann","lname":"Lee","date_of_birth":"2001-02-03","email":"ann@example.org","address":"1 Main St","gender":"f"}`
+	rec := e.do("POST", "/api/v1/student", e.token("creator"), body)
+	expectStatus(t, rec, http.StatusOK)
+	var created Student.Student
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+	if created.CreatedBy != strconv.FormatInt(creator, 10) || created.UpdatedBy != created.CreatedBy || created.UpdatedOn.IsZero() {
+		t.Fatalf("created = %+v", created)
+	}
+
+	rec = e.do("PATCH", fmt.Sprintf("/api/v1/student/%d", created.ID), e.token("editor"), `{"gender":"x"}`, "Content-Type", mergePatch)
+	expectStatus(t, rec, http.StatusOK)
+	var updated Student.Student
+	if err := json.NewDecoder(rec.Body).Decode(&updated); err != nil {
+		t.Fatal(err)
+	}
+	if updated.CreatedBy != created.CreatedBy || updated.UpdatedBy != strconv.FormatInt(editor, 10) || updated.UpdatedOn.Before(created.UpdatedOn) {
+		t.Errorf("updated = %+v", updated)
+	}
+}
@@ -0,0 +1,308 @@
+package http
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	student "Students-Final-Assignment/Internal/Student"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	exportCSV   = "csv"
+	exportJSONL = "jsonl"
+	exportXLSX  = "xlsx"
+)
+
+// exportWriteTimeout is how long writing one row may take. The deadline is
+// pushed forward for every row, so an export may run as long as it keeps
+// making progress.
+const exportWriteTimeout = 15 * time.Second
+
+// exportFormats lists the formats in order of preference, which breaks ties
+// between equally acceptable media types.
+var exportFormats = []struct {
+	format      string
+	contentType string
+}{
+	{exportCSV, "text/csv; charset=UTF-8"},
+	{exportJSONL, "application/x-ndjson"},
+	{exportXLSX, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"},
+}
+
+func exportContentType(format string) (string, bool) {
+	for _, f := range exportFormats {
+		if f.format == format {
+			return f.contentType, true
+		}
+	}
+	return "", false
+}
+
+type exportColumn struct {
+	name  string
+	value func(student.Student) interface{}
+}
+
+// exportColumns lists every column a caller may ask for, in the default order.
+var exportColumns = []exportColumn{
+	{"id", func(s student.Student) interface{} { return s.ID }},
+	{"fname", func(s student.Student) interface{} { return s.Fname }},
+	{"lname", func(s student.Student) interface{} { return s.Lname }},
+	{"date_of_birth", func(s student.Student) interface{} { return s.DateOfBirth.Format("2006-01-02") }},
+	{"email", func(s student.Student) interface{} { return s.Email }},
+	{"address", func(s student.Student) interface{} { return s.Address }},
+	{"gender", func(s student.Student) interface{} { return s.Gender }},
+	{"created_by", func(s student.Student) interface{} { return s.CreatedBy }},
+	{"created_on", func(s student.Student) interface{} { return s.CreatedOn.Format(time.RFC3339) }},
+	{"updated_by", func(s student.Student) interface{} { return s.UpdatedBy }},
+	{"updated_on", func(s student.Student) interface{} { return s.UpdatedOn.Format(time.RFC3339) }},
+}
+
+func parseExportColumns(v string) ([]exportColumn, error) {
+	if v == "" {
+		return exportColumns, nil
+	}
+
+	byName := map[string]exportColumn{}
+	for _, c := range exportColumns {
+		byName[c.name] = c
+	}
+	var cols []exportColumn
+	for _, name := range strings.Split(v, ",") {
+		name = strings.TrimSpace(name)
+		c, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown export column %q", name)
+		}
+		cols = append(cols, c)
+	}
+	return cols, nil
+}
+
+// exportFormat picks the output format from ?format= and falls back to the
+// Accept header, then to CSV.
+func exportFormat(r *http.Request) string {
+	if f := r.URL.Query().Get("format"); f != "" {
+		return strings.ToLower(f)
+	}
+	if format := negotiateExportFormat(r.Header.Get("Accept")); format != "" {
+		return format
+	}
+	return exportCSV
+}
+
+// negotiateExportFormat returns the format with the highest q-value in an
+// Accept header, or "" if the header accepts none of them. Each format takes
+// the q-value of the most specific media range matching it.
+func negotiateExportFormat(accept string) string {
+	best, bestQ := "", 0.0
+	for _, f := range exportFormats {
+		mediaType, _, _ := strings.Cut(f.contentType, ";")
+		mainType, _, _ := strings.Cut(mediaType, "/")
+		q, specificity := 0.0, -1
+		for _, part := range strings.Split(accept, ",") {
+			params := strings.Split(part, ";")
+			rng := strings.ToLower(strings.TrimSpace(params[0]))
+			s := -1
+			switch rng {
+			case mediaType:
+				s = 2
+			case mainType + "/*":
+				s = 1
+			case "*/*":
+				s = 0
+			}
+			if s <= specificity {
+				continue
+			}
+			specificity, q = s, 1
+			for _, p := range params[1:] {
+				if k, v, ok := strings.Cut(strings.TrimSpace(p), "="); ok && strings.EqualFold(k, "q") {
+					if parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+						q = parsed
+					}
+				}
+			}
+		}
+		if q > bestQ {
+			best, bestQ = f.format, q
+		}
+	}
+	return best
+}
+
+// spreadsheetCell stops a spreadsheet from evaluating an exported value as
+// a formula, by prefixing text that would start one with a quote.
+func spreadsheetCell(v interface{}) string {
+	s := fmt.Sprint(v)
+	if _, ok := v.(string); ok && s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// rowWriter is the common shape of the export encoders.
+type rowWriter interface {
+	WriteRow(values []interface{}) error
+	Close() error
+}
+
+type csvRowWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvRowWriter) WriteRow(values []interface{}) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		record[i] = spreadsheetCell(v)
+	}
+	return c.w.Write(record)
+}
+
+func (c *csvRowWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type jsonlRowWriter struct {
+	enc     *json.Encoder
+	columns []string
+}
+
+func (j *jsonlRowWriter) WriteRow(values []interface{}) error {
+	obj := make(map[string]interface{}, len(values))
+	for i, v := range values {
+		obj[j.columns[i]] = v
+	}
+	return j.enc.Encode(obj)
+}
+
+func (j *jsonlRowWriter) Close() error { return nil }
+
+type xlsxRowWriter struct {
+	x *xlsxWriter
+}
+
+func (x *xlsxRowWriter) WriteRow(values []interface{}) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		record[i] = spreadsheetCell(v)
+	}
+	return x.x.WriteRow(record)
+}
+
+func (x *xlsxRowWriter) Close() error { return x.x.Close() }
+
+// newRowWriter creates the encoder and writes the header row where the
+// format has one.
+func newRowWriter(format string, w io.Writer, columns []string) (rowWriter, error) {
+	header := make([]interface{}, len(columns))
+	for i, c := range columns {
+		header[i] = c
+	}
+
+	var rw rowWriter
+	switch format {
+	case exportCSV:
+		rw = &csvRowWriter{w: csv.NewWriter(w)}
+	case exportJSONL:
+		return &jsonlRowWriter{enc: json.NewEncoder(w), columns: columns}, nil
+	case exportXLSX:
+		x, err := newXLSXWriter(w)
+		if err != nil {
+			return nil, err
+		}
+		rw = &xlsxRowWriter{x: x}
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+	return rw, rw.WriteRow(header)
+}
+
+func (h *Handler) ExportStudents(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	format := exportFormat(r)
+	contentType, ok := exportContentType(format)
+	if !ok {
+		w.WriteHeader(http.StatusNotAcceptable)
+		return
+	}
+
+	opts, err := parseListOptions(q)
+	if err != nil {
+		log.Info(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	columns, err := parseExportColumns(q.Get("columns"))
+	if err != nil {
+		log.Info(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.name
+	}
+
+	// Nothing is written until the first row arrives, so an invalid sort is
+	// still reported as a 400 rather than as a truncated file.
+	var rw rowWriter
+	start := func() (err error) {
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="students.%s"`, format))
+		rw, err = newRowWriter(format, w, names)
+		return err
+	}
+
+	// The server's write timeout would cut off a long export, so every row
+	// pushes the deadline forward instead.
+	rc := http.NewResponseController(w)
+	rows := 0
+	err = h.Service.ExportStudents(r.Context(), opts, func(s student.Student) error {
+		if err := rc.SetWriteDeadline(time.Now().Add(exportWriteTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		if rw == nil {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		values := make([]interface{}, len(columns))
+		for i, c := range columns {
+			values[i] = c.value(s)
+		}
+		rows++
+		return rw.WriteRow(values)
+	})
+	if err != nil && rw == nil {
+		if errors.Is(err, student.ErrInvalidListOptions) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err != nil {
+		log.Errorf("student export aborted after %d rows: %s", rows, err.Error())
+	}
+
+	if rw == nil {
+		if err := start(); err != nil {
+			log.Error(err)
+			return
+		}
+	}
+	if err := rw.Close(); err != nil {
+		log.Error(err)
+	}
+}
@@ -0,0 +1,139 @@
+package http
+
+import (
+	"context"
+	"encoding/csv"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"Students-Final-Assignment/Internal/Student"
+	"Students-Final-Assignment/Internal/User"
+
+	"github.com/gorilla/mux"
+)
+
+func TestNegotiateExportFormat(t *testing.T) {
+	for accept, want := range map[string]string{
+		"":                                     "",
+		"application/json":                     "",
+		"*/*":                                  exportCSV,
+		"application/x-ndjson":                 exportJSONL,
+		"text/csv;q=0.5, application/x-ndjson": exportJSONL,
+		"application/x-ndjson;q=0.2, text/*;q=0.9": exportCSV,
+		"text/csv;q=0, */*;q=0.1":                  exportJSONL,
+		"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet, text/csv;q=0.8": exportXLSX,
+	} {
+		if got := negotiateExportFormat(accept); got != want {
+			t.Errorf("negotiateExportFormat(%q) = %q, want %q", accept, got, want)
+		}
+	}
+}
+
+func TestSpreadsheetCell(t *testing.T) {
+	for v, want := range map[interface{}]string{
+		"=HYPERLINK(\"x\")": "'=HYPERLINK(\"x\")",
+		"+1":                "'+1",
+		"-2+3":              "'-2+3",
+		"@SUM(A1)":          "'@SUM(A1)",
+		"Ann":               "Ann",
+		"":                  "",
+		int64(-5):           "-5",
+	} {
+		if got := spreadsheetCell(v); got != want {
+			t.Errorf("spreadsheetCell(%#v) = %q, want %q", v, got, want)
+		}
+	}
+}
+
+func TestExportStudentsCSV(t *testing.T) {
+	e := newTestEnv(t)
+	e.addUser("registrar", User.RoleRegistrar)
+	_, err := e.students.PostStudent(e.ctx, Student.Student{Fname: "=cmd()", Lname: "Lee", Email: "ann@example.org"}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := e.do("GET", "/api/v1/students/export?columns=fname,lname", e.token("registrar"), "", "Accept", "text/csv, application/x-ndjson;q=0.5")
+	expectStatus(t, rec, http.StatusOK)
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[1][0] != "'=cmd()" || records[1][1] != "Lee" {
+		t.Errorf("records = %q", records)
+	}
+
+	expectStatus(t, e.do("GET", "/api/v1/students/export?format=pdf", e.token("registrar"), ""), http.StatusNotAcceptable)
+}
+
+// slowExportService takes delay to produce each exported row.
+type slowExportService struct {
+	StudentService
+	delay time.Duration
+}
+
+func (s slowExportService) ExportStudents(ctx context.Context, opts Student.ListOptions, fn func(Student.Student) error) error {
+	return s.StudentService.ExportStudents(ctx, opts, func(st Student.Student) error {
+		time.Sleep(s.delay)
+		return fn(st)
+	})
+}
+
+// The export streams through a real server, where the per-row write
+// deadline is supported, well past the server's own write timeout.
+func TestExportStudentsOutlivesWriteTimeout(t *testing.T) {
+	e := newTestEnv(t)
+	e.addUser("registrar", User.RoleRegistrar)
+	for i := 0; i < 3; i++ {
+		if _, err := e.students.PostStudent(e.ctx, Student.Student{Fname: "Ann"}, "test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	slow := slowExportService{StudentService: e.h.Service, delay: 150 * time.Millisecond}
+	e.h.Service = slow
+
+	srv := httptest.NewUnstartedServer(e.h.Router)
+	srv.Config.WriteTimeout = 200 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL+"/api/v1/students/export?format=jsonl", nil)
+	req.Header.Set("Authorization", "Bearer "+e.token("registrar"))
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body := new(strings.Builder)
+	if _, err := io.Copy(body, resp.Body); err != nil {
+		t.Fatalf("export cut off after %q: %s", body.String(), err)
+	}
+	if lines := strings.Count(body.String(), "\n"); lines != 3 {
+		t.Errorf("got %d rows, want 3", lines)
+	}
+}
+
+func TestTimeoutMiddlewareSkipsStreamingRoutes(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(TimeoutMiddleware)
+	deadline := map[string]bool{}
+	record := func(w http.ResponseWriter, r *http.Request) {
+		_, deadline[r.URL.Path] = r.Context().Deadline()
+	}
+	router.HandleFunc("/stream", record).Name(streamingRoute)
+	router.HandleFunc("/plain", record)
+
+	for _, path := range []string{"/stream", "/plain"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	if deadline["/stream"] || !deadline["/plain"] {
+		t.Errorf("deadlines = %v", deadline)
+	}
+}
@@ -0,0 +1,60 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"Students-Final-Assignment/Internal/Student"
+	"Students-Final-Assignment/Internal/User"
+)
+
+func TestStudentHistoryEndpoint(t *testing.T) {
+	e := newTestEnv(t)
+	e.addUser("teacher", User.RoleTeacher)
+	token := e.token("teacher")
+	st := e.addStudent()
+	path := fmt.Sprintf("/api/v1/student/%d", st.ID)
+
+	expectStatus(t, e.do("PATCH", path, token, `{"address":"2 High St"}`, "Content-Type", mergePatch), http.StatusOK)
+
+	rec := e.do("GET", path+"/history", token, "")
+	expectStatus(t, rec, http.StatusOK)
+	var history []Student.HistoryEntry
+	if err := json.NewDecoder(rec.Body).Decode(&history); err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[1].Action != Student.ActionUpdate || history[1].Snapshot.Address != "2 High St" {
+		t.Fatalf("history = %+v", history)
+	}
+	if c := history[1].Changes; len(c) != 1 || c[0].Field != "address" {
+		t.Errorf("changes = %+v", c)
+	}
+
+	expectStatus(t, e.do("GET", "/api/v1/student/999/history", token, ""), http.StatusNotFound)
+}
+
+func TestGetStudentAsOf(t *testing.T) {
+	e := newTestEnv(t)
+	e.addUser("reader", User.RoleReadOnly)
+	token := e.token("reader")
+	st := e.addStudent()
+	path := fmt.Sprintf("/api/v1/student/%d?as_of=", st.ID)
+
+	expectStatus(t, e.do("GET", path+"1999-01-01", token, ""), http.StatusNotFound)
+	expectStatus(t, e.do("GET", path+"last-march", token, ""), http.StatusBadRequest)
+
+	later := url.QueryEscape(time.Now().Add(time.Hour).Format(time.RFC3339))
+	rec := e.do("GET", path+later, token, "")
+	expectStatus(t, rec, http.StatusOK)
+	var got Student.Student
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != st.ID || got.Address != st.Address {
+		t.Errorf("as of later = %+v", got)
+	}
+}
@@ -0,0 +1,222 @@
+package http
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	student "Students-Final-Assignment/Internal/Student"
+
+	"github.com/go-playground/validator/v10"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	maxImportBodyBytes = 10 << 20
+	maxImportRows      = 5000
+)
+
+var importColumns = []string{"fname", "lname", "date_of_birth", "email", "address", "gender"}
+
+type ImportRowResult struct {
+	Row     int              `json:"row"`
+	Status  string           `json:"status"`
+	Errors  []string         `json:"errors,omitempty"`
+	Student *student.Student `json:"student,omitempty"`
+}
+
+type ImportReport struct {
+	DryRun    bool              `json:"dry_run"`
+	Committed bool              `json:"committed"`
+	Total     int               `json:"total"`
+	Accepted  int               `json:"accepted"`
+	Rejected  int               `json:"rejected"`
+	Rows      []ImportRowResult `json:"rows"`
+}
+
+// importRow is one parsed input record. Err is set when the record could not
+// even be decoded into a PostStudentRequest.
+type importRow struct {
+	Line    int
+	Request PostStudentRequest
+	Err     error
+}
+
+func readCSVImport(r io.Reader) ([]importRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("could not read csv header: %w", err)
+	}
+	index := map[string]int{}
+	for i, col := range header {
+		index[strings.ToLower(strings.TrimSpace(col))] = i
+	}
+	for _, col := range importColumns {
+		if _, ok := index[col]; !ok {
+			return nil, fmt.Errorf("csv header is missing column %q", col)
+		}
+	}
+
+	var rows []importRow
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				rows = append(rows, importRow{Line: line, Err: err})
+				continue
+			}
+			return nil, err
+		}
+		field := func(col string) string {
+			if i := index[col]; i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		rows = append(rows, importRow{
+			Line: line,
+			Request: PostStudentRequest{
+				FirstName:   field("fname"),
+				LastName:    field("lname"),
+				DateOfBirth: field("date_of_birth"),
+				Email:       field("email"),
+				Address:     field("address"),
+				Gender:      field("gender"),
+			},
+		})
+	}
+}
+
+func readJSONLinesImport(r io.Reader) ([]importRow, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxImportBodyBytes)
+
+	var rows []importRow
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		row := importRow{Line: line}
+		row.Err = json.Unmarshal([]byte(text), &row.Request)
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read json lines: %w", err)
+	}
+	return rows, nil
+}
+
+// importFormat picks the input format from ?format= or the Content-Type.
+func importFormat(r *http.Request) string {
+	if f := r.URL.Query().Get("format"); f != "" {
+		return strings.ToLower(f)
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/x-ndjson", "application/jsonl", "application/x-jsonlines":
+		return "jsonl"
+	default:
+		return "csv"
+	}
+}
+
+func validationMessages(err error) []string {
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return []string{err.Error()}
+	}
+	msgs := make([]string, 0, len(validationErrs))
+	for _, fe := range validationErrs {
+		msgs = append(msgs, fmt.Sprintf("%s failed on the %q rule", fe.Field(), fe.Tag()))
+	}
+	return msgs
+}
+
+func (h *Handler) ImportStudents(w http.ResponseWriter, r *http.Request) {
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+	body := http.MaxBytesReader(w, r.Body, maxImportBodyBytes)
+
+	var rows []importRow
+	var err error
+	switch importFormat(r) {
+	case "csv":
+		rows, err = readCSVImport(body)
+	case "jsonl":
+		rows, err = readJSONLinesImport(body)
+	default:
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+	if err != nil {
+		log.Info(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if len(rows) > maxImportRows {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	report := ImportReport{DryRun: dryRun, Total: len(rows), Rows: make([]ImportRowResult, 0, len(rows))}
+	var students []student.Student
+	var accepted []int
+
+	validate := validator.New()
+	for _, row := range rows {
+		result := ImportRowResult{Row: row.Line}
+		if row.Err != nil {
+			result.Errors = []string{row.Err.Error()}
+		} else if err := validate.Struct(row.Request); err != nil {
+			result.Errors = validationMessages(err)
+		}
+
+		if len(result.Errors) > 0 {
+			result.Status = "rejected"
+			report.Rejected++
+		} else {
+			result.Status = "accepted"
+			report.Accepted++
+			accepted = append(accepted, len(report.Rows))
+			students = append(students, studentFromPostStudentRequest(row.Request))
+		}
+		report.Rows = append(report.Rows, result)
+	}
+
+	// The import is all or nothing: a single rejected row keeps every row
+	// out of the database, the report says what to fix.
+	if report.Rejected == 0 && len(students) > 0 {
+		imported, err := h.Service.ImportStudents(r.Context(), students, dryRun)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		for i, st := range imported {
+			report.Rows[accepted[i]].Student = &st
+		}
+		report.Committed = !dryRun
+	}
+
+	if report.Rejected > 0 {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	} else if report.Committed {
+		w.WriteHeader(http.StatusCreated)
+	}
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,112 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"Students-Final-Assignment/Internal/Student"
+	"Students-Final-Assignment/Internal/User"
+)
+
+const importCSV = "fname,lname,date_of_birth,email,address,gender\n" +
+	"Ann,Lee,2001-02-03,ann@example.org,1 Main St,f\n" +
+	"Bob,Ray,2002-03-04,bob@example.org,2 Main St,m\n"
+
+func (e *testEnv) studentCount() int64 {
+	e.t.Helper()
+	page, err := e.students.ListStudents(e.ctx, Student.ListOptions{})
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	return page.Total
+}
+
+func decodeImportReport(t *testing.T, body []byte) ImportReport {
+	t.Helper()
+	var report ImportReport
+	if err := json.Unmarshal(body, &report); err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
+
+func TestImportStudentsDryRunStoresNothing(t *testing.T) {
+	e := newTestEnv(t)
+	e.addUser("registrar", User.RoleRegistrar)
+	rec := e.do("POST", "/api/v1/students/import?dry_run=true", e.token("registrar"), importCSV, "Content-Type", "text/csv")
+	expectStatus(t, rec, http.StatusOK)
+
+	report := decodeImportReport(t, rec.Body.Bytes())
+	if !report.DryRun || report.Committed || report.Accepted != 2 || report.Rejected != 0 {
+		t.Fatalf("report = %+v", report)
+	}
+	if n := e.studentCount(); n != 0 {
+		t.Errorf("dry run stored %d students", n)
+	}
+}
+
+func TestImportStudentsCommits(t *testing.T) {
+	e := newTestEnv(t)
+	uid := e.addUser("registrar", User.RoleRegistrar)
+	rec := e.do("POST", "/api/v1/students/import", e.token("registrar"), importCSV, "Content-Type", "text/csv")
+	expectStatus(t, rec, http.StatusCreated)
+
+	report := decodeImportReport(t, rec.Body.Bytes())
+	if !report.Committed || report.Rows[1].Student == nil || report.Rows[1].Student.ID == 0 {
+		t.Fatalf("report = %+v", report)
+	}
+	if n := e.studentCount(); n != 2 {
+		t.Fatalf("stored %d students, want 2", n)
+	}
+	if st := report.Rows[0].Student; st.CreatedBy != strconv.FormatInt(uid, 10) {
+		t.Errorf("CreatedBy = %q", st.CreatedBy)
+	}
+}
+
+func TestImportStudentsRejectedRowKeepsAllOut(t *testing.T) {
+	e := newTestEnv(t)
+	e.addUser("registrar", User.RoleRegistrar)
+	body := importCSV + "Cid,,03/02/2001,not-an-email,3 Main St,m\n"
+	rec := e.do("POST", "/api/v1/students/import", e.token("registrar"), body, "Content-Type", "text/csv")
+	expectStatus(t, rec, http.StatusUnprocessableEntity)
+
+	report := decodeImportReport(t, rec.Body.Bytes())
+	if report.Committed || report.Accepted != 2 || report.Rejected != 1 {
+		t.Fatalf("report = %+v", report)
+	}
+	bad := report.Rows[2]
+	if bad.Row != 4 || bad.Status != "rejected" || len(bad.Errors) != 3 {
+		t.Errorf("rejected row = %+v", bad)
+	}
+	if n := e.studentCount(); n != 0 {
+		t.Errorf("stored %d students after a rejected row", n)
+	}
+}
+
+func TestImportStudentsJSONLines(t *testing.T) {
+	e := newTestEnv(t)
+	e.addUser("registrar", User.RoleRegistrar)
+	body := `{"fname":"Ann","lname":"Lee","date_of_birth":"2001-02-03","email":"ann@example.org","address":"1 Main St","gender":"f"}
+
+{"fname":`
+	rec := e.do("POST", "/api/v1/students/import?dry_run=true", e.token("registrar"), body, "Content-Type", "application/x-ndjson")
+	expectStatus(t, rec, http.StatusUnprocessableEntity)
+
+	report := decodeImportReport(t, rec.Body.Bytes())
+	if report.Total != 2 || report.Rows[0].Status != "accepted" || report.Rows[1].Row != 3 || report.Rows[1].Status != "rejected" {
+		t.Errorf("report = %+v", report)
+	}
+}
+
+func TestImportStudentsBadRequests(t *testing.T) {
+	e := newTestEnv(t)
+	e.addUser("registrar", User.RoleRegistrar)
+	e.addUser("teacher", User.RoleTeacher)
+	token := e.token("registrar")
+
+	expectStatus(t, e.do("POST", "/api/v1/students/import", token, "fname,lname\nAnn,Lee\n"), http.StatusBadRequest)
+	expectStatus(t, e.do("POST", "/api/v1/students/import?format=xml", token, "<x/>"), http.StatusUnsupportedMediaType)
+	expectStatus(t, e.do("POST", "/api/v1/students/import", e.token("teacher"), importCSV), http.StatusForbidden)
+}
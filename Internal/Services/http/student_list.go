@@ -0,0 +1,130 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	student "Students-Final-Assignment/Internal/Student"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// parseQueryTime accepts either a plain date, as used for date_of_birth in
+// PostStudentRequest, or a full RFC 3339 timestamp, and reports which.
+func parseQueryTime(v string) (t time.Time, dateOnly bool, err error) {
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return t, true, nil
+	}
+	t, err = time.Parse(time.RFC3339, v)
+	return t, false, err
+}
+
+func parseListFilter(q url.Values) (student.ListFilter, error) {
+	f := student.ListFilter{
+		Fname:  q.Get("fname"),
+		Lname:  q.Get("lname"),
+		Email:  q.Get("email"),
+		Gender: q.Get("gender"),
+	}
+
+	// A plain date as upper bound includes that whole day, so it becomes
+	// an exclusive bound at the start of the next one.
+	times := []struct {
+		key    string
+		dst    *time.Time
+		before *time.Time
+	}{
+		{"dob_from", &f.DateOfBirthFrom, nil},
+		{"dob_to", &f.DateOfBirthTo, &f.DateOfBirthBefore},
+		{"created_from", &f.CreatedFrom, nil},
+		{"created_to", &f.CreatedTo, &f.CreatedBefore},
+	}
+	for _, t := range times {
+		v := q.Get(t.key)
+		if v == "" {
+			continue
+		}
+		parsed, dateOnly, err := parseQueryTime(v)
+		if err != nil {
+			return student.ListFilter{}, fmt.Errorf("invalid %s: %w", t.key, err)
+		}
+		if dateOnly && t.before != nil {
+			*t.before = parsed.AddDate(0, 0, 1)
+			continue
+		}
+		*t.dst = parsed
+	}
+	return f, nil
+}
+
+// parseSort reads a comma separated list of fields, each optionally prefixed
+// with "-" for descending order, e.g. "lname,-date_of_birth".
+func parseSort(v string) []student.SortField {
+	var sort []student.SortField
+	for _, field := range strings.Split(v, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		sf := student.SortField{Field: field}
+		if strings.HasPrefix(field, "-") {
+			sf.Field = field[1:]
+			sf.Desc = true
+		}
+		sort = append(sort, sf)
+	}
+	return sort
+}
+
+func parseListOptions(q url.Values) (student.ListOptions, error) {
+	filter, err := parseListFilter(q)
+	if err != nil {
+		return student.ListOptions{}, err
+	}
+
+	opts := student.ListOptions{
+		Filter: filter,
+		Sort:   parseSort(q.Get("sort")),
+	}
+	if v := q.Get("limit"); v != "" {
+		if opts.Limit, err = strconv.Atoi(v); err != nil {
+			return student.ListOptions{}, fmt.Errorf("invalid limit: %w", err)
+		}
+	}
+	if v := q.Get("offset"); v != "" {
+		if opts.Offset, err = strconv.Atoi(v); err != nil {
+			return student.ListOptions{}, fmt.Errorf("invalid offset: %w", err)
+		}
+	}
+	return opts, nil
+}
+
+func (h *Handler) ListStudents(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseListOptions(r.URL.Query())
+	if err != nil {
+		log.Info(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.Service.ListStudents(r.Context(), opts)
+	if err != nil {
+		if errors.Is(err, student.ErrInvalidListOptions) {
+			log.Info(err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(page); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,109 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"Students-Final-Assignment/Internal/Student"
+	"Students-Final-Assignment/Internal/User"
+)
+
+func TestParseListFilterDateOnlyUpperBoundIncludesDay(t *testing.T) {
+	f, err := parseListFilter(url.Values{"dob_to": {"2001-02-03"}, "created_to": {"2024-05-06"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.DateOfBirthTo.IsZero() || !f.CreatedTo.IsZero() {
+		t.Fatalf("date-only bounds set the inclusive fields: %+v", f)
+	}
+	if want := time.Date(2001, 2, 4, 0, 0, 0, 0, time.UTC); !f.DateOfBirthBefore.Equal(want) {
+		t.Errorf("DateOfBirthBefore = %s, want %s", f.DateOfBirthBefore, want)
+	}
+	if want := time.Date(2024, 5, 7, 0, 0, 0, 0, time.UTC); !f.CreatedBefore.Equal(want) {
+		t.Errorf("CreatedBefore = %s, want %s", f.CreatedBefore, want)
+	}
+}
+
+func TestParseListFilterTimestampUpperBoundIsInclusive(t *testing.T) {
+	f, err := parseListFilter(url.Values{"created_to": {"2024-05-06T10:00:00Z"}, "dob_from": {"2000-01-01"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2024, 5, 6, 10, 0, 0, 0, time.UTC); !f.CreatedTo.Equal(want) || !f.CreatedBefore.IsZero() {
+		t.Errorf("CreatedTo = %s, CreatedBefore = %s", f.CreatedTo, f.CreatedBefore)
+	}
+	if want := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC); !f.DateOfBirthFrom.Equal(want) {
+		t.Errorf("DateOfBirthFrom = %s, want %s", f.DateOfBirthFrom, want)
+	}
+}
+
+func TestParseListOptionsRejectsBadValues(t *testing.T) {
+	for _, q := range []url.Values{
+		{"limit": {"ten"}},
+		{"offset": {"-x"}},
+		{"dob_to": {"03/02/2001"}},
+	} {
+		if _, err := parseListOptions(q); err == nil {
+			t.Errorf("parseListOptions(%v) succeeded", q)
+		}
+	}
+}
+
+func TestParseSort(t *testing.T) {
+	got := parseSort("lname, -date_of_birth,,id")
+	want := []Student.SortField{{Field: "lname"}, {Field: "date_of_birth", Desc: true}, {Field: "id"}}
+	if len(got) != len(want) {
+		t.Fatalf("parseSort = %+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("field %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestListStudentsFiltersSortsAndPages(t *testing.T) {
+	e := newTestEnv(t)
+	e.addUser("reader", User.RoleReadOnly)
+	token := e.token("reader")
+	for _, st := range []Student.Student{
+		{Fname: "Ann", Lname: "Lee", Gender: "f", DateOfBirth: time.Date(2001, 2, 3, 0, 0, 0, 0, time.UTC)},
+		{Fname: "Bob", Lname: "Ray", Gender: "m", DateOfBirth: time.Date(2001, 2, 4, 0, 0, 0, 0, time.UTC)},
+		{Fname: "Cid", Lname: "Ace", Gender: "m", DateOfBirth: time.Date(2001, 2, 3, 12, 0, 0, 0, time.UTC)},
+	} {
+		if _, err := e.students.PostStudent(e.ctx, st, "test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec := e.do("GET", "/api/v1/students?dob_to=2001-02-03&sort=-lname&limit=1&offset=1", token, "")
+	expectStatus(t, rec, http.StatusOK)
+	var page Student.StudentPage
+	if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 2 || len(page.Students) != 1 || page.Students[0].Fname != "Cid" {
+		t.Fatalf("page = %+v", page)
+	}
+
+	rec = e.do("GET", "/api/v1/students?gender=M&sort=fname", token, "")
+	expectStatus(t, rec, http.StatusOK)
+	if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 2 || page.Students[0].Fname != "Bob" || page.Limit != Student.DefaultListLimit {
+		t.Fatalf("page = %+v", page)
+	}
+}
+
+func TestListStudentsRejectsInvalidOptions(t *testing.T) {
+	e := newTestEnv(t)
+	e.addUser("reader", User.RoleReadOnly)
+	token := e.token("reader")
+	for _, q := range []string{"sort=password", "limit=1000", "offset=-1", "dob_from=yesterday"} {
+		expectStatus(t, e.do("GET", "/api/v1/students?"+q, token, ""), http.StatusBadRequest)
+	}
+}
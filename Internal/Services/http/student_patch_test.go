@@ -0,0 +1,93 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"Students-Final-Assignment/Internal/Student"
+	"Students-Final-Assignment/Internal/User"
+)
+
+const mergePatch = "application/merge-patch+json"
+
+func (e *testEnv) addStudent() Student.Student {
+	e.t.Helper()
+	st, err := e.students.PostStudent(e.ctx, Student.Student{
+		Fname:       "Ann",
+		Lname:       "Lee",
+		DateOfBirth: time.Date(2001, 2, 3, 0, 0, 0, 0, time.UTC),
+		Email:       "ann@example.org",
+		Address:     "1 Main St",
+		Gender:      "f",
+	}, "test")
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	return st
+}
+
+func TestPatchStudentUpdatesOnlyGivenFields(t *testing.T) {
+	e := newTestEnv(t)
+	e.addUser("teacher", User.RoleTeacher)
+	st := e.addStudent()
+
+	rec := e.do("PATCH", fmt.Sprintf("/api/v1/student/%d", st.ID), e.token("teacher"), `{"address":"2 High St"}`, "Content-Type", mergePatch)
+	expectStatus(t, rec, http.StatusOK)
+
+	var got Student.Student
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Address != "2 High St" || got.Fname != "Ann" || got.Email != "ann@example.org" || !got.DateOfBirth.Equal(st.DateOfBirth) {
+		t.Errorf("patched = %+v", got)
+	}
+	if got.Version != st.Version+1 {
+		t.Errorf("Version = %d, want %d", got.Version, st.Version+1)
+	}
+}
+
+func TestPatchStudentRejectsInvalidPatches(t *testing.T) {
+	e := newTestEnv(t)
+	e.addUser("teacher", User.RoleTeacher)
+	st := e.addStudent()
+	path := fmt.Sprintf("/api/v1/student/%d", st.ID)
+	token := e.token("teacher")
+
+	for _, body := range []string{
+		`{"email":"not-an-email"}`,
+		`{"fname":""}`,
+		`{"address":null}`,
+		`{"password":"x"}`,
+		`{"fname":5}`,
+		`[]`,
+	} {
+		expectStatus(t, e.do("PATCH", path, token, body, "Content-Type", mergePatch), http.StatusBadRequest)
+	}
+	expectStatus(t, e.do("PATCH", path, token, `{"fname":"Bo"}`, "Content-Type", "text/plain"), http.StatusUnsupportedMediaType)
+	expectStatus(t, e.do("PATCH", "/api/v1/student/999", token, `{"fname":"Bo"}`, "Content-Type", mergePatch), http.StatusNotFound)
+
+	unchanged, err := e.students.GetStudent(e.ctx, st.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unchanged.Version != st.Version || unchanged.Fname != "Ann" || unchanged.Address != "1 Main St" {
+		t.Errorf("student changed by rejected patches: %+v", unchanged)
+	}
+}
+
+func TestDecodePatchStudentRequest(t *testing.T) {
+	req, err := decodePatchStudentRequest(strings.NewReader(`{"lname":"Ray","date_of_birth":"2002-03-04T00:00:00Z"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.FirstName != nil || req.LastName == nil || *req.LastName != "Ray" || req.DateOfBirth == nil {
+		t.Errorf("req = %+v", req)
+	}
+	if patch := studentPatchFromPatchStudentRequest(req); patch.IsEmpty() || patch.Email != nil {
+		t.Errorf("patch = %+v", patch)
+	}
+}
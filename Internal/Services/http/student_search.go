@@ -0,0 +1,43 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	student "Students-Final-Assignment/Internal/Student"
+)
+
+type SearchStudentsResponse struct {
+	Query   string                 `json:"query"`
+	Results []student.SearchResult `json:"results"`
+}
+
+func (h *Handler) SearchStudents(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := q.Get("q")
+
+	limit := 0
+	if v := q.Get("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	results, err := h.Service.SearchStudents(r.Context(), query, limit)
+	if err != nil {
+		if errors.Is(err, student.ErrEmptySearchQuery) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(SearchStudentsResponse{Query: query, Results: results}); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,78 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	student "Students-Final-Assignment/Internal/Student"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+func (h *Handler) ListTrash(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseListOptions(r.URL.Query())
+	if err != nil {
+		log.Info(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.Service.ListTrash(r.Context(), opts)
+	if err != nil {
+		if errors.Is(err, student.ErrInvalidListOptions) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(page); err != nil {
+		panic(err)
+	}
+}
+
+func (h *Handler) RestoreStudent(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+	if idStr == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	s, err := h.Service.RestoreStudent(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, student.ErrNoStudentFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	setStudentETag(w, s)
+	if err := json.NewEncoder(w).Encode(s); err != nil {
+		panic(err)
+	}
+}
+
+func (h *Handler) PurgeTrash(w http.ResponseWriter, r *http.Request) {
+	n, err := h.Service.PurgeTrash(r.Context())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(map[string]int64{"purged": n}); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,91 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"Students-Final-Assignment/Internal/Student"
+	"Students-Final-Assignment/Internal/User"
+)
+
+func TestDeleteListTrashAndRestore(t *testing.T) {
+	e := newTestEnv(t)
+	e.addUser("registrar", User.RoleRegistrar)
+	token := e.token("registrar")
+	st := e.addStudent()
+	path := fmt.Sprintf("/api/v1/student/%d", st.ID)
+
+	expectStatus(t, e.do("DELETE", path, token, ""), http.StatusOK)
+	expectStatus(t, e.do("GET", path, token, ""), http.StatusNotFound)
+	if n := e.studentCount(); n != 0 {
+		t.Errorf("deleted student still listed")
+	}
+
+	rec := e.do("GET", "/api/v1/students/trash", token, "")
+	expectStatus(t, rec, http.StatusOK)
+	var page Student.StudentPage
+	if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 1 || page.Students[0].ID != st.ID || page.Students[0].DeletedAt == nil {
+		t.Fatalf("trash = %+v", page)
+	}
+
+	expectStatus(t, e.do("POST", path+"/restore", token, ""), http.StatusOK)
+	expectStatus(t, e.do("GET", path, token, ""), http.StatusOK)
+	expectStatus(t, e.do("POST", path+"/restore", token, ""), http.StatusNotFound)
+}
+
+func TestPurgeTrashIsAdminOnlyAndKeepsRecentDeletes(t *testing.T) {
+	e := newTestEnv(t)
+	e.addUser("registrar", User.RoleRegistrar)
+	e.addUser("admin", User.RoleAdmin)
+	st := e.addStudent()
+	if err := e.students.DeleteStudent(e.ctx, st.ID, 0, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	expectStatus(t, e.do("DELETE", "/api/v1/students/trash", e.token("registrar"), ""), http.StatusForbidden)
+
+	rec := e.do("DELETE", "/api/v1/students/trash", e.token("admin"), "")
+	expectStatus(t, rec, http.StatusOK)
+	var body map[string]int64
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body["purged"] != 0 {
+		t.Errorf("purged %d students inside the retention", body["purged"])
+	}
+}
+
+func TestPurgeTrashRemovesHistory(t *testing.T) {
+	e := newTestEnv(t)
+	e.addUser("admin", User.RoleAdmin)
+	token := e.token("admin")
+	e.h.Service.(*Student.Service).TrashRetention = time.Millisecond
+	st := e.addStudent()
+	path := fmt.Sprintf("/api/v1/student/%d", st.ID)
+	asOf := path + "?as_of=" + url.QueryEscape(time.Now().Format(time.RFC3339Nano))
+
+	expectStatus(t, e.do("GET", asOf, token, ""), http.StatusOK)
+	expectStatus(t, e.do("DELETE", path, token, ""), http.StatusOK)
+	time.Sleep(5 * time.Millisecond)
+
+	rec := e.do("DELETE", "/api/v1/students/trash", token, "")
+	expectStatus(t, rec, http.StatusOK)
+	var body map[string]int64
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body["purged"] != 1 {
+		t.Fatalf("purged %d students", body["purged"])
+	}
+
+	// No copy of the record is left to read back.
+	expectStatus(t, e.do("GET", path+"/history", token, ""), http.StatusNotFound)
+	expectStatus(t, e.do("GET", asOf, token, ""), http.StatusNotFound)
+}
@@ -0,0 +1,244 @@
+package http
+
+import (
+	"Students-Final-Assignment/Internal/Tenant"
+	"Students-Final-Assignment/Internal/User"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+type contextKey int
+
+const hostTenantKey contextKey = iota
+
+type TenantRequest struct {
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+}
+
+// TenantAdminRequest creates the first account of a new tenant. Role
+// defaults to admin.
+type TenantAdminRequest struct {
+	Username string    `json:"username" validate:"required,max=100"`
+	Email    string    `json:"email" validate:"required,email,max=100"`
+	Password string    `json:"password"`
+	Role     User.Role `json:"role"`
+}
+
+// TenantMiddleware scopes every request to the tenant its subdomain names,
+// and to the default tenant on any other host. JWTAuth later moves
+// authenticated requests to their token's tenant, which must agree with
+// the subdomain if there is one.
+func (h *Handler) TenantMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		tid := Tenant.DefaultTenantID
+		if h.Tenants != nil {
+			t, ok, err := h.Tenants.ResolveHost(ctx, r.Host)
+			switch {
+			case errors.Is(err, Tenant.ErrTenantNotFound):
+				w.WriteHeader(http.StatusNotFound)
+				log.Errorf("no tenant is served on %s", r.Host)
+				return
+			case err != nil:
+				log.Error(err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			case ok:
+				tid = t.ID
+				ctx = context.WithValue(ctx, hostTenantKey, t.ID)
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(Tenant.ContextWithTenant(ctx, tid)))
+	})
+}
+
+// hostTenant returns the tenant the request's subdomain names, if any.
+func hostTenant(ctx context.Context) (int64, bool) {
+	tid, ok := ctx.Value(hostTenantKey).(int64)
+	return tid, ok
+}
+
+// withCredentialTenant scopes ctx to the tenant of the token or key the
+// request authenticated with. It fails for credentials of one tenant used
+// on another tenant's subdomain.
+func withCredentialTenant(ctx context.Context, tid int64) (context.Context, bool) {
+	if host, ok := hostTenant(ctx); ok && host != tid {
+		return ctx, false
+	}
+	return Tenant.ContextWithTenant(ctx, tid), true
+}
+
+// withTenants answers 404 when the handler has no tenant service.
+func (h *Handler) withTenants(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.Tenants == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func writeTenantError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, Tenant.ErrTenantNotFound):
+		w.WriteHeader(http.StatusNotFound)
+	case errors.Is(err, Tenant.ErrInvalidTenant):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, Tenant.ErrSlugTaken), errors.Is(err, Tenant.ErrTenantNotEmpty):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, Tenant.ErrDefaultTenant):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func tenantID(r *http.Request) (int64, error) {
+	return strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+}
+
+func (h *Handler) ListTenants(w http.ResponseWriter, r *http.Request) {
+	tenants, err := h.Tenants.ListTenants(r.Context())
+	if err != nil {
+		writeTenantError(w, err)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(tenants); err != nil {
+		panic(err)
+	}
+}
+
+func (h *Handler) GetTenant(w http.ResponseWriter, r *http.Request) {
+	id, err := tenantID(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	t, err := h.Tenants.GetTenant(r.Context(), id)
+	if err != nil {
+		writeTenantError(w, err)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(t); err != nil {
+		panic(err)
+	}
+}
+
+func (h *Handler) CreateTenant(w http.ResponseWriter, r *http.Request) {
+	var req TenantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	t, err := h.Tenants.CreateTenant(r.Context(), Tenant.Tenant{Slug: req.Slug, Name: req.Name})
+	if err != nil {
+		writeTenantError(w, err)
+		return
+	}
+	log.Infof("user %s created tenant %d (%s)", User.ActorFromContext(r.Context()), t.ID, t.Slug)
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(t); err != nil {
+		panic(err)
+	}
+}
+
+func (h *Handler) UpdateTenant(w http.ResponseWriter, r *http.Request) {
+	id, err := tenantID(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var req TenantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	t, err := h.Tenants.UpdateTenant(r.Context(), Tenant.Tenant{ID: id, Slug: req.Slug, Name: req.Name})
+	if err != nil {
+		writeTenantError(w, err)
+		return
+	}
+	log.Infof("user %s updated tenant %d (%s)", User.ActorFromContext(r.Context()), t.ID, t.Slug)
+	if err := json.NewEncoder(w).Encode(t); err != nil {
+		panic(err)
+	}
+}
+
+// DeleteTenant only removes tenants without users or students; their data
+// has to be moved or deleted first.
+func (h *Handler) DeleteTenant(w http.ResponseWriter, r *http.Request) {
+	id, err := tenantID(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := h.Tenants.DeleteTenant(r.Context(), id); err != nil {
+		writeTenantError(w, err)
+		return
+	}
+	log.Infof("user %s deleted tenant %d", User.ActorFromContext(r.Context()), id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CreateTenantAdmin creates an account inside another tenant, so a new
+// school gets someone who can manage it. It is the one place a request
+// works on a tenant other than its own.
+func (h *Handler) CreateTenantAdmin(w http.ResponseWriter, r *http.Request) {
+	id, err := tenantID(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var req TenantAdminRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if err := validator.New().Struct(req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if req.Role == "" {
+		req.Role = User.RoleAdmin
+	}
+	if !req.Role.Valid() {
+		http.Error(w, User.ErrInvalidRole.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := h.Tenants.GetTenant(r.Context(), id); err != nil {
+		writeTenantError(w, err)
+		return
+	}
+
+	ctx := Tenant.ContextWithTenant(r.Context(), id)
+	user, err := h.UserService.ProvisionUser(ctx, req.Username, req.Email, req.Password, true)
+	if errors.Is(err, User.ErrUsernameTaken) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+	if err := h.UserService.AssignRole(ctx, user.UID, req.Role); err != nil {
+		writeUserError(w, err)
+		return
+	}
+	user.Role = req.Role
+
+	log.Infof("user %s created %s %d in tenant %d", User.ActorFromContext(r.Context()), req.Role, user.UID, id)
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(user); err != nil {
+		panic(err)
+	}
+}
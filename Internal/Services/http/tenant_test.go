@@ -0,0 +1,51 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"Students-Final-Assignment/Internal/Tenant"
+	"Students-Final-Assignment/Internal/User"
+)
+
+func TestTenantEndpointsOnMemoryStores(t *testing.T) {
+	e := newTestEnv(t)
+	e.addUser("root", User.RoleSuperAdmin)
+	e.addUser("admin", User.RoleAdmin)
+
+	// Without a tenant service the endpoints do not exist.
+	expectStatus(t, e.do("GET", "/api/v1/tenants", e.token("root"), ""), http.StatusNotFound)
+
+	e.h.Tenants = Tenant.NewService(Tenant.NewMemoryTenantStore(func(ctx context.Context, id int64) (bool, error) {
+		page, err := e.users.ListUsers(Tenant.ContextWithTenant(ctx, id), User.UserListOptions{Limit: 1})
+		return len(page.Users) > 0, err
+	}), Tenant.Config{})
+
+	expectStatus(t, e.do("GET", "/api/v1/tenants", e.token("admin"), ""), http.StatusForbidden)
+	rec := e.do("GET", "/api/v1/tenants", e.token("root"), "")
+	expectStatus(t, rec, http.StatusOK)
+	var tenants []Tenant.Tenant
+	if err := json.NewDecoder(rec.Body).Decode(&tenants); err != nil || len(tenants) != 1 || tenants[0].ID != Tenant.DefaultTenantID {
+		t.Fatalf("tenants = %+v, %v", tenants, err)
+	}
+
+	rec = e.do("POST", "/api/v1/tenants", e.token("root"), `{"slug":"north","name":"North School"}`)
+	expectStatus(t, rec, http.StatusCreated)
+	var north Tenant.Tenant
+	if err := json.NewDecoder(rec.Body).Decode(&north); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, e.do("POST", "/api/v1/tenants", e.token("root"), `{"slug":"north","name":"Again"}`), http.StatusConflict)
+
+	path := fmt.Sprintf("/api/v1/tenants/%d", north.ID)
+	body := fmt.Sprintf(`{"username":"head","email":"head@example.org","password":%q}`, testPassword)
+	expectStatus(t, e.do("POST", path+"/users", e.token("root"), body), http.StatusCreated)
+
+	// The new school has an account now, so it stays.
+	expectStatus(t, e.do("DELETE", path, e.token("root"), ""), http.StatusConflict)
+	expectStatus(t, e.do("GET", path, e.token("root"), ""), http.StatusOK)
+	expectStatus(t, e.do("DELETE", fmt.Sprintf("/api/v1/tenants/%d", Tenant.DefaultTenantID), e.token("root"), ""), http.StatusForbidden)
+}
@@ -0,0 +1,224 @@
+package http
+
+import (
+	"Students-Final-Assignment/Internal/User"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+type ChangeEmailRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// writeUserError maps the errors shared by the user handlers below.
+func writeUserError(w http.ResponseWriter, err error) {
+	if writePasswordPolicyError(w, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, User.ErrUserNotFound):
+		w.WriteHeader(http.StatusNotFound)
+	case errors.Is(err, User.ErrInvalidUserListOptions):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, User.ErrCannotModifySelf), errors.Is(err, User.ErrWrongPassword), errors.Is(err, User.ErrSuperAdminOnly):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func userID(r *http.Request) (int64, error) {
+	return strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+}
+
+func decodeChangeEmailRequest(r *http.Request) (ChangeEmailRequest, bool) {
+	var req ChangeEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return req, false
+	}
+	validate := validator.New()
+	return req, validate.Struct(req) == nil
+}
+
+func (h *Handler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	opts := User.UserListOptions{Query: q.Get("q")}
+	var err error
+	if v := q.Get("limit"); v != "" {
+		if opts.Limit, err = strconv.Atoi(v); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	if v := q.Get("offset"); v != "" {
+		if opts.Offset, err = strconv.Atoi(v); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	page, err := h.UserService.ListUsers(r.Context(), opts)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(page); err != nil {
+		panic(err)
+	}
+}
+
+func (h *Handler) GetUser(w http.ResponseWriter, r *http.Request) {
+	id, err := userID(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	user, err := h.UserService.GetUser(r.Context(), id)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(user); err != nil {
+		panic(err)
+	}
+}
+
+func (h *Handler) DisableUser(w http.ResponseWriter, r *http.Request) {
+	id, err := userID(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	actor, _ := User.UIDFromContext(r.Context())
+	if err := h.UserService.DisableUser(r.Context(), actor, id); err != nil {
+		writeUserError(w, err)
+		return
+	}
+	log.Infof("user %d disabled user %d", actor, id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) EnableUser(w http.ResponseWriter, r *http.Request) {
+	id, err := userID(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := h.UserService.EnableUser(r.Context(), id); err != nil {
+		writeUserError(w, err)
+		return
+	}
+	log.Infof("user %s enabled user %d", User.ActorFromContext(r.Context()), id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) ChangeUserEmail(w http.ResponseWriter, r *http.Request) {
+	id, err := userID(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	req, ok := decodeChangeEmailRequest(r)
+	if !ok {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.UserService.ChangeEmail(r.Context(), id, req.Email)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+	log.Infof("user %s changed the email of user %d", User.ActorFromContext(r.Context()), id)
+	if err := json.NewEncoder(w).Encode(user); err != nil {
+		panic(err)
+	}
+}
+
+func (h *Handler) ForcePasswordReset(w http.ResponseWriter, r *http.Request) {
+	id, err := userID(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := h.UserService.ForcePasswordReset(r.Context(), id); err != nil {
+		writeUserError(w, err)
+		return
+	}
+	log.Infof("user %s forced a password reset of user %d", User.ActorFromContext(r.Context()), id)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *Handler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	id, err := userID(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	actor, _ := User.UIDFromContext(r.Context())
+	if err := h.UserService.DeleteUser(r.Context(), actor, id); err != nil {
+		writeUserError(w, err)
+		return
+	}
+	log.Infof("user %d deleted user %d", actor, id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) GetMe(w http.ResponseWriter, r *http.Request) {
+	uid, _ := User.UIDFromContext(r.Context())
+	user, err := h.UserService.GetUser(r.Context(), uid)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(user); err != nil {
+		panic(err)
+	}
+}
+
+// UpdateMe changes the caller's email, the only field users edit themselves.
+func (h *Handler) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeChangeEmailRequest(r)
+	if !ok {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	uid, _ := User.UIDFromContext(r.Context())
+	user, err := h.UserService.ChangeEmail(r.Context(), uid, req.Email)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(user); err != nil {
+		panic(err)
+	}
+}
+
+func (h *Handler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	var req ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	uid, _ := User.UIDFromContext(r.Context())
+	sessionID, _ := User.SessionIDFromContext(r.Context())
+	if err := h.UserService.ChangePassword(r.Context(), uid, req.CurrentPassword, req.NewPassword, sessionID); err != nil {
+		writeUserError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
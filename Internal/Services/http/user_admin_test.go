@@ -0,0 +1,104 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"Students-Final-Assignment/Internal/User"
+)
+
+func TestAdminUserEndpoints(t *testing.T) {
+	e := newTestEnv(t)
+	admin := e.addUser("admin", User.RoleAdmin)
+	uid := e.addUser("ann", User.RoleTeacher)
+	token := e.token("admin")
+	annToken := e.token("ann")
+	path := fmt.Sprintf("/api/v1/admin/users/%d", uid)
+
+	expectStatus(t, e.do("GET", "/api/v1/admin/users", annToken, ""), http.StatusForbidden)
+
+	rec := e.do("GET", "/api/v1/admin/users?q=ann&limit=5", token, "")
+	expectStatus(t, rec, http.StatusOK)
+	var page User.UserPage
+	if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 1 || page.Limit != 5 || page.Users[0].UID != uid {
+		t.Errorf("page = %+v", page)
+	}
+	expectStatus(t, e.do("GET", "/api/v1/admin/users?limit=1000", token, ""), http.StatusBadRequest)
+	expectStatus(t, e.do("GET", "/api/v1/admin/users?offset=x", token, ""), http.StatusBadRequest)
+	expectStatus(t, e.do("GET", path, token, ""), http.StatusOK)
+	expectStatus(t, e.do("GET", "/api/v1/admin/users/404", token, ""), http.StatusNotFound)
+	expectStatus(t, e.do("GET", "/api/v1/admin/users/x", token, ""), http.StatusBadRequest)
+
+	expectStatus(t, e.do("PUT", path+"/email", token, `{"email":"not an address"}`), http.StatusBadRequest)
+	expectStatus(t, e.do("PUT", path+"/email", token, `{"email":"ann@example.com"}`), http.StatusOK)
+
+	expectStatus(t, e.do("POST", path+"/disable", token, ""), http.StatusNoContent)
+	expectStatus(t, e.do("GET", "/api/v1/me", annToken, ""), http.StatusUnauthorized)
+	expectStatus(t, e.do("POST", path+"/enable", token, ""), http.StatusNoContent)
+	annToken = e.token("ann")
+
+	expectStatus(t, e.do("POST", path+"/reset-password", token, ""), http.StatusAccepted)
+	expectStatus(t, e.do("GET", "/api/v1/me", annToken, ""), http.StatusUnauthorized)
+
+	self := fmt.Sprintf("/api/v1/admin/users/%d", admin)
+	expectStatus(t, e.do("POST", self+"/disable", token, ""), http.StatusForbidden)
+	expectStatus(t, e.do("DELETE", self, token, ""), http.StatusForbidden)
+	expectStatus(t, e.do("DELETE", path, token, ""), http.StatusNoContent)
+	expectStatus(t, e.do("DELETE", path, token, ""), http.StatusNotFound)
+}
+
+func TestMeEndpoints(t *testing.T) {
+	e := newTestEnv(t)
+	e.addUser("ann", User.RoleTeacher)
+	token := e.token("ann")
+
+	rec := e.do("PUT", "/api/v1/me", token, `{"email":"ann@example.com"}`)
+	expectStatus(t, rec, http.StatusOK)
+	var me User.User
+	if err := json.NewDecoder(rec.Body).Decode(&me); err != nil {
+		t.Fatal(err)
+	}
+	if me.Email != "ann@example.com" || me.Password != "" {
+		t.Errorf("me = %+v", me)
+	}
+
+	expectStatus(t, e.do("POST", "/api/v1/me/password", token, `{"current_password":"wrong","new_password":"Another-Good-Passw0rd"}`), http.StatusForbidden)
+	expectStatus(t, e.do("POST", "/api/v1/me/password", token, `{"current_password":"`+testPassword+`","new_password":"Another-Good-Passw0rd"}`), http.StatusNoContent)
+	expectStatus(t, e.do("GET", "/api/v1/me", token, ""), http.StatusOK)
+}
+
+func TestAdminsCannotTakeOverSuperAdmins(t *testing.T) {
+	e := newTestEnv(t)
+	e.addUser("admin", User.RoleAdmin)
+	root := e.addUser("root", User.RoleSuperAdmin)
+	token := e.token("admin")
+	path := fmt.Sprintf("/api/v1/admin/users/%d", root)
+
+	expectStatus(t, e.do("PUT", path+"/email", token, `{"email":"admin@example.org"}`), http.StatusForbidden)
+	expectStatus(t, e.do("POST", path+"/reset-password", token, ""), http.StatusForbidden)
+	expectStatus(t, e.do("POST", path+"/disable", token, ""), http.StatusForbidden)
+	expectStatus(t, e.do("DELETE", path, token, ""), http.StatusForbidden)
+
+	key := e.scimKey("admin")
+	scimPath := fmt.Sprintf("/scim/v2/Users/%d", root)
+	for _, tc := range []struct{ method, body string }{
+		{"PUT", `{"userName":"root","emails":[{"value":"root@example.org"}],"password":"Takeover-Passw0rd"}`},
+		{"PATCH", `{"Operations":[{"op":"replace","path":"password","value":"Takeover-Passw0rd"}]}`},
+		{"PATCH", `{"Operations":[{"op":"replace","path":"emails","value":"admin@example.org"}]}`},
+	} {
+		rec := e.do(tc.method, scimPath, key, tc.body)
+		expectStatus(t, rec, http.StatusForbidden)
+		decodeSCIMError(t, rec)
+	}
+
+	e.token("root")
+	e.h.UserService.Wait()
+	if messages := e.mailer.Messages(); len(messages) != 0 {
+		t.Errorf("mails sent: %+v", messages)
+	}
+}
@@ -0,0 +1,50 @@
+package http
+
+import (
+	"Students-Final-Assignment/Internal/User"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type ResendVerificationRequest struct {
+	Email string `json:"email"`
+}
+
+func (h *Handler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		http.Error(w, "missing token", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.UserService.VerifyEmail(r.Context(), token); err != nil {
+		if errors.Is(err, User.ErrInvalidVerificationToken) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(Response{Message: "email address verified"}); err != nil {
+		panic(err)
+	}
+}
+
+// ResendVerification always answers 202, like ForgotPassword.
+func (h *Handler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	var req ResendVerificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.UserService.ResendVerification(r.Context(), req.Email); err != nil {
+		log.Errorf("could not resend verification mail: %s", err.Error())
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
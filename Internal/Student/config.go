@@ -0,0 +1,49 @@
+package Student
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Config holds the student settings an operator can change without a
+// rebuild.
+type Config struct {
+	// TrashRetentionDays replaces DefaultTrashRetention when set. It must
+	// be positive, so a typo can't make PurgeTrash empty the whole trash.
+	TrashRetentionDays *int `json:"TrashRetentionDays"`
+}
+
+func LoadConfig(configPath string) (Config, error) {
+	file, err := os.Open(configPath)
+	if err != nil {
+		return Config{}, fmt.Errorf("could not open student config file: %w", err)
+	}
+	defer file.Close()
+
+	var config Config
+	if err := json.NewDecoder(file).Decode(&config); err != nil {
+		return Config{}, fmt.Errorf("could not decode student config file: %w", err)
+	}
+	if err := config.Validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid student config file: %w", err)
+	}
+	return config, nil
+}
+
+func (c Config) Validate() error {
+	if c.TrashRetentionDays != nil && *c.TrashRetentionDays <= 0 {
+		return errors.New("TrashRetentionDays must be positive")
+	}
+	return nil
+}
+
+// TrashRetention is how long deleted Students stay restorable.
+func (c Config) TrashRetention() time.Duration {
+	if c.TrashRetentionDays == nil {
+		return DefaultTrashRetention
+	}
+	return time.Duration(*c.TrashRetentionDays) * 24 * time.Hour
+}
@@ -0,0 +1,72 @@
+package Student
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigTrashRetention(t *testing.T) {
+	config, err := LoadConfig(writeConfig(t, `{"TrashRetentionDays": 7}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := config.TrashRetention(); got != 7*24*time.Hour {
+		t.Errorf("TrashRetention = %s", got)
+	}
+
+	config, err = LoadConfig(writeConfig(t, `{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := config.TrashRetention(); got != DefaultTrashRetention {
+		t.Errorf("default TrashRetention = %s", got)
+	}
+
+	for _, bad := range []string{`{"TrashRetentionDays": 0}`, `{"TrashRetentionDays": -3}`, `{"TrashRetentionDays": "30"}`} {
+		if _, err := LoadConfig(writeConfig(t, bad)); err == nil {
+			t.Errorf("LoadConfig(%s) succeeded", bad)
+		}
+	}
+}
+
+func TestPurgeTrashUsesRetention(t *testing.T) {
+	ctx := testContext()
+	store := NewMemoryStudentStore()
+	st, err := store.PostStudent(ctx, Student{Fname: "Ann"}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.DeleteStudent(ctx, st.ID, 0, "test"); err != nil {
+		t.Fatal(err)
+	}
+	s := NewService(store)
+
+	if n, err := s.PurgeTrash(ctx); err != nil || n != 0 {
+		t.Fatalf("default retention purged %d, err %v", n, err)
+	}
+
+	s.TrashRetention = 0
+	if _, err := s.PurgeTrash(ctx); err != ErrPurgingStudents {
+		t.Errorf("zero retention: err = %v", err)
+	}
+
+	s.TrashRetention = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	if n, err := s.PurgeTrash(ctx); err != nil || n != 1 {
+		t.Fatalf("purged %d, err %v", n, err)
+	}
+	if _, err := s.RestoreStudent(ctx, st.ID); err != ErrNoStudentFound {
+		t.Errorf("restore after purge: err = %v", err)
+	}
+}
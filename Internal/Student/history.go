@@ -0,0 +1,112 @@
+package Student
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionRestore = "restore"
+)
+
+var ErrFetchingHistory = errors.New("could not fetch Student history")
+
+// HistoryEntry is one version of a Student: the full record as it was
+// stored after the change, and who made it.
+type HistoryEntry struct {
+	Version   int64         `json:"version"`
+	Action    string        `json:"action"`
+	ChangedBy string        `json:"changed_by"`
+	ChangedAt time.Time     `json:"changed_at"`
+	Snapshot  Student       `json:"snapshot"`
+	Changes   []FieldChange `json:"changes"`
+}
+
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// historyFields are the fields compared between two versions.
+var historyFields = []struct {
+	name  string
+	value func(Student) interface{}
+}{
+	{"fname", func(s Student) interface{} { return s.Fname }},
+	{"lname", func(s Student) interface{} { return s.Lname }},
+	{"date_of_birth", func(s Student) interface{} { return s.DateOfBirth }},
+	{"email", func(s Student) interface{} { return s.Email }},
+	{"address", func(s Student) interface{} { return s.Address }},
+	{"gender", func(s Student) interface{} { return s.Gender }},
+	{"deleted_at", func(s Student) interface{} { return s.DeletedAt }},
+}
+
+func sameValue(a, b interface{}) bool {
+	switch av := a.(type) {
+	case time.Time:
+		return av.Equal(b.(time.Time))
+	case *time.Time:
+		bv := b.(*time.Time)
+		if av == nil || bv == nil {
+			return av == nil && bv == nil
+		}
+		return av.Equal(*bv)
+	default:
+		return a == b
+	}
+}
+
+// DiffStudents lists the fields that differ between two versions. Every
+// field of the first version counts as changed from nil.
+func DiffStudents(prev *Student, next Student) []FieldChange {
+	changes := []FieldChange{}
+	for _, f := range historyFields {
+		newValue := f.value(next)
+		if prev == nil {
+			changes = append(changes, FieldChange{Field: f.name, New: newValue})
+			continue
+		}
+		if oldValue := f.value(*prev); !sameValue(oldValue, newValue) {
+			changes = append(changes, FieldChange{Field: f.name, Old: oldValue, New: newValue})
+		}
+	}
+	return changes
+}
+
+func (s *Service) GetStudentHistory(ctx context.Context, ID int64) ([]HistoryEntry, error) {
+	entries, err := s.Store.GetStudentHistory(ctx, ID)
+	if err != nil {
+		log.Errorf("an error occurred fetching the Student history: %s", err.Error())
+		return nil, ErrFetchingHistory
+	}
+	if len(entries) == 0 {
+		return nil, ErrNoStudentFound
+	}
+
+	var prev *Student
+	for i := range entries {
+		entries[i].Changes = DiffStudents(prev, entries[i].Snapshot)
+		prev = &entries[i].Snapshot
+	}
+	return entries, nil
+}
+
+// GetStudentAsOf returns the Student as it was at the given time.
+func (s *Service) GetStudentAsOf(ctx context.Context, ID int64, asOf time.Time) (Student, error) {
+	st, err := s.Store.GetStudentAsOf(ctx, ID, asOf)
+	if err != nil {
+		if errors.Is(err, ErrNoStudentFound) {
+			return Student{}, ErrNoStudentFound
+		}
+		log.Errorf("an error occurred fetching the Student as of %s: %s", asOf, err.Error())
+		return Student{}, ErrFetchingStudent
+	}
+	return st, nil
+}
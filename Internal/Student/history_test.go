@@ -0,0 +1,84 @@
+package Student
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDiffStudents(t *testing.T) {
+	dob := time.Date(2001, 2, 3, 0, 0, 0, 0, time.UTC)
+	first := Student{Fname: "Ann", Lname: "Lee", DateOfBirth: dob}
+	if changes := DiffStudents(nil, first); len(changes) != len(historyFields) {
+		t.Errorf("first version changes = %+v", changes)
+	}
+
+	next := first
+	next.Lname = "Ray"
+	next.DateOfBirth = dob.In(time.FixedZone("x", 3600))
+	changes := DiffStudents(&first, next)
+	if len(changes) != 1 || changes[0].Field != "lname" || changes[0].Old != "Lee" || changes[0].New != "Ray" {
+		t.Errorf("changes = %+v", changes)
+	}
+
+	deleted := next
+	now := time.Now()
+	deleted.DeletedAt = &now
+	if changes := DiffStudents(&next, deleted); len(changes) != 1 || changes[0].Field != "deleted_at" {
+		t.Errorf("delete changes = %+v", changes)
+	}
+}
+
+func TestHistoryAndAsOf(t *testing.T) {
+	ctx := testContext()
+	store := NewMemoryStudentStore()
+	s := NewService(store)
+
+	beforeCreate := time.Now()
+	st, err := store.PostStudent(ctx, Student{Fname: "Ann", Address: "1 Main St"}, "7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	afterCreate := time.Now()
+	st.Address = "2 High St"
+	if _, err := store.UpdateStudent(ctx, st.ID, st, 0, "8"); err != nil {
+		t.Fatal(err)
+	}
+	afterUpdate := time.Now()
+	if err := store.DeleteStudent(ctx, st.ID, 0, "9"); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := s.GetStudentHistory(ctx, st.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	for _, e := range history {
+		actions = append(actions, e.Action+"/"+e.ChangedBy)
+	}
+	if len(history) != 3 || actions[0] != "create/7" || actions[1] != "update/8" || actions[2] != "delete/9" {
+		t.Fatalf("history = %v", actions)
+	}
+	if c := history[1].Changes; len(c) != 1 || c[0].Field != "address" || c[0].Old != "1 Main St" {
+		t.Errorf("update changes = %+v", c)
+	}
+	if history[2].Version <= history[1].Version {
+		t.Errorf("versions not increasing: %d, %d", history[1].Version, history[2].Version)
+	}
+
+	if _, err := s.GetStudentAsOf(ctx, st.ID, beforeCreate); err != ErrNoStudentFound {
+		t.Errorf("before create: err = %v", err)
+	}
+	if old, err := s.GetStudentAsOf(ctx, st.ID, afterCreate); err != nil || old.Address != "1 Main St" {
+		t.Errorf("after create: %+v, %v", old, err)
+	}
+	if cur, err := s.GetStudentAsOf(ctx, st.ID, afterUpdate); err != nil || cur.Address != "2 High St" {
+		t.Errorf("after update: %+v, %v", cur, err)
+	}
+	if _, err := s.GetStudentAsOf(ctx, st.ID, time.Now()); err != ErrNoStudentFound {
+		t.Errorf("after delete: err = %v", err)
+	}
+	if _, err := s.GetStudentHistory(ctx, st.ID+1); err != ErrNoStudentFound {
+		t.Errorf("unknown student: err = %v", err)
+	}
+}
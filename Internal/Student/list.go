@@ -0,0 +1,117 @@
+package Student
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultListLimit = 20
+	MaxListLimit     = 100
+)
+
+var (
+	ErrListingStudents     = errors.New("could not list Students")
+	ErrInvalidListOptions  = errors.New("invalid list options")
+	ErrInvalidSortField    = errors.New("invalid sort field")
+	ErrInvalidPageSettings = errors.New("invalid limit or offset")
+	ErrExportingStudents   = errors.New("could not export Students")
+)
+
+// SortableFields are the Student fields a listing can be ordered by.
+var SortableFields = map[string]bool{
+	"id":            true,
+	"fname":         true,
+	"lname":         true,
+	"date_of_birth": true,
+	"email":         true,
+	"gender":        true,
+	"created_on":    true,
+}
+
+// ListFilter narrows a listing. Zero values are ignored. The To bounds are
+// inclusive and the Before bounds exclusive, so a whole last day can be
+// included without knowing the precision of the stored times.
+type ListFilter struct {
+	Fname             string
+	Lname             string
+	Email             string
+	Gender            string
+	DateOfBirthFrom   time.Time
+	DateOfBirthTo     time.Time
+	DateOfBirthBefore time.Time
+	CreatedFrom       time.Time
+	CreatedTo         time.Time
+	CreatedBefore     time.Time
+}
+
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+type ListOptions struct {
+	Filter ListFilter
+	Sort   []SortField
+	Limit  int
+	Offset int
+}
+
+type StudentPage struct {
+	Students []Student `json:"students"`
+	Total    int64     `json:"total"`
+	Limit    int       `json:"limit"`
+	Offset   int       `json:"offset"`
+}
+
+// Normalize applies the default limit and checks the options against the
+// sortable fields and the page size bounds.
+func (o *ListOptions) Normalize() error {
+	if o.Limit == 0 {
+		o.Limit = DefaultListLimit
+	}
+	if o.Limit < 0 || o.Limit > MaxListLimit || o.Offset < 0 {
+		return ErrInvalidPageSettings
+	}
+	return validateSort(o.Sort)
+}
+
+func validateSort(sort []SortField) error {
+	for _, sf := range sort {
+		if !SortableFields[sf.Field] {
+			return ErrInvalidSortField
+		}
+	}
+	return nil
+}
+
+func (s *Service) ListStudents(ctx context.Context, opts ListOptions) (StudentPage, error) {
+	if err := opts.Normalize(); err != nil {
+		return StudentPage{}, errors.Join(ErrInvalidListOptions, err)
+	}
+
+	page, err := s.Store.ListStudents(ctx, opts)
+	if err != nil {
+		log.Errorf("an error occurred listing Students: %s", err.Error())
+		return StudentPage{}, ErrListingStudents
+	}
+	return page, nil
+}
+
+// ExportStudents calls fn for every Student matching the options' filter, in
+// the options' order. Limit and Offset are ignored, nothing is buffered, so
+// fn sees each Student as the store produces it.
+func (s *Service) ExportStudents(ctx context.Context, opts ListOptions, fn func(Student) error) error {
+	if err := validateSort(opts.Sort); err != nil {
+		return errors.Join(ErrInvalidListOptions, err)
+	}
+
+	if err := s.Store.StreamStudents(ctx, opts, fn); err != nil {
+		log.Errorf("an error occurred exporting Students: %s", err.Error())
+		return ErrExportingStudents
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package Student
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"Students-Final-Assignment/Internal/User"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// StudentPatch holds the fields of a partial update. Nil fields are left as
+// they are.
+type StudentPatch struct {
+	Fname       *string
+	Lname       *string
+	DateOfBirth *time.Time
+	Email       *string
+	Address     *string
+	Gender      *string
+}
+
+func (p StudentPatch) IsEmpty() bool {
+	return p.Fname == nil && p.Lname == nil && p.DateOfBirth == nil &&
+		p.Email == nil && p.Address == nil && p.Gender == nil
+}
+
+func (s *Service) PatchStudent(ctx context.Context, ID int64, patch StudentPatch, ifVersion int64) (Student, error) {
+	st, err := s.Store.PatchStudent(ctx, ID, patch, ifVersion, User.ActorFromContext(ctx))
+	if err != nil {
+		if errors.Is(err, ErrNoStudentFound) || errors.Is(err, ErrConflict) {
+			return Student{}, err
+		}
+		log.Errorf("an error occurred patching the Student: %s", err.Error())
+		return Student{}, ErrUpdatingStudent
+	}
+	return st, nil
+}
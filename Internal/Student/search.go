@@ -0,0 +1,283 @@
+package Student
+
+import (
+	"context"
+	"errors"
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+	// MaxSearchTerms bounds the work one query can cause; later words are
+	// ignored.
+	MaxSearchTerms = 8
+
+	highlightOpen  = "<em>"
+	highlightClose = "</em>"
+
+	// minTermScore is the lowest similarity at which a misspelled term still
+	// counts as a match.
+	minTermScore = 0.3
+)
+
+var (
+	ErrSearchingStudents = errors.New("could not search Students")
+	ErrEmptySearchQuery  = errors.New("search query is empty")
+	ErrSearchUnavailable = errors.New("search is not available")
+)
+
+// searchFields are the fields a query is matched against, with the weight a
+// match in that field contributes to the score.
+var searchFields = []struct {
+	name   string
+	weight float64
+	value  func(Student) string
+}{
+	{"fname", 3, func(s Student) string { return s.Fname }},
+	{"lname", 3, func(s Student) string { return s.Lname }},
+	{"email", 2, func(s Student) string { return s.Email }},
+	{"address", 1, func(s Student) string { return s.Address }},
+}
+
+type SearchResult struct {
+	Student    Student           `json:"student"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+// Searcher finds Students matching a free text query, best match first.
+type Searcher interface {
+	SearchStudents(ctx context.Context, query string, limit int) ([]SearchResult, error)
+}
+
+type token struct {
+	text       string
+	start, end int
+}
+
+// tokenize splits a value into lower cased words, remembering where each one
+// sits in the original string so it can be highlighted later.
+func tokenize(v string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range v {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			tokens = append(tokens, token{strings.ToLower(v[start:i]), start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{strings.ToLower(v[start:]), start, len(v)})
+	}
+	return tokens
+}
+
+// QueryTerms returns the first MaxSearchTerms distinct lower cased words of
+// a search query.
+func QueryTerms(query string) []string {
+	seen := map[string]bool{}
+	var terms []string
+	for _, t := range tokenize(query) {
+		if len(terms) == MaxSearchTerms {
+			break
+		}
+		if !seen[t.text] {
+			seen[t.text] = true
+			terms = append(terms, t.text)
+		}
+	}
+	return terms
+}
+
+// Trigrams returns the set of three letter shingles of a word padded with
+// two leading spaces and one trailing space, so short words still produce
+// shingles and word starts weigh more.
+func Trigrams(word string) map[string]struct{} {
+	padded := []rune("  " + word + " ")
+	grams := make(map[string]struct{}, len(padded))
+	for i := 0; i+3 <= len(padded); i++ {
+		grams[string(padded[i:i+3])] = struct{}{}
+	}
+	return grams
+}
+
+func trigramSimilarity(a, b string) float64 {
+	ga, gb := Trigrams(a), Trigrams(b)
+	shared := 0
+	for g := range ga {
+		if _, ok := gb[g]; ok {
+			shared++
+		}
+	}
+	union := len(ga) + len(gb) - shared
+	if union == 0 {
+		return 0
+	}
+	return float64(shared) / float64(union)
+}
+
+// editDistance is the optimal string alignment distance: insertions,
+// deletions, substitutions and transpositions of adjacent letters, the
+// usual shapes of a typo.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
+
+// termScore rates how well a query term matches a single word: 1 for an
+// exact match, less for prefixes, substrings and typos, 0 for no match.
+func termScore(term, word string) float64 {
+	switch {
+	case term == word:
+		return 1
+	case strings.HasPrefix(word, term):
+		return 0.9
+	case len(term) >= 3 && strings.Contains(word, term):
+		return 0.7
+	}
+
+	maxEdits := 1
+	if len([]rune(term)) > 6 {
+		maxEdits = 2
+	}
+	if len([]rune(term)) >= 3 {
+		if d := editDistance(term, word); d <= maxEdits {
+			return 0.6 - 0.1*float64(d-1)
+		}
+	}
+	if sim := trigramSimilarity(term, word); sim >= minTermScore {
+		return sim * 0.6
+	}
+	return 0
+}
+
+// highlight marks the matched words of v. The value is HTML-escaped first,
+// so the markers are the only markup in the result.
+func highlight(v string, matched []token) string {
+	if len(matched) == 0 {
+		return ""
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].start < matched[j].start })
+
+	var b strings.Builder
+	last := 0
+	for _, t := range matched {
+		if t.start < last {
+			continue
+		}
+		b.WriteString(html.EscapeString(v[last:t.start]))
+		b.WriteString(highlightOpen)
+		b.WriteString(html.EscapeString(v[t.start:t.end]))
+		b.WriteString(highlightClose)
+		last = t.end
+	}
+	b.WriteString(html.EscapeString(v[last:]))
+	return b.String()
+}
+
+// ScoreStudent ranks a Student against the query terms. It returns false if
+// no term matched any searchable field.
+func ScoreStudent(terms []string, st Student) (SearchResult, bool) {
+	result := SearchResult{Student: st, Highlights: map[string]string{}}
+
+	for _, f := range searchFields {
+		value := f.value(st)
+		words := tokenize(value)
+		matched := map[int]bool{}
+		var matchedTokens []token
+
+		for _, term := range terms {
+			best, bestIdx := 0.0, -1
+			for i, w := range words {
+				if sc := termScore(term, w.text); sc > best {
+					best, bestIdx = sc, i
+				}
+			}
+			if bestIdx < 0 {
+				continue
+			}
+			result.Score += best * f.weight
+			if !matched[bestIdx] {
+				matched[bestIdx] = true
+				matchedTokens = append(matchedTokens, words[bestIdx])
+			}
+		}
+
+		if hl := highlight(value, matchedTokens); hl != "" {
+			result.Highlights[f.name] = hl
+		}
+	}
+	return result, result.Score > 0
+}
+
+// RankStudents scores every candidate and returns the best limit matches.
+func RankStudents(terms []string, candidates []Student, limit int) []SearchResult {
+	results := []SearchResult{}
+	for _, st := range candidates {
+		if r, ok := ScoreStudent(terms, st); ok {
+			results = append(results, r)
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Student.ID < results[j].Student.ID
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+func (s *Service) SearchStudents(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, ErrEmptySearchQuery
+	}
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	limit = min(limit, MaxSearchLimit)
+	if s.Searcher == nil {
+		return nil, ErrSearchUnavailable
+	}
+
+	results, err := s.Searcher.SearchStudents(ctx, query, limit)
+	if err != nil {
+		log.Errorf("an error occurred searching Students: %s", err.Error())
+		return nil, ErrSearchingStudents
+	}
+	return results, nil
+}
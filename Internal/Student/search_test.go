@@ -0,0 +1,81 @@
+package Student
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"Students-Final-Assignment/Internal/Tenant"
+)
+
+func TestHighlightEscapesValue(t *testing.T) {
+	st := Student{Fname: "Ann", Address: `<script>alert("x")</script> Ann & co`}
+	r, ok := ScoreStudent([]string{"ann"}, st)
+	if !ok {
+		t.Fatal("no match")
+	}
+	want := `&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt; <em>Ann</em> &amp; co`
+	if got := r.Highlights["address"]; got != want {
+		t.Errorf("highlight = %q, want %q", got, want)
+	}
+	if got := r.Highlights["fname"]; got != "<em>Ann</em>" {
+		t.Errorf("fname highlight = %q", got)
+	}
+}
+
+func TestQueryTermsCapsTerms(t *testing.T) {
+	words := make([]string, MaxSearchTerms+5)
+	for i := range words {
+		words[i] = fmt.Sprintf("w%d", i)
+	}
+	terms := QueryTerms(strings.Join(words, " ") + " W0")
+	if len(terms) != MaxSearchTerms || terms[0] != "w0" {
+		t.Errorf("terms = %v", terms)
+	}
+}
+
+func testContext() context.Context {
+	return Tenant.ContextWithTenant(context.Background(), Tenant.DefaultTenantID)
+}
+
+func TestSearchStudentsLimit(t *testing.T) {
+	ctx := testContext()
+	store := NewMemoryStudentStore()
+	for i := 0; i < MaxSearchLimit+10; i++ {
+		if _, err := store.PostStudent(ctx, Student{Fname: "Ann", Lname: fmt.Sprint("L", i)}, "test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := NewService(store)
+
+	for limit, want := range map[int]int{0: DefaultSearchLimit, 5: 5, MaxSearchLimit + 1: MaxSearchLimit} {
+		results, err := s.SearchStudents(ctx, "ann", limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != want {
+			t.Errorf("limit %d: %d results, want %d", limit, len(results), want)
+		}
+	}
+	if _, err := s.SearchStudents(ctx, "  ", 5); err != ErrEmptySearchQuery {
+		t.Errorf("blank query: err = %v", err)
+	}
+}
+
+func TestRankStudentsOrdersByScore(t *testing.T) {
+	candidates := []Student{
+		{ID: 1, Fname: "Bob", Address: "Smith street"},
+		{ID: 2, Fname: "Jon", Lname: "Smith"},
+		{ID: 3, Fname: "Ann", Lname: "Smyth"},
+		{ID: 4, Fname: "Zed"},
+	}
+	results := RankStudents([]string{"smith"}, candidates, 10)
+	var ids []int64
+	for _, r := range results {
+		ids = append(ids, r.Student.ID)
+	}
+	if fmt.Sprint(ids) != "[2 3 1]" {
+		t.Errorf("ranked ids = %v, want [2 3 1]", ids)
+	}
+}
@@ -5,47 +5,75 @@ import (
 	"errors"
 	"time"
 
+	"Students-Final-Assignment/Internal/User"
+
 	log "github.com/sirupsen/logrus"
 )
 
 var (
-	ErrFetchingStudent = errors.New("could not fetch Student by ID")
-	ErrUpdatingStudent = errors.New("could not update Student")
-	ErrNoStudentFound  = errors.New("no Student found")
-	ErrDeletingStudent = errors.New("could not delete Student")
-	ErrNotImplemented  = errors.New("not implemented")
+	ErrFetchingStudent   = errors.New("could not fetch Student by ID")
+	ErrUpdatingStudent   = errors.New("could not update Student")
+	ErrNoStudentFound    = errors.New("no Student found")
+	ErrConflict          = errors.New("Student was changed by someone else")
+	ErrDeletingStudent   = errors.New("could not delete Student")
+	ErrNotImplemented    = errors.New("not implemented")
+	ErrImportingStudents = errors.New("could not import Students")
 )
 
 type Student struct {
-	ID          int64     `json:"id"`
-	Fname       string    `json:"fname"`
-	Lname       string    `json:"lname"`
-	DateOfBirth time.Time `json:"date_of_birth"`
-	Email       string    `json:"email"`
-	Address     string    `json:"address"`
-	Gender      string    `json:"gender"`
-	CreatedBy   string    `json:"created_by"`
-	CreatedOn   time.Time `json:"created_on"`
-	UpdatedBy   int       `json:"updated_by"`
-	UpdatedOn   time.Time `json:"updated_on"`
+	ID          int64      `json:"id"`
+	TenantID    int64      `json:"tenant_id"`
+	Fname       string     `json:"fname"`
+	Lname       string     `json:"lname"`
+	DateOfBirth time.Time  `json:"date_of_birth"`
+	Email       string     `json:"email"`
+	Address     string     `json:"address"`
+	Gender      string     `json:"gender"`
+	CreatedBy   string     `json:"created_by"`
+	CreatedOn   time.Time  `json:"created_on"`
+	UpdatedBy   string     `json:"updated_by"`
+	UpdatedOn   time.Time  `json:"updated_on"`
+	Version     int64      `json:"version"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	DeletedBy   string     `json:"deleted_by,omitempty"`
 }
 
+// StudentStore only ever sees the Students of the tenant the context is
+// scoped to, and fails with Tenant.ErrNoTenant for a context without one.
 type StudentStore interface {
 	GetStudent(context.Context, int64) (Student, error)
-	PostStudent(context.Context, Student) (Student, error)
-	UpdateStudent(context.Context, int64, Student) (Student, error)
-	DeleteStudent(context.Context, int64) error
+	PostStudent(context.Context, Student, string) (Student, error)
+	UpdateStudent(context.Context, int64, Student, int64, string) (Student, error)
+	PatchStudent(context.Context, int64, StudentPatch, int64, string) (Student, error)
+	DeleteStudent(context.Context, int64, int64, string) error
+	RestoreStudent(context.Context, int64, string) (Student, error)
+	ListTrash(context.Context, ListOptions) (StudentPage, error)
+	GetStudentHistory(context.Context, int64) ([]HistoryEntry, error)
+	GetStudentAsOf(context.Context, int64, time.Time) (Student, error)
+	PurgeStudents(context.Context, time.Time) (int64, error)
+	ListStudents(context.Context, ListOptions) (StudentPage, error)
+	StreamStudents(context.Context, ListOptions, func(Student) error) error
+	ImportStudents(context.Context, []Student, bool, string) ([]Student, error)
 	Ping(context.Context) error
 }
 
 type Service struct {
-	Store StudentStore
+	Store          StudentStore
+	Searcher       Searcher
+	TrashRetention time.Duration
 }
 
+// NewService uses the store for searching when it can search itself; a
+// different Searcher can be set on the returned Service.
 func NewService(store StudentStore) *Service {
-	return &Service{
-		Store: store,
+	s := &Service{
+		Store:          store,
+		TrashRetention: DefaultTrashRetention,
+	}
+	if searcher, ok := store.(Searcher); ok {
+		s.Searcher = searcher
 	}
+	return s
 }
 
 func (s *Service) GetStudent(ctx context.Context, ID int64) (Student, error) {
@@ -58,25 +86,44 @@ func (s *Service) GetStudent(ctx context.Context, ID int64) (Student, error) {
 }
 
 func (s *Service) PostStudent(ctx context.Context, cmt Student) (Student, error) {
-	cmt, err := s.Store.PostStudent(ctx, cmt)
+	cmt, err := s.Store.PostStudent(ctx, cmt, User.ActorFromContext(ctx))
 	if err != nil {
 		log.Errorf("an error occurred adding the Student: %s", err.Error())
+		return Student{}, err
 	}
 	return cmt, nil
 }
 
+// UpdateStudent replaces a Student. A non-zero ifVersion makes the update
+// conditional on the stored version, failing with ErrConflict otherwise.
 func (s *Service) UpdateStudent(
-	ctx context.Context, ID int64, newStudent Student,
+	ctx context.Context, ID int64, newStudent Student, ifVersion int64,
 ) (Student, error) {
-	cmt, err := s.Store.UpdateStudent(ctx, ID, newStudent)
+	cmt, err := s.Store.UpdateStudent(ctx, ID, newStudent, ifVersion, User.ActorFromContext(ctx))
 	if err != nil {
+		if errors.Is(err, ErrNoStudentFound) || errors.Is(err, ErrConflict) {
+			return Student{}, err
+		}
 		log.Errorf("an error occurred updating the Student: %s", err.Error())
+		return Student{}, ErrUpdatingStudent
 	}
 	return cmt, nil
 }
 
-func (s *Service) DeleteStudent(ctx context.Context, ID int64) error {
-	return s.Store.DeleteStudent(ctx, ID)
+func (s *Service) DeleteStudent(ctx context.Context, ID int64, ifVersion int64) error {
+	return s.Store.DeleteStudent(ctx, ID, ifVersion, User.ActorFromContext(ctx))
+}
+
+// ImportStudents adds all Students in one transaction. With dryRun set the
+// transaction is rolled back, so the returned Students show what would have
+// been stored.
+func (s *Service) ImportStudents(ctx context.Context, students []Student, dryRun bool) ([]Student, error) {
+	imported, err := s.Store.ImportStudents(ctx, students, dryRun, User.ActorFromContext(ctx))
+	if err != nil {
+		log.Errorf("an error occurred importing Students: %s", err.Error())
+		return nil, ErrImportingStudents
+	}
+	return imported, nil
 }
 
 func (s *Service) ReadyCheck(ctx context.Context) error {
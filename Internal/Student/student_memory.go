@@ -0,0 +1,445 @@
+package Student
+
+import (
+	"cmp"
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"Students-Final-Assignment/Internal/Tenant"
+)
+
+// MemoryStudentStore keeps Students and their history in process. It
+// behaves like the SQL store, down to ids, versions and errors, so it can
+// stand in for it in demos and tests; everything is lost on restart.
+type MemoryStudentStore struct {
+	mu       sync.RWMutex
+	lastID   int64
+	students map[int64]Student
+	history  map[int64][]HistoryEntry
+}
+
+func NewMemoryStudentStore() *MemoryStudentStore {
+	return &MemoryStudentStore{
+		students: map[int64]Student{},
+		history:  map[int64][]HistoryEntry{},
+	}
+}
+
+func storeTenant(ctx context.Context) (int64, error) {
+	tid, ok := Tenant.FromContext(ctx)
+	if !ok {
+		return 0, Tenant.ErrNoTenant
+	}
+	return tid, nil
+}
+
+// copyStudent returns st with its own DeletedAt, so callers can't change
+// what is stored.
+func copyStudent(st Student) Student {
+	if st.DeletedAt != nil {
+		deletedAt := *st.DeletedAt
+		st.DeletedAt = &deletedAt
+	}
+	return st
+}
+
+func (m *MemoryStudentStore) Ping(ctx context.Context) error {
+	return nil
+}
+
+// liveLocked returns the Student of the tenant that is not in the trash,
+// checking ifVersion the way the SQL store's row lock does.
+func (m *MemoryStudentStore) liveLocked(tid, id, ifVersion int64) (Student, error) {
+	st, ok := m.students[id]
+	if !ok || st.TenantID != tid || st.DeletedAt != nil {
+		return Student{}, ErrNoStudentFound
+	}
+	if ifVersion != 0 && st.Version != ifVersion {
+		return Student{}, ErrConflict
+	}
+	return st, nil
+}
+
+// saveLocked stores st and appends it to its history as the next version.
+func (m *MemoryStudentStore) saveLocked(st Student, action, changedBy string) Student {
+	m.students[st.ID] = st
+	entries := m.history[st.ID]
+	m.history[st.ID] = append(entries, HistoryEntry{
+		Version:   int64(len(entries)) + 1,
+		Action:    action,
+		ChangedBy: changedBy,
+		ChangedAt: time.Now(),
+		Snapshot:  copyStudent(st),
+	})
+	return copyStudent(st)
+}
+
+func (m *MemoryStudentStore) GetStudent(ctx context.Context, id int64) (Student, error) {
+	tid, err := storeTenant(ctx)
+	if err != nil {
+		return Student{}, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	st, err := m.liveLocked(tid, id, 0)
+	if err != nil {
+		return Student{}, err
+	}
+	return copyStudent(st), nil
+}
+
+// newStudent fills in what the database sets on insert.
+func newStudent(tid int64, st Student, actor string, now time.Time) Student {
+	return Student{
+		TenantID:    tid,
+		Fname:       st.Fname,
+		Lname:       st.Lname,
+		DateOfBirth: st.DateOfBirth,
+		Email:       st.Email,
+		Address:     st.Address,
+		Gender:      st.Gender,
+		CreatedBy:   actor,
+		CreatedOn:   now,
+		UpdatedBy:   actor,
+		UpdatedOn:   now,
+		Version:     1,
+	}
+}
+
+func (m *MemoryStudentStore) PostStudent(ctx context.Context, st Student, actor string) (Student, error) {
+	tid, err := storeTenant(ctx)
+	if err != nil {
+		return Student{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored := newStudent(tid, st, actor, time.Now())
+	m.lastID++
+	stored.ID = m.lastID
+	return m.saveLocked(stored, ActionCreate, actor), nil
+}
+
+// ImportStudents stores all Students or, with dryRun set, none of them and
+// returns them with a zero ID.
+func (m *MemoryStudentStore) ImportStudents(ctx context.Context, students []Student, dryRun bool, actor string) ([]Student, error) {
+	tid, err := storeTenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	imported := make([]Student, 0, len(students))
+	for _, st := range students {
+		stored := newStudent(tid, st, actor, time.Now())
+		if dryRun {
+			imported = append(imported, stored)
+			continue
+		}
+		m.lastID++
+		stored.ID = m.lastID
+		imported = append(imported, m.saveLocked(stored, ActionCreate, actor))
+	}
+	return imported, nil
+}
+
+func (m *MemoryStudentStore) UpdateStudent(ctx context.Context, id int64, st Student, ifVersion int64, actor string) (Student, error) {
+	tid, err := storeTenant(ctx)
+	if err != nil {
+		return Student{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, err := m.liveLocked(tid, id, ifVersion)
+	if err != nil {
+		return Student{}, err
+	}
+	stored.Fname = st.Fname
+	stored.Lname = st.Lname
+	stored.DateOfBirth = st.DateOfBirth
+	stored.Email = st.Email
+	stored.Address = st.Address
+	stored.Gender = st.Gender
+	stored.UpdatedBy = actor
+	stored.UpdatedOn = time.Now()
+	stored.Version++
+	return m.saveLocked(stored, ActionUpdate, actor), nil
+}
+
+// PatchStudent returns the Student unchanged, without a new version, for
+// an empty patch.
+func (m *MemoryStudentStore) PatchStudent(ctx context.Context, id int64, patch StudentPatch, ifVersion int64, actor string) (Student, error) {
+	tid, err := storeTenant(ctx)
+	if err != nil {
+		return Student{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, err := m.liveLocked(tid, id, ifVersion)
+	if err != nil {
+		return Student{}, err
+	}
+	if patch.IsEmpty() {
+		return copyStudent(stored), nil
+	}
+
+	if patch.Fname != nil {
+		stored.Fname = *patch.Fname
+	}
+	if patch.Lname != nil {
+		stored.Lname = *patch.Lname
+	}
+	if patch.DateOfBirth != nil {
+		stored.DateOfBirth = *patch.DateOfBirth
+	}
+	if patch.Email != nil {
+		stored.Email = *patch.Email
+	}
+	if patch.Address != nil {
+		stored.Address = *patch.Address
+	}
+	if patch.Gender != nil {
+		stored.Gender = *patch.Gender
+	}
+	stored.UpdatedBy = actor
+	stored.UpdatedOn = time.Now()
+	stored.Version++
+	return m.saveLocked(stored, ActionUpdate, actor), nil
+}
+
+func (m *MemoryStudentStore) DeleteStudent(ctx context.Context, id int64, ifVersion int64, actor string) error {
+	tid, err := storeTenant(ctx)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, err := m.liveLocked(tid, id, ifVersion)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	stored.DeletedAt = &now
+	stored.DeletedBy = actor
+	stored.Version++
+	m.saveLocked(stored, ActionDelete, actor)
+	return nil
+}
+
+func (m *MemoryStudentStore) RestoreStudent(ctx context.Context, id int64, actor string) (Student, error) {
+	tid, err := storeTenant(ctx)
+	if err != nil {
+		return Student{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.students[id]
+	if !ok || stored.TenantID != tid || stored.DeletedAt == nil {
+		return Student{}, ErrNoStudentFound
+	}
+	stored.DeletedAt = nil
+	stored.DeletedBy = ""
+	stored.UpdatedBy = actor
+	stored.UpdatedOn = time.Now()
+	stored.Version++
+	return m.saveLocked(stored, ActionRestore, actor), nil
+}
+
+// PurgeStudents removes Students of the tenant that have been in the trash
+// since before the given time, and their history with them.
+func (m *MemoryStudentStore) PurgeStudents(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	tid, err := storeTenant(ctx)
+	if err != nil {
+		return 0, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n int64
+	for id, st := range m.students {
+		if st.TenantID == tid && st.DeletedAt != nil && st.DeletedAt.Before(deletedBefore) {
+			delete(m.students, id)
+			delete(m.history, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *MemoryStudentStore) GetStudentHistory(ctx context.Context, id int64) ([]HistoryEntry, error) {
+	tid, err := storeTenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	entries := []HistoryEntry{}
+	for _, entry := range m.history[id] {
+		if entry.Snapshot.TenantID == tid {
+			entry.Snapshot = copyStudent(entry.Snapshot)
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func (m *MemoryStudentStore) GetStudentAsOf(ctx context.Context, id int64, asOf time.Time) (Student, error) {
+	tid, err := storeTenant(ctx)
+	if err != nil {
+		return Student{}, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	entries := m.history[id]
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if entry.Snapshot.TenantID != tid || entry.ChangedAt.After(asOf) {
+			continue
+		}
+		if entry.Action == ActionDelete {
+			return Student{}, ErrNoStudentFound
+		}
+		return copyStudent(entry.Snapshot), nil
+	}
+	return Student{}, ErrNoStudentFound
+}
+
+func (m *MemoryStudentStore) ListStudents(ctx context.Context, opts ListOptions) (StudentPage, error) {
+	return m.listStudents(ctx, opts, false)
+}
+
+func (m *MemoryStudentStore) ListTrash(ctx context.Context, opts ListOptions) (StudentPage, error) {
+	return m.listStudents(ctx, opts, true)
+}
+
+func (m *MemoryStudentStore) listStudents(ctx context.Context, opts ListOptions, trashed bool) (StudentPage, error) {
+	students, err := m.matching(ctx, opts, trashed)
+	if err != nil {
+		return StudentPage{}, err
+	}
+	page := StudentPage{
+		Students: []Student{},
+		Total:    int64(len(students)),
+		Limit:    opts.Limit,
+		Offset:   opts.Offset,
+	}
+	if opts.Offset < len(students) {
+		end := len(students)
+		if opts.Limit < end-opts.Offset {
+			end = opts.Offset + opts.Limit
+		}
+		page.Students = students[opts.Offset:end]
+	}
+	return page, nil
+}
+
+// StreamStudents calls fn on a snapshot taken under the lock, so fn may
+// use the store itself.
+func (m *MemoryStudentStore) StreamStudents(ctx context.Context, opts ListOptions, fn func(Student) error) error {
+	students, err := m.matching(ctx, opts, false)
+	if err != nil {
+		return err
+	}
+	for _, st := range students {
+		if err := fn(st); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SearchStudents ranks every live Student of the tenant; without a database
+// there is nothing cheaper to narrow the candidates down with.
+func (m *MemoryStudentStore) SearchStudents(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	tid, err := storeTenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	terms := QueryTerms(query)
+	if len(terms) == 0 {
+		return []SearchResult{}, nil
+	}
+	m.mu.RLock()
+	var candidates []Student
+	for _, st := range m.students {
+		if st.TenantID == tid && st.DeletedAt == nil {
+			candidates = append(candidates, copyStudent(st))
+		}
+	}
+	m.mu.RUnlock()
+	return RankStudents(terms, candidates, limit), nil
+}
+
+// matching returns the Students of the tenant passing the filter, ordered
+// like the SQL store orders them.
+func (m *MemoryStudentStore) matching(ctx context.Context, opts ListOptions, trashed bool) ([]Student, error) {
+	tid, err := storeTenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateSort(opts.Sort); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	students := []Student{}
+	for _, st := range m.students {
+		if st.TenantID == tid && (st.DeletedAt != nil) == trashed && opts.Filter.matches(st) {
+			students = append(students, copyStudent(st))
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(students, func(i, j int) bool {
+		for _, sf := range opts.Sort {
+			c := compareField(sf.Field, students[i], students[j])
+			if sf.Desc {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return students[i].ID < students[j].ID
+	})
+	return students, nil
+}
+
+// matches compares text case-insensitively, as MySQL's default collation
+// does.
+func (f ListFilter) matches(st Student) bool {
+	switch {
+	case f.Fname != "" && !strings.EqualFold(st.Fname, f.Fname),
+		f.Lname != "" && !strings.EqualFold(st.Lname, f.Lname),
+		f.Email != "" && !strings.EqualFold(st.Email, f.Email),
+		f.Gender != "" && !strings.EqualFold(st.Gender, f.Gender),
+		!f.DateOfBirthFrom.IsZero() && st.DateOfBirth.Before(f.DateOfBirthFrom),
+		!f.DateOfBirthTo.IsZero() && st.DateOfBirth.After(f.DateOfBirthTo),
+		!f.DateOfBirthBefore.IsZero() && !st.DateOfBirth.Before(f.DateOfBirthBefore),
+		!f.CreatedFrom.IsZero() && st.CreatedOn.Before(f.CreatedFrom),
+		!f.CreatedTo.IsZero() && st.CreatedOn.After(f.CreatedTo),
+		!f.CreatedBefore.IsZero() && !st.CreatedOn.Before(f.CreatedBefore):
+		return false
+	}
+	return true
+}
+
+func compareField(field string, a, b Student) int {
+	switch field {
+	case "id":
+		return cmp.Compare(a.ID, b.ID)
+	case "fname":
+		return strings.Compare(strings.ToLower(a.Fname), strings.ToLower(b.Fname))
+	case "lname":
+		return strings.Compare(strings.ToLower(a.Lname), strings.ToLower(b.Lname))
+	case "date_of_birth":
+		return a.DateOfBirth.Compare(b.DateOfBirth)
+	case "email":
+		return strings.Compare(strings.ToLower(a.Email), strings.ToLower(b.Email))
+	case "gender":
+		return strings.Compare(strings.ToLower(a.Gender), strings.ToLower(b.Gender))
+	case "created_on":
+		return a.CreatedOn.Compare(b.CreatedOn)
+	}
+	return 0
+}
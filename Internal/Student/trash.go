@@ -0,0 +1,61 @@
+package Student
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"Students-Final-Assignment/Internal/User"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultTrashRetention is how long a deleted Student stays restorable
+// before PurgeTrash removes it for good.
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+var (
+	ErrRestoringStudent = errors.New("could not restore Student")
+	ErrPurgingStudents  = errors.New("could not purge Students")
+)
+
+func (s *Service) RestoreStudent(ctx context.Context, ID int64) (Student, error) {
+	st, err := s.Store.RestoreStudent(ctx, ID, User.ActorFromContext(ctx))
+	if err != nil {
+		if errors.Is(err, ErrNoStudentFound) {
+			return Student{}, ErrNoStudentFound
+		}
+		log.Errorf("an error occurred restoring the Student: %s", err.Error())
+		return Student{}, ErrRestoringStudent
+	}
+	return st, nil
+}
+
+func (s *Service) ListTrash(ctx context.Context, opts ListOptions) (StudentPage, error) {
+	if err := opts.Normalize(); err != nil {
+		return StudentPage{}, errors.Join(ErrInvalidListOptions, err)
+	}
+
+	page, err := s.Store.ListTrash(ctx, opts)
+	if err != nil {
+		log.Errorf("an error occurred listing deleted Students: %s", err.Error())
+		return StudentPage{}, ErrListingStudents
+	}
+	return page, nil
+}
+
+// PurgeTrash permanently removes Students deleted longer ago than the
+// service's TrashRetention and returns how many were removed.
+func (s *Service) PurgeTrash(ctx context.Context) (int64, error) {
+	if s.TrashRetention <= 0 {
+		log.Errorf("refusing to purge deleted Students with a retention of %s", s.TrashRetention)
+		return 0, ErrPurgingStudents
+	}
+	n, err := s.Store.PurgeStudents(ctx, time.Now().Add(-s.TrashRetention))
+	if err != nil {
+		log.Errorf("an error occurred purging deleted Students: %s", err.Error())
+		return 0, ErrPurgingStudents
+	}
+	log.Infof("purged %d deleted Students", n)
+	return n, nil
+}
@@ -0,0 +1,19 @@
+package Tenant
+
+import "context"
+
+type contextKey int
+
+const tenantKey contextKey = iota
+
+// ContextWithTenant returns a copy of ctx scoped to the tenant. Stores
+// refuse to work without one.
+func ContextWithTenant(ctx context.Context, id int64) context.Context {
+	return context.WithValue(ctx, tenantKey, id)
+}
+
+// FromContext returns the tenant ctx is scoped to, if any.
+func FromContext(ctx context.Context) (int64, bool) {
+	id, ok := ctx.Value(tenantKey).(int64)
+	return id, ok && id != 0
+}
@@ -0,0 +1,182 @@
+package Tenant
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultTenantID is the school every deployment starts with. Data from
+// before tenants existed belongs to it, and requests that name no tenant
+// use it.
+const DefaultTenantID int64 = 1
+
+var (
+	ErrTenantNotFound = errors.New("tenant not found")
+	// ErrNoTenant means a store was called with a context that names no
+	// tenant. It is a programming error, never something to recover from
+	// by guessing.
+	ErrNoTenant       = errors.New("no tenant in context")
+	ErrInvalidTenant  = errors.New("invalid tenant")
+	ErrSlugTaken      = errors.New("tenant slug already exists")
+	ErrTenantNotEmpty = errors.New("tenant still has users or students")
+	ErrDefaultTenant  = errors.New("the default tenant cannot be deleted")
+	// ErrOutsideTenant is returned for resources of another tenant.
+	ErrOutsideTenant = errors.New("outside the current tenant")
+)
+
+// Tenant is one school. Its slug is the subdomain it is served on.
+type Tenant struct {
+	ID        int64     `db:"id" json:"id"`
+	Slug      string    `db:"slug" json:"slug"`
+	Name      string    `db:"name" json:"name"`
+	CreatedOn time.Time `db:"created_on" json:"created_on"`
+}
+
+type TenantStore interface {
+	GetTenant(ctx context.Context, id int64) (Tenant, error)
+	GetTenantBySlug(ctx context.Context, slug string) (Tenant, error)
+	ListTenants(ctx context.Context) ([]Tenant, error)
+	CreateTenant(ctx context.Context, t Tenant) (Tenant, error)
+	UpdateTenant(ctx context.Context, t Tenant) error
+	// DeleteTenant fails with ErrTenantNotEmpty while users or students
+	// belong to the tenant.
+	DeleteTenant(ctx context.Context, id int64) error
+}
+
+// Config says how requests are matched to tenants. With BaseDomain set to
+// "students.example.org", "north.students.example.org" is served as the
+// tenant with slug "north"; other hosts fall back to DefaultTenantID.
+type Config struct {
+	BaseDomain string `json:"BaseDomain"`
+}
+
+func LoadConfig(configPath string) (Config, error) {
+	file, err := os.Open(configPath)
+	if err != nil {
+		return Config{}, fmt.Errorf("could not open tenant config file: %w", err)
+	}
+	defer file.Close()
+
+	var config Config
+	if err := json.NewDecoder(file).Decode(&config); err != nil {
+		return Config{}, fmt.Errorf("could not decode tenant config file: %w", err)
+	}
+	return config, nil
+}
+
+type Service struct {
+	store  TenantStore
+	config Config
+}
+
+func NewService(store TenantStore, config Config) *Service {
+	return &Service{store: store, config: config}
+}
+
+// slugPattern keeps slugs valid as a DNS label.
+var slugPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+func (t *Tenant) normalize() error {
+	t.Slug = strings.ToLower(strings.TrimSpace(t.Slug))
+	t.Name = strings.TrimSpace(t.Name)
+	if !slugPattern.MatchString(t.Slug) {
+		return fmt.Errorf("%w: the slug must be a DNS label of lower case letters, digits and dashes", ErrInvalidTenant)
+	}
+	if t.Name == "" || len(t.Name) > 100 {
+		return fmt.Errorf("%w: the name must be 1 to 100 characters", ErrInvalidTenant)
+	}
+	return nil
+}
+
+// SlugFromHost returns the subdomain of host under the base domain, if it
+// is one.
+func (s *Service) SlugFromHost(host string) (string, bool) {
+	if s.config.BaseDomain == "" {
+		return "", false
+	}
+	host = strings.ToLower(host)
+	if h, _, ok := strings.Cut(host, ":"); ok {
+		host = h
+	}
+	slug, ok := strings.CutSuffix(host, "."+strings.ToLower(s.config.BaseDomain))
+	if !ok || slug == "" || strings.Contains(slug, ".") {
+		return "", false
+	}
+	return slug, true
+}
+
+// ResolveHost finds the tenant a request's Host header names. It returns
+// false for hosts outside the base domain and ErrTenantNotFound for
+// subdomains that are not a tenant.
+func (s *Service) ResolveHost(ctx context.Context, host string) (Tenant, bool, error) {
+	slug, ok := s.SlugFromHost(host)
+	if !ok {
+		return Tenant{}, false, nil
+	}
+	t, err := s.store.GetTenantBySlug(ctx, slug)
+	if err != nil {
+		return Tenant{}, true, err
+	}
+	return t, true, nil
+}
+
+func (s *Service) ListTenants(ctx context.Context) ([]Tenant, error) {
+	return s.store.ListTenants(ctx)
+}
+
+func (s *Service) GetTenant(ctx context.Context, id int64) (Tenant, error) {
+	return s.store.GetTenant(ctx, id)
+}
+
+func (s *Service) CreateTenant(ctx context.Context, t Tenant) (Tenant, error) {
+	if err := t.normalize(); err != nil {
+		return Tenant{}, err
+	}
+	if _, err := s.store.GetTenantBySlug(ctx, t.Slug); err == nil {
+		return Tenant{}, ErrSlugTaken
+	} else if !errors.Is(err, ErrTenantNotFound) {
+		return Tenant{}, err
+	}
+	t.CreatedOn = time.Now()
+	return s.store.CreateTenant(ctx, t)
+}
+
+// UpdateTenant renames a tenant or moves it to another slug. Links that
+// use the old subdomain stop working.
+func (s *Service) UpdateTenant(ctx context.Context, t Tenant) (Tenant, error) {
+	current, err := s.store.GetTenant(ctx, t.ID)
+	if err != nil {
+		return Tenant{}, err
+	}
+	if err := t.normalize(); err != nil {
+		return Tenant{}, err
+	}
+	if t.Slug != current.Slug {
+		if _, err := s.store.GetTenantBySlug(ctx, t.Slug); err == nil {
+			return Tenant{}, ErrSlugTaken
+		} else if !errors.Is(err, ErrTenantNotFound) {
+			return Tenant{}, err
+		}
+	}
+	t.CreatedOn = current.CreatedOn
+	if err := s.store.UpdateTenant(ctx, t); err != nil {
+		return Tenant{}, err
+	}
+	return t, nil
+}
+
+func (s *Service) DeleteTenant(ctx context.Context, id int64) error {
+	if id == DefaultTenantID {
+		return ErrDefaultTenant
+	}
+	if _, err := s.store.GetTenant(ctx, id); err != nil {
+		return err
+	}
+	return s.store.DeleteTenant(ctx, id)
+}
@@ -0,0 +1,119 @@
+package Tenant
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryTenantStore keeps tenants in process. Like the tenants table it
+// starts with the default tenant and keeps slugs unique.
+type MemoryTenantStore struct {
+	mu      sync.RWMutex
+	lastID  int64
+	tenants map[int64]Tenant
+	inUse   func(ctx context.Context, id int64) (bool, error)
+}
+
+// NewMemoryTenantStore returns a store holding the default tenant. inUse
+// tells whether users or students still belong to a tenant, which the
+// store cannot see itself; nil treats every tenant as empty.
+func NewMemoryTenantStore(inUse func(ctx context.Context, id int64) (bool, error)) *MemoryTenantStore {
+	return &MemoryTenantStore{
+		lastID: DefaultTenantID,
+		tenants: map[int64]Tenant{
+			DefaultTenantID: {ID: DefaultTenantID, Slug: "default", Name: "Default", CreatedOn: time.Now()},
+		},
+		inUse: inUse,
+	}
+}
+
+func (m *MemoryTenantStore) GetTenant(ctx context.Context, id int64) (Tenant, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	t, ok := m.tenants[id]
+	if !ok {
+		return Tenant{}, ErrTenantNotFound
+	}
+	return t, nil
+}
+
+func (m *MemoryTenantStore) GetTenantBySlug(ctx context.Context, slug string) (Tenant, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, t := range m.tenants {
+		if t.Slug == slug {
+			return t, nil
+		}
+	}
+	return Tenant{}, ErrTenantNotFound
+}
+
+func (m *MemoryTenantStore) ListTenants(ctx context.Context) ([]Tenant, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	tenants := make([]Tenant, 0, len(m.tenants))
+	for _, t := range m.tenants {
+		tenants = append(tenants, t)
+	}
+	sort.Slice(tenants, func(i, j int) bool { return tenants[i].ID < tenants[j].ID })
+	return tenants, nil
+}
+
+// slugTakenLocked reports whether a tenant other than id uses slug.
+func (m *MemoryTenantStore) slugTakenLocked(id int64, slug string) bool {
+	for _, t := range m.tenants {
+		if t.ID != id && t.Slug == slug {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateTenant fails with ErrSlugTaken where the table's unique key would.
+func (m *MemoryTenantStore) CreateTenant(ctx context.Context, t Tenant) (Tenant, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.slugTakenLocked(0, t.Slug) {
+		return Tenant{}, ErrSlugTaken
+	}
+	m.lastID++
+	t.ID = m.lastID
+	m.tenants[t.ID] = t
+	return t, nil
+}
+
+func (m *MemoryTenantStore) UpdateTenant(ctx context.Context, t Tenant) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current, ok := m.tenants[t.ID]
+	if !ok {
+		return nil
+	}
+	if m.slugTakenLocked(t.ID, t.Slug) {
+		return ErrSlugTaken
+	}
+	current.Slug, current.Name = t.Slug, t.Name
+	m.tenants[t.ID] = current
+	return nil
+}
+
+func (m *MemoryTenantStore) DeleteTenant(ctx context.Context, id int64) error {
+	if m.inUse != nil {
+		used, err := m.inUse(ctx, id)
+		if err != nil {
+			return err
+		}
+		if used {
+			return ErrTenantNotEmpty
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.tenants[id]; !ok {
+		return ErrTenantNotEmpty
+	}
+	delete(m.tenants, id)
+	return nil
+}
@@ -0,0 +1,78 @@
+package Tenant
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMemoryTenantStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewService(NewMemoryTenantStore(nil), Config{BaseDomain: "students.example.org"})
+
+	if def, err := s.GetTenant(ctx, DefaultTenantID); err != nil || def.Slug != "default" {
+		t.Fatalf("default tenant = %+v, %v", def, err)
+	}
+	north, err := s.CreateTenant(ctx, Tenant{Slug: " North ", Name: "North School"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if north.ID != DefaultTenantID+1 || north.Slug != "north" {
+		t.Errorf("created = %+v", north)
+	}
+	if _, err := s.CreateTenant(ctx, Tenant{Slug: "north", Name: "Again"}); !errors.Is(err, ErrSlugTaken) {
+		t.Errorf("duplicate slug: err = %v", err)
+	}
+	if _, err := s.UpdateTenant(ctx, Tenant{ID: north.ID, Slug: "default", Name: "North"}); !errors.Is(err, ErrSlugTaken) {
+		t.Errorf("renaming onto a taken slug: err = %v", err)
+	}
+	if _, err := s.UpdateTenant(ctx, Tenant{ID: north.ID, Slug: "nord", Name: "Nord"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok, err := s.ResolveHost(ctx, "nord.students.example.org"); err != nil || !ok || got.ID != north.ID || got.Name != "Nord" {
+		t.Errorf("resolved = %+v, %v, %v", got, ok, err)
+	}
+	if _, _, err := s.ResolveHost(ctx, "north.students.example.org"); !errors.Is(err, ErrTenantNotFound) {
+		t.Errorf("old slug: err = %v", err)
+	}
+
+	tenants, err := s.ListTenants(ctx)
+	if err != nil || len(tenants) != 2 || tenants[0].ID != DefaultTenantID || tenants[1].ID != north.ID {
+		t.Errorf("tenants = %+v, %v", tenants, err)
+	}
+
+	if err := s.DeleteTenant(ctx, DefaultTenantID); !errors.Is(err, ErrDefaultTenant) {
+		t.Errorf("deleting the default tenant: err = %v", err)
+	}
+	if err := s.DeleteTenant(ctx, north.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteTenant(ctx, north.ID); !errors.Is(err, ErrTenantNotFound) {
+		t.Errorf("deleting twice: err = %v", err)
+	}
+}
+
+func TestMemoryTenantStoreKeepsTenantsInUse(t *testing.T) {
+	ctx := context.Background()
+	used := map[int64]bool{}
+	s := NewService(NewMemoryTenantStore(func(ctx context.Context, id int64) (bool, error) {
+		return used[id], nil
+	}), Config{})
+
+	north, err := s.CreateTenant(ctx, Tenant{Slug: "north", Name: "North"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	used[north.ID] = true
+	if err := s.DeleteTenant(ctx, north.ID); !errors.Is(err, ErrTenantNotEmpty) {
+		t.Errorf("tenant in use: err = %v", err)
+	}
+	if _, err := s.GetTenant(ctx, north.ID); err != nil {
+		t.Errorf("tenant in use was deleted: %v", err)
+	}
+
+	used[north.ID] = false
+	if err := s.DeleteTenant(ctx, north.ID); err != nil {
+		t.Errorf("emptied tenant: err = %v", err)
+	}
+}
@@ -0,0 +1,254 @@
+package User
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	DefaultUserListLimit = 20
+	MaxUserListLimit     = 100
+)
+
+var (
+	ErrInvalidUserListOptions = errors.New("invalid limit or offset")
+	ErrAccountDisabled        = errors.New("account disabled")
+	ErrCannotModifySelf       = errors.New("administrators cannot disable or delete their own account")
+	ErrWrongPassword          = errors.New("current password is wrong")
+)
+
+// Disabled reports whether an administrator switched the account off.
+func (u User) Disabled() bool {
+	return u.DisabledAt != nil
+}
+
+// UserListOptions pages through users. Query matches a part of the
+// username or email; Role, when set, keeps only users with that role.
+type UserListOptions struct {
+	Query  string
+	Role   Role
+	Limit  int
+	Offset int
+}
+
+type UserPage struct {
+	Users  []User `json:"users"`
+	Total  int64  `json:"total"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+}
+
+func (o *UserListOptions) Normalize() error {
+	o.Query = strings.TrimSpace(o.Query)
+	if o.Limit == 0 {
+		o.Limit = DefaultUserListLimit
+	}
+	if o.Limit < 0 || o.Limit > MaxUserListLimit || o.Offset < 0 || (o.Role != "" && !o.Role.Valid()) {
+		return ErrInvalidUserListOptions
+	}
+	return nil
+}
+
+func (s *Service) ListUsers(ctx context.Context, opts UserListOptions) (UserPage, error) {
+	if err := opts.Normalize(); err != nil {
+		return UserPage{}, err
+	}
+	return s.store.ListUsers(ctx, opts)
+}
+
+func (s *Service) GetUser(ctx context.Context, uid int64) (User, error) {
+	return s.store.GetUserByID(ctx, uid)
+}
+
+func (s *Service) GetUserByUsername(ctx context.Context, username string) (User, error) {
+	return s.store.GetUserByUsername(ctx, username)
+}
+
+// managedUser fetches the user an administrator acts on. Only a
+// super-admin may act on another super-admin's account, or an admin could
+// take it over by changing its email or password.
+func (s *Service) managedUser(ctx context.Context, uid int64) (User, error) {
+	user, err := s.store.GetUserByID(ctx, uid)
+	if err != nil {
+		return User{}, err
+	}
+	if user.Role == RoleSuperAdmin && !isSuperAdmin(ctx) {
+		return User{}, ErrSuperAdminOnly
+	}
+	return user, nil
+}
+
+// DisableUser switches an account off and ends its sessions. Its API keys
+// stop working with it.
+func (s *Service) DisableUser(ctx context.Context, actor, uid int64) error {
+	if actor == uid {
+		return ErrCannotModifySelf
+	}
+	if _, err := s.managedUser(ctx, uid); err != nil {
+		return err
+	}
+	now := time.Now()
+	if err := s.store.SetUserDisabled(ctx, uid, &now); err != nil {
+		return fmt.Errorf("could not disable user: %w", err)
+	}
+	if _, err := s.sessions.RevokeUserSessions(ctx, uid); err != nil {
+		return fmt.Errorf("could not revoke sessions: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) EnableUser(ctx context.Context, uid int64) error {
+	if _, err := s.managedUser(ctx, uid); err != nil {
+		return err
+	}
+	return s.store.SetUserDisabled(ctx, uid, nil)
+}
+
+// ChangeEmail sets a new address, which has to be verified again.
+func (s *Service) ChangeEmail(ctx context.Context, uid int64, email string) (User, error) {
+	user, err := s.managedUser(ctx, uid)
+	if err != nil {
+		return User{}, err
+	}
+	if user.Email == email {
+		return user, nil
+	}
+	if err := s.store.ChangeEmail(ctx, uid, email); err != nil {
+		return User{}, fmt.Errorf("could not change email: %w", err)
+	}
+	user.Email, user.EmailVerifiedAt = email, nil
+	if err := s.sendVerification(user); err != nil {
+		log.Errorf("could not send verification mail to user %d: %s", uid, err.Error())
+	}
+	return user, nil
+}
+
+// ForcePasswordReset locks the user out of their current password and
+// sessions and mails them a reset link.
+func (s *Service) ForcePasswordReset(ctx context.Context, uid int64) error {
+	user, err := s.managedUser(ctx, uid)
+	if err != nil {
+		return err
+	}
+	if err := s.setPassword(ctx, user, ""); err != nil {
+		return err
+	}
+	if _, err := s.sessions.RevokeUserSessions(ctx, uid); err != nil {
+		return fmt.Errorf("could not revoke sessions: %w", err)
+	}
+	return s.sendPasswordReset(ctx, user, "An administrator asked you to choose a new password.")
+}
+
+func (s *Service) DeleteUser(ctx context.Context, actor, uid int64) error {
+	if actor == uid {
+		return ErrCannotModifySelf
+	}
+	if _, err := s.managedUser(ctx, uid); err != nil {
+		return err
+	}
+	if _, err := s.sessions.RevokeUserSessions(ctx, uid); err != nil {
+		return fmt.Errorf("could not revoke sessions: %w", err)
+	}
+	if err := s.deleteCredentials(ctx, uid); err != nil {
+		return err
+	}
+	return s.store.DeleteUser(ctx, uid)
+}
+
+// deleteCredentials removes everything that lets someone act as the user
+// or that was derived from their secrets. Nothing in the schema cascades,
+// so a deleted account would otherwise leave it behind. The account row
+// goes last, so a failed delete can simply be retried.
+func (s *Service) deleteCredentials(ctx context.Context, uid int64) error {
+	if s.APIKeys != nil {
+		if err := s.APIKeys.DeleteUserAPIKeys(ctx, uid); err != nil {
+			return fmt.Errorf("could not delete API keys: %w", err)
+		}
+	}
+	if s.Identities != nil {
+		if err := s.Identities.UnlinkUser(ctx, uid); err != nil {
+			return fmt.Errorf("could not unlink identities: %w", err)
+		}
+	}
+	if s.MFA != nil {
+		if err := s.MFA.DeleteTOTP(ctx, uid); err != nil {
+			return fmt.Errorf("could not delete TOTP: %w", err)
+		}
+	}
+	if s.Resets != nil {
+		if err := s.Resets.DeleteUserResetTokens(ctx, uid); err != nil {
+			return fmt.Errorf("could not delete reset tokens: %w", err)
+		}
+	}
+	if s.PasswordHistory != nil {
+		if err := s.PasswordHistory.DeletePasswordHistory(ctx, uid); err != nil {
+			return fmt.Errorf("could not delete password history: %w", err)
+		}
+	}
+	return nil
+}
+
+// ChangePassword lets a signed in user replace their password. Every other
+// session of theirs is ended; keepSession stays signed in.
+func (s *Service) ChangePassword(ctx context.Context, uid int64, current, password, keepSession string) error {
+	user, err := s.store.GetUserByID(ctx, uid)
+	if err != nil {
+		return err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(current)) != nil {
+		return ErrWrongPassword
+	}
+	if err := s.CheckPassword(ctx, user, password); err != nil {
+		return err
+	}
+	if err := s.setPassword(ctx, user, password); err != nil {
+		return err
+	}
+	if _, err := s.sessions.RevokeOtherSessions(ctx, uid, keepSession); err != nil {
+		return fmt.Errorf("could not revoke sessions: %w", err)
+	}
+	return nil
+}
+
+// setPassword stores the hash of a password that passed CheckPassword. An
+// empty password is replaced by a random one nobody knows, which is kept
+// out of the history.
+func (s *Service) setPassword(ctx context.Context, user User, password string) error {
+	random := password == ""
+	if random {
+		token, err := randomToken(32)
+		if err != nil {
+			return err
+		}
+		password = token
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	user.Password = string(hashedPassword)
+	user.JWTToken = nil
+	if err := s.store.UpdateUser(ctx, user); err != nil {
+		return fmt.Errorf("could not update password: %w", err)
+	}
+	if !random {
+		s.recordPasswordHistory(ctx, user.UID, user.Password)
+	}
+	return nil
+}
+
+// recordPasswordHistory only logs failures: the password is already set.
+func (s *Service) recordPasswordHistory(ctx context.Context, uid int64, hash string) {
+	if s.PasswordPolicy.HistorySize <= 0 {
+		return
+	}
+	if err := s.PasswordHistory.AddPasswordHistory(ctx, uid, hash, time.Now(), s.PasswordPolicy.HistorySize); err != nil {
+		log.Errorf("could not record password history of user %d: %s", uid, err.Error())
+	}
+}
@@ -0,0 +1,305 @@
+package User
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"Students-Final-Assignment/Internal/Tenant"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func TestListUsersFiltersAndPages(t *testing.T) {
+	s := newTestService(t)
+	s.addUser("ann", RoleTeacher)
+	s.addUser("bob", RoleTeacher)
+	s.addUser("annika", RoleReadOnly)
+
+	page, err := s.ListUsers(s.ctx, UserListOptions{Query: " ANN "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 2 || page.Limit != DefaultUserListLimit || len(page.Users) != 2 {
+		t.Errorf("query: %+v", page)
+	}
+	page, _ = s.ListUsers(s.ctx, UserListOptions{Role: RoleTeacher, Limit: 1, Offset: 1})
+	if page.Total != 2 || len(page.Users) != 1 || page.Users[0].Username != "bob" {
+		t.Errorf("role and paging: %+v", page)
+	}
+
+	for _, opts := range []UserListOptions{{Limit: -1}, {Limit: MaxUserListLimit + 1}, {Offset: -1}, {Role: "owner"}} {
+		if _, err := s.ListUsers(s.ctx, opts); !errors.Is(err, ErrInvalidUserListOptions) {
+			t.Errorf("%+v: err = %v", opts, err)
+		}
+	}
+}
+
+func TestDisableUserEndsSessionsAndLogin(t *testing.T) {
+	s := newTestService(t)
+	admin := s.addUser("admin", RoleAdmin)
+	uid := s.addUser("ann", RoleTeacher)
+	session := s.login("ann")
+
+	if err := s.DisableUser(s.ctx, admin, admin); !errors.Is(err, ErrCannotModifySelf) {
+		t.Errorf("disabling oneself: err = %v", err)
+	}
+	if err := s.DisableUser(s.ctx, admin, 404); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("unknown user: err = %v", err)
+	}
+	if err := s.DisableUser(s.ctx, admin, uid); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.validate(session.AccessToken); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("session survived: %v", err)
+	}
+	if _, err := s.Login(s.ctx, "ann", testPassword, "192.0.2.1"); !errors.Is(err, ErrAccountDisabled) {
+		t.Errorf("login while disabled: err = %v", err)
+	}
+
+	if err := s.EnableUser(s.ctx, uid); err != nil {
+		t.Fatal(err)
+	}
+	s.login("ann")
+}
+
+func TestChangeEmailNeedsVerificationAgain(t *testing.T) {
+	s := newTestService(t)
+	uid := s.addUser("ann", RoleTeacher)
+	if err := s.users.SetEmailVerified(s.ctx, uid, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := s.ChangeEmail(s.ctx, uid, "ann@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Email != "ann@example.com" || user.EmailVerifiedAt != nil {
+		t.Errorf("user = %+v", user)
+	}
+	stored, _ := s.GetUser(s.ctx, uid)
+	if stored.Email != "ann@example.com" || stored.EmailVerifiedAt != nil {
+		t.Errorf("stored = %+v", stored)
+	}
+	s.Wait()
+	if messages := s.mailer.Messages(); len(messages) != 1 || messages[0].To != "ann@example.com" {
+		t.Errorf("messages = %+v", messages)
+	}
+}
+
+func TestForcePasswordReset(t *testing.T) {
+	s := newTestService(t)
+	s.addUser("ann", RoleTeacher)
+	uid := s.addUser("bob", RoleTeacher)
+	session := s.login("bob")
+
+	if err := s.ForcePasswordReset(s.ctx, uid); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Login(s.ctx, "bob", testPassword, "192.0.2.1"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("old password still works: err = %v", err)
+	}
+	if err := s.validate(session.AccessToken); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("session survived: %v", err)
+	}
+	s.Wait()
+	messages := s.mailer.Messages()
+	if len(messages) != 1 || messages[0].To != "bob@example.org" {
+		t.Fatalf("messages = %+v", messages)
+	}
+	if err := s.ResetPassword(s.ctx, resetTokenFrom(t, messages[0].Body), "Another-Good-Passw0rd"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Login(s.ctx, "bob", "Another-Good-Passw0rd", "192.0.2.1"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	s := newTestService(t)
+	admin := s.addUser("admin", RoleAdmin)
+	uid := s.addUser("ann", RoleTeacher)
+
+	if err := s.DeleteUser(s.ctx, admin, admin); !errors.Is(err, ErrCannotModifySelf) {
+		t.Errorf("deleting oneself: err = %v", err)
+	}
+	if err := s.DeleteUser(s.ctx, admin, uid); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetUser(s.ctx, uid); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("deleted user: err = %v", err)
+	}
+	if err := s.DeleteUser(s.ctx, admin, uid); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("second delete: err = %v", err)
+	}
+}
+
+func TestDeleteUserRemovesCredentials(t *testing.T) {
+	s := newTestService(t)
+	s.Identities = NewMemoryIdentityStore()
+	admin := s.addUser("admin", RoleAdmin)
+	uid := s.addUser("ann", RoleTeacher)
+	keep := s.addUser("bob", RoleTeacher)
+
+	created, err := s.CreateAPIKey(s.ctx, uid, NewAPIKey{Name: "sync", Scopes: []Permission{PermReadStudents}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateAPIKey(s.ctx, keep, NewAPIKey{Name: "sync", Scopes: []Permission{PermReadStudents}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Identities.LinkIdentity(s.ctx, "campus", "uid=ann", uid, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Identities.LinkIdentity(s.ctx, "campus", "uid=bob", keep, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.EnrollTOTP(s.ctx, uid); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ForgotPassword(s.ctx, "ann@example.org", "192.0.2.1"); err != nil {
+		t.Fatal(err)
+	}
+	s.Wait()
+	reset := resetTokenFrom(t, s.mailer.Messages()[0].Body)
+	s.recordPasswordHistory(s.ctx, uid, "old-hash")
+
+	if err := s.DeleteUser(s.ctx, admin, uid); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := s.AuthenticateAPIKey(s.ctx, created.Key); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("API key of a deleted user: err = %v", err)
+	}
+	if keys, err := s.APIKeys.ListAPIKeys(s.ctx, uid); err != nil || len(keys) != 0 {
+		t.Errorf("API keys left: %+v, %v", keys, err)
+	}
+	if _, err := s.Identities.GetIdentityUID(s.ctx, "campus", "uid=ann"); !errors.Is(err, ErrIdentityNotFound) {
+		t.Errorf("identity link left: err = %v", err)
+	}
+	if _, err := s.MFA.GetTOTP(s.ctx, uid); !errors.Is(err, ErrTOTPNotEnrolled) {
+		t.Errorf("TOTP secret left: err = %v", err)
+	}
+	if _, err := s.Resets.GetResetToken(s.ctx, hashToken(reset)); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("reset token left: err = %v", err)
+	}
+	if hashes, err := s.PasswordHistory.ListPasswordHistory(s.ctx, uid, 10); err != nil || len(hashes) != 0 {
+		t.Errorf("password history left: %v, %v", hashes, err)
+	}
+
+	// Other accounts keep theirs.
+	if keys, _ := s.APIKeys.ListAPIKeys(s.ctx, keep); len(keys) != 1 {
+		t.Errorf("bob's API keys = %+v", keys)
+	}
+	if linked, err := s.Identities.GetIdentityUID(s.ctx, "campus", "uid=bob"); err != nil || linked != keep {
+		t.Errorf("bob's link = %d, %v", linked, err)
+	}
+}
+
+func TestChangePasswordKeepsTheCallersSession(t *testing.T) {
+	s := newTestService(t)
+	uid := s.addUser("ann", RoleTeacher)
+	current, other := s.login("ann"), s.login("ann")
+	token, err := s.ParseToken(current.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sid, _ := token.Claims.(jwt.MapClaims)["sid"].(string)
+
+	if err := s.ChangePassword(s.ctx, uid, "wrong", "Another-Good-Passw0rd", sid); !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("wrong current password: err = %v", err)
+	}
+	if err := s.ChangePassword(s.ctx, uid, testPassword, "Another-Good-Passw0rd", sid); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.validate(current.AccessToken); err != nil {
+		t.Errorf("caller's session: %v", err)
+	}
+	if err := s.validate(other.AccessToken); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("other session survived: %v", err)
+	}
+}
+
+func TestOnlySuperAdminsManageSuperAdmins(t *testing.T) {
+	s := newTestService(t)
+	admin := s.addUser("admin", RoleAdmin)
+	root := s.addUser("root", RoleSuperAdmin)
+	other := s.addUser("other", RoleSuperAdmin)
+	asAdmin := ContextWithRole(ContextWithUID(s.ctx, admin), RoleAdmin)
+	asRoot := ContextWithRole(ContextWithUID(s.ctx, root), RoleSuperAdmin)
+
+	password := "Takeover-Passw0rd"
+	email := "admin@example.org"
+	active := false
+	attempts := map[string]func(ctx context.Context, actor int64) error{
+		"DisableUser": func(ctx context.Context, actor int64) error { return s.DisableUser(ctx, actor, other) },
+		"ChangeEmail": func(ctx context.Context, actor int64) error {
+			_, err := s.ChangeEmail(ctx, other, email)
+			return err
+		},
+		"ForcePasswordReset": func(ctx context.Context, actor int64) error { return s.ForcePasswordReset(ctx, other) },
+		"UpdateAccount password": func(ctx context.Context, actor int64) error {
+			_, err := s.UpdateAccount(ctx, actor, other, AccountChanges{Password: &password})
+			return err
+		},
+		"UpdateAccount email": func(ctx context.Context, actor int64) error {
+			_, err := s.UpdateAccount(ctx, actor, other, AccountChanges{Email: &email})
+			return err
+		},
+		"UpdateAccount active": func(ctx context.Context, actor int64) error {
+			_, err := s.UpdateAccount(ctx, actor, other, AccountChanges{Active: &active})
+			return err
+		},
+		"DeleteUser": func(ctx context.Context, actor int64) error { return s.DeleteUser(ctx, actor, other) },
+	}
+	for name, attempt := range attempts {
+		if err := attempt(asAdmin, admin); !errors.Is(err, ErrSuperAdminOnly) {
+			t.Errorf("%s by an admin: err = %v", name, err)
+		}
+	}
+	user, err := s.GetUser(s.ctx, other)
+	if err != nil || user.Email != "other@example.org" || user.Disabled() {
+		t.Fatalf("super-admin changed: %+v, %v", user, err)
+	}
+	s.login("other")
+	s.Wait()
+	if messages := s.mailer.Messages(); len(messages) != 0 {
+		t.Errorf("mails sent: %+v", messages)
+	}
+
+	if _, err := s.UpdateAccount(asRoot, root, other, AccountChanges{Password: &password}); err != nil {
+		t.Errorf("super-admin setting a password: %v", err)
+	}
+	if err := s.DeleteUser(asRoot, root, other); err != nil {
+		t.Errorf("super-admin deleting a super-admin: %v", err)
+	}
+	// Admins still manage everyone else.
+	if _, err := s.ChangeEmail(asAdmin, s.addUser("ann", RoleTeacher), "ann@example.com"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAdminsOnlyManageTheirTenant(t *testing.T) {
+	s := newTestService(t)
+	uid := s.addUser("ann", RoleTeacher)
+	root := s.addUser("root", RoleSuperAdmin)
+	elsewhere := Tenant.ContextWithTenant(context.Background(), 2)
+
+	for _, ctx := range []context.Context{
+		ContextWithRole(ContextWithUID(elsewhere, 99), RoleAdmin),
+		ContextWithRole(ContextWithUID(elsewhere, 99), RoleSuperAdmin),
+	} {
+		for _, target := range []int64{uid, root} {
+			if _, err := s.ChangeEmail(ctx, target, "x@example.org"); !errors.Is(err, ErrUserNotFound) {
+				t.Errorf("ChangeEmail of %d from tenant 2: err = %v", target, err)
+			}
+			if err := s.ForcePasswordReset(ctx, target); !errors.Is(err, ErrUserNotFound) {
+				t.Errorf("ForcePasswordReset of %d from tenant 2: err = %v", target, err)
+			}
+			if err := s.DeleteUser(ctx, 99, target); !errors.Is(err, ErrUserNotFound) {
+				t.Errorf("DeleteUser of %d from tenant 2: err = %v", target, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package User
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// apiKeyPrefix marks our keys, so they are easy to spot in leaked
+	// configs and secret scanners.
+	apiKeyPrefix = "sfa_"
+
+	// apiKeyTouchInterval limits how often LastUsedAt is written for a key
+	// in constant use.
+	apiKeyTouchInterval = time.Minute
+)
+
+var (
+	ErrInvalidAPIKey  = errors.New("invalid or expired API key")
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	ErrInvalidScope   = errors.New("API key scopes must be permissions of the owner's role")
+	ErrEmptyKeyName   = errors.New("API key name must not be empty")
+)
+
+// APIKey lets a script act as its owner without a password. It can never do
+// more than both its scopes and the owner's current role allow. Only the
+// hash of the secret is stored; Prefix is kept in clear so keys can be told
+// apart in listings.
+type APIKey struct {
+	ID         int64        `json:"id"`
+	UID        int64        `json:"uid"`
+	TenantID   int64        `json:"-"`
+	Name       string       `json:"name"`
+	Prefix     string       `json:"prefix"`
+	Hash       string       `json:"-"`
+	Scopes     []Permission `json:"scopes"`
+	CreatedOn  time.Time    `json:"created_on"`
+	LastUsedAt *time.Time   `json:"last_used_at"`
+	ExpiresAt  *time.Time   `json:"expires_at"`
+}
+
+func (k APIKey) expiredAt(now time.Time) bool {
+	return k.ExpiresAt != nil && !k.ExpiresAt.After(now)
+}
+
+// Allows reports whether the key's scopes include p.
+func (k APIKey) Allows(p Permission) bool {
+	for _, scope := range k.Scopes {
+		if scope == p {
+			return true
+		}
+	}
+	return false
+}
+
+type APIKeyStore interface {
+	CreateAPIKey(ctx context.Context, key APIKey) (int64, error)
+	// GetAPIKeyByPrefix fails with ErrAPIKeyNotFound for an unknown prefix.
+	GetAPIKeyByPrefix(ctx context.Context, prefix string) (APIKey, error)
+	ListAPIKeys(ctx context.Context, uid int64) ([]APIKey, error)
+	// DeleteAPIKey fails with ErrAPIKeyNotFound unless uid owns the key.
+	DeleteAPIKey(ctx context.Context, uid, id int64) error
+	// DeleteUserAPIKeys removes every key of the user.
+	DeleteUserAPIKeys(ctx context.Context, uid int64) error
+	TouchAPIKey(ctx context.Context, id int64, at time.Time) error
+}
+
+type NewAPIKey struct {
+	Name      string       `json:"name"`
+	Scopes    []Permission `json:"scopes"`
+	ExpiresAt *time.Time   `json:"expires_at"`
+}
+
+// CreatedAPIKey carries the secret, which is shown this once only.
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// splitAPIKey returns the visible prefix of a raw key, "sfa_" plus eight
+// characters.
+func splitAPIKey(raw string) (string, bool) {
+	if !strings.HasPrefix(raw, apiKeyPrefix) {
+		return "", false
+	}
+	prefix, _, ok := strings.Cut(raw, ".")
+	return prefix, ok && len(prefix) == len(apiKeyPrefix)+8
+}
+
+// IsAPIKey tells a raw API key from other credentials, such as a JWT sent
+// under the same Bearer scheme.
+func IsAPIKey(raw string) bool {
+	_, ok := splitAPIKey(raw)
+	return ok
+}
+
+// CreateAPIKey makes a key for uid. Its scopes must all be permissions the
+// user's role grants.
+func (s *Service) CreateAPIKey(ctx context.Context, uid int64, req NewAPIKey) (CreatedAPIKey, error) {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return CreatedAPIKey{}, ErrEmptyKeyName
+	}
+	user, err := s.store.GetUserByID(ctx, uid)
+	if err != nil {
+		return CreatedAPIKey{}, err
+	}
+	if len(req.Scopes) == 0 {
+		return CreatedAPIKey{}, ErrInvalidScope
+	}
+	for _, scope := range req.Scopes {
+		if !user.Role.Can(scope) {
+			return CreatedAPIKey{}, fmt.Errorf("%w: %q", ErrInvalidScope, scope)
+		}
+	}
+
+	id, err := randomToken(6)
+	if err != nil {
+		return CreatedAPIKey{}, err
+	}
+	secret, err := randomToken(32)
+	if err != nil {
+		return CreatedAPIKey{}, err
+	}
+	// The prefix is taken from base64url text, which never contains a dot.
+	prefix := apiKeyPrefix + id
+	raw := prefix + "." + secret
+
+	key := APIKey{
+		UID:       uid,
+		TenantID:  user.TenantID,
+		Name:      req.Name,
+		Prefix:    prefix,
+		Hash:      hashToken(raw),
+		Scopes:    req.Scopes,
+		CreatedOn: time.Now(),
+		ExpiresAt: req.ExpiresAt,
+	}
+	if key.ID, err = s.APIKeys.CreateAPIKey(ctx, key); err != nil {
+		return CreatedAPIKey{}, fmt.Errorf("could not store API key: %w", err)
+	}
+	return CreatedAPIKey{APIKey: key, Key: raw}, nil
+}
+
+func (s *Service) ListAPIKeys(ctx context.Context, uid int64) ([]APIKey, error) {
+	return s.APIKeys.ListAPIKeys(ctx, uid)
+}
+
+func (s *Service) DeleteAPIKey(ctx context.Context, uid, id int64) error {
+	return s.APIKeys.DeleteAPIKey(ctx, uid, id)
+}
+
+// AuthenticateAPIKey returns the key and its owner for a raw key from an
+// "Authorization: ApiKey" header. The owner is looked up in the tenant the
+// key was created in; callers scope the request to user.TenantID.
+func (s *Service) AuthenticateAPIKey(ctx context.Context, raw string) (APIKey, User, error) {
+	prefix, ok := splitAPIKey(raw)
+	if !ok {
+		return APIKey{}, User{}, ErrInvalidAPIKey
+	}
+	key, err := s.APIKeys.GetAPIKeyByPrefix(ctx, prefix)
+	if err != nil {
+		if !errors.Is(err, ErrAPIKeyNotFound) {
+			log.Errorf("could not fetch API key %s: %s", prefix, err.Error())
+		}
+		return APIKey{}, User{}, ErrInvalidAPIKey
+	}
+	now := time.Now()
+	if subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hashToken(raw))) != 1 || key.expiredAt(now) {
+		return APIKey{}, User{}, ErrInvalidAPIKey
+	}
+	user, err := s.store.GetUserByID(userContext(ctx, key.TenantID), key.UID)
+	if err != nil || user.Disabled() {
+		return APIKey{}, User{}, ErrInvalidAPIKey
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > apiKeyTouchInterval {
+		if err := s.APIKeys.TouchAPIKey(ctx, key.ID, now); err != nil {
+			log.Errorf("could not record use of API key %s: %s", prefix, err.Error())
+		}
+	}
+	return key, user, nil
+}
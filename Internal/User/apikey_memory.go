@@ -0,0 +1,109 @@
+package User
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+var errDuplicateAPIKeyPrefix = errors.New("API key prefix already exists")
+
+// MemoryAPIKeyStore keeps API keys in process. Keys stop working when the
+// process ends.
+type MemoryAPIKeyStore struct {
+	mu     sync.Mutex
+	lastID int64
+	keys   map[int64]APIKey
+}
+
+func NewMemoryAPIKeyStore() *MemoryAPIKeyStore {
+	return &MemoryAPIKeyStore{keys: map[int64]APIKey{}}
+}
+
+// copyAPIKey returns key with its own scopes and times, so callers can't
+// change what is stored.
+func copyAPIKey(key APIKey) APIKey {
+	key.Scopes = append([]Permission(nil), key.Scopes...)
+	if key.LastUsedAt != nil {
+		at := *key.LastUsedAt
+		key.LastUsedAt = &at
+	}
+	if key.ExpiresAt != nil {
+		at := *key.ExpiresAt
+		key.ExpiresAt = &at
+	}
+	return key
+}
+
+// CreateAPIKey rejects a prefix that is taken, as the table's unique key
+// would.
+func (m *MemoryAPIKeyStore) CreateAPIKey(ctx context.Context, key APIKey) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, k := range m.keys {
+		if k.Prefix == key.Prefix {
+			return 0, errDuplicateAPIKeyPrefix
+		}
+	}
+	m.lastID++
+	key.ID = m.lastID
+	m.keys[key.ID] = copyAPIKey(key)
+	return key.ID, nil
+}
+
+func (m *MemoryAPIKeyStore) GetAPIKeyByPrefix(ctx context.Context, prefix string) (APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, k := range m.keys {
+		if k.Prefix == prefix {
+			return copyAPIKey(k), nil
+		}
+	}
+	return APIKey{}, ErrAPIKeyNotFound
+}
+
+func (m *MemoryAPIKeyStore) ListAPIKeys(ctx context.Context, uid int64) ([]APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys := []APIKey{}
+	for _, k := range m.keys {
+		if k.UID == uid {
+			keys = append(keys, copyAPIKey(k))
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys, nil
+}
+
+func (m *MemoryAPIKeyStore) DeleteAPIKey(ctx context.Context, uid, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if k, ok := m.keys[id]; !ok || k.UID != uid {
+		return ErrAPIKeyNotFound
+	}
+	delete(m.keys, id)
+	return nil
+}
+
+func (m *MemoryAPIKeyStore) DeleteUserAPIKeys(ctx context.Context, uid int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, k := range m.keys {
+		if k.UID == uid {
+			delete(m.keys, id)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryAPIKeyStore) TouchAPIKey(ctx context.Context, id int64, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if k, ok := m.keys[id]; ok {
+		k.LastUsedAt = &at
+		m.keys[id] = k
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package User
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCreateAPIKeyChecksNameAndScopes(t *testing.T) {
+	s := newTestService(t)
+	uid := s.addUser("reader", RoleReadOnly)
+
+	if _, err := s.CreateAPIKey(s.ctx, uid, NewAPIKey{Name: " ", Scopes: []Permission{PermReadStudents}}); !errors.Is(err, ErrEmptyKeyName) {
+		t.Errorf("blank name: err = %v", err)
+	}
+	if _, err := s.CreateAPIKey(s.ctx, uid, NewAPIKey{Name: "sync"}); !errors.Is(err, ErrInvalidScope) {
+		t.Errorf("no scopes: err = %v", err)
+	}
+	if _, err := s.CreateAPIKey(s.ctx, uid, NewAPIKey{Name: "sync", Scopes: []Permission{PermWriteStudents}}); !errors.Is(err, ErrInvalidScope) {
+		t.Errorf("scope beyond the role: err = %v", err)
+	}
+
+	created, err := s.CreateAPIKey(s.ctx, uid, NewAPIKey{Name: "sync", Scopes: []Permission{PermReadStudents}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsAPIKey(created.Key) || !strings.HasPrefix(created.Key, created.Prefix+".") || created.Hash == created.Key {
+		t.Errorf("created = %+v", created)
+	}
+	keys, err := s.ListAPIKeys(s.ctx, uid)
+	if err != nil || len(keys) != 1 || keys[0].Hash != hashToken(created.Key) {
+		t.Errorf("listed = %+v, %v", keys, err)
+	}
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	s := newTestService(t)
+	uid := s.addUser("sync", RoleRegistrar)
+	created, err := s.CreateAPIKey(s.ctx, uid, NewAPIKey{Name: "sync", Scopes: []Permission{PermExportStudents}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, user, err := s.AuthenticateAPIKey(s.ctx, created.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.UID != uid || !key.Allows(PermExportStudents) || key.Allows(PermReadStudents) {
+		t.Errorf("key = %+v, user = %+v", key, user)
+	}
+	keys, _ := s.ListAPIKeys(s.ctx, uid)
+	if keys[0].LastUsedAt == nil {
+		t.Error("use of the key was not recorded")
+	}
+
+	for _, raw := range []string{
+		created.Key + "x",
+		created.Prefix + ".wrong-secret",
+		"sfa_nothere.secret",
+		"not-a-key",
+	} {
+		if _, _, err := s.AuthenticateAPIKey(s.ctx, raw); !errors.Is(err, ErrInvalidAPIKey) {
+			t.Errorf("%q: err = %v", raw, err)
+		}
+	}
+
+	now := time.Now()
+	if err := s.users.SetUserDisabled(s.ctx, uid, &now); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.AuthenticateAPIKey(s.ctx, created.Key); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("key of a disabled user: err = %v", err)
+	}
+}
+
+func TestExpiredAndDeletedAPIKeys(t *testing.T) {
+	s := newTestService(t)
+	uid := s.addUser("sync", RoleRegistrar)
+	other := s.addUser("other", RoleRegistrar)
+	past := time.Now().Add(-time.Minute)
+	expired, err := s.CreateAPIKey(s.ctx, uid, NewAPIKey{Name: "old", Scopes: []Permission{PermReadStudents}, ExpiresAt: &past})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.AuthenticateAPIKey(s.ctx, expired.Key); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("expired key: err = %v", err)
+	}
+
+	live, _ := s.CreateAPIKey(s.ctx, uid, NewAPIKey{Name: "live", Scopes: []Permission{PermReadStudents}})
+	if err := s.DeleteAPIKey(s.ctx, other, live.ID); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Errorf("deleting someone else's key: err = %v", err)
+	}
+	if err := s.DeleteAPIKey(s.ctx, uid, live.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.AuthenticateAPIKey(s.ctx, live.Key); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("deleted key: err = %v", err)
+	}
+}
@@ -0,0 +1,38 @@
+package User
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidBootstrapAdmin = errors.New("the bootstrap admin needs a username, an email and a password")
+
+// BootstrapAdmin is the super-admin an operator configures for a deployment
+// that has no other way to get one, such as one on in-memory stores.
+type BootstrapAdmin struct {
+	Username string `json:"Username"`
+	Email    string `json:"Email"`
+	Password string `json:"Password"`
+}
+
+// EnsureBootstrapAdmin creates the configured super-admin in ctx's tenant
+// unless an account of that name exists, which is left as it is. The
+// password has to pass the policy like any other, and the email counts as
+// verified. It reports whether the account was created.
+func (s *Service) EnsureBootstrapAdmin(ctx context.Context, admin BootstrapAdmin) (bool, error) {
+	if admin.Username == "" || admin.Email == "" || admin.Password == "" {
+		return false, ErrInvalidBootstrapAdmin
+	}
+	user, err := s.ProvisionUser(ctx, admin.Username, admin.Email, admin.Password, true)
+	if errors.Is(err, ErrUsernameTaken) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("could not create the bootstrap admin: %w", err)
+	}
+	if err := s.store.SetUserRole(ctx, user.UID, RoleSuperAdmin); err != nil {
+		return false, fmt.Errorf("could not promote the bootstrap admin: %w", err)
+	}
+	return true, nil
+}
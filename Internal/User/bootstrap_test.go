@@ -0,0 +1,63 @@
+package User
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEnsureBootstrapAdmin(t *testing.T) {
+	s := newTestService(t)
+	admin := BootstrapAdmin{Username: "root", Email: "root@example.org", Password: testPassword}
+
+	created, err := s.EnsureBootstrapAdmin(s.ctx, admin)
+	if err != nil || !created {
+		t.Fatalf("created = %v, err = %v", created, err)
+	}
+	root, err := s.GetUserByUsername(s.ctx, "root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root.Role != RoleSuperAdmin || !root.EmailVerified() || root.Disabled() {
+		t.Errorf("bootstrap admin = %+v", root)
+	}
+	s.login("root")
+
+	// A restart with the same config leaves the account alone.
+	admin.Password = "Another-Horse-Battery-7"
+	if created, err := s.EnsureBootstrapAdmin(s.ctx, admin); err != nil || created {
+		t.Errorf("second run: created = %v, err = %v", created, err)
+	}
+	s.login("root")
+}
+
+func TestEnsureBootstrapAdminKeepsExistingAccounts(t *testing.T) {
+	s := newTestService(t)
+	uid := s.addUser("root", RoleReadOnly)
+
+	created, err := s.EnsureBootstrapAdmin(s.ctx, BootstrapAdmin{Username: "root", Email: "root@example.org", Password: testPassword})
+	if err != nil || created {
+		t.Fatalf("created = %v, err = %v", created, err)
+	}
+	if u, _ := s.GetUser(s.ctx, uid); u.Role != RoleReadOnly {
+		t.Errorf("existing account promoted: %+v", u)
+	}
+}
+
+func TestEnsureBootstrapAdminRejectsBadConfig(t *testing.T) {
+	s := newTestService(t)
+	for _, admin := range []BootstrapAdmin{
+		{Email: "root@example.org", Password: testPassword},
+		{Username: "root", Password: testPassword},
+		{Username: "root", Email: "root@example.org"},
+	} {
+		if _, err := s.EnsureBootstrapAdmin(s.ctx, admin); !errors.Is(err, ErrInvalidBootstrapAdmin) {
+			t.Errorf("%+v: err = %v", admin, err)
+		}
+	}
+	if _, err := s.EnsureBootstrapAdmin(s.ctx, BootstrapAdmin{Username: "root", Email: "root@example.org", Password: "root"}); !errors.Is(err, ErrWeakPassword) {
+		t.Errorf("weak password: err = %v", err)
+	}
+	if _, err := s.GetUserByUsername(s.ctx, "root"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("rejected admin was created: err = %v", err)
+	}
+}
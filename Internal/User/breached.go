@@ -0,0 +1,94 @@
+package User
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BreachedPasswords looks passwords up by SHA-1 among known compromised
+// ones. Like the Have I Been Pwned range API it splits each hash into a
+// five character prefix and the rest, so it can be backed by either
+//
+//   - a single file of "HASH" or "HASH:count" lines, held in memory, or
+//   - a directory of range files named "<PREFIX>.txt" with "SUFFIX:count"
+//     lines, where only the one file for a password's prefix is read.
+//
+// The directory form suits the full list, which is too large for memory.
+type BreachedPasswords struct {
+	dir      string
+	suffixes map[string]map[string]bool
+}
+
+func LoadBreachedPasswords(path string) (*BreachedPasswords, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open breached password list: %w", err)
+	}
+	if info.IsDir() {
+		return &BreachedPasswords{dir: path}, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open breached password list: %w", err)
+	}
+	defer file.Close()
+
+	b := &BreachedPasswords{suffixes: map[string]map[string]bool{}}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		hash := hashField(scanner.Text())
+		if len(hash) != sha1.Size*2 {
+			continue
+		}
+		prefix, suffix := hash[:5], hash[5:]
+		if b.suffixes[prefix] == nil {
+			b.suffixes[prefix] = map[string]bool{}
+		}
+		b.suffixes[prefix][suffix] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read breached password list: %w", err)
+	}
+	return b, nil
+}
+
+// hashField returns the upper case hash before an optional ":count".
+func hashField(line string) string {
+	hash, _, _ := strings.Cut(strings.TrimSpace(line), ":")
+	return strings.ToUpper(hash)
+}
+
+func (b *BreachedPasswords) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	if b.dir == "" {
+		return b.suffixes[prefix][suffix], nil
+	}
+
+	file, err := os.Open(filepath.Join(b.dir, prefix+".txt"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if hashField(scanner.Text()) == suffix {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
@@ -0,0 +1,46 @@
+package User
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Config holds the account policies an operator can change without a
+// rebuild.
+type Config struct {
+	// RequireVerifiedEmail makes Login refuse accounts whose email has not
+	// been verified yet.
+	RequireVerifiedEmail bool `json:"RequireVerifiedEmail"`
+	// Lockout replaces DefaultLockoutPolicy when set.
+	Lockout *LockoutPolicy `json:"Lockout"`
+	// MFAIssuer replaces DefaultMFAIssuer when set.
+	MFAIssuer string `json:"MFAIssuer"`
+	// PasswordPolicy replaces DefaultPasswordPolicy when set. A relative
+	// BreachedListPath is taken from the config file's directory.
+	PasswordPolicy *PasswordPolicy `json:"PasswordPolicy"`
+	// PasswordResetLimit replaces DefaultResetRateLimit when set.
+	PasswordResetLimit *ResetRateLimit `json:"PasswordResetLimit"`
+	// BootstrapAdmin is created as a super-admin of the default tenant
+	// when the app runs on in-memory stores, where no account could be
+	// promoted by hand.
+	BootstrapAdmin *BootstrapAdmin `json:"BootstrapAdmin"`
+}
+
+func LoadConfig(configPath string) (Config, error) {
+	file, err := os.Open(configPath)
+	if err != nil {
+		return Config{}, fmt.Errorf("could not open user config file: %w", err)
+	}
+	defer file.Close()
+
+	var config Config
+	if err := json.NewDecoder(file).Decode(&config); err != nil {
+		return Config{}, fmt.Errorf("could not decode user config file: %w", err)
+	}
+	if p := config.PasswordPolicy; p != nil && p.BreachedListPath != "" && !filepath.IsAbs(p.BreachedListPath) {
+		p.BreachedListPath = filepath.Join(filepath.Dir(configPath), p.BreachedListPath)
+	}
+	return config, nil
+}
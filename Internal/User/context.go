@@ -0,0 +1,72 @@
+package User
+
+import (
+	"context"
+	"strconv"
+)
+
+type contextKey int
+
+const (
+	uidKey contextKey = iota
+	roleKey
+	sessionKey
+	apiKeyKey
+)
+
+// ContextWithUID returns a copy of ctx carrying the id of the authenticated
+// user.
+func ContextWithUID(ctx context.Context, uid int64) context.Context {
+	return context.WithValue(ctx, uidKey, uid)
+}
+
+// UIDFromContext returns the id of the authenticated user, if any.
+func UIDFromContext(ctx context.Context) (int64, bool) {
+	uid, ok := ctx.Value(uidKey).(int64)
+	return uid, ok
+}
+
+// ContextWithRole returns a copy of ctx carrying the role of the
+// authenticated user.
+func ContextWithRole(ctx context.Context, role Role) context.Context {
+	return context.WithValue(ctx, roleKey, role)
+}
+
+// RoleFromContext returns the role of the authenticated user, if any.
+func RoleFromContext(ctx context.Context) (Role, bool) {
+	role, ok := ctx.Value(roleKey).(Role)
+	return role, ok
+}
+
+// ContextWithSessionID returns a copy of ctx carrying the session the
+// request's token belongs to.
+func ContextWithSessionID(ctx context.Context, sessionID string) context.Context {
+	return context.WithValue(ctx, sessionKey, sessionID)
+}
+
+// SessionIDFromContext returns the session of the request's token, if any.
+func SessionIDFromContext(ctx context.Context) (string, bool) {
+	sessionID, ok := ctx.Value(sessionKey).(string)
+	return sessionID, ok
+}
+
+// ContextWithAPIKey returns a copy of ctx carrying the API key the request
+// authenticated with.
+func ContextWithAPIKey(ctx context.Context, key APIKey) context.Context {
+	return context.WithValue(ctx, apiKeyKey, key)
+}
+
+// APIKeyFromContext returns the API key of the request, if it used one.
+func APIKeyFromContext(ctx context.Context) (APIKey, bool) {
+	key, ok := ctx.Value(apiKeyKey).(APIKey)
+	return key, ok
+}
+
+// ActorFromContext names the authenticated user for audit columns. Work done
+// without a user, e.g. from a background job, is recorded as "system".
+func ActorFromContext(ctx context.Context) string {
+	if uid, ok := UIDFromContext(ctx); ok {
+		return strconv.FormatInt(uid, 10)
+	}
+	return "system"
+}
@@ -0,0 +1,20 @@
+package User
+
+import (
+	"context"
+	"testing"
+)
+
+func TestActorFromContext(t *testing.T) {
+	ctx := context.Background()
+	if got := ActorFromContext(ctx); got != "system" {
+		t.Errorf("actor without a user = %q", got)
+	}
+	ctx = ContextWithUID(ctx, 42)
+	if got := ActorFromContext(ctx); got != "42" {
+		t.Errorf("actor = %q, want 42", got)
+	}
+	if uid, ok := UIDFromContext(ctx); !ok || uid != 42 {
+		t.Errorf("UIDFromContext = %d, %v", uid, ok)
+	}
+}
@@ -0,0 +1,299 @@
+package User
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const externalLoginPurpose = "external-login"
+
+var (
+	ErrIdentityNotFound = errors.New("external identity not linked")
+	// ErrNoLocalAccount is returned when an external user has no account
+	// and the provider may not create one.
+	ErrNoLocalAccount = errors.New("no local account for this identity")
+	// ErrIdentityConflict is returned when the address of an external user
+	// belongs to a local account the identity may not be linked to.
+	ErrIdentityConflict     = errors.New("email belongs to another account")
+	ErrInvalidExternalLogin = errors.New("invalid or expired external login")
+	errUsernameUnavailable  = errors.New("no free username")
+)
+
+// ExternalIdentity is a user vouched for by an outside identity provider.
+// Provider and Subject together identify them for good; the email may
+// change on the provider's side.
+type ExternalIdentity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Username      string
+	Groups        []string
+}
+
+// IdentityStore remembers which local account an external identity signs in
+// to. Links are kept per tenant, so one person can have an account at
+// several schools.
+type IdentityStore interface {
+	// GetIdentityUID returns ErrIdentityNotFound for unknown identities.
+	GetIdentityUID(ctx context.Context, provider, subject string) (int64, error)
+	// LinkIdentity replaces any earlier link of the identity.
+	LinkIdentity(ctx context.Context, provider, subject string, uid int64, at time.Time) error
+	// UnlinkUser removes every link to the account.
+	UnlinkUser(ctx context.Context, uid int64) error
+}
+
+// Authenticator checks passwords against a source other than the local
+// hashes, such as a directory.
+type Authenticator interface {
+	// Authenticate returns ErrInvalidCredentials for unknown users and
+	// wrong passwords.
+	Authenticate(ctx context.Context, username, password string) (ExternalIdentity, error)
+	// LoginPolicy says how the source's users map onto local accounts.
+	LoginPolicy() ExternalLoginPolicy
+}
+
+// ExternalLoginPolicy is how one provider's users map onto local accounts.
+type ExternalLoginPolicy struct {
+	// AutoProvision creates accounts for identities with no local match.
+	AutoProvision bool
+	// GroupRoles maps provider groups to roles. A user in several mapped
+	// groups gets the most privileged role, and the role is synced on
+	// every login. Users in no mapped group keep their role, and so do
+	// super-admins, a role no provider can grant.
+	GroupRoles map[string]Role
+	// DefaultRole is given to provisioned users in no mapped group.
+	DefaultRole Role
+	// LinkByEmail lets an identity with no link sign in to the account
+	// that has its verified email. Only set it for providers trusted to
+	// verify addresses; otherwise such a login is an ErrIdentityConflict.
+	LinkByEmail bool
+}
+
+func (p ExternalLoginPolicy) Validate() error {
+	for group, role := range p.GroupRoles {
+		if !role.Valid() || role == RoleSuperAdmin {
+			return fmt.Errorf("%w %q for group %q", ErrInvalidRole, role, group)
+		}
+	}
+	if p.DefaultRole != "" && (!p.DefaultRole.Valid() || p.DefaultRole == RoleSuperAdmin) {
+		return fmt.Errorf("%w %q", ErrInvalidRole, p.DefaultRole)
+	}
+	return nil
+}
+
+// rolePrecedence orders the roles from most to least privileged.
+var rolePrecedence = []Role{RoleAdmin, RoleRegistrar, RoleTeacher, RoleReadOnly}
+
+// mappedRole returns the role the groups map to, if any.
+func (p ExternalLoginPolicy) mappedRole(groups []string) (Role, bool) {
+	granted := make(map[Role]bool)
+	for _, group := range groups {
+		if role, ok := p.GroupRoles[group]; ok {
+			granted[role] = true
+		}
+	}
+	for _, role := range rolePrecedence {
+		if granted[role] {
+			return role, true
+		}
+	}
+	return "", false
+}
+
+// LoginExternal signs in a user the provider has already authenticated.
+// Local TOTP is not asked for; second factors are the provider's business.
+func (s *Service) LoginExternal(ctx context.Context, id ExternalIdentity, policy ExternalLoginPolicy) (TokenPair, error) {
+	user, err := s.externalUser(ctx, id, policy)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if user.Disabled() {
+		return TokenPair{}, ErrAccountDisabled
+	}
+	return s.finishLogin(ctx, user)
+}
+
+// externalUser returns the account of an external identity. It is found by
+// the linked identity, then by a verified email if the policy allows, and
+// is otherwise created if the policy allows. Its role and email
+// verification follow the provider.
+func (s *Service) externalUser(ctx context.Context, id ExternalIdentity, policy ExternalLoginPolicy) (User, error) {
+	user, err := s.resolveExternal(ctx, id, policy)
+	if err != nil {
+		return User{}, err
+	}
+
+	if role, ok := policy.mappedRole(id.Groups); ok && role != user.Role && user.Role != RoleSuperAdmin {
+		if err := s.store.SetUserRole(ctx, user.UID, role); err != nil {
+			return User{}, err
+		}
+		log.Infof("role of %s synced to %s from %s", user.Username, role, id.Provider)
+		user.Role = role
+	}
+	if id.EmailVerified && !user.EmailVerified() && strings.EqualFold(id.Email, user.Email) {
+		now := time.Now()
+		if err := s.store.SetEmailVerified(ctx, user.UID, now); err != nil {
+			return User{}, err
+		}
+		user.EmailVerifiedAt = &now
+	}
+	return user, nil
+}
+
+func (s *Service) resolveExternal(ctx context.Context, id ExternalIdentity, policy ExternalLoginPolicy) (User, error) {
+	if s.Identities != nil {
+		uid, err := s.Identities.GetIdentityUID(ctx, id.Provider, id.Subject)
+		if err == nil {
+			user, err := s.store.GetUserByID(ctx, uid)
+			// A link to a deleted account is replaced below.
+			if !errors.Is(err, ErrUserNotFound) {
+				return user, err
+			}
+		} else if !errors.Is(err, ErrIdentityNotFound) {
+			return User{}, err
+		}
+	}
+
+	if id.Email != "" {
+		user, err := s.store.GetUserByEmail(ctx, id.Email)
+		switch {
+		case err == nil && id.EmailVerified && policy.LinkByEmail:
+			log.Infof("linking %s identity %s to %s by email", id.Provider, id.Subject, user.Username)
+			return user, s.linkIdentity(ctx, id, user.UID)
+		case err == nil:
+			return User{}, ErrIdentityConflict
+		case !errors.Is(err, ErrUserNotFound):
+			return User{}, err
+		}
+	}
+
+	if !policy.AutoProvision {
+		return User{}, ErrNoLocalAccount
+	}
+	user, err := s.provisionExternal(ctx, id, policy)
+	if err != nil {
+		return User{}, err
+	}
+	log.Infof("provisioned %s for %s identity %s", user.Username, id.Provider, id.Subject)
+	return user, s.linkIdentity(ctx, id, user.UID)
+}
+
+func (s *Service) linkIdentity(ctx context.Context, id ExternalIdentity, uid int64) error {
+	if s.Identities == nil {
+		return nil
+	}
+	return s.Identities.LinkIdentity(ctx, id.Provider, id.Subject, uid, time.Now())
+}
+
+// provisionExternal creates an account that can only be signed in to
+// through the provider until its owner sets a password.
+func (s *Service) provisionExternal(ctx context.Context, id ExternalIdentity, policy ExternalLoginPolicy) (User, error) {
+	if id.Email == "" {
+		return User{}, fmt.Errorf("%w: the provider sent no email", ErrNoLocalAccount)
+	}
+	username, err := s.freeUsername(ctx, id)
+	if err != nil {
+		return User{}, err
+	}
+	secret, err := randomToken(32)
+	if err != nil {
+		return User{}, err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return User{}, err
+	}
+
+	role, ok := policy.mappedRole(id.Groups)
+	if !ok {
+		role = policy.DefaultRole
+	}
+	if role == "" {
+		role = DefaultRole
+	}
+	user := User{
+		Username:  username,
+		Password:  string(hash),
+		Email:     id.Email,
+		Role:      role,
+		CreatedOn: time.Now(),
+	}
+	if err := s.store.CreateUser(ctx, user); err != nil {
+		return User{}, err
+	}
+	return s.store.GetUserByUsername(ctx, username)
+}
+
+// freeUsername prefers the provider's username, then the local part of the
+// email, adding a number when the name is taken.
+func (s *Service) freeUsername(ctx context.Context, id ExternalIdentity) (string, error) {
+	base := id.Username
+	if base == "" {
+		base, _, _ = strings.Cut(id.Email, "@")
+	}
+	if base == "" {
+		base = "user"
+	}
+	if len(base) > 90 {
+		base = base[:90]
+	}
+
+	for i := 1; i <= 100; i++ {
+		candidate := base
+		if i > 1 {
+			candidate = base + strconv.Itoa(i)
+		}
+		_, err := s.store.GetUserByUsername(ctx, candidate)
+		if errors.Is(err, ErrUserNotFound) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return "", errUsernameUnavailable
+}
+
+// SealExternalLogin signs the values an external login has to keep while
+// the browser is away at the provider, such as the OIDC state and PKCE
+// verifier. Like the other purpose tokens it can never pass as an access
+// token.
+func (s *Service) SealExternalLogin(values map[string]string, ttl time.Duration) (string, error) {
+	claims := jwt.MapClaims{
+		"purpose": externalLoginPurpose,
+		"exp":     time.Now().Add(ttl).Unix(),
+	}
+	for k, v := range values {
+		if _, reserved := claims[k]; !reserved {
+			claims[k] = v
+		}
+	}
+	return s.keys.Sign(claims)
+}
+
+func (s *Service) OpenExternalLogin(token string) (map[string]string, error) {
+	parsed, err := s.keys.Parse(token)
+	if err != nil || !parsed.Valid {
+		return nil, ErrInvalidExternalLogin
+	}
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != externalLoginPurpose {
+		return nil, ErrInvalidExternalLogin
+	}
+	values := make(map[string]string, len(claims))
+	for k, v := range claims {
+		if str, ok := v.(string); ok && k != "purpose" {
+			values[k] = str
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,64 @@
+package User
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type identityKey struct {
+	tenant   int64
+	provider string
+	subject  string
+}
+
+// MemoryIdentityStore keeps the links of external identities in process,
+// per tenant like the user_identities table.
+type MemoryIdentityStore struct {
+	mu    sync.RWMutex
+	links map[identityKey]int64
+}
+
+func NewMemoryIdentityStore() *MemoryIdentityStore {
+	return &MemoryIdentityStore{links: map[identityKey]int64{}}
+}
+
+func (m *MemoryIdentityStore) GetIdentityUID(ctx context.Context, provider, subject string) (int64, error) {
+	tid, err := storeTenant(ctx)
+	if err != nil {
+		return 0, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	uid, ok := m.links[identityKey{tid, provider, subject}]
+	if !ok {
+		return 0, ErrIdentityNotFound
+	}
+	return uid, nil
+}
+
+func (m *MemoryIdentityStore) LinkIdentity(ctx context.Context, provider, subject string, uid int64, at time.Time) error {
+	tid, err := storeTenant(ctx)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.links[identityKey{tid, provider, subject}] = uid
+	return nil
+}
+
+func (m *MemoryIdentityStore) UnlinkUser(ctx context.Context, uid int64) error {
+	tid, err := storeTenant(ctx)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, linked := range m.links {
+		if key.tenant == tid && linked == uid {
+			delete(m.links, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package User
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"Students-Final-Assignment/Internal/Tenant"
+)
+
+func TestMemoryIdentityStore(t *testing.T) {
+	m := NewMemoryIdentityStore()
+	school := Tenant.ContextWithTenant(context.Background(), Tenant.DefaultTenantID)
+	other := Tenant.ContextWithTenant(context.Background(), 2)
+
+	if err := m.LinkIdentity(school, "campus", "ann", 7, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if uid, err := m.GetIdentityUID(school, "campus", "ann"); err != nil || uid != 7 {
+		t.Errorf("linked: uid = %d, err = %v", uid, err)
+	}
+	if _, err := m.GetIdentityUID(other, "campus", "ann"); !errors.Is(err, ErrIdentityNotFound) {
+		t.Errorf("other tenant: err = %v", err)
+	}
+	if _, err := m.GetIdentityUID(school, "google", "ann"); !errors.Is(err, ErrIdentityNotFound) {
+		t.Errorf("other provider: err = %v", err)
+	}
+
+	// Linking again moves the identity to the new account.
+	if err := m.LinkIdentity(school, "campus", "ann", 9, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if uid, _ := m.GetIdentityUID(school, "campus", "ann"); uid != 9 {
+		t.Errorf("relinked uid = %d", uid)
+	}
+
+	if _, err := m.GetIdentityUID(context.Background(), "campus", "ann"); !errors.Is(err, Tenant.ErrNoTenant) {
+		t.Errorf("no tenant: err = %v", err)
+	}
+	if err := m.LinkIdentity(context.Background(), "campus", "ann", 9, time.Now()); !errors.Is(err, Tenant.ErrNoTenant) {
+		t.Errorf("no tenant: err = %v", err)
+	}
+}
+
+func TestExternalLoginFollowsTheLinkedIdentity(t *testing.T) {
+	s := newTestService(t)
+	s.Identities = NewMemoryIdentityStore()
+	policy := ExternalLoginPolicy{AutoProvision: true}
+	id := ExternalIdentity{Provider: "campus", Subject: "uid=ann", Email: "ann@example.org", EmailVerified: true, Username: "ann"}
+
+	if _, err := s.LoginExternal(s.ctx, id, policy); err != nil {
+		t.Fatal(err)
+	}
+	ann, err := s.GetUserByUsername(s.ctx, "ann")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The provider renamed her; the link still finds the same account.
+	id.Email, id.Username = "ann.new@example.org", "ann.new"
+	if _, err := s.LoginExternal(s.ctx, id, policy); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetUserByUsername(s.ctx, "ann.new"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("a second account was provisioned: err = %v", err)
+	}
+	if uid, err := s.Identities.GetIdentityUID(s.ctx, "campus", "uid=ann"); err != nil || uid != ann.UID {
+		t.Errorf("link = %d, %v, want %d", uid, err, ann.UID)
+	}
+}
@@ -0,0 +1,312 @@
+package User
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+var (
+	ErrUnknownKey        = errors.New("token signed with an unknown key")
+	ErrAlgorithmMismatch = errors.New("token algorithm does not match its key")
+	ErrWeakSecret        = errors.New("HS256 secret is too weak")
+	ErrMissingSecret     = errors.New("HS256 key has no Secret")
+)
+
+// minSecretLength is the shortest HS256 secret accepted: as long as the
+// SHA-256 output, as RFC 7518 requires.
+const minSecretLength = 32
+
+// knownSecrets have been published, so tokens signed with them can be
+// forged by anyone.
+var knownSecrets = map[string]bool{
+	"missionimpossible": true,
+	"secret":            true,
+	"changeme":          true,
+}
+
+// KeyConfig describes one key. HS256 keys use Secret; RS256, ES256 and
+// EdDSA keys use PEM files, where a key with only a public key can verify
+// tokens but never sign them.
+//
+// No key is shipped, the server refuses to start until one is configured.
+// Generate a random HS256 secret with
+//
+//	openssl rand -base64 48
+//
+// or a private key with one of
+//
+//	openssl genpkey -algorithm RSA -pkeyopt rsa_keygen_bits:3072 -out rs256.pem
+//	openssl genpkey -algorithm EC -pkeyopt ec_paramgen_curve:P-256 -out es256.pem
+//	openssl genpkey -algorithm ed25519 -out eddsa.pem
+type KeyConfig struct {
+	KID            string `json:"KID"`
+	Algorithm      string `json:"Algorithm"`
+	Secret         string `json:"Secret"`
+	PrivateKeyFile string `json:"PrivateKeyFile"`
+	PublicKeyFile  string `json:"PublicKeyFile"`
+}
+
+// KeysConfig lists every key tokens may be signed with. New tokens are
+// signed with SigningKeyID; the others stay valid for verification, which
+// is how keys are rotated.
+type KeysConfig struct {
+	SigningKeyID string      `json:"SigningKeyID"`
+	Keys         []KeyConfig `json:"Keys"`
+}
+
+type key struct {
+	kid       string
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+type KeySet struct {
+	signing *key
+	keys    map[string]*key
+}
+
+// LoadKeySet reads a KeysConfig file. Key file paths are relative to the
+// directory of the config file.
+func LoadKeySet(configPath string) (*KeySet, error) {
+	file, err := os.Open(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not open keys config file: %w", err)
+	}
+	defer file.Close()
+
+	var config KeysConfig
+	if err := json.NewDecoder(file).Decode(&config); err != nil {
+		return nil, fmt.Errorf("could not decode keys config file: %w", err)
+	}
+	return NewKeySet(config, filepath.Dir(configPath))
+}
+
+func NewKeySet(config KeysConfig, baseDir string) (*KeySet, error) {
+	ks := &KeySet{keys: map[string]*key{}}
+	for _, kc := range config.Keys {
+		if kc.KID == "" {
+			return nil, errors.New("every key needs a KID")
+		}
+		if _, dup := ks.keys[kc.KID]; dup {
+			return nil, fmt.Errorf("duplicate key id %q", kc.KID)
+		}
+		k, err := loadKey(kc, baseDir)
+		if err != nil {
+			return nil, fmt.Errorf("could not load key %q: %w", kc.KID, err)
+		}
+		ks.keys[kc.KID] = k
+	}
+
+	signing, ok := ks.keys[config.SigningKeyID]
+	if !ok {
+		return nil, fmt.Errorf("signing key %q is not configured", config.SigningKeyID)
+	}
+	if signing.signKey == nil {
+		return nil, fmt.Errorf("signing key %q has no private key", config.SigningKeyID)
+	}
+	ks.signing = signing
+	return ks, nil
+}
+
+// EphemeralKeySet signs with a random HS256 secret that only this process
+// knows, for deployments without a configured key, such as one on
+// in-memory stores. Tokens stop working when the process exits.
+func EphemeralKeySet() (*KeySet, error) {
+	secret, err := randomToken(48)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeySet(KeysConfig{
+		SigningKeyID: "ephemeral",
+		Keys:         []KeyConfig{{KID: "ephemeral", Algorithm: "HS256", Secret: secret}},
+	}, "")
+}
+
+func readPEM(baseDir, file string) ([]byte, error) {
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(baseDir, file)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s does not contain a PEM block", file)
+	}
+	return block.Bytes, nil
+}
+
+func parsePrivateKey(der []byte) (crypto.Signer, error) {
+	if k, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		signer, ok := k.(crypto.Signer)
+		if !ok {
+			return nil, errors.New("unsupported private key type")
+		}
+		return signer, nil
+	}
+	if k, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return k, nil
+	}
+	return x509.ParseECPrivateKey(der)
+}
+
+func loadKey(kc KeyConfig, baseDir string) (*key, error) {
+	k := &key{kid: kc.KID, method: jwt.GetSigningMethod(kc.Algorithm)}
+
+	switch kc.Algorithm {
+	case "HS256":
+		if kc.Secret == "" {
+			return nil, fmt.Errorf("%w, generate one with `openssl rand -base64 48`", ErrMissingSecret)
+		}
+		if len(kc.Secret) < minSecretLength || knownSecrets[kc.Secret] {
+			return nil, fmt.Errorf("%w: use at least %d random bytes, e.g. from `openssl rand -base64 48`", ErrWeakSecret, minSecretLength)
+		}
+		k.signKey, k.verifyKey = []byte(kc.Secret), []byte(kc.Secret)
+		return k, nil
+	case "RS256", "ES256", "EdDSA":
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", kc.Algorithm)
+	}
+
+	if kc.PrivateKeyFile != "" {
+		der, err := readPEM(baseDir, kc.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := parsePrivateKey(der)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse private key: %w", err)
+		}
+		k.signKey, k.verifyKey = signer, signer.Public()
+	} else if kc.PublicKeyFile != "" {
+		der, err := readPEM(baseDir, kc.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if k.verifyKey, err = x509.ParsePKIXPublicKey(der); err != nil {
+			return nil, fmt.Errorf("could not parse public key: %w", err)
+		}
+	} else {
+		return nil, errors.New("a PrivateKeyFile or PublicKeyFile is required")
+	}
+
+	// The key must fit the algorithm, and jwt-go wants the concrete key
+	// types rather than crypto.Signer.
+	switch pub := k.verifyKey.(type) {
+	case *rsa.PublicKey:
+		if kc.Algorithm != "RS256" {
+			return nil, errors.New("an RSA key can only be used with RS256")
+		}
+		if k.signKey != nil {
+			k.signKey = k.signKey.(*rsa.PrivateKey)
+		}
+	case *ecdsa.PublicKey:
+		if kc.Algorithm != "ES256" || pub.Curve != elliptic.P256() {
+			return nil, errors.New("an EC key can only be used with ES256 on P-256")
+		}
+		if k.signKey != nil {
+			k.signKey = k.signKey.(*ecdsa.PrivateKey)
+		}
+	case ed25519.PublicKey:
+		if kc.Algorithm != "EdDSA" {
+			return nil, errors.New("an Ed25519 key can only be used with EdDSA")
+		}
+		if k.signKey != nil {
+			k.signKey = k.signKey.(ed25519.PrivateKey)
+		}
+	default:
+		return nil, errors.New("unsupported key type")
+	}
+	return k, nil
+}
+
+// Sign signs claims with the current signing key and names it in the kid
+// header.
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.signing.method, claims)
+	token.Header["kid"] = ks.signing.kid
+	return token.SignedString(ks.signing.signKey)
+}
+
+// Parse verifies a token against the key its kid header names. The alg
+// header has to be the key's algorithm, so a public key can never be
+// passed off as an HMAC secret.
+func (ks *KeySet) Parse(tokenString string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		k, ok := ks.keys[kid]
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+		if token.Method.Alg() != k.method.Alg() {
+			return nil, ErrAlgorithmMismatch
+		}
+		return k.verifyKey, nil
+	})
+}
+
+// JWK is a public key in RFC 7517 form.
+type JWK struct {
+	KTY string `json:"kty"`
+	KID string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	CRV string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// JWKS publishes the public half of every asymmetric key. HMAC secrets are
+// never published, so HS256 tokens can only be checked by this service.
+func (ks *KeySet) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	for _, k := range ks.keys {
+		jwk := JWK{KID: k.kid, Use: "sig", Alg: k.method.Alg()}
+		switch pub := k.verifyKey.(type) {
+		case *rsa.PublicKey:
+			jwk.KTY = "RSA"
+			jwk.N = b64(pub.N.Bytes())
+			jwk.E = b64(big.NewInt(int64(pub.E)).Bytes())
+		case *ecdsa.PublicKey:
+			size := (pub.Curve.Params().BitSize + 7) / 8
+			jwk.KTY, jwk.CRV = "EC", "P-256"
+			jwk.X = b64(pub.X.FillBytes(make([]byte, size)))
+			jwk.Y = b64(pub.Y.FillBytes(make([]byte, size)))
+		case ed25519.PublicKey:
+			jwk.KTY, jwk.CRV = "OKP", "Ed25519"
+			jwk.X = b64(pub)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].KID < set.Keys[j].KID })
+	return set
+}
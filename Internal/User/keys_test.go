@@ -0,0 +1,164 @@
+package User
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const testSecret = "a-test-secret-of-at-least-32-bytes"
+
+func TestShippedKeysConfigRefusesToLoad(t *testing.T) {
+	if _, err := LoadKeySet("keys.json"); !errors.Is(err, ErrMissingSecret) {
+		t.Fatalf("the shipped keys.json: err = %v", err)
+	}
+}
+
+func TestEphemeralKeySet(t *testing.T) {
+	a, err := EphemeralKeySet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := EphemeralKeySet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := a.Sign(jwt.MapClaims{"sub": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Parse(token); err != nil {
+		t.Errorf("own token rejected: %v", err)
+	}
+	// Every run gets its own secret.
+	if _, err := b.Parse(token); err == nil {
+		t.Error("token of another ephemeral key accepted")
+	}
+}
+
+func TestNewKeySetRejectsWeakSecrets(t *testing.T) {
+	for _, secret := range []string{"missionimpossible", "short", "changeme"} {
+		_, err := NewKeySet(KeysConfig{SigningKeyID: "k", Keys: []KeyConfig{{KID: "k", Algorithm: "HS256", Secret: secret}}}, "")
+		if !errors.Is(err, ErrWeakSecret) {
+			t.Errorf("secret %q: err = %v", secret, err)
+		}
+	}
+	if _, err := NewKeySet(KeysConfig{SigningKeyID: "k", Keys: []KeyConfig{{KID: "k", Algorithm: "HS256"}}}, ""); !errors.Is(err, ErrMissingSecret) {
+		t.Error("empty secret accepted")
+	}
+}
+
+func writePrivateKey(t *testing.T, dir, name string, key crypto.Signer) {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKeySetSignsAndVerifiesEveryAlgorithm(t *testing.T) {
+	dir := t.TempDir()
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	writePrivateKey(t, dir, "rs.pem", rsaKey)
+	writePrivateKey(t, dir, "es.pem", ecKey)
+	writePrivateKey(t, dir, "ed.pem", edKey)
+	keys := []KeyConfig{
+		{KID: "hs", Algorithm: "HS256", Secret: testSecret},
+		{KID: "rs", Algorithm: "RS256", PrivateKeyFile: "rs.pem"},
+		{KID: "es", Algorithm: "ES256", PrivateKeyFile: "es.pem"},
+		{KID: "ed", Algorithm: "EdDSA", PrivateKeyFile: "ed.pem"},
+	}
+
+	var tokens []string
+	for _, k := range keys {
+		ks, err := NewKeySet(KeysConfig{SigningKeyID: k.KID, Keys: keys}, dir)
+		if err != nil {
+			t.Fatalf("%s: %v", k.KID, err)
+		}
+		token, err := ks.Sign(jwt.MapClaims{"uid": 1})
+		if err != nil {
+			t.Fatalf("%s: %v", k.KID, err)
+		}
+		tokens = append(tokens, token)
+	}
+
+	// After rotating to the last key, tokens signed with the earlier ones
+	// still verify.
+	ks, err := NewKeySet(KeysConfig{SigningKeyID: "ed", Keys: keys}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, token := range tokens {
+		parsed, err := ks.Parse(token)
+		if err != nil {
+			t.Errorf("%s: %v", keys[i].KID, err)
+			continue
+		}
+		if parsed.Header["kid"] != keys[i].KID {
+			t.Errorf("kid = %v, want %s", parsed.Header["kid"], keys[i].KID)
+		}
+	}
+
+	jwks := ks.JWKS()
+	if len(jwks.Keys) != 3 {
+		t.Fatalf("JWKS published %d keys, want the 3 asymmetric ones", len(jwks.Keys))
+	}
+	for _, k := range jwks.Keys {
+		if k.KID == "hs" {
+			t.Error("JWKS published the HMAC secret")
+		}
+	}
+}
+
+// innerError unwraps a jwt-go ValidationError, which predates errors.Unwrap.
+func innerError(err error) error {
+	var ve *jwt.ValidationError
+	if errors.As(err, &ve) {
+		return ve.Inner
+	}
+	return err
+}
+
+func TestKeySetParseRejectsUnknownKidAndAlgorithmSwap(t *testing.T) {
+	dir := t.TempDir()
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	writePrivateKey(t, dir, "rs.pem", rsaKey)
+	ks, err := NewKeySet(KeysConfig{SigningKeyID: "rs", Keys: []KeyConfig{{KID: "rs", Algorithm: "RS256", PrivateKeyFile: "rs.pem"}}}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An attacker signs with HMAC, using the published public key as the
+	// secret.
+	pub, _ := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"uid": 1})
+	forged.Header["kid"] = "rs"
+	forgedString, _ := forged.SignedString(pub)
+	if _, err := ks.Parse(forgedString); !errors.Is(innerError(err), ErrAlgorithmMismatch) {
+		t.Errorf("algorithm swap: err = %v", err)
+	}
+
+	unknown := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"uid": 1})
+	unknown.Header["kid"] = "gone"
+	unknownString, _ := unknown.SignedString([]byte(testSecret))
+	if _, err := ks.Parse(unknownString); !errors.Is(innerError(err), ErrUnknownKey) {
+		t.Errorf("unknown kid: err = %v", err)
+	}
+}
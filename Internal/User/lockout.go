@@ -0,0 +1,185 @@
+package User
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"Students-Final-Assignment/Internal/Tenant"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrInvalidCredentials is returned for an unknown username and for a
+	// wrong password alike, so callers cannot tell which usernames exist.
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrTooManyAttempts    = errors.New("too many failed login attempts")
+)
+
+// LockoutError is returned while an account or address is locked out.
+type LockoutError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockoutError) Error() string {
+	return fmt.Sprintf("%s, retry in %s", ErrTooManyAttempts, e.RetryAfter.Round(time.Second))
+}
+
+func (e *LockoutError) Unwrap() error { return ErrTooManyAttempts }
+
+// LoginAttempts counts the recent failed logins for one account or one
+// client address. Key is "user:<tenant>:<username>" or "ip:<address>".
+type LoginAttempts struct {
+	Key          string     `db:"attempt_key" json:"key"`
+	Failures     int        `db:"failures" json:"failures"`
+	LastFailedAt time.Time  `db:"last_failed_at" json:"last_failed_at"`
+	LockedUntil  *time.Time `db:"locked_until" json:"locked_until"`
+}
+
+func (a LoginAttempts) lockedAt(now time.Time) bool {
+	return a.LockedUntil != nil && a.LockedUntil.After(now)
+}
+
+type LoginAttemptStore interface {
+	// GetAttempts returns the zero LoginAttempts for an unknown key.
+	GetAttempts(ctx context.Context, key string) (LoginAttempts, error)
+	// RecordFailure adds a failure and returns the new count. A key whose
+	// last failure is older than resetBefore starts again from one.
+	RecordFailure(ctx context.Context, key string, now, resetBefore time.Time) (int, error)
+	LockUntil(ctx context.Context, key string, until time.Time) error
+	ClearAttempts(ctx context.Context, key string) error
+	// ListLockouts returns the keys still locked at now.
+	ListLockouts(ctx context.Context, now time.Time) ([]LoginAttempts, error)
+}
+
+// LockoutPolicy sets how failed logins are punished, per account and per
+// client address. After the free attempts every failure locks the key for
+// BaseDelay, doubling each time; reaching the limit locks it for the full
+// Lockout. Failures are forgotten after Window without one.
+type LockoutPolicy struct {
+	AccountFreeAttempts int `json:"AccountFreeAttempts"`
+	AccountLimit        int `json:"AccountLimit"`
+	IPFreeAttempts      int `json:"IPFreeAttempts"`
+	IPLimit             int `json:"IPLimit"`
+	BaseDelaySeconds    int `json:"BaseDelaySeconds"`
+	LockoutSeconds      int `json:"LockoutSeconds"`
+	WindowSeconds       int `json:"WindowSeconds"`
+}
+
+var DefaultLockoutPolicy = LockoutPolicy{
+	AccountFreeAttempts: 3,
+	AccountLimit:        10,
+	IPFreeAttempts:      10,
+	IPLimit:             50,
+	BaseDelaySeconds:    1,
+	LockoutSeconds:      15 * 60,
+	WindowSeconds:       15 * 60,
+}
+
+// delay is how long a key stays locked after its n-th failure.
+func (p LockoutPolicy) delay(n, free, limit int) time.Duration {
+	lockout := time.Duration(p.LockoutSeconds) * time.Second
+	if n >= limit {
+		return lockout
+	}
+	if n <= free {
+		return 0
+	}
+	d := time.Duration(p.BaseDelaySeconds) * time.Second
+	for i := free + 1; i < n && d < lockout; i++ {
+		d *= 2
+	}
+	if d > lockout {
+		return lockout
+	}
+	return d
+}
+
+func accountKey(ctx context.Context, username string) string {
+	tid, _ := Tenant.FromContext(ctx)
+	return tenantAccountPrefix(tid) + strings.ToLower(username)
+}
+
+func tenantAccountPrefix(tid int64) string {
+	return "user:" + strconv.FormatInt(tid, 10) + ":"
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// checkLockout fails with a LockoutError if either key is locked.
+func (s *Service) checkLockout(ctx context.Context, now time.Time, keys ...string) error {
+	for _, key := range keys {
+		attempts, err := s.Attempts.GetAttempts(ctx, key)
+		if err != nil {
+			return fmt.Errorf("could not read login attempts: %w", err)
+		}
+		if attempts.lockedAt(now) {
+			return &LockoutError{RetryAfter: attempts.LockedUntil.Sub(now)}
+		}
+	}
+	return nil
+}
+
+func (s *Service) recordLoginFailure(ctx context.Context, username, ip string) {
+	now := time.Now()
+	resetBefore := now.Add(-time.Duration(s.Lockout.WindowSeconds) * time.Second)
+	limits := []struct {
+		key         string
+		free, limit int
+	}{
+		{accountKey(ctx, username), s.Lockout.AccountFreeAttempts, s.Lockout.AccountLimit},
+		{ipKey(ip), s.Lockout.IPFreeAttempts, s.Lockout.IPLimit},
+	}
+	for _, l := range limits {
+		n, err := s.Attempts.RecordFailure(ctx, l.key, now, resetBefore)
+		if err != nil {
+			log.Errorf("could not record failed login for %s: %s", l.key, err.Error())
+			continue
+		}
+		if d := s.Lockout.delay(n, l.free, l.limit); d > 0 {
+			if err := s.Attempts.LockUntil(ctx, l.key, now.Add(d)); err != nil {
+				log.Errorf("could not lock %s: %s", l.key, err.Error())
+			}
+			if n >= l.limit {
+				log.Warnf("%s locked out after %d failed logins", l.key, n)
+			}
+		}
+	}
+}
+
+// ListLockouts returns the locked accounts of the caller's tenant. A
+// super-admin sees every tenant's accounts and the locked addresses, which
+// are shared by all tenants.
+func (s *Service) ListLockouts(ctx context.Context) ([]LoginAttempts, error) {
+	all, err := s.Attempts.ListLockouts(ctx, time.Now())
+	if err != nil || isSuperAdmin(ctx) {
+		return all, err
+	}
+	lockouts := []LoginAttempts{}
+	for _, a := range all {
+		if s.lockoutVisible(ctx, a.Key) {
+			lockouts = append(lockouts, a)
+		}
+	}
+	return lockouts, nil
+}
+
+// ClearLockout forgets the failures of one account or address. Outside
+// the super-admin role only accounts of the caller's tenant can be cleared.
+func (s *Service) ClearLockout(ctx context.Context, key string) error {
+	if !isSuperAdmin(ctx) && !s.lockoutVisible(ctx, key) {
+		return Tenant.ErrOutsideTenant
+	}
+	return s.Attempts.ClearAttempts(ctx, key)
+}
+
+func (s *Service) lockoutVisible(ctx context.Context, key string) bool {
+	tid, ok := Tenant.FromContext(ctx)
+	return ok && strings.HasPrefix(key, tenantAccountPrefix(tid))
+}
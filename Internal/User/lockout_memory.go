@@ -0,0 +1,73 @@
+package User
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryLoginAttemptStore keeps login attempts in process. It suits a
+// single instance; several instances behind a load balancer need the SQL
+// store so they share one count.
+type MemoryLoginAttemptStore struct {
+	mu       sync.Mutex
+	attempts map[string]LoginAttempts
+}
+
+func NewMemoryLoginAttemptStore() *MemoryLoginAttemptStore {
+	return &MemoryLoginAttemptStore{attempts: map[string]LoginAttempts{}}
+}
+
+func (m *MemoryLoginAttemptStore) GetAttempts(ctx context.Context, key string) (LoginAttempts, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.attempts[key]
+	if !ok {
+		return LoginAttempts{Key: key}, nil
+	}
+	return a, nil
+}
+
+func (m *MemoryLoginAttemptStore) RecordFailure(ctx context.Context, key string, now, resetBefore time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.attempts[key]
+	if !ok || a.LastFailedAt.Before(resetBefore) {
+		a = LoginAttempts{Key: key, LockedUntil: a.LockedUntil}
+	}
+	a.Failures++
+	a.LastFailedAt = now
+	m.attempts[key] = a
+	return a.Failures, nil
+}
+
+func (m *MemoryLoginAttemptStore) LockUntil(ctx context.Context, key string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a := m.attempts[key]
+	a.Key = key
+	a.LockedUntil = &until
+	m.attempts[key] = a
+	return nil
+}
+
+func (m *MemoryLoginAttemptStore) ClearAttempts(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.attempts, key)
+	return nil
+}
+
+func (m *MemoryLoginAttemptStore) ListLockouts(ctx context.Context, now time.Time) ([]LoginAttempts, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	locked := []LoginAttempts{}
+	for _, a := range m.attempts {
+		if a.lockedAt(now) {
+			locked = append(locked, a)
+		}
+	}
+	sort.Slice(locked, func(i, j int) bool { return locked[i].Key < locked[j].Key })
+	return locked, nil
+}
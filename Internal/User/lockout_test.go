@@ -0,0 +1,119 @@
+package User
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"Students-Final-Assignment/Internal/Tenant"
+)
+
+func TestLockoutDelay(t *testing.T) {
+	p := LockoutPolicy{BaseDelaySeconds: 1, LockoutSeconds: 10}
+	for n, want := range map[int]time.Duration{
+		2:  0,
+		3:  time.Second,
+		4:  2 * time.Second,
+		6:  8 * time.Second,
+		7:  10 * time.Second,
+		20: 10 * time.Second,
+	} {
+		if got := p.delay(n, 2, 20); got != want {
+			t.Errorf("delay(%d) = %s, want %s", n, got, want)
+		}
+	}
+	if got := p.delay(5, 2, 5); got != 10*time.Second {
+		t.Errorf("delay at the limit = %s", got)
+	}
+}
+
+func TestLoginLocksOutAccount(t *testing.T) {
+	s := newTestService(t)
+	s.Lockout = LockoutPolicy{AccountFreeAttempts: 2, AccountLimit: 3, IPFreeAttempts: 100, IPLimit: 100, BaseDelaySeconds: 60, LockoutSeconds: 600, WindowSeconds: 600}
+	s.addUser("ann", RoleTeacher)
+
+	for i := 0; i < 2; i++ {
+		if _, err := s.Login(s.ctx, "ann", "wrong", "192.0.2.1"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("failure %d: err = %v", i+1, err)
+		}
+	}
+	if _, err := s.Login(s.ctx, "ann", "wrong", "192.0.2.1"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("third failure: err = %v", err)
+	}
+	// Locked now, even with the right password and from another address.
+	var lockout *LockoutError
+	if _, err := s.Login(s.ctx, "ann", testPassword, "192.0.2.2"); !errors.As(err, &lockout) || lockout.RetryAfter <= 0 {
+		t.Fatalf("locked login: err = %v", err)
+	}
+	if !errors.Is(lockout, ErrTooManyAttempts) {
+		t.Error("LockoutError does not unwrap to ErrTooManyAttempts")
+	}
+
+	if err := s.ClearLockout(s.ctx, accountKey(s.ctx, "ann")); err != nil {
+		t.Fatal(err)
+	}
+	s.login("ann")
+}
+
+func TestLoginLocksOutAddressAcrossUsernames(t *testing.T) {
+	s := newTestService(t)
+	s.Lockout = LockoutPolicy{AccountFreeAttempts: 100, AccountLimit: 100, IPFreeAttempts: 2, IPLimit: 3, BaseDelaySeconds: 60, LockoutSeconds: 600, WindowSeconds: 600}
+	s.addUser("ann", RoleTeacher)
+
+	for _, username := range []string{"x", "y", "z"} {
+		if _, err := s.Login(s.ctx, username, "wrong", "192.0.2.9"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("%s: err = %v", username, err)
+		}
+	}
+	if _, err := s.Login(s.ctx, "ann", testPassword, "192.0.2.9"); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("login from a locked address: err = %v", err)
+	}
+	s.login("ann")
+}
+
+func TestSuccessfulLoginForgetsFailures(t *testing.T) {
+	s := newTestService(t)
+	s.Lockout = LockoutPolicy{AccountFreeAttempts: 2, AccountLimit: 10, IPFreeAttempts: 100, IPLimit: 100, BaseDelaySeconds: 60, LockoutSeconds: 600, WindowSeconds: 600}
+	s.addUser("ann", RoleTeacher)
+
+	for round := 0; round < 3; round++ {
+		for i := 0; i < 2; i++ {
+			if _, err := s.Login(s.ctx, "ann", "wrong", "192.0.2.1"); !errors.Is(err, ErrInvalidCredentials) {
+				t.Fatalf("round %d: err = %v", round, err)
+			}
+		}
+		s.login("ann")
+	}
+}
+
+func TestLockoutsAreScopedToTheTenant(t *testing.T) {
+	s := newTestService(t)
+	s.Lockout = LockoutPolicy{AccountFreeAttempts: 0, AccountLimit: 1, IPFreeAttempts: 0, IPLimit: 1, BaseDelaySeconds: 60, LockoutSeconds: 600, WindowSeconds: 600}
+	other := Tenant.ContextWithTenant(context.Background(), 2)
+	s.Login(s.ctx, "ann", "wrong", "192.0.2.1")
+	s.Login(other, "bob", "wrong", "192.0.2.2")
+
+	admin := ContextWithRole(s.ctx, RoleAdmin)
+	lockouts, err := s.ListLockouts(admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lockouts) != 1 || lockouts[0].Key != accountKey(s.ctx, "ann") {
+		t.Errorf("admin sees %+v", lockouts)
+	}
+	if err := s.ClearLockout(admin, accountKey(other, "bob")); !errors.Is(err, Tenant.ErrOutsideTenant) {
+		t.Errorf("clearing another tenant's lockout: err = %v", err)
+	}
+	if err := s.ClearLockout(admin, ipKey("192.0.2.1")); !errors.Is(err, Tenant.ErrOutsideTenant) {
+		t.Errorf("clearing a shared address: err = %v", err)
+	}
+
+	super := ContextWithRole(s.ctx, RoleSuperAdmin)
+	if lockouts, _ := s.ListLockouts(super); len(lockouts) != 4 {
+		t.Errorf("super-admin sees %d lockouts, want 4", len(lockouts))
+	}
+	if err := s.ClearLockout(super, accountKey(other, "bob")); err != nil {
+		t.Errorf("super-admin clearing: %v", err)
+	}
+}
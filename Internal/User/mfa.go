@@ -0,0 +1,258 @@
+package User
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	MFAChallengeTTL   = 5 * time.Minute
+	RecoveryCodeCount = 10
+
+	// DefaultMFAIssuer names the app in authenticator apps.
+	DefaultMFAIssuer = "Students"
+
+	mfaPurpose = "mfa"
+)
+
+var (
+	ErrTOTPNotEnrolled     = errors.New("two-factor authentication is not set up")
+	ErrTOTPAlreadyEnabled  = errors.New("two-factor authentication is already enabled")
+	ErrInvalidMFACode      = errors.New("invalid two-factor code")
+	ErrInvalidMFAChallenge = errors.New("invalid or expired MFA challenge")
+)
+
+// MFARequiredError is returned by Login when the password was right but the
+// account has TOTP enabled. Token is exchanged for a token pair by
+// CompleteMFALogin.
+type MFARequiredError struct {
+	Token     string
+	ExpiresIn int64
+}
+
+func (e *MFARequiredError) Error() string {
+	return "two-factor code required"
+}
+
+// TOTP is a user's authenticator secret. It only protects logins once
+// ConfirmedAt is set. LastStep is the last time step a code was accepted
+// for, so a code cannot be replayed.
+type TOTP struct {
+	UID         int64      `db:"uid"`
+	Secret      string     `db:"secret"`
+	ConfirmedAt *time.Time `db:"confirmed_at"`
+	LastStep    int64      `db:"last_step"`
+}
+
+type MFAStore interface {
+	// GetTOTP fails with ErrTOTPNotEnrolled if the user has no secret.
+	GetTOTP(ctx context.Context, uid int64) (TOTP, error)
+	// SaveTOTPSecret stores an unconfirmed secret, replacing any earlier
+	// unconfirmed one.
+	SaveTOTPSecret(ctx context.Context, uid int64, secret string) error
+	ConfirmTOTP(ctx context.Context, uid int64, at time.Time) error
+	// UseTOTPStep records step as used, returning false if it or a later
+	// step was used already.
+	UseTOTPStep(ctx context.Context, uid int64, step int64) (bool, error)
+	DeleteTOTP(ctx context.Context, uid int64) error
+	ReplaceRecoveryCodes(ctx context.Context, uid int64, hashes []string) error
+	// UseRecoveryCode marks an unused code used, returning false if there
+	// is none with this hash.
+	UseRecoveryCode(ctx context.Context, uid int64, hash string) (bool, error)
+}
+
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// EnrollTOTP starts TOTP setup. It has no effect on logins until
+// ConfirmTOTP is called with a code from the new secret.
+func (s *Service) EnrollTOTP(ctx context.Context, uid int64) (TOTPEnrollment, error) {
+	user, err := s.store.GetUserByID(ctx, uid)
+	if err != nil {
+		return TOTPEnrollment{}, err
+	}
+	existing, err := s.MFA.GetTOTP(ctx, uid)
+	if err == nil && existing.ConfirmedAt != nil {
+		return TOTPEnrollment{}, ErrTOTPAlreadyEnabled
+	}
+	if err != nil && !errors.Is(err, ErrTOTPNotEnrolled) {
+		return TOTPEnrollment{}, err
+	}
+
+	secret, err := newTOTPSecret()
+	if err != nil {
+		return TOTPEnrollment{}, err
+	}
+	if err := s.MFA.SaveTOTPSecret(ctx, uid, secret); err != nil {
+		return TOTPEnrollment{}, fmt.Errorf("could not store TOTP secret: %w", err)
+	}
+	return TOTPEnrollment{Secret: secret, URI: totpURI(s.MFAIssuer, user.Username, secret)}, nil
+}
+
+// ConfirmTOTP turns TOTP on once the user proves their app has the secret,
+// and returns the recovery codes. They are shown this once only.
+func (s *Service) ConfirmTOTP(ctx context.Context, uid int64, code string) ([]string, error) {
+	totp, err := s.MFA.GetTOTP(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	if totp.ConfirmedAt != nil {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+	ok, err := s.useTOTPCode(ctx, totp, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+	if err := s.MFA.ConfirmTOTP(ctx, uid, time.Now()); err != nil {
+		return nil, fmt.Errorf("could not confirm TOTP: %w", err)
+	}
+	return s.newRecoveryCodes(ctx, uid)
+}
+
+// DisableTOTP turns TOTP off. It takes a current code or a recovery code,
+// so a stolen access token alone cannot remove the second factor.
+func (s *Service) DisableTOTP(ctx context.Context, uid int64, code string) error {
+	if err := s.verifyMFACode(ctx, uid, code); err != nil {
+		return err
+	}
+	return s.MFA.DeleteTOTP(ctx, uid)
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes of the user.
+func (s *Service) RegenerateRecoveryCodes(ctx context.Context, uid int64, code string) ([]string, error) {
+	if err := s.verifyMFACode(ctx, uid, code); err != nil {
+		return nil, err
+	}
+	return s.newRecoveryCodes(ctx, uid)
+}
+
+func (s *Service) newRecoveryCodes(ctx context.Context, uid int64) ([]string, error) {
+	codes := make([]string, RecoveryCodeCount)
+	hashes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		raw, err := newTOTPSecret()
+		if err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(raw[:5] + "-" + raw[5:10])
+		codes[i], hashes[i] = code, hashToken(code)
+	}
+	if err := s.MFA.ReplaceRecoveryCodes(ctx, uid, hashes); err != nil {
+		return nil, fmt.Errorf("could not store recovery codes: %w", err)
+	}
+	return codes, nil
+}
+
+func (s *Service) useTOTPCode(ctx context.Context, totp TOTP, code string) (bool, error) {
+	step, ok := matchTOTP(totp.Secret, code, time.Now())
+	if !ok || step <= totp.LastStep {
+		return false, nil
+	}
+	return s.MFA.UseTOTPStep(ctx, totp.UID, step)
+}
+
+// verifyMFACode accepts a TOTP code or, failing that, a recovery code, which
+// is then used up.
+func (s *Service) verifyMFACode(ctx context.Context, uid int64, code string) error {
+	totp, err := s.MFA.GetTOTP(ctx, uid)
+	if err != nil {
+		return err
+	}
+	if totp.ConfirmedAt == nil {
+		return ErrTOTPNotEnrolled
+	}
+
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), " ", ""))
+	ok, err := s.useTOTPCode(ctx, totp, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if ok, err = s.MFA.UseRecoveryCode(ctx, uid, hashToken(code)); err != nil {
+			return err
+		}
+		if ok {
+			log.Infof("user %d signed in with a recovery code", uid)
+		}
+	}
+	if !ok {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+// mfaEnabled reports whether the user has confirmed TOTP.
+func (s *Service) mfaEnabled(ctx context.Context, uid int64) (bool, error) {
+	totp, err := s.MFA.GetTOTP(ctx, uid)
+	if errors.Is(err, ErrTOTPNotEnrolled) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return totp.ConfirmedAt != nil, nil
+}
+
+func (s *Service) mfaChallenge(user User) error {
+	token, err := s.keys.Sign(jwt.MapClaims{
+		"purpose":   mfaPurpose,
+		"uid":       user.UID,
+		tenantClaim: user.TenantID,
+		"exp":       time.Now().Add(MFAChallengeTTL).Unix(),
+	})
+	if err != nil {
+		return err
+	}
+	return &MFARequiredError{Token: token, ExpiresIn: int64(MFAChallengeTTL.Seconds())}
+}
+
+// CompleteMFALogin finishes a login Login answered with an
+// MFARequiredError. Wrong codes count as failed logins.
+func (s *Service) CompleteMFALogin(ctx context.Context, challenge, code, ip string) (TokenPair, error) {
+	parsed, err := s.keys.Parse(challenge)
+	if err != nil || !parsed.Valid {
+		return TokenPair{}, ErrInvalidMFAChallenge
+	}
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != mfaPurpose {
+		return TokenPair{}, ErrInvalidMFAChallenge
+	}
+	uid, ok := claims["uid"].(float64)
+	if !ok {
+		return TokenPair{}, ErrInvalidMFAChallenge
+	}
+	ctx = userContext(ctx, ClaimTenant(claims))
+	user, err := s.store.GetUserByID(ctx, int64(uid))
+	if err != nil {
+		return TokenPair{}, ErrInvalidMFAChallenge
+	}
+	// The account may have changed since the challenge was issued.
+	if user.Disabled() {
+		return TokenPair{}, ErrAccountDisabled
+	}
+	if s.RequireVerifiedEmail && !user.EmailVerified() {
+		return TokenPair{}, ErrEmailNotVerified
+	}
+
+	if err := s.checkLockout(ctx, time.Now(), accountKey(ctx, user.Username), ipKey(ip)); err != nil {
+		return TokenPair{}, err
+	}
+	if err := s.verifyMFACode(ctx, user.UID, code); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			s.recordLoginFailure(ctx, user.Username, ip)
+		}
+		return TokenPair{}, err
+	}
+	return s.finishLogin(ctx, user)
+}
@@ -0,0 +1,90 @@
+package User
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryMFAStore keeps TOTP secrets and recovery codes in process.
+type MemoryMFAStore struct {
+	mu       sync.Mutex
+	totp     map[int64]TOTP
+	recovery map[int64]map[string]bool
+}
+
+func NewMemoryMFAStore() *MemoryMFAStore {
+	return &MemoryMFAStore{
+		totp:     map[int64]TOTP{},
+		recovery: map[int64]map[string]bool{},
+	}
+}
+
+func (m *MemoryMFAStore) GetTOTP(ctx context.Context, uid int64) (TOTP, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	totp, ok := m.totp[uid]
+	if !ok {
+		return TOTP{}, ErrTOTPNotEnrolled
+	}
+	return totp, nil
+}
+
+func (m *MemoryMFAStore) SaveTOTPSecret(ctx context.Context, uid int64, secret string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.totp[uid] = TOTP{UID: uid, Secret: secret}
+	return nil
+}
+
+func (m *MemoryMFAStore) ConfirmTOTP(ctx context.Context, uid int64, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if totp, ok := m.totp[uid]; ok {
+		totp.ConfirmedAt = &at
+		m.totp[uid] = totp
+	}
+	return nil
+}
+
+func (m *MemoryMFAStore) UseTOTPStep(ctx context.Context, uid int64, step int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	totp, ok := m.totp[uid]
+	if !ok || totp.LastStep >= step {
+		return false, nil
+	}
+	totp.LastStep = step
+	m.totp[uid] = totp
+	return true, nil
+}
+
+func (m *MemoryMFAStore) DeleteTOTP(ctx context.Context, uid int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.totp, uid)
+	delete(m.recovery, uid)
+	return nil
+}
+
+func (m *MemoryMFAStore) ReplaceRecoveryCodes(ctx context.Context, uid int64, hashes []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	codes := make(map[string]bool, len(hashes))
+	for _, hash := range hashes {
+		codes[hash] = false
+	}
+	m.recovery[uid] = codes
+	return nil
+}
+
+func (m *MemoryMFAStore) UseRecoveryCode(ctx context.Context, uid int64, hash string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	used, ok := m.recovery[uid][hash]
+	if !ok || used {
+		return false, nil
+	}
+	m.recovery[uid][hash] = true
+	return true, nil
+}
@@ -0,0 +1,50 @@
+package User
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryPasswordHistoryStore keeps password hashes in process, oldest
+// first per user.
+type MemoryPasswordHistoryStore struct {
+	mu     sync.Mutex
+	hashes map[int64][]string
+}
+
+func NewMemoryPasswordHistoryStore() *MemoryPasswordHistoryStore {
+	return &MemoryPasswordHistoryStore{hashes: map[int64][]string{}}
+}
+
+func (m *MemoryPasswordHistoryStore) AddPasswordHistory(ctx context.Context, uid int64, hash string, at time.Time, keep int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	hashes := append(m.hashes[uid], hash)
+	if keep < 0 {
+		keep = 0
+	}
+	if len(hashes) > keep {
+		hashes = append([]string(nil), hashes[len(hashes)-keep:]...)
+	}
+	m.hashes[uid] = hashes
+	return nil
+}
+
+func (m *MemoryPasswordHistoryStore) ListPasswordHistory(ctx context.Context, uid int64, n int) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	hashes := m.hashes[uid]
+	newest := []string{}
+	for i := len(hashes) - 1; i >= 0 && len(newest) < n; i-- {
+		newest = append(newest, hashes[i])
+	}
+	return newest, nil
+}
+
+func (m *MemoryPasswordHistoryStore) DeletePasswordHistory(ctx context.Context, uid int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.hashes, uid)
+	return nil
+}
@@ -0,0 +1,172 @@
+package User
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// bcryptMaxLength is the longest password bcrypt accepts.
+const bcryptMaxLength = 72
+
+var ErrWeakPassword = errors.New("password does not meet the password policy")
+
+// PasswordViolation is one broken rule. Code is stable for clients to
+// switch on; Message is for people.
+type PasswordViolation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// PasswordPolicyError lists every rule a password broke, so the user can
+// fix them all at once.
+type PasswordPolicyError struct {
+	Violations []PasswordViolation
+}
+
+func (e *PasswordPolicyError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.Message
+	}
+	return fmt.Sprintf("%s: %s", ErrWeakPassword, strings.Join(messages, "; "))
+}
+
+func (e *PasswordPolicyError) Unwrap() error { return ErrWeakPassword }
+
+// PasswordPolicy is checked whenever a user picks a password. HistorySize
+// previous passwords may not be reused. BreachedListPath names a file or
+// directory of compromised password hashes, see LoadBreachedPasswords.
+type PasswordPolicy struct {
+	MinLength        int    `json:"MinLength"`
+	RequireLower     bool   `json:"RequireLower"`
+	RequireUpper     bool   `json:"RequireUpper"`
+	RequireDigit     bool   `json:"RequireDigit"`
+	RequireSymbol    bool   `json:"RequireSymbol"`
+	DisallowUsername bool   `json:"DisallowUsername"`
+	HistorySize      int    `json:"HistorySize"`
+	BreachedListPath string `json:"BreachedListPath"`
+}
+
+var DefaultPasswordPolicy = PasswordPolicy{
+	MinLength:        10,
+	RequireLower:     true,
+	RequireUpper:     true,
+	RequireDigit:     true,
+	DisallowUsername: true,
+	HistorySize:      5,
+}
+
+type PasswordHistoryStore interface {
+	// AddPasswordHistory records a password hash and keeps only the newest
+	// keep entries of the user.
+	AddPasswordHistory(ctx context.Context, uid int64, hash string, at time.Time, keep int) error
+	// ListPasswordHistory returns up to n hashes, newest first.
+	ListPasswordHistory(ctx context.Context, uid int64, n int) ([]string, error)
+	DeletePasswordHistory(ctx context.Context, uid int64) error
+}
+
+// checkRules applies the rules that need nothing but the password.
+func (p PasswordPolicy) checkRules(username, password string) []PasswordViolation {
+	var violations []PasswordViolation
+	add := func(code, format string, args ...interface{}) {
+		violations = append(violations, PasswordViolation{Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if n := len([]rune(password)); n < p.MinLength || n == 0 {
+		add("too_short", "must be at least %d characters long", max(p.MinLength, 1))
+	}
+	if len(password) > bcryptMaxLength {
+		add("too_long", "must be at most %d bytes long", bcryptMaxLength)
+	}
+
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if p.RequireLower && !lower {
+		add("missing_lowercase", "must contain a lowercase letter")
+	}
+	if p.RequireUpper && !upper {
+		add("missing_uppercase", "must contain an uppercase letter")
+	}
+	if p.RequireDigit && !digit {
+		add("missing_digit", "must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		add("missing_symbol", "must contain a symbol")
+	}
+	if p.DisallowUsername && username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		add("contains_username", "must not contain the username")
+	}
+	return violations
+}
+
+// CheckPassword returns a PasswordPolicyError if password may not be set
+// for user. A user with a zero UID is new and has no history.
+func (s *Service) CheckPassword(ctx context.Context, user User, password string) error {
+	violations := s.PasswordPolicy.checkRules(user.Username, password)
+
+	if s.Breached != nil {
+		breached, err := s.Breached.Contains(password)
+		if err != nil {
+			return fmt.Errorf("could not check breached passwords: %w", err)
+		}
+		if breached {
+			violations = append(violations, PasswordViolation{
+				Code:    "breached",
+				Message: "appears in a list of compromised passwords",
+			})
+		}
+	}
+
+	if user.UID != 0 && s.PasswordPolicy.HistorySize > 0 {
+		reused, err := s.reusesPassword(ctx, user, password)
+		if err != nil {
+			return err
+		}
+		if reused {
+			violations = append(violations, PasswordViolation{
+				Code:    "reused",
+				Message: fmt.Sprintf("must differ from the last %d passwords", s.PasswordPolicy.HistorySize),
+			})
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// reusesPassword compares against the history, which setPassword keeps,
+// and the current hash for accounts older than the history.
+func (s *Service) reusesPassword(ctx context.Context, user User, password string) (bool, error) {
+	hashes, err := s.PasswordHistory.ListPasswordHistory(ctx, user.UID, s.PasswordPolicy.HistorySize)
+	if err != nil {
+		return false, fmt.Errorf("could not read password history: %w", err)
+	}
+	if user.Password != "" {
+		hashes = append([]string{user.Password}, hashes...)
+	}
+	for _, hash := range hashes {
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,160 @@
+package User
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// violationCodes returns the codes of a PasswordPolicyError, or nil.
+func violationCodes(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var policyErr *PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("err = %v, want a PasswordPolicyError", err)
+	}
+	if !errors.Is(err, ErrWeakPassword) {
+		t.Errorf("%v does not wrap ErrWeakPassword", err)
+	}
+	codes := make([]string, len(policyErr.Violations))
+	for i, v := range policyErr.Violations {
+		codes[i] = v.Code
+	}
+	return codes
+}
+
+func TestPasswordPolicyRules(t *testing.T) {
+	s := newTestService(t)
+	s.PasswordPolicy.RequireSymbol = true
+
+	for _, tc := range []struct {
+		password string
+		want     []string
+	}{
+		{"Correct-Horse-9", nil},
+		{"", []string{"too_short", "missing_lowercase", "missing_uppercase", "missing_digit", "missing_symbol"}},
+		{"Sh0rt-", []string{"too_short"}},
+		{"correct-horse-9", []string{"missing_uppercase"}},
+		{"CORRECT-HORSE-9", []string{"missing_lowercase"}},
+		{"Correct-Horse-X", []string{"missing_digit"}},
+		{"CorrectHorse99", []string{"missing_symbol"}},
+		{"My-ANN-password-9", []string{"contains_username"}},
+		{"Ä-ö-ü-ß-é-Ñ-9", nil},
+		{"Aa1-" + strings.Repeat("x", bcryptMaxLength), []string{"too_long"}},
+	} {
+		got := violationCodes(t, s.CheckPassword(s.ctx, User{Username: "ann"}, tc.password))
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q: violations = %v, want %v", tc.password, got, tc.want)
+		}
+	}
+}
+
+func TestPasswordPolicyCountsRunes(t *testing.T) {
+	s := newTestService(t)
+	s.PasswordPolicy = PasswordPolicy{MinLength: 5}
+	// Five characters, ten bytes.
+	if err := s.CheckPassword(s.ctx, User{}, "äääää"); err != nil {
+		t.Error(err)
+	}
+	if codes := violationCodes(t, s.CheckPassword(s.ctx, User{}, "ääää")); !reflect.DeepEqual(codes, []string{"too_short"}) {
+		t.Errorf("violations = %v", codes)
+	}
+}
+
+func TestPasswordHistoryPreventsReuse(t *testing.T) {
+	s := newTestService(t)
+	s.PasswordPolicy.HistorySize = 2
+	uid := s.addUser("ann", RoleTeacher)
+
+	change := func(current, password string) error {
+		return s.ChangePassword(s.ctx, uid, current, password, "")
+	}
+	if codes := violationCodes(t, change(testPassword, testPassword)); !reflect.DeepEqual(codes, []string{"reused"}) {
+		t.Errorf("current password: violations = %v", codes)
+	}
+	if err := change(testPassword, "Second-Passw0rd"); err != nil {
+		t.Fatal(err)
+	}
+	if err := change("Second-Passw0rd", "Third-Passw0rd"); err != nil {
+		t.Fatal(err)
+	}
+	if codes := violationCodes(t, change("Third-Passw0rd", "Second-Passw0rd")); !reflect.DeepEqual(codes, []string{"reused"}) {
+		t.Errorf("recent password: violations = %v", codes)
+	}
+	if err := change("Third-Passw0rd", "Fourth-Passw0rd"); err != nil {
+		t.Fatal(err)
+	}
+	// Only the last two passwords are kept.
+	if err := change("Fourth-Passw0rd", "Second-Passw0rd"); err != nil {
+		t.Errorf("password older than the history: %v", err)
+	}
+}
+
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func TestBreachedPasswords(t *testing.T) {
+	const breached = "Tr0ub4dor&3-Horse"
+	hash := sha1Hex(breached)
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "breached.txt")
+	content := "not a hash\n" + strings.ToLower(hash) + ":42\n" + sha1Hex("other") + "\n"
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ranges := filepath.Join(dir, "ranges")
+	if err := os.Mkdir(ranges, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(ranges, hash[:5]+".txt"), []byte(hash[5:]+":42\r\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{file, ranges} {
+		list, err := LoadBreachedPasswords(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for password, want := range map[string]bool{breached: true, "Correct-Horse-9": false} {
+			if got, err := list.Contains(password); err != nil || got != want {
+				t.Errorf("%s: Contains(%q) = %v, %v", filepath.Base(path), password, got, err)
+			}
+		}
+
+		s := newTestService(t)
+		s.Breached = list
+		if codes := violationCodes(t, s.CheckPassword(s.ctx, User{}, breached)); !reflect.DeepEqual(codes, []string{"breached"}) {
+			t.Errorf("%s: violations = %v", filepath.Base(path), codes)
+		}
+	}
+
+	if _, err := LoadBreachedPasswords(filepath.Join(dir, "missing.txt")); err == nil {
+		t.Error("missing list loaded")
+	}
+}
+
+func TestLoadConfigResolvesBreachedListPath(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(path, []byte(`{"PasswordPolicy": {"MinLength": 12, "BreachedListPath": "breached"}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := config.PasswordPolicy; p == nil || p.MinLength != 12 || p.BreachedListPath != filepath.Join(dir, "breached") {
+		t.Errorf("policy = %+v", config.PasswordPolicy)
+	}
+}
@@ -0,0 +1,201 @@
+package User
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"Students-Final-Assignment/Internal/Mail"
+	"Students-Final-Assignment/Internal/Tenant"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const PasswordResetTTL = time.Hour
+
+var (
+	ErrInvalidResetToken    = errors.New("invalid or expired reset token")
+	ErrTooManyResetRequests = errors.New("too many password reset requests")
+)
+
+// ResetRateLimit bounds the reset mails asked for per client address and
+// per email address within Window, so ForgotPassword can't flood a mailbox
+// or probe addresses in bulk. Requests are counted in the login attempt
+// store, under their own keys.
+type ResetRateLimit struct {
+	PerIP         int `json:"PerIP"`
+	PerEmail      int `json:"PerEmail"`
+	WindowSeconds int `json:"WindowSeconds"`
+}
+
+var DefaultResetRateLimit = ResetRateLimit{
+	PerIP:         20,
+	PerEmail:      3,
+	WindowSeconds: 60 * 60,
+}
+
+// PasswordResetToken is stored by hash only and can be used once.
+type PasswordResetToken struct {
+	Hash      string     `db:"token_hash"`
+	UID       int64      `db:"uid"`
+	TenantID  int64      `db:"tenant_id"`
+	CreatedOn time.Time  `db:"created_on"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	UsedAt    *time.Time `db:"used_at"`
+}
+
+type PasswordResetStore interface {
+	// CreateResetToken stores a new token and drops the user's older unused
+	// ones, so only the latest mail works.
+	CreateResetToken(ctx context.Context, token PasswordResetToken) error
+	// GetResetToken returns an unused, unexpired token without using it,
+	// or fails with ErrInvalidResetToken.
+	GetResetToken(ctx context.Context, hash string) (PasswordResetToken, error)
+	// ConsumeResetToken marks an unused, unexpired token used and returns
+	// it, or fails with ErrInvalidResetToken.
+	ConsumeResetToken(ctx context.Context, hash string) (PasswordResetToken, error)
+	// DeleteUserResetTokens removes every token of the user, used or not.
+	DeleteUserResetTokens(ctx context.Context, uid int64) error
+}
+
+// resetLink is where the mail sends the user; the page there posts the
+// token back to /api/v1/password/reset.
+func (s *Service) resetLink(token string) string {
+	return strings.TrimRight(s.BaseURL, "/") + "/reset-password?token=" + token
+}
+
+func resetIPKey(ip string) string {
+	return "reset-ip:" + ip
+}
+
+func resetEmailKey(ctx context.Context, email string) string {
+	tid, _ := Tenant.FromContext(ctx)
+	return "reset-email:" + strconv.FormatInt(tid, 10) + ":" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// checkResetRate counts a reset request against the client address and the
+// email address, and fails once either is over its limit.
+func (s *Service) checkResetRate(ctx context.Context, email, ip string) error {
+	now := time.Now()
+	resetBefore := now.Add(-time.Duration(s.ResetLimit.WindowSeconds) * time.Second)
+	limits := []struct {
+		key   string
+		limit int
+	}{
+		{resetIPKey(ip), s.ResetLimit.PerIP},
+		{resetEmailKey(ctx, email), s.ResetLimit.PerEmail},
+	}
+	var exceeded bool
+	for _, l := range limits {
+		n, err := s.Attempts.RecordFailure(ctx, l.key, now, resetBefore)
+		if err != nil {
+			return fmt.Errorf("could not count reset requests: %w", err)
+		}
+		if n > l.limit {
+			log.Warnf("%s made %d password reset requests", l.key, n)
+			exceeded = true
+		}
+	}
+	if exceeded {
+		return ErrTooManyResetRequests
+	}
+	return nil
+}
+
+// ForgotPassword mails a reset link to the user with this email. Nothing
+// tells the caller whether the address is known: the lookup and the mail
+// happen in the background, so the call takes as long either way. Too many
+// requests from ip or for email fail with ErrTooManyResetRequests, whether
+// or not the address is known.
+func (s *Service) ForgotPassword(ctx context.Context, email, ip string) error {
+	if err := s.checkResetRate(ctx, email, ip); err != nil {
+		return err
+	}
+	s.background(ctx, "send a password reset mail", func(ctx context.Context) error {
+		return s.forgotPassword(ctx, email)
+	})
+	return nil
+}
+
+func (s *Service) forgotPassword(ctx context.Context, email string) error {
+	user, err := s.store.GetUserByEmail(ctx, email)
+	if errors.Is(err, ErrUserNotFound) {
+		log.Infof("password reset requested for unknown email")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if user.Disabled() {
+		log.Infof("password reset requested for disabled user %d", user.UID)
+		return nil
+	}
+	return s.sendPasswordReset(ctx, user, "Someone asked to reset your password.")
+}
+
+// sendPasswordReset stores a new reset token for user and mails the link.
+// reason opens the mail.
+func (s *Service) sendPasswordReset(ctx context.Context, user User, reason string) error {
+	token, err := randomToken(32)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	err = s.Resets.CreateResetToken(ctx, PasswordResetToken{
+		Hash:      hashToken(token),
+		UID:       user.UID,
+		TenantID:  user.TenantID,
+		CreatedOn: now,
+		ExpiresAt: now.Add(PasswordResetTTL),
+	})
+	if err != nil {
+		return fmt.Errorf("could not store reset token: %w", err)
+	}
+
+	return s.Mailer.Send(Mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hello %s,\n\n%s Follow this link within %s to choose a new one:\n\n%s\n\nIf you did not expect this mail, contact an administrator.\n",
+			user.Username, reason, PasswordResetTTL, s.resetLink(token),
+		),
+	})
+}
+
+// ResetPassword sets a new password with a token from ForgotPassword and
+// signs the user out everywhere. A password the policy rejects leaves the
+// token usable for another try.
+func (s *Service) ResetPassword(ctx context.Context, token, password string) error {
+	reset, err := s.Resets.GetResetToken(ctx, hashToken(token))
+	if err != nil {
+		return err
+	}
+	ctx = userContext(ctx, reset.TenantID)
+	user, err := s.store.GetUserByID(ctx, reset.UID)
+	if errors.Is(err, ErrUserNotFound) || user.Disabled() {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.CheckPassword(ctx, user, password); err != nil {
+		return err
+	}
+	if _, err := s.Resets.ConsumeResetToken(ctx, reset.Hash); err != nil {
+		return err
+	}
+
+	if err := s.setPassword(ctx, user, password); err != nil {
+		return err
+	}
+
+	n, err := s.sessions.RevokeUserSessions(ctx, user.UID)
+	if err != nil {
+		return fmt.Errorf("could not revoke sessions: %w", err)
+	}
+	log.Infof("user %d reset their password, %d sessions revoked", user.UID, n)
+	return nil
+}
@@ -0,0 +1,68 @@
+package User
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryPasswordResetStore keeps reset tokens in process.
+type MemoryPasswordResetStore struct {
+	mu     sync.Mutex
+	tokens map[string]PasswordResetToken
+}
+
+func NewMemoryPasswordResetStore() *MemoryPasswordResetStore {
+	return &MemoryPasswordResetStore{tokens: map[string]PasswordResetToken{}}
+}
+
+func (m *MemoryPasswordResetStore) CreateResetToken(ctx context.Context, token PasswordResetToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for hash, t := range m.tokens {
+		if t.UID == token.UID && t.UsedAt == nil {
+			delete(m.tokens, hash)
+		}
+	}
+	m.tokens[token.Hash] = token
+	return nil
+}
+
+// validLocked returns the token if it is unused and unexpired.
+func (m *MemoryPasswordResetStore) validLocked(hash string, now time.Time) (PasswordResetToken, error) {
+	token, ok := m.tokens[hash]
+	if !ok || token.UsedAt != nil || !token.ExpiresAt.After(now) {
+		return PasswordResetToken{}, ErrInvalidResetToken
+	}
+	return token, nil
+}
+
+func (m *MemoryPasswordResetStore) GetResetToken(ctx context.Context, hash string) (PasswordResetToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.validLocked(hash, time.Now())
+}
+
+func (m *MemoryPasswordResetStore) ConsumeResetToken(ctx context.Context, hash string) (PasswordResetToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	token, err := m.validLocked(hash, now)
+	if err != nil {
+		return PasswordResetToken{}, err
+	}
+	token.UsedAt = &now
+	m.tokens[hash] = token
+	return token, nil
+}
+
+func (m *MemoryPasswordResetStore) DeleteUserResetTokens(ctx context.Context, uid int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for hash, t := range m.tokens {
+		if t.UID == uid {
+			delete(m.tokens, hash)
+		}
+	}
+	return nil
+}
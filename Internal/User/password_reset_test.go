@@ -0,0 +1,104 @@
+package User
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// resetTokenFrom returns the token in the link of a reset mail.
+func resetTokenFrom(t *testing.T, body string) string {
+	t.Helper()
+	_, rest, ok := strings.Cut(body, "token=")
+	if !ok {
+		t.Fatalf("no reset link in %q", body)
+	}
+	return strings.Fields(rest)[0]
+}
+
+func TestForgotPasswordMailsOnlyKnownAddresses(t *testing.T) {
+	s := newTestService(t)
+	s.addUser("ann", RoleTeacher)
+
+	for _, email := range []string{"ann@example.org", "nobody@example.org"} {
+		if err := s.ForgotPassword(s.ctx, email, "192.0.2.1"); err != nil {
+			t.Fatalf("%s: %v", email, err)
+		}
+	}
+	s.Wait()
+	messages := s.mailer.Messages()
+	if len(messages) != 1 || messages[0].To != "ann@example.org" {
+		t.Fatalf("messages = %+v", messages)
+	}
+}
+
+func TestForgotPasswordRateLimits(t *testing.T) {
+	s := newTestService(t)
+	s.ResetLimit = ResetRateLimit{PerIP: 3, PerEmail: 2, WindowSeconds: 60}
+
+	for i := 0; i < 2; i++ {
+		if err := s.ForgotPassword(s.ctx, "Ann@example.org", "192.0.2.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The address is counted case-insensitively and whether or not it is
+	// known.
+	if err := s.ForgotPassword(s.ctx, "ann@example.org ", "192.0.2.2"); !errors.Is(err, ErrTooManyResetRequests) {
+		t.Errorf("third request for one address: err = %v", err)
+	}
+	if err := s.ForgotPassword(s.ctx, "bob@example.org", "192.0.2.1"); err != nil {
+		t.Errorf("third request from one client: err = %v", err)
+	}
+	if err := s.ForgotPassword(s.ctx, "cid@example.org", "192.0.2.1"); !errors.Is(err, ErrTooManyResetRequests) {
+		t.Errorf("fourth request from one client: err = %v", err)
+	}
+	s.Wait()
+}
+
+func TestResetPasswordIsSingleUseAndRevokesSessions(t *testing.T) {
+	s := newTestService(t)
+	s.addUser("ann", RoleTeacher)
+	session := s.login("ann")
+	if err := s.ForgotPassword(s.ctx, "ann@example.org", "192.0.2.1"); err != nil {
+		t.Fatal(err)
+	}
+	s.Wait()
+	token := resetTokenFrom(t, s.mailer.Messages()[0].Body)
+
+	var policyErr *PasswordPolicyError
+	if err := s.ResetPassword(s.ctx, token, "short"); !errors.As(err, &policyErr) {
+		t.Fatalf("weak password: err = %v", err)
+	}
+	if err := s.ResetPassword(s.ctx, token, "Another-Good-Passw0rd"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ResetPassword(s.ctx, token, "Yet-Another-Passw0rd"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("second use: err = %v", err)
+	}
+	if err := s.validate(session.AccessToken); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("session survived the reset: %v", err)
+	}
+	if _, err := s.Login(s.ctx, "ann", "Another-Good-Passw0rd", "192.0.2.1"); err != nil {
+		t.Errorf("login with the new password: %v", err)
+	}
+}
+
+func TestResetPasswordRejectsExpiredTokens(t *testing.T) {
+	s := newTestService(t)
+	uid := s.addUser("ann", RoleTeacher)
+	past := time.Now().Add(-2 * PasswordResetTTL)
+	err := s.Resets.CreateResetToken(s.ctx, PasswordResetToken{
+		Hash:      hashToken("expired"),
+		UID:       uid,
+		TenantID:  1,
+		CreatedOn: past,
+		ExpiresAt: past.Add(PasswordResetTTL),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ResetPassword(s.ctx, "expired", "Another-Good-Passw0rd"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("expired token: err = %v", err)
+	}
+}
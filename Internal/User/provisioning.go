@@ -0,0 +1,131 @@
+package User
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrUsernameTaken = errors.New("username already exists")
+
+// AccountChanges are the parts of an account a directory such as an HR
+// system manages. Nil fields are left alone.
+type AccountChanges struct {
+	Username *string
+	Email    *string
+	Password *string
+	Active   *bool
+}
+
+// ProvisionUser creates an account on behalf of a directory. The directory
+// vouches for the email, so it counts as verified. Without a password the
+// user signs in through an identity provider or resets it.
+func (s *Service) ProvisionUser(ctx context.Context, username, email, password string, active bool) (User, error) {
+	if _, err := s.store.GetUserByUsername(ctx, username); err == nil {
+		return User{}, ErrUsernameTaken
+	} else if !errors.Is(err, ErrUserNotFound) {
+		return User{}, err
+	}
+
+	random := password == ""
+	if random {
+		token, err := randomToken(32)
+		if err != nil {
+			return User{}, err
+		}
+		password = token
+	} else if err := s.CheckPassword(ctx, User{Username: username}, password); err != nil {
+		return User{}, err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return User{}, err
+	}
+
+	err = s.store.CreateUser(ctx, User{
+		Username:  username,
+		Password:  string(hashedPassword),
+		Email:     email,
+		Role:      DefaultRole,
+		CreatedOn: time.Now(),
+	})
+	if err != nil {
+		return User{}, fmt.Errorf("could not create user: %w", err)
+	}
+	user, err := s.store.GetUserByUsername(ctx, username)
+	if err != nil {
+		return User{}, err
+	}
+
+	now := time.Now()
+	if err := s.store.SetEmailVerified(ctx, user.UID, now); err != nil {
+		return User{}, err
+	}
+	user.EmailVerifiedAt = &now
+	if !active {
+		if err := s.store.SetUserDisabled(ctx, user.UID, &now); err != nil {
+			return User{}, fmt.Errorf("could not disable user: %w", err)
+		}
+		user.DisabledAt = &now
+	}
+	if !random {
+		s.recordPasswordHistory(ctx, user.UID, user.Password)
+	}
+	return user, nil
+}
+
+// UpdateAccount applies a directory's changes to an account. Deactivating
+// it works like DisableUser, and super-admins are off limits the same way.
+func (s *Service) UpdateAccount(ctx context.Context, actor, uid int64, changes AccountChanges) (User, error) {
+	user, err := s.managedUser(ctx, uid)
+	if err != nil {
+		return User{}, err
+	}
+
+	if changes.Username != nil && *changes.Username != user.Username {
+		if _, err := s.store.GetUserByUsername(ctx, *changes.Username); err == nil {
+			return User{}, ErrUsernameTaken
+		} else if !errors.Is(err, ErrUserNotFound) {
+			return User{}, err
+		}
+		if err := s.store.RenameUser(ctx, uid, *changes.Username); err != nil {
+			return User{}, fmt.Errorf("could not rename user: %w", err)
+		}
+		log.Infof("user %d renamed from %s to %s", uid, user.Username, *changes.Username)
+		user.Username = *changes.Username
+	}
+	if changes.Email != nil && *changes.Email != user.Email {
+		now := time.Now()
+		if err := s.store.ChangeEmail(ctx, uid, *changes.Email); err != nil {
+			return User{}, fmt.Errorf("could not change email: %w", err)
+		}
+		if err := s.store.SetEmailVerified(ctx, uid, now); err != nil {
+			return User{}, err
+		}
+		user.Email, user.EmailVerifiedAt = *changes.Email, &now
+	}
+	if changes.Password != nil {
+		if err := s.CheckPassword(ctx, user, *changes.Password); err != nil {
+			return User{}, err
+		}
+		if err := s.setPassword(ctx, user, *changes.Password); err != nil {
+			return User{}, err
+		}
+	}
+	if changes.Active != nil && *changes.Active == user.Disabled() {
+		if *changes.Active {
+			err = s.EnableUser(ctx, uid)
+		} else {
+			err = s.DisableUser(ctx, actor, uid)
+		}
+		if err != nil {
+			return User{}, err
+		}
+	}
+
+	return s.store.GetUserByID(ctx, uid)
+}
@@ -0,0 +1,128 @@
+package User
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+type Role string
+
+const (
+	// RoleSuperAdmin manages the tenants themselves on top of everything
+	// an admin can do. Only another super-admin can grant it.
+	RoleSuperAdmin Role = "superadmin"
+	RoleAdmin      Role = "admin"
+	RoleRegistrar  Role = "registrar"
+	RoleTeacher    Role = "teacher"
+	RoleReadOnly   Role = "readonly"
+
+	// DefaultRole is given to newly registered users.
+	DefaultRole = RoleReadOnly
+)
+
+type Permission string
+
+const (
+	PermReadStudents   Permission = "students:read"
+	PermWriteStudents  Permission = "students:write"
+	PermDeleteStudents Permission = "students:delete"
+	PermImportStudents Permission = "students:import"
+	PermExportStudents Permission = "students:export"
+	PermPurgeStudents  Permission = "students:purge"
+	PermManageUsers    Permission = "users:manage"
+	PermManageTenants  Permission = "tenants:manage"
+)
+
+var (
+	ErrInvalidRole  = errors.New("invalid role")
+	ErrUserNotFound = errors.New("user not found")
+	// ErrSuperAdminOnly is returned when someone other than a super-admin
+	// grants or takes away the super-admin role, or manages the account of
+	// a super-admin.
+	ErrSuperAdminOnly = errors.New("only a super-admin can manage super-admins")
+	// ErrCannotDemoteSelf and ErrLastAdmin keep a tenant from being left
+	// with nobody who can manage its users.
+	ErrCannotDemoteSelf = errors.New("administrators cannot take away their own admin role")
+	ErrLastAdmin        = errors.New("the tenant must keep at least one admin")
+)
+
+// RolePermissions is the fixed set of permissions granted by each role.
+var RolePermissions = map[Role][]Permission{
+	RoleSuperAdmin: {
+		PermReadStudents, PermWriteStudents, PermDeleteStudents, PermImportStudents,
+		PermExportStudents, PermPurgeStudents, PermManageUsers, PermManageTenants,
+	},
+	RoleAdmin: {
+		PermReadStudents, PermWriteStudents, PermDeleteStudents, PermImportStudents,
+		PermExportStudents, PermPurgeStudents, PermManageUsers,
+	},
+	RoleRegistrar: {
+		PermReadStudents, PermWriteStudents, PermDeleteStudents, PermImportStudents, PermExportStudents,
+	},
+	RoleTeacher: {
+		PermReadStudents, PermWriteStudents,
+	},
+	RoleReadOnly: {
+		PermReadStudents,
+	},
+}
+
+func (r Role) Valid() bool {
+	_, ok := RolePermissions[r]
+	return ok
+}
+
+func (r Role) Can(p Permission) bool {
+	for _, granted := range RolePermissions[r] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+// isSuperAdmin reports whether the request was made by a super-admin.
+func isSuperAdmin(ctx context.Context) bool {
+	role, _ := RoleFromContext(ctx)
+	return role == RoleSuperAdmin
+}
+
+// AssignRole changes the role of a user. It takes effect with the user's
+// next token. Only a super-admin can make or unmake another one, and an
+// admin can neither demote themselves nor the tenant's last admin.
+func (s *Service) AssignRole(ctx context.Context, uid int64, role Role) error {
+	if !role.Valid() {
+		return ErrInvalidRole
+	}
+	user, err := s.store.GetUserByID(ctx, uid)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return ErrUserNotFound
+		}
+		return fmt.Errorf("could not fetch user: %w", err)
+	}
+	if (role == RoleSuperAdmin || user.Role == RoleSuperAdmin) && role != user.Role && !isSuperAdmin(ctx) {
+		return ErrSuperAdminOnly
+	}
+	if user.Role == RoleAdmin && role != RoleAdmin {
+		if err := s.checkAdminDemotion(ctx, uid); err != nil {
+			return err
+		}
+	}
+	return s.store.SetUserRole(ctx, uid, role)
+}
+
+func (s *Service) checkAdminDemotion(ctx context.Context, uid int64) error {
+	if actor, ok := UIDFromContext(ctx); ok && actor == uid {
+		return ErrCannotDemoteSelf
+	}
+	admins, err := s.store.ListUsers(ctx, UserListOptions{Role: RoleAdmin, Limit: 1})
+	if err != nil {
+		return fmt.Errorf("could not count admins: %w", err)
+	}
+	if admins.Total <= 1 {
+		return ErrLastAdmin
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package User
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRoleCan(t *testing.T) {
+	for _, c := range []struct {
+		role Role
+		perm Permission
+		want bool
+	}{
+		{RoleReadOnly, PermReadStudents, true},
+		{RoleReadOnly, PermWriteStudents, false},
+		{RoleTeacher, PermWriteStudents, true},
+		{RoleTeacher, PermDeleteStudents, false},
+		{RoleRegistrar, PermExportStudents, true},
+		{RoleRegistrar, PermPurgeStudents, false},
+		{RoleAdmin, PermManageUsers, true},
+		{RoleAdmin, PermManageTenants, false},
+		{RoleSuperAdmin, PermManageTenants, true},
+		{Role("root"), PermReadStudents, false},
+	} {
+		if got := c.role.Can(c.perm); got != c.want {
+			t.Errorf("%s.Can(%s) = %v, want %v", c.role, c.perm, got, c.want)
+		}
+	}
+	if Role("root").Valid() || !DefaultRole.Valid() {
+		t.Error("Valid disagrees with RolePermissions")
+	}
+}
+
+func TestAssignRoleGuardsSuperAdminsAndTheLastAdmin(t *testing.T) {
+	s := newTestService(t)
+	root := s.addUser("root", RoleSuperAdmin)
+	ann := s.addUser("ann", RoleAdmin)
+	bob := s.addUser("bob", RoleAdmin)
+	asAnn := ContextWithRole(ContextWithUID(s.ctx, ann), RoleAdmin)
+	asRoot := ContextWithRole(ContextWithUID(s.ctx, root), RoleSuperAdmin)
+
+	if err := s.AssignRole(asAnn, root, RoleAdmin); !errors.Is(err, ErrSuperAdminOnly) {
+		t.Errorf("admin demoting a super-admin: err = %v", err)
+	}
+	if err := s.AssignRole(asAnn, bob, RoleSuperAdmin); !errors.Is(err, ErrSuperAdminOnly) {
+		t.Errorf("admin promoting to super-admin: err = %v", err)
+	}
+	if err := s.AssignRole(asAnn, ann, RoleTeacher); !errors.Is(err, ErrCannotDemoteSelf) {
+		t.Errorf("admin demoting themselves: err = %v", err)
+	}
+	if err := s.AssignRole(asAnn, bob, RoleTeacher); err != nil {
+		t.Fatal(err)
+	}
+	// Not even a super-admin takes away the last admin.
+	if err := s.AssignRole(asRoot, ann, RoleTeacher); !errors.Is(err, ErrLastAdmin) {
+		t.Errorf("last admin: err = %v", err)
+	}
+	if err := s.AssignRole(asAnn, ann, RoleAdmin); err != nil {
+		t.Errorf("keeping one's own role: %v", err)
+	}
+	if err := s.AssignRole(asRoot, bob, RoleSuperAdmin); err != nil {
+		t.Errorf("super-admin promoting: %v", err)
+	}
+}
@@ -0,0 +1,225 @@
+package User
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reused")
+	ErrSessionRevoked      = errors.New("session revoked")
+)
+
+// Session is one login. Each refresh rotates its refresh token and replaces
+// its access token; only the latest of either is accepted.
+type Session struct {
+	ID        string     `db:"id"`
+	UID       int64      `db:"uid"`
+	TenantID  int64      `db:"tenant_id"`
+	AccessJTI string     `db:"access_jti"`
+	CreatedOn time.Time  `db:"created_on"`
+	RevokedAt *time.Time `db:"revoked_at"`
+}
+
+// RefreshToken is stored by hash only. UsedAt is set once it has been
+// exchanged, so presenting it again is detected as reuse.
+type RefreshToken struct {
+	Hash      string     `db:"token_hash"`
+	SessionID string     `db:"session_id"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	UsedAt    *time.Time `db:"used_at"`
+}
+
+type SessionStore interface {
+	CreateSession(ctx context.Context, session Session, refresh RefreshToken) error
+	GetSession(ctx context.Context, id string) (Session, error)
+	GetRefreshToken(ctx context.Context, hash string) (RefreshToken, error)
+	// RotateRefreshToken marks the old token used, stores the new one and
+	// moves the session to a new access token, all or nothing. It fails with
+	// ErrRefreshTokenReused if the old token was used in the meantime.
+	RotateRefreshToken(ctx context.Context, oldHash string, refresh RefreshToken, accessJTI string) error
+	RevokeSession(ctx context.Context, id string) error
+	RevokeUserSessions(ctx context.Context, uid int64) (int64, error)
+	// RevokeOtherSessions ends every session of uid except keepID.
+	RevokeOtherSessions(ctx context.Context, uid int64, keepID string) (int64, error)
+}
+
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *Service) signAccessToken(user User, sessionID, jti string) (string, error) {
+	return s.keys.Sign(jwt.MapClaims{
+		"uid":       user.UID,
+		tenantClaim: user.TenantID,
+		"role":      user.Role,
+		"sid":       sessionID,
+		"jti":       jti,
+		"exp":       time.Now().Add(AccessTokenTTL).Unix(),
+	})
+}
+
+// ParseToken verifies a token issued by signAccessToken.
+func (s *Service) ParseToken(tokenString string) (*jwt.Token, error) {
+	return s.keys.Parse(tokenString)
+}
+
+// JWKS returns the public keys other services can verify tokens with.
+func (s *Service) JWKS() JWKS {
+	return s.keys.JWKS()
+}
+
+func newRefreshToken(sessionID string) (string, RefreshToken, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return "", RefreshToken{}, err
+	}
+	return token, RefreshToken{
+		Hash:      hashToken(token),
+		SessionID: sessionID,
+		ExpiresAt: time.Now().Add(RefreshTokenTTL),
+	}, nil
+}
+
+// startSession opens a new session for a user who just authenticated.
+func (s *Service) startSession(ctx context.Context, user User) (TokenPair, error) {
+	sessionID, err := randomToken(16)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	jti, err := randomToken(16)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	refreshToken, refresh, err := newRefreshToken(sessionID)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	accessToken, err := s.signAccessToken(user, sessionID, jti)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	session := Session{ID: sessionID, UID: user.UID, TenantID: user.TenantID, AccessJTI: jti, CreatedOn: time.Now()}
+	if err := s.sessions.CreateSession(ctx, session, refresh); err != nil {
+		return TokenPair{}, fmt.Errorf("could not create session: %w", err)
+	}
+	return TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// Refresh exchanges a refresh token for a new token pair. A token that was
+// already exchanged means it leaked, so the whole session is revoked. The
+// user is looked up in the tenant the session was opened in.
+func (s *Service) Refresh(ctx context.Context, refreshToken string) (TokenPair, error) {
+	refresh, err := s.sessions.GetRefreshToken(ctx, hashToken(refreshToken))
+	if err != nil {
+		return TokenPair{}, ErrInvalidRefreshToken
+	}
+	if refresh.UsedAt != nil {
+		s.revokeReusedSession(ctx, refresh.SessionID)
+		return TokenPair{}, ErrRefreshTokenReused
+	}
+	if time.Now().After(refresh.ExpiresAt) {
+		return TokenPair{}, ErrInvalidRefreshToken
+	}
+
+	session, err := s.sessions.GetSession(ctx, refresh.SessionID)
+	if err != nil || session.RevokedAt != nil {
+		return TokenPair{}, ErrInvalidRefreshToken
+	}
+	ctx = userContext(ctx, session.TenantID)
+	user, err := s.store.GetUserByID(ctx, session.UID)
+	if err != nil || user.Disabled() {
+		return TokenPair{}, ErrInvalidRefreshToken
+	}
+
+	jti, err := randomToken(16)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	newToken, newRefresh, err := newRefreshToken(session.ID)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	accessToken, err := s.signAccessToken(user, session.ID, jti)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	if err := s.sessions.RotateRefreshToken(ctx, refresh.Hash, newRefresh, jti); err != nil {
+		if errors.Is(err, ErrRefreshTokenReused) {
+			s.revokeReusedSession(ctx, session.ID)
+			return TokenPair{}, ErrRefreshTokenReused
+		}
+		return TokenPair{}, fmt.Errorf("could not rotate refresh token: %w", err)
+	}
+	return TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: newToken,
+		ExpiresIn:    int64(AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+func (s *Service) revokeReusedSession(ctx context.Context, sessionID string) {
+	log.Warnf("refresh token reuse detected, revoking session %s", sessionID)
+	if err := s.sessions.RevokeSession(ctx, sessionID); err != nil {
+		log.Errorf("could not revoke session %s: %s", sessionID, err.Error())
+	}
+}
+
+// ValidateSession checks that an access token still belongs to a live
+// session and is the session's latest access token.
+func (s *Service) ValidateSession(ctx context.Context, sessionID, jti string) error {
+	session, err := s.sessions.GetSession(ctx, sessionID)
+	if err != nil || session.RevokedAt != nil || session.AccessJTI != jti {
+		return ErrSessionRevoked
+	}
+	return nil
+}
+
+func (s *Service) Logout(ctx context.Context, sessionID string) error {
+	return s.sessions.RevokeSession(ctx, sessionID)
+}
+
+// RevokeAllSessions signs a user out everywhere and returns how many
+// sessions were ended.
+func (s *Service) RevokeAllSessions(ctx context.Context, uid int64) (int64, error) {
+	if _, err := s.store.GetUserByID(ctx, uid); err != nil {
+		return 0, err
+	}
+	return s.sessions.RevokeUserSessions(ctx, uid)
+}
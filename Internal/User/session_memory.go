@@ -0,0 +1,98 @@
+package User
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemorySessionStore keeps sessions and refresh tokens in process. Sessions
+// end when the process does.
+type MemorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]Session
+	refresh  map[string]RefreshToken
+}
+
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{
+		sessions: map[string]Session{},
+		refresh:  map[string]RefreshToken{},
+	}
+}
+
+func (m *MemorySessionStore) CreateSession(ctx context.Context, session Session, refresh RefreshToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[session.ID] = session
+	m.refresh[refresh.Hash] = refresh
+	return nil
+}
+
+func (m *MemorySessionStore) GetSession(ctx context.Context, id string) (Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	session, ok := m.sessions[id]
+	if !ok {
+		return Session{}, ErrSessionRevoked
+	}
+	return session, nil
+}
+
+func (m *MemorySessionStore) GetRefreshToken(ctx context.Context, hash string) (RefreshToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	refresh, ok := m.refresh[hash]
+	if !ok {
+		return RefreshToken{}, ErrInvalidRefreshToken
+	}
+	return refresh, nil
+}
+
+func (m *MemorySessionStore) RotateRefreshToken(ctx context.Context, oldHash string, refresh RefreshToken, accessJTI string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	old, ok := m.refresh[oldHash]
+	if !ok || old.UsedAt != nil {
+		return ErrRefreshTokenReused
+	}
+	now := time.Now()
+	old.UsedAt = &now
+	m.refresh[oldHash] = old
+	m.refresh[refresh.Hash] = refresh
+	if session, ok := m.sessions[refresh.SessionID]; ok {
+		session.AccessJTI = accessJTI
+		m.sessions[refresh.SessionID] = session
+	}
+	return nil
+}
+
+func (m *MemorySessionStore) RevokeSession(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if session, ok := m.sessions[id]; ok && session.RevokedAt == nil {
+		now := time.Now()
+		session.RevokedAt = &now
+		m.sessions[id] = session
+	}
+	return nil
+}
+
+func (m *MemorySessionStore) RevokeUserSessions(ctx context.Context, uid int64) (int64, error) {
+	return m.RevokeOtherSessions(ctx, uid, "")
+}
+
+func (m *MemorySessionStore) RevokeOtherSessions(ctx context.Context, uid int64, keepID string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	var n int64
+	for id, session := range m.sessions {
+		if session.UID == uid && id != keepID && session.RevokedAt == nil {
+			session.RevokedAt = &now
+			m.sessions[id] = session
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,102 @@
+package User
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// validate checks an access token the way JWTAuth does.
+func (s *testService) validate(accessToken string) error {
+	s.t.Helper()
+	token, err := s.ParseToken(accessToken)
+	if err != nil {
+		return err
+	}
+	claims := token.Claims.(jwt.MapClaims)
+	sid, _ := claims["sid"].(string)
+	jti, _ := claims["jti"].(string)
+	return s.ValidateSession(s.ctx, sid, jti)
+}
+
+func TestRefreshRotatesTokens(t *testing.T) {
+	s := newTestService(t)
+	s.addUser("ann", RoleTeacher)
+	first := s.login("ann")
+	if err := s.validate(first.AccessToken); err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := s.Refresh(s.ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("refresh token was not rotated")
+	}
+	if err := s.validate(first.AccessToken); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("replaced access token: err = %v", err)
+	}
+	if err := s.validate(second.AccessToken); err != nil {
+		t.Errorf("new access token: err = %v", err)
+	}
+	if _, err := s.Refresh(s.ctx, "not-a-token"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("unknown refresh token: err = %v", err)
+	}
+}
+
+func TestRefreshTokenReuseRevokesSession(t *testing.T) {
+	s := newTestService(t)
+	s.addUser("ann", RoleTeacher)
+	first := s.login("ann")
+	second, err := s.Refresh(s.ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Refresh(s.ctx, first.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("reused refresh token: err = %v", err)
+	}
+	// The thief and the owner are both signed out.
+	if err := s.validate(second.AccessToken); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("access token after reuse: err = %v", err)
+	}
+	if _, err := s.Refresh(s.ctx, second.RefreshToken); err == nil {
+		t.Error("refresh token of a revoked session accepted")
+	}
+}
+
+func TestLogoutAndRevokeAllSessions(t *testing.T) {
+	s := newTestService(t)
+	uid := s.addUser("ann", RoleTeacher)
+	other := s.addUser("bob", RoleTeacher)
+	a, b, c := s.login("ann"), s.login("ann"), s.login("bob")
+
+	token, _ := s.ParseToken(a.AccessToken)
+	if err := s.Logout(s.ctx, token.Claims.(jwt.MapClaims)["sid"].(string)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.validate(a.AccessToken); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("after logout: err = %v", err)
+	}
+	if _, err := s.Refresh(s.ctx, a.RefreshToken); err == nil {
+		t.Error("refresh after logout accepted")
+	}
+	if err := s.validate(b.AccessToken); err != nil {
+		t.Errorf("other session ended by logout: %v", err)
+	}
+
+	if _, err := s.RevokeAllSessions(s.ctx, uid); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.validate(b.AccessToken); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("after revoke all: err = %v", err)
+	}
+	if err := s.validate(c.AccessToken); err != nil {
+		t.Errorf("user %d signed out by revoking user %d: %v", other, uid, err)
+	}
+	if _, err := s.RevokeAllSessions(s.ctx, 999); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("unknown user: err = %v", err)
+	}
+}
@@ -0,0 +1,31 @@
+package User
+
+import (
+	"context"
+
+	"Students-Final-Assignment/Internal/Tenant"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// tenantClaim names the tenant of the user a token was issued to.
+const tenantClaim = "tid"
+
+// ClaimTenant reads the tenant claim of a token. Tokens from before tenants
+// existed carry none and belong to the default tenant.
+func ClaimTenant(claims jwt.MapClaims) int64 {
+	if tid, ok := claims[tenantClaim].(float64); ok && tid > 0 {
+		return int64(tid)
+	}
+	return Tenant.DefaultTenantID
+}
+
+// userContext scopes ctx to the tenant recorded with a token, session or
+// key. Those are only handed out within their user's tenant, so they decide
+// which school an otherwise anonymous request is about.
+func userContext(ctx context.Context, tid int64) context.Context {
+	if tid == 0 {
+		tid = Tenant.DefaultTenantID
+	}
+	return Tenant.ContextWithTenant(ctx, tid)
+}
@@ -0,0 +1,187 @@
+package User
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHOTPVectors(t *testing.T) {
+	// RFC 4226 appendix D.
+	key := []byte("12345678901234567890")
+	for counter, want := range []string{"755224", "287082", "359152", "969429", "338314"} {
+		if got := hotp(key, int64(counter)); got != want {
+			t.Errorf("hotp(%d) = %s, want %s", counter, got, want)
+		}
+	}
+}
+
+func TestMatchTOTPWindow(t *testing.T) {
+	secret := totpEncoding.EncodeToString([]byte("12345678901234567890"))
+	now := time.Unix(1111111109, 0)
+	key := []byte("12345678901234567890")
+	current := totpStep(now)
+
+	for offset, want := range map[int64]bool{-2: false, -1: true, 0: true, 1: true, 2: false} {
+		step, ok := matchTOTP(secret, hotp(key, current+offset), now)
+		if ok != want || (ok && step != current+offset) {
+			t.Errorf("offset %d: step %d, ok %v", offset, step, ok)
+		}
+	}
+	if _, ok := matchTOTP(secret, "12345", now); ok {
+		t.Error("short code accepted")
+	}
+	if _, ok := matchTOTP("not base32!", "123456", now); ok {
+		t.Error("invalid secret accepted")
+	}
+}
+
+// totpCode is the code of the enrolled secret offset periods from now.
+func totpCode(t *testing.T, secret string, offset int64) string {
+	t.Helper()
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hotp(key, totpStep(time.Now())+offset)
+}
+
+func TestTOTPLoginFlow(t *testing.T) {
+	s := newTestService(t)
+	uid := s.addUser("ann", RoleTeacher)
+
+	enrollment, err := s.EnrollTOTP(s.ctx, uid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(enrollment.URI, "otpauth://totp/") || !strings.Contains(enrollment.URI, enrollment.Secret) {
+		t.Errorf("URI = %q", enrollment.URI)
+	}
+	// Unconfirmed TOTP does not protect logins yet.
+	s.login("ann")
+
+	if _, err := s.ConfirmTOTP(s.ctx, uid, "abcdef"); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("wrong confirmation code: err = %v", err)
+	}
+	confirmation := totpCode(t, enrollment.Secret, -1)
+	codes, err := s.ConfirmTOTP(s.ctx, uid, confirmation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != RecoveryCodeCount {
+		t.Fatalf("%d recovery codes", len(codes))
+	}
+
+	_, err = s.Login(s.ctx, "ann", testPassword, "192.0.2.1")
+	var mfa *MFARequiredError
+	if !errors.As(err, &mfa) {
+		t.Fatalf("login with TOTP: err = %v", err)
+	}
+	if err := s.validate(mfa.Token); err == nil {
+		t.Error("MFA challenge accepted as an access token")
+	}
+
+	// The code used to confirm can't be replayed.
+	if _, err := s.CompleteMFALogin(s.ctx, mfa.Token, confirmation, "192.0.2.1"); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("replayed code: err = %v", err)
+	}
+	tokens, err := s.CompleteMFALogin(s.ctx, mfa.Token, totpCode(t, enrollment.Secret, 0), "192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.validate(tokens.AccessToken); err != nil {
+		t.Errorf("access token after MFA: %v", err)
+	}
+
+	// A recovery code works once, with any case and spacing.
+	recovery := " " + strings.ToUpper(codes[0]) + " "
+	if _, err := s.CompleteMFALogin(s.ctx, mfa.Token, recovery, "192.0.2.1"); err != nil {
+		t.Errorf("recovery code: %v", err)
+	}
+	if _, err := s.CompleteMFALogin(s.ctx, mfa.Token, recovery, "192.0.2.1"); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("reused recovery code: err = %v", err)
+	}
+	if _, err := s.CompleteMFALogin(s.ctx, "forged", codes[1], "192.0.2.1"); !errors.Is(err, ErrInvalidMFAChallenge) {
+		t.Errorf("forged challenge: err = %v", err)
+	}
+}
+
+func TestWrongMFACodesCountAsFailedLogins(t *testing.T) {
+	s := newTestService(t)
+	s.Lockout = LockoutPolicy{AccountFreeAttempts: 1, AccountLimit: 2, IPFreeAttempts: 100, IPLimit: 100, BaseDelaySeconds: 60, LockoutSeconds: 600, WindowSeconds: 600}
+	uid := s.addUser("ann", RoleTeacher)
+	enrollment, _ := s.EnrollTOTP(s.ctx, uid)
+	if _, err := s.ConfirmTOTP(s.ctx, uid, totpCode(t, enrollment.Secret, -1)); err != nil {
+		t.Fatal(err)
+	}
+	_, err := s.Login(s.ctx, "ann", testPassword, "192.0.2.1")
+	var mfa *MFARequiredError
+	if !errors.As(err, &mfa) {
+		t.Fatal(err)
+	}
+
+	wrong := "abcdef"
+	for i := 0; i < 2; i++ {
+		s.CompleteMFALogin(s.ctx, mfa.Token, wrong, "192.0.2.1")
+	}
+	if _, err := s.CompleteMFALogin(s.ctx, mfa.Token, totpCode(t, enrollment.Secret, 0), "192.0.2.1"); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("right code after lockout: err = %v", err)
+	}
+}
+
+func TestCompleteMFALoginRechecksTheAccount(t *testing.T) {
+	s := newTestService(t)
+	uid := s.addUser("ann", RoleTeacher)
+	enrollment, _ := s.EnrollTOTP(s.ctx, uid)
+	if _, err := s.ConfirmTOTP(s.ctx, uid, totpCode(t, enrollment.Secret, -1)); err != nil {
+		t.Fatal(err)
+	}
+	_, err := s.Login(s.ctx, "ann", testPassword, "192.0.2.1")
+	var mfa *MFARequiredError
+	if !errors.As(err, &mfa) {
+		t.Fatal(err)
+	}
+
+	// Disabled between the password and the code.
+	now := time.Now()
+	if err := s.users.SetUserDisabled(s.ctx, uid, &now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CompleteMFALogin(s.ctx, mfa.Token, totpCode(t, enrollment.Secret, 0), "192.0.2.1"); !errors.Is(err, ErrAccountDisabled) {
+		t.Errorf("disabled account: err = %v", err)
+	}
+	if err := s.users.SetUserDisabled(s.ctx, uid, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	s.RequireVerifiedEmail = true
+	if _, err := s.CompleteMFALogin(s.ctx, mfa.Token, totpCode(t, enrollment.Secret, 0), "192.0.2.1"); !errors.Is(err, ErrEmailNotVerified) {
+		t.Errorf("unverified email: err = %v", err)
+	}
+	if err := s.users.SetEmailVerified(s.ctx, uid, now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CompleteMFALogin(s.ctx, mfa.Token, totpCode(t, enrollment.Secret, 0), "192.0.2.1"); err != nil {
+		t.Errorf("verified and enabled again: err = %v", err)
+	}
+}
+
+func TestDisableTOTPNeedsACode(t *testing.T) {
+	s := newTestService(t)
+	uid := s.addUser("ann", RoleTeacher)
+	enrollment, _ := s.EnrollTOTP(s.ctx, uid)
+	if _, err := s.ConfirmTOTP(s.ctx, uid, totpCode(t, enrollment.Secret, -1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.EnrollTOTP(s.ctx, uid); !errors.Is(err, ErrTOTPAlreadyEnabled) {
+		t.Errorf("re-enroll: err = %v", err)
+	}
+	if err := s.DisableTOTP(s.ctx, uid, "abcdef"); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("disable without a code: err = %v", err)
+	}
+	if err := s.DisableTOTP(s.ctx, uid, totpCode(t, enrollment.Secret, 0)); err != nil {
+		t.Fatal(err)
+	}
+	s.login("ann")
+}
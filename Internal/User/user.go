@@ -2,85 +2,229 @@ package User
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
+	"Students-Final-Assignment/Internal/Mail"
+
+	log "github.com/sirupsen/logrus"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type User struct {
 	UID       int64     `db:"uid" json:"uid"`
+	TenantID  int64     `db:"tenant_id" json:"tenant_id"`
 	Username  string    `db:"username" json:"username"`
-	Password  string    `db:"password" json:"password"`
+	Password  string    `db:"password" json:"-"`
 	Email     string    `db:"email" json:"email"`
-	JWTToken  *string   `db:"jwt_token" json:"jwt_token"`
+	JWTToken  *string   `db:"jwt_token" json:"-"`
+	Role      Role      `db:"role" json:"role"`
 	CreatedOn time.Time `db:"created_on" json:"created_on"`
 	UpdatedOn time.Time `db:"updated_on" json:"updated_on"`
+
+	EmailVerifiedAt *time.Time `db:"email_verified_at" json:"email_verified_at"`
+	DisabledAt      *time.Time `db:"disabled_at" json:"disabled_at"`
 }
 
+// UserStore only ever sees the users of the tenant the context is scoped
+// to, and fails with Tenant.ErrNoTenant for a context without one.
 type UserStore interface {
 	GetUserByUsername(ctx context.Context, username string) (User, error)
 	GetUserByID(ctx context.Context, id int64) (User, error)
+	GetUserByEmail(ctx context.Context, email string) (User, error)
 	CreateUser(ctx context.Context, user User) error
 	UpdateUser(ctx context.Context, user User) error
 	DeleteUser(ctx context.Context, id int64) error
+	SetUserRole(ctx context.Context, id int64, role Role) error
+	SetEmailVerified(ctx context.Context, id int64, at time.Time) error
+	// ChangeEmail sets a new address and marks it unverified.
+	ChangeEmail(ctx context.Context, id int64, email string) error
+	RenameUser(ctx context.Context, id int64, username string) error
+	// SetUserDisabled disables the user at the given time, or enables
+	// them for nil.
+	SetUserDisabled(ctx context.Context, id int64, at *time.Time) error
+	ListUsers(ctx context.Context, opts UserListOptions) (UserPage, error)
 	Ping(ctx context.Context) error
 }
 
 type Service struct {
-	store UserStore
+	store    UserStore
+	sessions SessionStore
+	keys     *KeySet
+
+	Resets PasswordResetStore
+	Mailer Mail.Mailer
+	// BaseURL is the public address of the app, used for links in mails.
+	BaseURL string
+	// RequireVerifiedEmail makes Login refuse unverified accounts.
+	RequireVerifiedEmail bool
+	Attempts             LoginAttemptStore
+	Lockout              LockoutPolicy
+	MFA                  MFAStore
+	MFAIssuer            string
+	APIKeys              APIKeyStore
+	PasswordPolicy       PasswordPolicy
+	PasswordHistory      PasswordHistoryStore
+	// Breached is optional; without it passwords are not checked against
+	// a compromised list.
+	Breached *BreachedPasswords
+	// Authenticators are asked, in order, about passwords that do not
+	// match the local hash.
+	Authenticators []Authenticator
+	// Identities links accounts to external providers. Without it they are
+	// matched by verified email, where the provider's policy allows it, on
+	// every login.
+	Identities IdentityStore
+	// ResetLimit replaces DefaultResetRateLimit.
+	ResetLimit ResetRateLimit
+
+	tasks sync.WaitGroup
 }
 
+// Credentials is the body of login and registration. The validate tags are
+// for registration; login only needs username and password.
 type Credentials struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
-	Email    string `json:"email"`
+	Username string `json:"username" validate:"required,max=100"`
+	Password string `json:"password" validate:"required"`
+	Email    string `json:"email" validate:"required,email,max=100"`
 }
 
-func NewService(store UserStore) *Service {
-	return &Service{store: store}
+func NewService(store UserStore, sessions SessionStore, keys *KeySet) *Service {
+	return &Service{
+		store:     store,
+		sessions:  sessions,
+		keys:      keys,
+		Attempts:  NewMemoryLoginAttemptStore(),
+		Lockout:   DefaultLockoutPolicy,
+		MFAIssuer: DefaultMFAIssuer,
+
+		PasswordPolicy: DefaultPasswordPolicy,
+		ResetLimit:     DefaultResetRateLimit,
+	}
 }
 
-func (s *Service) Login(username, password string) (string, error) {
-	user, err := s.store.GetUserByUsername(context.Background(), username)
-	if err != nil {
-		fmt.Println("Error fetching user:", err)
-		return "", fmt.Errorf("user not found")
+// background runs work after the request has been answered, so its
+// duration tells the caller nothing. Errors can only be logged.
+func (s *Service) background(ctx context.Context, what string, work func(context.Context) error) {
+	ctx = context.WithoutCancel(ctx)
+	s.tasks.Add(1)
+	go func() {
+		defer s.tasks.Done()
+		if err := work(ctx); err != nil {
+			log.Errorf("could not %s: %s", what, err.Error())
+		}
+	}()
+}
+
+// Wait blocks until the background work started so far is done.
+func (s *Service) Wait() {
+	s.tasks.Wait()
+}
+
+// dummyPasswordHash is compared against when the username is unknown, so
+// that case takes as long as a wrong password.
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("not a real password"), bcrypt.DefaultCost)
+	return hash
+})
+
+// Login checks a username and password from the client at ip. Unknown
+// usernames and wrong passwords both fail with ErrInvalidCredentials and
+// count against the account and the address; too many failures lock them
+// out with a LockoutError. Accounts with TOTP get an MFARequiredError
+// instead of tokens. Passwords the local hash rejects go through
+// s.Authenticators.
+func (s *Service) Login(ctx context.Context, username, password, ip string) (TokenPair, error) {
+	if err := s.checkLockout(ctx, time.Now(), accountKey(ctx, username), ipKey(ip)); err != nil {
+		return TokenPair{}, err
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
-		fmt.Println("Invalid password:", err)
-		return "", fmt.Errorf("invalid password")
+	user, err := s.authenticate(ctx, username, password)
+	if errors.Is(err, ErrInvalidCredentials) {
+		s.recordLoginFailure(ctx, username, ip)
+	}
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if user.Disabled() {
+		return TokenPair{}, ErrAccountDisabled
+	}
+	if s.RequireVerifiedEmail && !user.EmailVerified() {
+		return TokenPair{}, ErrEmailNotVerified
 	}
 
-	mySigningKey := []byte("missionimpossible")
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"uid": user.UID,
-		"exp": time.Now().Add(time.Hour * 72).Unix(),
-	})
+	mfa, err := s.mfaEnabled(ctx, user.UID)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if mfa {
+		return TokenPair{}, s.mfaChallenge(user)
+	}
+	return s.finishLogin(ctx, user)
+}
 
-	tokenString, err := token.SignedString(mySigningKey)
+// authenticate checks the password against the local hash, then against
+// each of s.Authenticators in turn. A user the local hash does not know is
+// compared against a dummy hash, so that case takes as long as a wrong
+// password.
+func (s *Service) authenticate(ctx context.Context, username, password string) (User, error) {
+	user, err := s.store.GetUserByUsername(ctx, username)
+	hash := []byte(user.Password)
 	if err != nil {
-		fmt.Println("Error generating token:", err)
-		return "", err
+		log.Infof("login for unknown user %q: %s", username, err.Error())
+		hash = dummyPasswordHash()
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil && err == nil {
+		return user, nil
+	}
+
+	for _, a := range s.Authenticators {
+		id, err := a.Authenticate(ctx, username, password)
+		if errors.Is(err, ErrInvalidCredentials) {
+			continue
+		}
+		if err != nil {
+			// An unreachable source must not lock out users of the others.
+			log.Errorf("could not authenticate %q: %s", username, err.Error())
+			continue
+		}
+		return s.externalUser(ctx, id, a.LoginPolicy())
 	}
-	user.JWTToken = &tokenString
-	err = s.store.UpdateUser(context.Background(), user)
+	return User{}, ErrInvalidCredentials
+}
+
+// finishLogin opens a session for a user who passed every login check.
+// Failures are only forgotten here, so a known password does not reset the
+// count while TOTP codes are being guessed.
+func (s *Service) finishLogin(ctx context.Context, user User) (TokenPair, error) {
+	if err := s.Attempts.ClearAttempts(ctx, accountKey(ctx, user.Username)); err != nil {
+		log.Errorf("could not clear failed logins of %s: %s", user.Username, err.Error())
+	}
+	tokens, err := s.startSession(ctx, user)
 	if err != nil {
-		fmt.Println("Error updating user with token:", err)
-		return "", err
+		log.Errorf("could not start session: %s", err.Error())
+		return TokenPair{}, err
+	}
+	user.JWTToken = &tokens.AccessToken
+	if err := s.store.UpdateUser(ctx, user); err != nil {
+		log.Errorf("could not update user with token: %s", err.Error())
+		return TokenPair{}, err
 	}
 
-	return tokenString, nil
+	return tokens, nil
 }
 
-func (s *Service) Register(username, password, email string) error {
-	_, err := s.store.GetUserByUsername(context.Background(), username)
+func (s *Service) Register(ctx context.Context, username, password, email string) error {
+	_, err := s.store.GetUserByUsername(ctx, username)
 	if err == nil {
 		return fmt.Errorf("username already exists")
 	}
 
+	if err := s.CheckPassword(ctx, User{Username: username}, password); err != nil {
+		return err
+	}
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		return err
@@ -90,7 +234,21 @@ func (s *Service) Register(username, password, email string) error {
 		Username:  username,
 		Password:  string(hashedPassword),
 		Email:     email,
+		Role:      DefaultRole,
 		CreatedOn: time.Now(),
 	}
-	return s.store.CreateUser(context.Background(), user)
+	if err := s.store.CreateUser(ctx, user); err != nil {
+		return err
+	}
+
+	// The account exists either way; a lost mail can be resent.
+	created, err := s.store.GetUserByUsername(ctx, username)
+	if err == nil {
+		s.recordPasswordHistory(ctx, created.UID, created.Password)
+		err = s.sendVerification(created)
+	}
+	if err != nil {
+		log.Errorf("could not send verification mail to %s: %s", username, err.Error())
+	}
+	return nil
 }
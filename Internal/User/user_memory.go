@@ -0,0 +1,239 @@
+package User
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"Students-Final-Assignment/Internal/Tenant"
+)
+
+// MemoryUserStore keeps users in process, for demos and tests. Like the
+// users table it numbers users from 1, keeps usernames unique per tenant
+// and compares them case-insensitively.
+type MemoryUserStore struct {
+	mu      sync.RWMutex
+	lastUID int64
+	users   map[int64]User
+}
+
+func NewMemoryUserStore() *MemoryUserStore {
+	return &MemoryUserStore{users: map[int64]User{}}
+}
+
+func storeTenant(ctx context.Context) (int64, error) {
+	tid, ok := Tenant.FromContext(ctx)
+	if !ok {
+		return 0, Tenant.ErrNoTenant
+	}
+	return tid, nil
+}
+
+// copyUser returns u with its own pointer fields, so callers can't change
+// what is stored.
+func copyUser(u User) User {
+	if u.JWTToken != nil {
+		token := *u.JWTToken
+		u.JWTToken = &token
+	}
+	if u.EmailVerifiedAt != nil {
+		at := *u.EmailVerifiedAt
+		u.EmailVerifiedAt = &at
+	}
+	if u.DisabledAt != nil {
+		at := *u.DisabledAt
+		u.DisabledAt = &at
+	}
+	return u
+}
+
+func (m *MemoryUserStore) Ping(ctx context.Context) error {
+	return nil
+}
+
+// findLocked returns the first user of the tenant, by uid, that match
+// accepts.
+func (m *MemoryUserStore) findLocked(tid int64, match func(User) bool) (User, bool) {
+	var found User
+	ok := false
+	for _, u := range m.users {
+		if u.TenantID == tid && match(u) && (!ok || u.UID < found.UID) {
+			found, ok = u, true
+		}
+	}
+	return found, ok
+}
+
+func (m *MemoryUserStore) getBy(ctx context.Context, match func(User) bool) (User, error) {
+	tid, err := storeTenant(ctx)
+	if err != nil {
+		return User{}, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	u, ok := m.findLocked(tid, match)
+	if !ok {
+		return User{}, ErrUserNotFound
+	}
+	return copyUser(u), nil
+}
+
+func (m *MemoryUserStore) GetUserByUsername(ctx context.Context, username string) (User, error) {
+	return m.getBy(ctx, func(u User) bool { return strings.EqualFold(u.Username, username) })
+}
+
+func (m *MemoryUserStore) GetUserByID(ctx context.Context, id int64) (User, error) {
+	return m.getBy(ctx, func(u User) bool { return u.UID == id })
+}
+
+func (m *MemoryUserStore) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	return m.getBy(ctx, func(u User) bool { return strings.EqualFold(u.Email, email) })
+}
+
+// CreateUser fails with ErrUsernameTaken where the table's unique key
+// would reject the insert.
+func (m *MemoryUserStore) CreateUser(ctx context.Context, user User) error {
+	tid, err := storeTenant(ctx)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, taken := m.findLocked(tid, func(u User) bool { return strings.EqualFold(u.Username, user.Username) }); taken {
+		return ErrUsernameTaken
+	}
+	if user.Role == "" {
+		user.Role = DefaultRole
+	}
+	now := time.Now()
+	m.lastUID++
+	m.users[m.lastUID] = User{
+		UID:       m.lastUID,
+		TenantID:  tid,
+		Username:  user.Username,
+		Password:  user.Password,
+		Email:     user.Email,
+		Role:      user.Role,
+		CreatedOn: now,
+		UpdatedOn: now,
+	}
+	return nil
+}
+
+// update applies change to the user of the tenant. Like an UPDATE that
+// matches no row, it does nothing for unknown ids.
+func (m *MemoryUserStore) update(ctx context.Context, id int64, change func(*User) error) error {
+	tid, err := storeTenant(ctx)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[id]
+	if !ok || u.TenantID != tid {
+		return nil
+	}
+	if err := change(&u); err != nil {
+		return err
+	}
+	u.UpdatedOn = time.Now()
+	m.users[id] = copyUser(u)
+	return nil
+}
+
+func (m *MemoryUserStore) UpdateUser(ctx context.Context, user User) error {
+	return m.update(ctx, user.UID, func(u *User) error {
+		u.Password = user.Password
+		u.Email = user.Email
+		u.JWTToken = user.JWTToken
+		return nil
+	})
+}
+
+func (m *MemoryUserStore) SetUserRole(ctx context.Context, id int64, role Role) error {
+	return m.update(ctx, id, func(u *User) error {
+		u.Role = role
+		return nil
+	})
+}
+
+func (m *MemoryUserStore) SetEmailVerified(ctx context.Context, id int64, at time.Time) error {
+	return m.update(ctx, id, func(u *User) error {
+		u.EmailVerifiedAt = &at
+		return nil
+	})
+}
+
+func (m *MemoryUserStore) ChangeEmail(ctx context.Context, id int64, email string) error {
+	return m.update(ctx, id, func(u *User) error {
+		u.Email = email
+		u.EmailVerifiedAt = nil
+		return nil
+	})
+}
+
+// RenameUser fails with ErrUsernameTaken if another user of the tenant has
+// the name.
+func (m *MemoryUserStore) RenameUser(ctx context.Context, id int64, username string) error {
+	return m.update(ctx, id, func(u *User) error {
+		other, taken := m.findLocked(u.TenantID, func(o User) bool { return strings.EqualFold(o.Username, username) })
+		if taken && other.UID != u.UID {
+			return ErrUsernameTaken
+		}
+		u.Username = username
+		return nil
+	})
+}
+
+func (m *MemoryUserStore) SetUserDisabled(ctx context.Context, id int64, at *time.Time) error {
+	return m.update(ctx, id, func(u *User) error {
+		u.DisabledAt = at
+		return nil
+	})
+}
+
+func (m *MemoryUserStore) DeleteUser(ctx context.Context, id int64) error {
+	tid, err := storeTenant(ctx)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if u, ok := m.users[id]; ok && u.TenantID == tid {
+		delete(m.users, id)
+	}
+	return nil
+}
+
+func (m *MemoryUserStore) ListUsers(ctx context.Context, opts UserListOptions) (UserPage, error) {
+	tid, err := storeTenant(ctx)
+	if err != nil {
+		return UserPage{}, err
+	}
+	query := strings.ToLower(opts.Query)
+	m.mu.RLock()
+	var users []User
+	for _, u := range m.users {
+		if u.TenantID != tid || (opts.Role != "" && u.Role != opts.Role) {
+			continue
+		}
+		if query != "" && !strings.Contains(strings.ToLower(u.Username), query) && !strings.Contains(strings.ToLower(u.Email), query) {
+			continue
+		}
+		users = append(users, copyUser(u))
+	}
+	m.mu.RUnlock()
+	sort.Slice(users, func(i, j int) bool { return users[i].UID < users[j].UID })
+
+	page := UserPage{Users: []User{}, Total: int64(len(users)), Limit: opts.Limit, Offset: opts.Offset}
+	if opts.Offset < len(users) {
+		end := len(users)
+		if opts.Limit < end-opts.Offset {
+			end = opts.Offset + opts.Limit
+		}
+		page.Users = users[opts.Offset:end]
+	}
+	return page, nil
+}
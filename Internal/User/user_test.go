@@ -0,0 +1,80 @@
+package User
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"Students-Final-Assignment/Internal/Mail"
+	"Students-Final-Assignment/Internal/Tenant"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const testPassword = "Correct-Horse-Battery-9"
+
+func init() {
+	log.SetOutput(io.Discard)
+}
+
+// testService is a Service on in-memory stores, in the default tenant.
+type testService struct {
+	*Service
+	t      *testing.T
+	users  *MemoryUserStore
+	mailer *Mail.MemoryMailer
+	ctx    context.Context
+}
+
+func newTestService(t *testing.T) *testService {
+	t.Helper()
+	keys, err := NewKeySet(KeysConfig{
+		SigningKeyID: "test",
+		Keys:         []KeyConfig{{KID: "test", Algorithm: "HS256", Secret: "a-test-secret-of-at-least-32-bytes"}},
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testService{
+		t:      t,
+		users:  NewMemoryUserStore(),
+		mailer: Mail.NewMemoryMailer(),
+		ctx:    Tenant.ContextWithTenant(context.Background(), Tenant.DefaultTenantID),
+	}
+	s.Service = NewService(s.users, NewMemorySessionStore(), keys)
+	s.Resets = NewMemoryPasswordResetStore()
+	s.MFA = NewMemoryMFAStore()
+	s.APIKeys = NewMemoryAPIKeyStore()
+	s.PasswordHistory = NewMemoryPasswordHistoryStore()
+	s.Mailer = s.mailer
+	return s
+}
+
+// addUser creates a user with testPassword and returns its uid.
+func (s *testService) addUser(username string, role Role) int64 {
+	s.t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	err = s.users.CreateUser(s.ctx, User{Username: username, Password: string(hash), Email: username + "@example.org", Role: role})
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	u, err := s.users.GetUserByUsername(s.ctx, username)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return u.UID
+}
+
+// login logs a user in with testPassword.
+func (s *testService) login(username string) TokenPair {
+	s.t.Helper()
+	tokens, err := s.Login(s.ctx, username, testPassword, "192.0.2.1")
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return tokens
+}
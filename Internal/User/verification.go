@@ -0,0 +1,117 @@
+package User
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"Students-Final-Assignment/Internal/Mail"
+
+	"github.com/dgrijalva/jwt-go"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	VerificationTokenTTL = 48 * time.Hour
+
+	verifyEmailPurpose = "verify-email"
+)
+
+var (
+	ErrInvalidVerificationToken = errors.New("invalid or expired verification token")
+	ErrEmailNotVerified         = errors.New("email address not verified")
+)
+
+// EmailVerified reports whether the user confirmed their current address.
+func (u User) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// verificationToken is a JWT signed with the access token keys. It names the
+// address being verified, so a link stops working once the email changes,
+// and carries a purpose claim so it can never pass as an access token.
+func (s *Service) verificationToken(user User) (string, error) {
+	return s.keys.Sign(jwt.MapClaims{
+		"purpose":   verifyEmailPurpose,
+		"uid":       user.UID,
+		tenantClaim: user.TenantID,
+		"email":     user.Email,
+		"exp":       time.Now().Add(VerificationTokenTTL).Unix(),
+	})
+}
+
+func (s *Service) sendVerification(user User) error {
+	token, err := s.verificationToken(user)
+	if err != nil {
+		return err
+	}
+	link := strings.TrimRight(s.BaseURL, "/") + "/api/v1/verify-email?token=" + url.QueryEscape(token)
+	return s.Mailer.Send(Mail.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf(
+			"Hello %s,\n\nPlease confirm your email address by following this link within %s:\n\n%s\n",
+			user.Username, VerificationTokenTTL, link,
+		),
+	})
+}
+
+// VerifyEmail marks the address named in the token as verified.
+func (s *Service) VerifyEmail(ctx context.Context, token string) error {
+	parsed, err := s.keys.Parse(token)
+	if err != nil || !parsed.Valid {
+		return ErrInvalidVerificationToken
+	}
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != verifyEmailPurpose {
+		return ErrInvalidVerificationToken
+	}
+	uid, ok := claims["uid"].(float64)
+	if !ok {
+		return ErrInvalidVerificationToken
+	}
+
+	ctx = userContext(ctx, ClaimTenant(claims))
+	user, err := s.store.GetUserByID(ctx, int64(uid))
+	if errors.Is(err, ErrUserNotFound) {
+		return ErrInvalidVerificationToken
+	}
+	if err != nil {
+		return err
+	}
+	if email, _ := claims["email"].(string); email != user.Email {
+		return ErrInvalidVerificationToken
+	}
+	if user.EmailVerified() {
+		return nil
+	}
+	return s.store.SetEmailVerified(ctx, user.UID, time.Now())
+}
+
+// ResendVerification mails a new link to an unverified account. Like
+// ForgotPassword it says nothing about whether the address is known, and
+// does the work in the background.
+func (s *Service) ResendVerification(ctx context.Context, email string) error {
+	s.background(ctx, "resend a verification mail", func(ctx context.Context) error {
+		return s.resendVerification(ctx, email)
+	})
+	return nil
+}
+
+func (s *Service) resendVerification(ctx context.Context, email string) error {
+	user, err := s.store.GetUserByEmail(ctx, email)
+	if errors.Is(err, ErrUserNotFound) {
+		log.Infof("verification resend requested for unknown email")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if user.EmailVerified() {
+		return nil
+	}
+	return s.sendVerification(user)
+}
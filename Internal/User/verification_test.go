@@ -0,0 +1,89 @@
+package User
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// verificationTokenFrom returns the token in the link of a verification
+// mail.
+func verificationTokenFrom(t *testing.T, body string) string {
+	t.Helper()
+	_, rest, ok := strings.Cut(body, "token=")
+	if !ok {
+		t.Fatalf("no verification link in %q", body)
+	}
+	token, err := url.QueryUnescape(strings.Fields(rest)[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestRegisterVerifyAndLogin(t *testing.T) {
+	s := newTestService(t)
+	s.RequireVerifiedEmail = true
+	if err := s.Register(s.ctx, "ann", testPassword, "ann@example.org"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Login(s.ctx, "ann", testPassword, "192.0.2.1"); !errors.Is(err, ErrEmailNotVerified) {
+		t.Fatalf("unverified login: err = %v", err)
+	}
+
+	messages := s.mailer.Messages()
+	if len(messages) != 1 || messages[0].To != "ann@example.org" {
+		t.Fatalf("messages = %+v", messages)
+	}
+	token := verificationTokenFrom(t, messages[0].Body)
+
+	// The link can't be used as an access token.
+	if err := s.validate(token); err == nil {
+		t.Error("verification token accepted as an access token")
+	}
+	if err := s.VerifyEmail(s.ctx, token); err != nil {
+		t.Fatal(err)
+	}
+	s.login("ann")
+	if err := s.VerifyEmail(s.ctx, "garbage"); !errors.Is(err, ErrInvalidVerificationToken) {
+		t.Errorf("garbage token: err = %v", err)
+	}
+}
+
+func TestVerificationLinkDiesWithTheAddress(t *testing.T) {
+	s := newTestService(t)
+	uid := s.addUser("ann", RoleTeacher)
+	user, _ := s.users.GetUserByID(s.ctx, uid)
+	token, err := s.verificationToken(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.users.ChangeEmail(s.ctx, uid, "new@example.org"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.VerifyEmail(s.ctx, token); !errors.Is(err, ErrInvalidVerificationToken) {
+		t.Errorf("link for the old address: err = %v", err)
+	}
+}
+
+func TestResendVerificationOnlyForUnverifiedAccounts(t *testing.T) {
+	s := newTestService(t)
+	s.addUser("ann", RoleTeacher)
+	verified := s.addUser("bob", RoleTeacher)
+	if err := s.users.SetEmailVerified(s.ctx, verified, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, email := range []string{"ann@example.org", "bob@example.org", "nobody@example.org"} {
+		if err := s.ResendVerification(s.ctx, email); err != nil {
+			t.Fatalf("%s: %v", email, err)
+		}
+	}
+	s.Wait()
+	messages := s.mailer.Messages()
+	if len(messages) != 1 || messages[0].To != "ann@example.org" {
+		t.Errorf("messages = %+v", messages)
+	}
+}